@@ -0,0 +1,258 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrReadOnly is returned when changing the current config other than via
+// the Admin that owns it, see Open.
+var ErrReadOnly = errors.New("config is read-only, changes require its Admin")
+
+// owner is the state of the Admin owning the current config, guarded by
+// cfg.mu.
+var owner *admin
+
+// Admin is the handle allowed to change, reload, and save the current
+// config, once returned by Open. Bootstrap code keeps the Admin, and hands the
+// rest of the code base a Config (see Current), which can only be read.
+type Admin struct {
+	*admin
+	// actor is who changes are made by, see As.
+	actor string
+}
+
+// admin is the state shared by an Admin, and those returned by its As.
+type admin struct {
+	source Source
+	opts   []Option
+	// base is the config last read from the source, and pos the positions
+	// of its keys, and overrides the runtime overrides merged over it,
+	// guarded by cfg.mu.
+	base      map[string]interface{}
+	pos       map[string]Location
+	overrides map[string]interface{}
+}
+
+// Open reads the configuration from `s` (or via Read, with `opts`, when `s`
+// is nil), installs it as the current config, and returns its Admin. From
+// then on TrySetConfig, TrySetCoerce, ApplyPatch, and Load fail with
+// ErrReadOnly (as logged by SetConfig and SetCoerce); changes have to be made
+// via the Admin. Only one Admin may be opened.
+func Open(s Source, opts ...Option) (*Admin, error) {
+	a := &Admin{admin: &admin{source: s, opts: opts}}
+	c, err := a.read()
+	if err != nil {
+		return nil, err
+	}
+	err = update(nil, "open", func() error {
+		if owner != nil {
+			return errors.New("config: an Admin is already open")
+		}
+		owner = a.admin
+		a.base, a.pos = c.m, c.pos
+		cfg.m, cfg.coerce, cfg.pos = c.m, c.coerce, c.pos
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Admin) read() (Config, error) {
+	if a.source == nil {
+		return Read(a.opts...)
+	}
+	return a.source.Read()
+}
+
+// As returns a handle of the Admin making its changes as `actor`, eg. the
+// user of a request, for the audit log (see AddAuditSink).
+func (a *Admin) As(actor string) *Admin {
+	return &Admin{a.admin, actor}
+}
+
+// Config returns the current config.
+func (a *Admin) Config() Config {
+	return Current()
+}
+
+// Set replaces the current config with `m`, see SetConfig. The changes are
+// kept as runtime overrides (see Override), so they outlast reloads.
+func (a *Admin) Set(m map[string]interface{}) error {
+	return update(a, "set", func() error {
+		a.replace(m)
+		return nil
+	})
+}
+
+// SetCoerce sets whether the current config coerces values, see SetCoerce.
+func (a *Admin) SetCoerce(coerce bool) error {
+	return update(a, "coerce", func() error {
+		cfg.coerce = coerce
+		return nil
+	})
+}
+
+// SetLenientBools sets whether the current config coerces strings to bools
+// via ParseBool, see TrySetLenientBools.
+func (a *Admin) SetLenientBools(lenient bool) error {
+	return setLenientBools(a, lenient)
+}
+
+// Patch applies `patch` to the current config, see ApplyPatch. As with Set,
+// the changes are kept as runtime overrides.
+func (a *Admin) Patch(patch []byte, format PatchFormat) error {
+	return update(a, "patch", func() error {
+		c, err := cfg.Patch(patch, format)
+		if err == nil {
+			a.replace(c.m)
+		}
+		return err
+	})
+}
+
+// Reload re-reads the source the Admin was opened with, and installs it with
+// the runtime overrides merged over it, see Override. On failure the current
+// config is kept.
+func (a *Admin) Reload() error {
+	c, err := a.read()
+	if err != nil {
+		return err
+	}
+	return update(a, "reload", func() error {
+		a.base, a.pos = c.m, c.pos
+		cfg.m, cfg.coerce, cfg.pos = a.overridden(), c.coerce, a.positions()
+		return nil
+	})
+}
+
+// Override merges the merge patch `patch` (see MergePatch) into the runtime
+// overrides, and installs the config last read from the source with them
+// merged over it. The overrides are kept across reloads.
+func (a *Admin) Override(patch []byte) error {
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return fmt.Errorf("invalid merge patch: %w", err)
+	}
+	pm, ok := p.(map[string]interface{})
+	if !ok {
+		return errors.New("invalid merge patch: must be an object")
+	}
+	return update(a, "override", func() error {
+		overrides, _ := copyVal(a.overrides).(map[string]interface{})
+		if overrides == nil {
+			overrides = make(map[string]interface{})
+		}
+		mergeOverrides(overrides, pm)
+		a.overrides = overrides
+		cfg.m, cfg.pos = a.overridden(), a.positions()
+		return nil
+	})
+}
+
+// Overrides returns a copy of the runtime overrides, see Override.
+func (a *Admin) Overrides() map[string]interface{} {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	m, _ := copyVal(a.overrides).(map[string]interface{})
+	return m
+}
+
+// overridden returns the base config with the overrides merged over it.
+// Callers must hold cfg.mu.
+func (a *Admin) overridden() map[string]interface{} {
+	if len(a.overrides) == 0 {
+		return a.base
+	}
+	m, _ := copyVal(a.base).(map[string]interface{})
+	if m == nil {
+		m = make(map[string]interface{})
+	}
+	mergePatch(m, a.overrides)
+	return m
+}
+
+// replace installs `m` as the current config, with the overrides replaced by
+// the changes from the base config to `m`. Callers must hold cfg.mu.
+func (a *Admin) replace(m map[string]interface{}) {
+	a.overrides = mergeDiff(a.base, m)
+	cfg.m, cfg.pos = m, a.positions()
+}
+
+// mergeDiff returns the merge patch turning `from` into `to`, see MergePatch.
+// Values of `to` which are null can't be told from removed keys.
+func mergeDiff(from, to map[string]interface{}) map[string]interface{} {
+	p := make(map[string]interface{})
+	for key, v := range to {
+		fv, ok := from[key]
+		fg, fromGroup := fv.(map[string]interface{})
+		g, isGroup := v.(map[string]interface{})
+		switch {
+		case ok && fromGroup && isGroup:
+			if d := mergeDiff(fg, g); len(d) > 0 {
+				p[key] = d
+			}
+		case !ok || !reflect.DeepEqual(fv, v):
+			p[key] = copyVal(v)
+		}
+	}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			p[key] = nil
+		}
+	}
+	return p
+}
+
+// positions returns the positions of the base config's keys, but those of the
+// overrides. Callers must hold cfg.mu.
+func (a *Admin) positions() map[string]Location {
+	if len(a.overrides) == 0 || a.pos == nil {
+		return a.pos
+	}
+	pos := make(map[string]Location, len(a.pos))
+	for path, l := range a.pos {
+		pos[path] = l
+	}
+	return mergePositions(pos, FromMap(a.overrides))
+}
+
+// mergeOverrides merges the merge patch `p` into the merge patch `dst`,
+// keeping nulls, so they still remove keys once applied.
+func mergeOverrides(dst, p map[string]interface{}) {
+	for key, v := range p {
+		pv, isGroup := v.(map[string]interface{})
+		g, hasGroup := dst[key].(map[string]interface{})
+		if isGroup && hasGroup {
+			mergeOverrides(g, pv)
+			continue
+		}
+		dst[key] = copyVal(v)
+	}
+}
+
+// Save writes the current config, see Save.
+func (a *Admin) Save(opts ...Option) error {
+	return Save(opts...)
+}
+
+// Freeze freezes the current config, see Freeze.
+func (a *Admin) Freeze() {
+	Freeze()
+}
+
+// Current returns a snapshot of the current config, which doesn't follow
+// later changes.
+func Current() Config {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return cfg.with(cfg.m)
+}
@@ -0,0 +1,111 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openTest opens an Admin of `s`, restoring the current config, and letting
+// another Admin be opened, once the test is done.
+func openTest(t *testing.T, s Source) *Admin {
+	cfg.mu.Lock()
+	m, pos, coerce := cfg.m, cfg.pos, cfg.coerce
+	cfg.mu.Unlock()
+	t.Cleanup(func() {
+		cfg.mu.Lock()
+		defer cfg.mu.Unlock()
+		cfg.m, cfg.pos, cfg.coerce, owner = m, pos, coerce, nil
+	})
+	a, err := Open(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAdminPositions(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(f, []byte("{\n\t\"port\": 9090\n}"), 0600)
+	a := openTest(t, File(f))
+	if l, ok := Position("port"); !ok || l.Line != 2 {
+		t.Fatalf("opened: port at %v, want line 2", l)
+	}
+
+	// Failed validation restores the positions, along with the config.
+	remove := OnValidate(func(Config) error { return errors.New("rejected") })
+	if err := a.Set(map[string]interface{}{"port": 1.0}); err == nil {
+		t.Fatal("set: want the validation error")
+	}
+	remove()
+	if l, ok := Position("port"); !ok || l.Line != 2 {
+		t.Errorf("rejected set: port at %v, want line 2", l)
+	}
+
+	if err := a.Override([]byte(`{"port": 1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := Position("port"); ok {
+		t.Errorf("overridden: port at %v, want none", l)
+	}
+	os.WriteFile(f, []byte("{\n\t\"host\": \"a\",\n\t\"port\": 9090\n}"), 0600)
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := Position("host"); !ok || l.Line != 2 {
+		t.Errorf("reloaded: host at %v, want line 2", l)
+	}
+	if l, ok := Position("port"); ok {
+		t.Errorf("reloaded: overridden port at %v, want none", l)
+	}
+
+	if err := a.Set(map[string]interface{}{"host": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := Position("host"); ok {
+		t.Errorf("set: host at %v, want none", l)
+	}
+}
+
+func TestAdminChangesOutlastReloads(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(f, []byte(`{"port": 9090, "debug": false}`), 0600)
+	a := openTest(t, File(f))
+	gen := Generation()
+
+	if err := a.Patch([]byte(`{"debug": true}`), MergePatch); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Override([]byte(`{"port": 1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	c := a.Config()
+	if debug, _ := c.Bool("debug"); !debug {
+		t.Error("patched debug undone by Override and Reload")
+	}
+	if port, _ := c.Int("port"); port != 1 {
+		t.Errorf("port = %d, want the override", port)
+	}
+
+	if err := a.Rollback(gen); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Override([]byte(`{"host": "a"}`)); err != nil {
+		t.Fatal(err)
+	}
+	c = a.Config()
+	if debug, _ := c.Bool("debug"); debug {
+		t.Error("rollback undone by Override")
+	}
+	if port, _ := c.Int("port"); port != 9090 {
+		t.Errorf("port = %d, want the rolled back 9090", port)
+	}
+}
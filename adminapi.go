@@ -0,0 +1,124 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// AdminAPI is an http.Handler serving REST endpoints changing the runtime
+// overrides of the current config (see Admin.Override):
+//
+//	GET   /config          the generation, redacted config, and the paths overridden
+//	PATCH /config          merge the `application/merge-patch+json` body into the overrides
+//	POST  /config/reload   reload the config, keeping the overrides
+//	GET   /config/history  the generations within the History
+//
+// Paths are relative to where it's mounted, eg.
+//
+//	mux.Handle("/admin/config", http.StripPrefix("/admin", api))
+//	mux.Handle("/admin/config/", http.StripPrefix("/admin", api))
+//
+// Every change is logged, and audited (see AddAuditSink), with who made it,
+// and the keys it changed.
+type AdminAPI struct {
+	// Admin owns the current config, and must be set.
+	Admin *Admin
+	// Auth wraps every request, eg. checking credentials, and must be set;
+	// requests are refused otherwise. See AllowAll.
+	Auth func(http.Handler) http.Handler
+	// Actor returns who made a request, for the log. When nil, it's the user
+	// of the request's basic auth, else its remote address.
+	Actor func(r *http.Request) string
+}
+
+func (api *AdminAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if api.Auth == nil || api.Admin == nil {
+		http.Error(w, "config admin API requires an Admin and Auth", http.StatusForbidden)
+		return
+	}
+	api.Auth(http.HandlerFunc(api.serve)).ServeHTTP(w, r)
+}
+
+func (api *AdminAPI) serve(w http.ResponseWriter, r *http.Request) {
+	switch r.Method + " " + strings.TrimSuffix(r.URL.Path, "/") {
+	case "GET /config":
+		c := Current()
+		overrides := flatten(api.Admin.Overrides())
+		paths := make([]string, 0, len(overrides))
+		for path := range overrides {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		writeJSON(w, map[string]interface{}{
+			"generation": Generation(),
+			"config":     c.Redact(),
+			"overrides":  paths,
+		})
+	case "PATCH /config":
+		if t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); t != "application/merge-patch+json" {
+			http.Error(w, "expected an application/merge-patch+json request", http.StatusUnsupportedMediaType)
+			return
+		}
+		patch, err := io.ReadAll(io.LimitReader(r.Body, DefaultMaxSize))
+		var p map[string]interface{}
+		if err == nil {
+			err = json.Unmarshal(patch, &p)
+		}
+		if err != nil {
+			http.Error(w, "invalid merge patch: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		api.change(w, r, "patched", func(a *Admin) error { return a.Override(patch) })
+	case "POST /config/reload":
+		if !isJSON(r) {
+			// Forms can't send JSON, so requests can't be forged cross-site.
+			http.Error(w, "expected a JSON request", http.StatusUnsupportedMediaType)
+			return
+		}
+		api.change(w, r, "reloaded", (*Admin).Reload)
+	case "GET /config/history":
+		writeJSON(w, History())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// change makes a change via `fn`, as the request's actor (see Admin.As),
+// logs it, and writes the keys it changed.
+func (api *AdminAPI) change(w http.ResponseWriter, r *http.Request, action string, fn func(a *Admin) error) {
+	actor := api.actor(r)
+	before := Current()
+	if err := fn(api.Admin.As(actor)); err != nil {
+		writeError(w, err)
+		return
+	}
+	changes := Diff(before, Current())
+	if changes == nil {
+		changes = []Change{}
+	}
+	paths := make([]string, len(changes))
+	for i, ch := range changes {
+		paths[i] = ch.Path
+	}
+	log.Printf("config: %s %s the config, changing [%s]", actor, action, strings.Join(paths, ", "))
+	writeJSON(w, map[string]interface{}{"generation": Generation(), "changes": changes})
+}
+
+func (api *AdminAPI) actor(r *http.Request) string {
+	if api.Actor != nil {
+		return api.Actor(r)
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		return user
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,187 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+// AuditEvent records a change to the current config. Actions are `open`,
+// `load`, `set`, `coerce`, `defaults`, `patch`, `override`, `reload`,
+// `refresh`, `renew`, and `rollback`.
+type AuditEvent struct {
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	Generation uint64    `json:"generation"`
+	// Source describes the source of the Admin the change was made via,
+	// empty for changes made otherwise.
+	Source string `json:"source,omitempty"`
+	// Changes are the keys changed, with sensitive values redacted.
+	Changes []Change `json:"changes"`
+}
+
+// AuditSink receives the audit events of every change to the current config.
+type AuditSink interface {
+	Audit(e AuditEvent) error
+}
+
+// AuditFunc is an AuditSink calling itself.
+type AuditFunc func(e AuditEvent) error
+
+func (fn AuditFunc) Audit(e AuditEvent) error {
+	return fn(e)
+}
+
+var (
+	sinksMu sync.Mutex
+	sinks   = map[*AuditSink]bool{}
+)
+
+// AddAuditSink sends the audit event of every change to the current config,
+// eg. via Set, Reload, ApplyPatch, or Rollback, to `s`, until `remove` is
+// called. Events are sent once the change has been made, in order, as
+// watchers are called (see Watch). Failures to send are logged.
+//
+// The actor of changes made via an Admin is set by As, and is otherwise the
+// user and host running the process.
+func AddAuditSink(s AuditSink) (remove func()) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	p := &s
+	sinks[p] = true
+	return func() {
+		sinksMu.Lock()
+		defer sinksMu.Unlock()
+		delete(sinks, p)
+	}
+}
+
+func audit(a *Admin, action string, gen uint64, before, after Config) {
+	sinksMu.Lock()
+	l := make([]AuditSink, 0, len(sinks))
+	for p := range sinks {
+		l = append(l, *p)
+	}
+	sinksMu.Unlock()
+	if len(l) == 0 {
+		return
+	}
+	e := AuditEvent{
+		Time:       time.Now(),
+		Actor:      processActor(),
+		Action:     action,
+		Generation: gen,
+		Changes:    Diff(before, after),
+	}
+	if a != nil {
+		e.Source = "config.Read"
+		if a.source != nil {
+			e.Source = sourceName(a.source)
+		}
+		if a.actor != "" {
+			e.Actor = a.actor
+		}
+	}
+	for _, s := range l {
+		if err := s.Audit(e); err != nil {
+			log.Printf("config: failed to audit %s of generation %d: %s", action, gen, err)
+		}
+	}
+}
+
+var (
+	actorOnce sync.Once
+	actor     string
+)
+
+// processActor returns the `user@host` running the process.
+func processActor() string {
+	actorOnce.Do(func() {
+		name := "unknown"
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+		host, _ := os.Hostname()
+		actor = name + "@" + host
+	})
+	return actor
+}
+
+// AuditWriter writes audit events as lines of JSON.
+type AuditWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewAuditWriter returns a sink writing audit events to `w`.
+func NewAuditWriter(w io.Writer) *AuditWriter {
+	return &AuditWriter{w: w}
+}
+
+// AuditFile returns a sink appending audit events to the file at `path`,
+// created when missing.
+func AuditFile(path string) (*AuditWriter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file %s: %w", path, err)
+	}
+	return NewAuditWriter(f), nil
+}
+
+func (w *AuditWriter) Audit(e AuditEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(append(b, '\n'))
+	return err
+}
+
+// Close closes the underlying writer, when it's an io.Closer.
+func (w *AuditWriter) Close() error {
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// AuditWebhook is a sink POSTing each audit event, as JSON, to a URL.
+type AuditWebhook struct {
+	URL string
+	// Client is the client used for requests, DefaultHTTPClient when nil.
+	Client *http.Client
+}
+
+func (h *AuditWebhook) Audit(e AuditEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	client := h.Client
+	if client == nil {
+		client = DefaultHTTPClient
+	}
+	resp, err := client.Post(h.URL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("audit webhook %s: %s", h.URL, resp.Status)
+	}
+	return nil
+}
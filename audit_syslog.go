@@ -0,0 +1,28 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !plan9
+
+package config
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+// AuditSyslog returns a sink writing audit events, as JSON, to the local
+// syslog, tagged `tag`, with the auth facility.
+func AuditSyslog(tag string) (AuditSink, error) {
+	w, err := syslog.New(syslog.LOG_NOTICE|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, err
+	}
+	return AuditFunc(func(e AuditEvent) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return w.Notice(string(b))
+	}), nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(f, []byte(`{"port": 9090, "db": {"$sensitive": true, "password": "hunter2"}}`), 0600)
+	a := openTest(t, File(f))
+
+	var buf bytes.Buffer
+	remove := AddAuditSink(NewAuditWriter(&buf))
+	defer remove()
+	m := Current().AllowSensitive().Map()
+	m["port"] = 80.0
+	m["db"].(map[string]interface{})["password"] = "hunter3"
+	if err := a.As("alice").Set(m); err != nil {
+		t.Fatal(err)
+	}
+	remove()
+	m["port"] = 81.0
+	a.Set(m)
+
+	var events []AuditEvent
+	for s := bufio.NewScanner(&buf); s.Scan(); {
+		var e AuditEvent
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 1 {
+		t.Fatalf("audited %d events, want 1, until the sink is removed", len(events))
+	}
+	e := events[0]
+	if e.Actor != "alice" || e.Action != "set" || e.Generation != Generation()-1 || e.Source != "file "+f {
+		t.Errorf("audited %+v", e)
+	}
+	want := map[string]interface{}{"db.password": Redacted, "port": 80.0}
+	if len(e.Changes) != len(want) {
+		t.Fatalf("changes %v, want %v", e.Changes, want)
+	}
+	for _, c := range e.Changes {
+		if want[c.Path] != c.New {
+			t.Errorf("%s changed to %v, want %v", c.Path, c.New, want[c.Path])
+		}
+	}
+	if bytes.Contains(buf.Bytes(), []byte("hunter")) {
+		t.Errorf("audited the password: %s", &buf)
+	}
+}
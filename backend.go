@@ -0,0 +1,133 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+)
+
+// Backend is a store of configuration documents, eg. a proprietary system,
+// implemented outside this package, and adapted to a Source by FromBackend, so
+// it layers within a Chain, and reports its Name as the source of its values
+// (see Chain.Provenance, and Admin's audit log).
+type Backend interface {
+	// Load returns the JSON document held by the backend. Errors wrapping
+	// fs.ErrNotExist are skipped by a Chain.
+	Load(ctx context.Context) ([]byte, error)
+	// Watch returns a channel signaling every change to the document, until
+	// `ctx` is done, or the backend closes it.
+	Watch(ctx context.Context) (<-chan struct{}, error)
+	// Name describes the backend, eg. `etcd /app/config`.
+	Name() string
+}
+
+// BackendFunc returns the backend of `uri`, see RegisterBackend.
+type BackendFunc func(uri *url.URL) (Backend, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendFunc{}
+)
+
+// RegisterBackend registers `fn` as returning the backends of URIs with
+// `scheme`, see OpenBackend, typically from the init of the backend's
+// package. A nil `fn` removes the scheme.
+func RegisterBackend(scheme string, fn BackendFunc) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if fn == nil {
+		delete(backends, scheme)
+		return
+	}
+	backends[scheme] = fn
+}
+
+// OpenBackend returns a source of the backend at `uri`, by the backend
+// registered for its scheme, eg.
+//
+//	import _ "example.com/configstore"
+//
+//	s, err := config.OpenBackend("configstore://prod/app")
+//	...
+//	a, err := config.Open(config.NewChain(config.Env("APP_"), s))
+func OpenBackend(uri string, opts ...Option) (*BackendSource, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backend URI %q: %w", uri, err)
+	}
+	backendsMu.RLock()
+	fn := backends[u.Scheme]
+	backendsMu.RUnlock()
+	if fn == nil {
+		return nil, fmt.Errorf("unknown backend URI scheme %q", u.Scheme)
+	}
+	b, err := fn(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backend %s: %w", u.Redacted(), err)
+	}
+	return FromBackend(b, opts...), nil
+}
+
+// BackendSource reads the configuration from a Backend.
+type BackendSource struct {
+	Backend Backend
+	opts    []Option
+}
+
+// FromBackend returns a source reading the documents of `b`, bounded as by
+// ReadFrom with `opts`.
+func FromBackend(b Backend, opts ...Option) *BackendSource {
+	return &BackendSource{Backend: b, opts: opts}
+}
+
+func (s *BackendSource) Read() (Config, error) {
+	b, err := s.Backend.Load(context.Background())
+	if err != nil {
+		return *new(Config), err
+	}
+	c, err := ReadFrom(b, s.opts...)
+	if err != nil {
+		return *new(Config), fmt.Errorf("%s: %w", s.Name(), err)
+	}
+	return c, nil
+}
+
+func (s *BackendSource) Name() string {
+	return s.Backend.Name()
+}
+
+// Watch reloads the current config every time the backend signals a change,
+// via `a` when an Admin owns it (see Admin.Reload, which re-reads the whole
+// source it was opened with, eg. a Chain holding the backend), or by loading
+// the backend otherwise (see Load), until `ctx` is done, or the backend stops
+// watching. Failed reloads are logged, and the current config kept.
+func (s *BackendSource) Watch(ctx context.Context, a *Admin) error {
+	changes, err := s.Backend.Watch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", s.Name(), err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-changes:
+			if !ok {
+				return nil
+			}
+			if a != nil {
+				err = a.Reload()
+			} else {
+				err = Load(s)
+			}
+			if err != nil {
+				log.Printf("config: failed to reload %s: %s", s.Name(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,480 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DecodeHook converts the value `v` into a value assignable to `to`, returning
+// false when it doesn't handle the conversion, so that the next hook, or the
+// default decoding, is tried.
+type DecodeHook func(v interface{}, to reflect.Type) (interface{}, bool, error)
+
+var (
+	hooksMu sync.RWMutex
+	hooks   []DecodeHook
+)
+
+// RegisterDecodeHook adds `hook` to the hooks used by Bind. Registered hooks
+// are tried in order, before the built-in ones.
+func RegisterDecodeHook(hook DecodeHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, hook)
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	ipType       = reflect.TypeOf(net.IP{})
+	urlType      = reflect.TypeOf(url.URL{})
+	secretType   = reflect.TypeOf((*SecretString)(nil))
+)
+
+// builtinHooks convert strings (and numbers, for durations) to:
+//
+//	time.Duration              via time.ParseDuration, numbers being seconds
+//	net.IP                     via net.ParseIP
+//	url.URL, *url.URL          via url.Parse
+//	*SecretString              via NewSecretString
+//	encoding.TextUnmarshaler   via UnmarshalText, eg. time.Time or custom enums
+//
+// Secrets, eg. resolved references, bind as strings otherwise.
+var builtinHooks = []DecodeHook{
+	func(v interface{}, to reflect.Type) (interface{}, bool, error) {
+		if to != secretType {
+			return nil, false, nil
+		}
+		switch v := v.(type) {
+		case *SecretString:
+			return newSecret(append([]byte(nil), v.value()...)), true, nil
+		case string:
+			return NewSecretString(v), true, nil
+		}
+		return nil, false, nil
+	},
+	func(v interface{}, to reflect.Type) (interface{}, bool, error) {
+		if to != durationType {
+			return nil, false, nil
+		}
+		switch v := v.(type) {
+		case time.Duration:
+			return v, true, nil
+		case string:
+			d, err := time.ParseDuration(v)
+			return d, true, err
+		case float64:
+			return time.Duration(v * float64(time.Second)), true, nil
+		case json.Number:
+			f, err := v.Float64()
+			return time.Duration(f * float64(time.Second)), true, err
+		case int:
+			return time.Duration(v) * time.Second, true, nil
+		}
+		return nil, false, nil
+	},
+	func(v interface{}, to reflect.Type) (interface{}, bool, error) {
+		s, ok := v.(string)
+		if !ok || to != ipType {
+			return nil, false, nil
+		}
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, true, fmt.Errorf("invalid IP address %q", s)
+		}
+		return ip, true, nil
+	},
+	func(v interface{}, to reflect.Type) (interface{}, bool, error) {
+		s, ok := v.(string)
+		if !ok || (to != urlType && to != reflect.PtrTo(urlType)) {
+			return nil, false, nil
+		}
+		u, err := url.Parse(s)
+		if err != nil || to == reflect.PtrTo(urlType) {
+			return u, true, err
+		}
+		return *u, true, nil
+	},
+	func(v interface{}, to reflect.Type) (interface{}, bool, error) {
+		s, ok := v.(string)
+		if !ok || to.Kind() == reflect.String {
+			return nil, false, nil
+		}
+		p := reflect.New(to)
+		if to.Kind() == reflect.Ptr {
+			p.Elem().Set(reflect.New(to.Elem()))
+			p = p.Elem()
+		}
+		u, ok := p.Interface().(encoding.TextUnmarshaler)
+		if !ok {
+			return nil, false, nil
+		}
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return nil, true, err
+		}
+		if to.Kind() == reflect.Ptr {
+			return p.Interface(), true, nil
+		}
+		return p.Elem().Interface(), true, nil
+	},
+}
+
+// BindError is returned when a value can't be bound to a field.
+type BindError struct {
+	// Path is the dot separated path of the value, and Position where it's
+	// defined, when known.
+	Path     string
+	Position *Location
+	Err      error
+}
+
+func (e *BindError) Error() string {
+	if e.Position != nil {
+		return fmt.Sprintf("failed to bind '%s' (%s): %s", e.Path, e.Position, e.Err)
+	}
+	return fmt.Sprintf("failed to bind '%s': %s", e.Path, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Bind decodes the config into the struct pointed to by `v`.
+//
+// Keys are matched to exported fields by the name within the field's `config`
+// tag, else its `koanf`, `envconfig`, or `json` tag, else by the
+// case-insensitive field name (snake cased with envconfig's
+// `split_words:"true"`). Fields tagged `config:"-"` are skipped, as are keys
+// without a field. Nested groups bind to struct, or map, fields, and lists to
+// slices. Missing fields are set from their `default` tag, when present, and
+// fail when tagged `required:"true"`.
+//
+// Values are converted by the registered decode hooks (see
+// RegisterDecodeHook), then the built-in ones (durations, IP addresses, URLs,
+// and encoding.TextUnmarshaler implementers), and otherwise by kind. Numbers
+// only bind to integer fields when they're whole, and in range.
+func (c Config) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("config: Bind requires a non-nil pointer to a struct")
+	}
+	b := binder{coerce: c.coerce, lenientBools: c.lenientBools}
+	return c.locate(b.bind("", c.visible(c.values()), rv.Elem()))
+}
+
+// locate sets the position of the value of a BindError, when known.
+func (c Config) locate(err error) error {
+	if be, ok := err.(*BindError); ok {
+		if l, ok := c.Position(be.Path); ok {
+			be.Position = &l
+		}
+	}
+	return err
+}
+
+// Bind decodes the current config into `v`, see Config.Bind.
+func Bind(v interface{}) error {
+	return cfg.Bind(v)
+}
+
+// bindKey decodes the value of `key`, within the root, into `dst`, a pointer.
+func (c Config) bindKey(key string, dst interface{}) error {
+	v, ok := c.Val(key)
+	if !ok {
+		return fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	return c.locate(binder{coerce: c.coerce, lenientBools: c.lenientBools}.bind(key, v, reflect.ValueOf(dst).Elem()))
+}
+
+type binder struct {
+	coerce, lenientBools bool
+}
+
+// bind decodes `v`, the value of `key`, into `dst`, prefixing the path of
+// errors with `key`.
+func (b binder) bind(key string, v interface{}, dst reflect.Value) error {
+	err := b.decode(v, dst)
+	if err == nil {
+		return nil
+	}
+	if be, ok := err.(*BindError); ok {
+		be.Path = join(key, be.Path)
+		return be
+	}
+	return &BindError{Path: key, Err: err}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func (b binder) decode(v interface{}, dst reflect.Value) error {
+	if s, ok := v.(*SecretString); ok && s != nil && dst.Type() != secretType && dst.Kind() != reflect.Interface {
+		v = s.Reveal()
+	}
+	hooksMu.RLock()
+	hs := append(hooks[:len(hooks):len(hooks)], builtinHooks...)
+	hooksMu.RUnlock()
+	for _, hook := range hs {
+		out, ok, err := hook(v, dst.Type())
+		if err != nil {
+			return err
+		}
+		if ok {
+			o := reflect.ValueOf(out)
+			if !o.IsValid() {
+				dst.Set(reflect.Zero(dst.Type()))
+				return nil
+			}
+			if !o.Type().AssignableTo(dst.Type()) {
+				return fmt.Errorf("decode hook returned %s, not %s", o.Type(), dst.Type())
+			}
+			dst.Set(o)
+			return nil
+		}
+	}
+
+	if v == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.Ptr:
+		p := reflect.New(dst.Type().Elem())
+		if err := b.decode(v, p.Elem()); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	case reflect.Interface:
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(dst.Type()) {
+			break
+		}
+		dst.Set(rv)
+		return nil
+	case reflect.Struct:
+		if m, ok := v.(map[string]interface{}); ok {
+			return b.decodeStruct(m, dst)
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(m)))
+		}
+		for key, val := range m {
+			if key == sensitiveKey {
+				continue
+			}
+			e := reflect.New(dst.Type().Elem()).Elem()
+			if err := b.bind(key, val, e); err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), e)
+		}
+		return nil
+	case reflect.Slice:
+		l, ok := v.([]interface{})
+		if strs, isStrings := v.([]string); isStrings {
+			// Set by list flags, and defaults, see Define.
+			for _, s := range strs {
+				l = append(l, s)
+			}
+			ok = true
+		}
+		if s, isString := v.(string); isString && b.coerce {
+			for _, item := range strings.Split(s, ",") {
+				l = append(l, strings.TrimSpace(item))
+			}
+			ok = true
+		}
+		if !ok {
+			break
+		}
+		sl := reflect.MakeSlice(dst.Type(), len(l), len(l))
+		for i, item := range l {
+			if err := b.bind(strconv.Itoa(i), item, sl.Index(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(sl)
+		return nil
+	case reflect.String:
+		if s, ok := v.(string); ok {
+			dst.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		switch x := v.(type) {
+		case bool:
+			dst.SetBool(x)
+			return nil
+		case string:
+			if b.coerce {
+				bl, err := coerceBool(x, b.lenientBools)
+				dst.SetBool(bl)
+				return err
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok, err := b.number(v)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if f != math.Trunc(f) || dst.OverflowInt(int64(f)) || f > math.MaxInt64 || f < math.MinInt64 {
+				return fmt.Errorf("%v overflows, or isn't a whole %s", f, dst.Type())
+			}
+			dst.SetInt(int64(f))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, ok, err := b.number(v)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if f != math.Trunc(f) || f < 0 || f > math.MaxUint64 || dst.OverflowUint(uint64(f)) {
+				return fmt.Errorf("%v overflows, or isn't a whole %s", f, dst.Type())
+			}
+			dst.SetUint(uint64(f))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		f, ok, err := b.number(v)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if dst.OverflowFloat(f) {
+				return fmt.Errorf("%v overflows %s", f, dst.Type())
+			}
+			dst.SetFloat(f)
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot bind %s to %s", kindOf(v), dst.Type())
+}
+
+func (b binder) number(v interface{}) (float64, bool, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, true, nil
+	case int:
+		return float64(x), true, nil
+	case json.Number:
+		f, err := x.Float64()
+		return f, true, err
+	case string:
+		if b.coerce {
+			f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+			return f, true, err
+		}
+	}
+	return 0, false, nil
+}
+
+func (b binder) decodeStruct(m map[string]interface{}, dst reflect.Value) error {
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		key, v, found := lookupField(m, name)
+		if !found {
+			switch def, hasDefault := f.Tag.Lookup("default"); {
+			case f.Anonymous && f.Type.Kind() == reflect.Struct:
+				// Embedded structs are bound from the same group.
+				if err := b.decodeStruct(m, dst.Field(i)); err != nil {
+					return err
+				}
+			case hasDefault:
+				// Defaults are strings, so they're always coerced.
+				if err := (binder{coerce: true, lenientBools: b.lenientBools}).bind(name, def, dst.Field(i)); err != nil {
+					return err
+				}
+			case f.Tag.Get("required") == "true":
+				return &BindError{Path: name, Err: ErrNotFound}
+			}
+			continue
+		}
+		if err := b.bind(key, v, dst.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldName returns the key name for `f`, or false when it's skipped.
+func fieldName(f reflect.StructField) (string, bool) {
+	for _, tag := range []string{"config", "koanf", "envconfig", "json"} {
+		name, ok := f.Tag.Lookup(tag)
+		if !ok {
+			continue
+		}
+		if name = strings.Split(name, ",")[0]; name == "-" {
+			return "", false
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	if f.Tag.Get("split_words") == "true" {
+		return splitWords(f.Name), true
+	}
+	return f.Name, true
+}
+
+// splitWords converts the camel cased `name` to snake case, as envconfig's
+// `split_words` does, eg. `MaxIdleConns` is `max_idle_conns`, and `APIKey` is
+// `api_key`.
+func splitWords(name string) string {
+	var b strings.Builder
+	r := []rune(name)
+	for i, c := range r {
+		upper := unicode.IsUpper(c)
+		if upper && i > 0 && (!unicode.IsUpper(r[i-1]) || (i+1 < len(r) && unicode.IsLower(r[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
+
+// lookupField finds `name` within `m`, preferring an exact match.
+func lookupField(m map[string]interface{}, name string) (string, interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return name, v, true
+	}
+	for key, v := range m {
+		if strings.EqualFold(key, name) {
+			return key, v, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,67 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+// KoanfProvider provides a config to koanf, implementing koanf.Provider, eg.
+//
+//	k := koanf.New(".")
+//	k.Load(c.Koanf(), nil)
+type KoanfProvider struct {
+	m map[string]interface{}
+}
+
+// Koanf returns a koanf provider of the config's values.
+func (c Config) Koanf() *KoanfProvider {
+	return &KoanfProvider{c.m}
+}
+
+// ReadBytes isn't supported, as the values are already parsed.
+func (p *KoanfProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("config koanf provider does not support ReadBytes")
+}
+
+// Read returns a copy of the config's values.
+func (p *KoanfProvider) Read() (map[string]interface{}, error) {
+	m, _ := copyVal(p.m).(map[string]interface{})
+	if m == nil {
+		m = make(map[string]interface{})
+	}
+	return m, nil
+}
+
+// KoanfRaw is implemented by koanf instances.
+type KoanfRaw interface {
+	Raw() map[string]interface{}
+}
+
+// FromKoanf returns a Config of the values loaded into the koanf instance `k`.
+func FromKoanf(k KoanfRaw) Config {
+	m, _ := copyVal(k.Raw()).(map[string]interface{})
+	return FromMap(m)
+}
+
+// ProcessEnv binds the environment variables named `<PREFIX>_<KEY>` into `v`,
+// as envconfig.Process does, eg. with a prefix of `myapp`, `MYAPP_PORT` binds
+// to the field `Port`, or a field tagged `envconfig:"port"`. Fields tagged
+// `split_words:"true"` are snake cased, so `MaxIdle` binds `MYAPP_MAX_IDLE`.
+// With an empty prefix, variables are matched without one.
+//
+// Unlike Env, underscores don't separate groups, so fields of nested structs
+// are bound only via their own envconfig tags.
+func ProcessEnv(prefix string, v interface{}) error {
+	if prefix != "" {
+		prefix = strings.ToUpper(prefix) + "_"
+	}
+	c, err := (&EnvSource{Prefix: prefix, Lowercase: true}).Read()
+	if err != nil {
+		return err
+	}
+	return c.Bind(v)
+}
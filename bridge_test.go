@@ -0,0 +1,57 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+type rawMap map[string]interface{}
+
+func (m rawMap) Raw() map[string]interface{} {
+	return m
+}
+
+func TestKoanf(t *testing.T) {
+	c := FromMap(map[string]interface{}{"db": map[string]interface{}{"port": 5432.0}})
+	p := c.Koanf()
+	m, err := p.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m["db"].(map[string]interface{})["port"] = 1.0
+	if port, _ := c.Group("db").Int("port"); port != 5432 {
+		t.Error("changing the values read changed the config")
+	}
+	if _, err = p.ReadBytes(); err == nil {
+		t.Error("read bytes")
+	}
+
+	raw := rawMap{"db": map[string]interface{}{"host": "db"}}
+	c = FromKoanf(raw)
+	raw["db"].(map[string]interface{})["host"] = "changed"
+	if host, _ := c.Group("db").String("host"); host != "db" {
+		t.Errorf("db.host = %q, want a copy of koanf's values", host)
+	}
+}
+
+func TestProcessEnv(t *testing.T) {
+	for k, v := range map[string]string{"BRIDGETEST_PORT": "9090", "BRIDGETEST_MAX_IDLE": "4", "BRIDGETEST_DEBUG": "true"} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	var v struct {
+		Port    int
+		MaxIdle int  `split_words:"true"`
+		Verbose bool `envconfig:"debug"`
+	}
+	if err := ProcessEnv("bridgetest", &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Port != 9090 || v.MaxIdle != 4 || !v.Verbose {
+		t.Errorf("processed %+v", v)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// RuntimeGroup is the group describing the running process, eg. for
+// diagnostics endpoints, once provided by ProvideBuildInfo:
+//
+//	go_version   eg. "go1.22.1"
+//	os, arch     runtime.GOOS and runtime.GOARCH
+//	revision     the VCS revision the binary was built from, when known
+//	modified     whether the working tree had uncommitted changes
+//	start_time   when the process started, as RFC 3339
+//	hostname
+//	pid
+const RuntimeGroup = "runtime"
+
+var startTime = time.Now()
+
+// ProvideBuildInfo provides the keys of RuntimeGroup (see Provide), for
+// every config. They're computed, so only read through the accessors, not
+// walked or dumped.
+func ProvideBuildInfo() {
+	var revision string
+	var modified bool
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+	}
+	hostname, _ := os.Hostname()
+	for key, v := range map[string]interface{}{
+		"go_version": runtime.Version(),
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+		"revision":   revision,
+		"modified":   modified,
+		"start_time": startTime.Format(time.RFC3339),
+		"hostname":   hostname,
+		"pid":        os.Getpid(),
+	} {
+		v := v
+		Provide(RuntimeGroup+"."+key, func() interface{} { return v })
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// cacheMagic prefixes cache files, versioning the format: version 2 holds
+// the format of the data too, version 1 only JSON documents.
+var (
+	cacheMagic  = []byte("cfgcache2")
+	cacheMagic1 = []byte("cfgcache1")
+)
+
+// ErrCacheKey is returned when a cache is used without a valid key.
+var ErrCacheKey = errors.New("config cache key must be 16, 24, or 32 bytes")
+
+// Cache is an encrypted (AES-GCM) local copy of the last good config fetched
+// from a remote source, so processes can start during a backend outage.
+type Cache struct {
+	// File is where the cache is persisted.
+	File string
+	// Key is the AES key, when nil it's fetched from KeyURI, or taken from
+	// `APP_CONFIG_CACHE_KEY` (hex or base64 encoded).
+	Key []byte
+	// KeyURI is the keyring URI of the key, see FetchKey, eg. a data key
+	// decrypted by a KMS. It's fetched once, on first use.
+	KeyURI string
+
+	mu      sync.Mutex
+	fetched []byte
+}
+
+func (c *Cache) aead() (cipher.AEAD, error) {
+	key := c.Key
+	if key == nil && c.KeyURI != "" {
+		c.mu.Lock()
+		if c.fetched == nil {
+			b, err := FetchKey(context.Background(), c.KeyURI)
+			if err != nil {
+				c.mu.Unlock()
+				return nil, err
+			}
+			c.fetched = b
+		}
+		key = c.fetched
+		c.mu.Unlock()
+	}
+	if key == nil {
+		env := os.Getenv("APP_CONFIG_CACHE_KEY")
+		for _, size := range []int{32, 24, 16} {
+			if b, err := decodeKey(env, size); err == nil {
+				key = b
+				break
+			}
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrCacheKey
+	}
+	return cipher.NewGCM(block)
+}
+
+// Store encrypts and persists `data`, fetched from `source` at `fetched`.
+func (c *Cache) Store(source string, data []byte, fetched time.Time) error {
+	return c.store(source, "", data, fetched)
+}
+
+// store persists `data` of the format `ext` (see RegisterFormat), empty for
+// JSON.
+func (c *Cache) store(source, ext string, data []byte, fetched time.Time) error {
+	if len(ext) > 255 {
+		return fmt.Errorf("invalid config format %q", ext)
+	}
+	gcm, err := c.aead()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	plain := make([]byte, 8, 8+1+len(ext)+len(data))
+	binary.BigEndian.PutUint64(plain, uint64(fetched.UnixNano()))
+	plain = append(plain, byte(len(ext)))
+	plain = append(plain, ext...)
+	plain = append(plain, data...)
+
+	var b bytes.Buffer
+	b.Write(cacheMagic)
+	b.Write(nonce)
+	b.Write(gcm.Seal(nil, nonce, plain, []byte(source)))
+
+	// Write to a temp file first, so a crash never leaves a torn cache.
+	tmp, err := ioutil.TempFile(filepath.Dir(c.File), filepath.Base(c.File)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(b.Bytes()); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.File)
+}
+
+// Load returns the cached data for `source`, along with when it was fetched.
+func (c *Cache) Load(source string) ([]byte, time.Time, error) {
+	_, data, fetched, err := c.load(source)
+	return data, fetched, err
+}
+
+// load returns the cached data for `source`, along with its format, see
+// store, and when it was fetched.
+func (c *Cache) load(source string) (string, []byte, time.Time, error) {
+	gcm, err := c.aead()
+	if err != nil {
+		return "", nil, time.Time{}, err
+	}
+	b, err := ioutil.ReadFile(c.File)
+	if err != nil {
+		return "", nil, time.Time{}, err
+	}
+	v1 := bytes.HasPrefix(b, cacheMagic1)
+	n := len(cacheMagic) + gcm.NonceSize()
+	if len(b) < n || !(v1 || bytes.HasPrefix(b, cacheMagic)) {
+		return "", nil, time.Time{}, fmt.Errorf("invalid config cache %s", c.File)
+	}
+	plain, err := gcm.Open(nil, b[len(cacheMagic):n], b[n:], []byte(source))
+	if err != nil || len(plain) < 8 {
+		return "", nil, time.Time{}, fmt.Errorf("failed to decrypt config cache %s", c.File)
+	}
+	fetched := time.Unix(0, int64(binary.BigEndian.Uint64(plain)))
+	if v1 {
+		return "", plain[8:], fetched, nil
+	}
+	if len(plain) < 9 || len(plain) < 9+int(plain[8]) {
+		return "", nil, time.Time{}, fmt.Errorf("invalid config cache %s", c.File)
+	}
+	n = 9 + int(plain[8])
+	return string(plain[9:n]), plain[n:], fetched, nil
+}
+
+// Status describes the freshness of a remote source.
+type Status struct {
+	// Source is the name of the source, eg. its URL.
+	Source string
+	// Cached is true when the config in use came from the local cache,
+	// because the last fetch failed.
+	Cached bool
+	// Fetched is when the config in use was fetched from the source.
+	Fetched time.Time
+	// Attempted is when the source was last fetched from.
+	Attempted time.Time
+	// Err is the error of the last fetch, if it failed.
+	Err error
+}
+
+var (
+	statusMu sync.Mutex
+	statuses = make(map[string]Status)
+)
+
+func setStatus(s Status) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	statuses[s.Source] = s
+}
+
+// SourceStatus returns the status of every remote source used, by name.
+func SourceStatus() []Status {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	s := make([]Status, 0, len(statuses))
+	for _, st := range statuses {
+		s = append(s, st)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Source < s[j].Source })
+	return s
+}
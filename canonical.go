@@ -0,0 +1,184 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+// MarshalCanonical returns the canonical JSON encoding of `v`, whose bytes
+// only depend on its values, as in RFC 8785: object keys are sorted, there's
+// no insignificant whitespace, strings only escape what they must, and every
+// number, whatever its type, is written as the shortest float64 that reads
+// back the same (eg. `1`, `0.5`, `1e+21`). Integers beyond 2^53 are rounded.
+// Keys are sorted by their UTF-16 code units, and SecretStrings written as
+// Redacted.
+func MarshalCanonical(v interface{}) ([]byte, error) {
+	return marshalCanonical(v, false)
+}
+
+// marshalCanonical returns the canonical JSON encoding of `v`, with the
+// values of SecretStrings when `secrets` is set, eg. for Hash to tell
+// rotated secrets apart.
+func marshalCanonical(v interface{}, secrets bool) ([]byte, error) {
+	var b bytes.Buffer
+	if err := canonical(&b, v, secrets); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// MarshalCanonical returns the canonical JSON encoding of the config, see
+// MarshalCanonical.
+func (c Config) MarshalCanonical() ([]byte, error) {
+	return MarshalCanonical(c.doc())
+}
+
+func canonical(b *bytes.Buffer, v interface{}, secrets bool) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case string:
+		canonicalString(b, v)
+	case *SecretString:
+		if secrets && v != nil {
+			canonicalString(b, v.Reveal())
+		} else {
+			canonicalString(b, Redacted)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return utf16Less(keys[i], keys[j]) })
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			canonicalString(b, key)
+			b.WriteByte(':')
+			if err := canonical(b, v[key], secrets); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	case []interface{}:
+		b.WriteByte('[')
+		for i, val := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := canonical(b, val, secrets); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case []string:
+		b.WriteByte('[')
+		for i, s := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			canonicalString(b, s)
+		}
+		b.WriteByte(']')
+	default:
+		if f, err := CoerceFloat64(v); err == nil {
+			return canonicalNumber(b, f)
+		}
+		// Anything else is encoded as it would be by encoding/json, then
+		// re-encoded.
+		j, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var val interface{}
+		if err = decode(j, &val, true); err != nil {
+			return err
+		}
+		return canonical(b, val, secrets)
+	}
+	return nil
+}
+
+// utf16Less returns whether `a` sorts before `b` by their UTF-16 code units,
+// see RFC 8785, which differs from their bytes beyond the BMP, eg.
+// U+FF61 sorts after U+1F600, encoded as the surrogates D83D DE00.
+func utf16Less(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// canonicalNumber writes `f` as ECMAScript would, see RFC 8785.
+func canonicalNumber(b *bytes.Buffer, f float64) error {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return fmt.Errorf("unsupported number %v", f)
+	}
+	if f == 0 {
+		// Including -0.
+		b.WriteByte('0')
+		return nil
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+	if format == 'e' {
+		// Trim the exponent's leading zero, eg. `1e-07` to `1e-7`.
+		if n := len(s); n >= 4 && s[n-4] == 'e' && s[n-2] == '0' {
+			s = s[:n-2] + s[n-1:]
+		}
+	}
+	b.WriteString(s)
+	return nil
+}
+
+// canonicalString writes `s` quoted, escaping only quotes, backslashes, and
+// control characters, see RFC 8785.
+func canonicalString(b *bytes.Buffer, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				// Invalid UTF-8 is written as the replacement character.
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
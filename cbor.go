@@ -0,0 +1,245 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrCBOR is returned when a CBOR document is malformed.
+var ErrCBOR = errors.New("malformed CBOR")
+
+// maxBinaryDepth bounds the nesting of binary, and XML, documents while
+// they're decoded, before the limits of their options are checked, as
+// encoding/json does.
+const maxBinaryDepth = 10000
+
+func init() {
+	RegisterFormat(".cbor", DecoderFunc(decodeCBOR))
+}
+
+// ReadCBOR returns a new Config from the CBOR (RFC 8949) document `b`, whose
+// root must be a map. See ReadFrom for `opts`.
+//
+// Numbers are read as float64 (matching JSON numbers), byte strings as
+// []byte, and tagged dates (tags 0 and 1) as time.Time; other tags are
+// dropped, leaving their values.
+func ReadCBOR(b []byte, opts ...Option) (Config, error) {
+	return newOptions(opts).readFormat(DecoderFunc(decodeCBOR), b, "")
+}
+
+func decodeCBOR(b []byte) (map[string]interface{}, error) {
+	d := &cborDecoder{b: b}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, unexpectedBreak(err)
+	}
+	if d.off != len(b) {
+		return nil, fmt.Errorf("%s: data after the root value", ErrCBOR)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: root is not a map", ErrCBOR)
+	}
+	return m, nil
+}
+
+type cborDecoder struct {
+	b   []byte
+	off int
+}
+
+// cborBreak is returned by value for the break code of indefinite lengths.
+var cborBreak = errors.New("break")
+
+func (d *cborDecoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)-d.off) {
+		return nil, fmt.Errorf("%s: unexpected end", ErrCBOR)
+	}
+	p := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return p, nil
+}
+
+// head reads the head of a data item: its major type, additional info, and
+// argument.
+func (d *cborDecoder) head() (major, info byte, arg uint64, err error) {
+	p, err := d.next(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = p[0]>>5, p[0]&0x1f
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		if p, err = d.next(1 << (info - 24)); err != nil {
+			return 0, 0, 0, err
+		}
+		arg = beUint(p)
+	case info == 31:
+		if major == 0 || major == 1 || major == 6 {
+			return 0, 0, 0, fmt.Errorf("%s: invalid indefinite length", ErrCBOR)
+		}
+	default:
+		return 0, 0, 0, fmt.Errorf("%s: reserved additional info %d", ErrCBOR, info)
+	}
+	return major, info, arg, nil
+}
+
+func (d *cborDecoder) value(depth int) (interface{}, error) {
+	if depth > maxBinaryDepth {
+		return nil, fmt.Errorf("%s: nested too deeply", ErrCBOR)
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		return float64(arg), nil
+	case 1:
+		return -1 - float64(arg), nil
+	case 2, 3:
+		b, err := d.bytes(major, info, arg)
+		if err != nil || major == 2 {
+			return b, err
+		}
+		if !utf8.Valid(b) {
+			return nil, fmt.Errorf("%s: invalid UTF-8 text", ErrCBOR)
+		}
+		return string(b), nil
+	case 4:
+		var l []interface{}
+		for i := uint64(0); info == 31 || i < arg; i++ {
+			v, err := d.value(depth + 1)
+			if err == cborBreak && info == 31 {
+				break
+			}
+			if err != nil {
+				return nil, unexpectedBreak(err)
+			}
+			l = append(l, v)
+		}
+		if l == nil {
+			l = []interface{}{}
+		}
+		return l, nil
+	case 5:
+		m := make(map[string]interface{})
+		for i := uint64(0); info == 31 || i < arg; i++ {
+			k, err := d.value(depth + 1)
+			if err == cborBreak && info == 31 {
+				break
+			}
+			if err != nil {
+				return nil, unexpectedBreak(err)
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: map key %v is not a string", ErrCBOR, k)
+			}
+			if m[key], err = d.value(depth + 1); err != nil {
+				return nil, unexpectedBreak(err)
+			}
+		}
+		return m, nil
+	case 6:
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, unexpectedBreak(err)
+		}
+		switch t := v.(type) {
+		case string:
+			if arg == 0 {
+				return time.Parse(time.RFC3339Nano, t)
+			}
+		case float64:
+			if arg == 1 {
+				sec, frac := math.Modf(t)
+				return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+			}
+		}
+		return v, nil
+	}
+	// Major type 7: simple values, and floats.
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return halfFloat(uint16(arg)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case 27:
+		return math.Float64frombits(arg), nil
+	case 31:
+		return nil, cborBreak
+	}
+	return nil, fmt.Errorf("%s: unsupported simple value %d", ErrCBOR, arg)
+}
+
+func unexpectedBreak(err error) error {
+	if err == cborBreak {
+		return fmt.Errorf("%s: unexpected break", ErrCBOR)
+	}
+	return err
+}
+
+// bytes reads the content of a byte, or text, string, concatenating the
+// chunks of indefinite lengths.
+func (d *cborDecoder) bytes(major, info byte, arg uint64) ([]byte, error) {
+	if info != 31 {
+		p, err := d.next(arg)
+		return append([]byte{}, p...), err
+	}
+	b := []byte{}
+	for {
+		if d.off < len(d.b) && d.b[d.off] == 0xff {
+			d.off++
+			return b, nil
+		}
+		m, i, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || i == 31 {
+			return nil, fmt.Errorf("%s: invalid chunk of an indefinite string", ErrCBOR)
+		}
+		p, err := d.next(n)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, p...)
+	}
+}
+
+// halfFloat returns the IEEE 754 half precision float of `h`.
+func halfFloat(h uint16) float64 {
+	exp, frac := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(frac, -24)
+	case 31:
+		f = math.Inf(1)
+		if frac != 0 {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(frac+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
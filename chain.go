@@ -0,0 +1,307 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Source provides configuration, eg. to a Chain.
+type Source interface {
+	Read() (Config, error)
+}
+
+// Chain combines multiple sources into a single Config. Lookups walk the
+// sources in their declared order, so values from earlier sources take
+// precedence over those of later ones. Groups are merged key by key.
+type Chain struct {
+	sources []Source
+}
+
+// NewChain returns a chain of `sources`, in priority order, eg.
+//
+//	config.NewChain(config.Flags(fs), config.Env("APP_"), config.File("config.json"))
+func NewChain(sources ...Source) *Chain {
+	return &Chain{sources}
+}
+
+// Read reads every source and returns the combined Config. Sources that
+// don't exist (eg. a missing file) are skipped; any other error aborts.
+// When any source is string-typed, the combined config coerces values (see
+// Config.Coerce).
+func (ch *Chain) Read() (Config, error) {
+	m := make(map[string]interface{})
+	var pos map[string]Location
+	coerce := false
+	// Layer from lowest to highest priority, so higher ones overwrite.
+	for i := len(ch.sources) - 1; i >= 0; i-- {
+		c, err := ch.sources[i].Read()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return *new(Config), err
+		}
+		m = merge(m, c.m)
+		pos = mergePositions(pos, c)
+		coerce = coerce || c.coerce
+	}
+	return Config{m: m, coerce: coerce, pos: pos}, nil
+}
+
+// Layer is the value of a key within one of a chain's sources, see
+// Chain.Provenance.
+type Layer struct {
+	Source string      `json:"source"`
+	Value  interface{} `json:"value"`
+	// Position is where the source defines the key, when known.
+	Position *Location `json:"position,omitempty"`
+}
+
+// Provenance reads every source and returns the layers defining each key,
+// by its dot separated path (see Config.Flatten), in priority order. The
+// first layer holds the value in effect, unless a higher source replaced the
+// key's group with a value. Sources that don't exist are skipped.
+func (ch *Chain) Provenance() (map[string][]Layer, error) {
+	layers := make(map[string][]Layer)
+	for _, s := range ch.sources {
+		c, err := s.Read()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := sourceName(s)
+		for path, v := range flatten(c.m) {
+			l := Layer{Source: name, Value: v}
+			if pos, ok := c.Position(path); ok {
+				l.Position = &pos
+			}
+			layers[path] = append(layers[path], l)
+		}
+	}
+	return layers, nil
+}
+
+// sourceName describes `s`, by its Name method when implemented.
+func sourceName(s Source) string {
+	switch s := s.(type) {
+	case interface{ Name() string }:
+		return s.Name()
+	case *FileSource:
+		return "file " + s.Path
+	case *GlobSource:
+		return "glob " + s.Pattern
+	case *EnvSource:
+		return "env " + s.Prefix
+	case *FlagSource:
+		return "flags"
+	case *HTTPSource:
+		return "remote " + s.URL
+	case *DefaultsSource:
+		return "defaults"
+	case *Chain:
+		return "chain"
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// Load reads `s` and installs it as the global config.
+func Load(s Source) error {
+	c, err := s.Read()
+	if err != nil {
+		return err
+	}
+	return install(c)
+}
+
+// merge returns a copy of `dst` with `src` deep-merged over it.
+func merge(dst, src map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(dst)+len(src))
+	for k, v := range dst {
+		m[k] = v
+	}
+	for k, v := range src {
+		if sm, ok := v.(map[string]interface{}); ok {
+			if dm, ok := m[k].(map[string]interface{}); ok {
+				m[k] = merge(dm, sm)
+				continue
+			}
+			m[k] = merge(nil, sm)
+			continue
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// set sets the value at `path` within `m`, creating groups as needed.
+func set(m map[string]interface{}, path []string, v interface{}) {
+	for _, k := range path[:len(path)-1] {
+		g, ok := m[k].(map[string]interface{})
+		if !ok {
+			g = make(map[string]interface{})
+			m[k] = g
+		}
+		m = g
+	}
+	m[path[len(path)-1]] = v
+}
+
+// FileSource reads the configuration from a file.
+type FileSource struct {
+	Path string
+	opts []Option
+}
+
+// File returns a source reading the JSON file at `path`, as by Read with
+// `opts`, eg. WithStrict.
+func File(path string, opts ...Option) *FileSource {
+	return &FileSource{Path: path, opts: opts}
+}
+
+func (s *FileSource) Read() (Config, error) {
+	return readFile(s.Path, newOptions(s.opts))
+}
+
+// GlobSource reads the configuration from every file matching a pattern.
+type GlobSource struct {
+	Pattern string
+	opts    []Option
+}
+
+// Glob returns a source reading the JSON files matching `pattern` (see
+// filepath.Match), eg. `conf.d/*.json`, each as by Read with `opts`.
+// Files are read in lexical order, each deep-merged over the ones before it,
+// so `20-local.json` overrides `10-base.json`. Files declaring their version
+// (see VersionKey) are migrated on their own, the others once merged.
+func Glob(pattern string, opts ...Option) *GlobSource {
+	return &GlobSource{Pattern: pattern, opts: opts}
+}
+
+func (s *GlobSource) Read() (Config, error) {
+	files, err := filepath.Glob(s.Pattern)
+	if err != nil {
+		return *new(Config), fmt.Errorf("invalid config pattern %s: %w", s.Pattern, err)
+	}
+	if len(files) == 0 {
+		return *new(Config), fmt.Errorf("no configuration files match %s: %w", s.Pattern, fs.ErrNotExist)
+	}
+	sort.Strings(files)
+	m := make(map[string]interface{})
+	var pos map[string]Location
+	o := newOptions(s.opts)
+	// The digest and signature within the environment are of a single
+	// config file, so each file is verified by its sidecars.
+	o.sidecars = true
+	// Files without a version are fragments of a single config, migrated
+	// once merged.
+	o.partial = true
+	for _, f := range files {
+		c, err := readFile(f, o)
+		if err != nil {
+			return *new(Config), err
+		}
+		m = merge(m, c.m)
+		pos = mergePositions(pos, c)
+	}
+	if _, err = migrate(m); err != nil {
+		return *new(Config), fmt.Errorf("failed to migrate %s: %w", s.Pattern, err)
+	}
+	return Config{m: m, pos: pos}, nil
+}
+
+// LoadGlob reads the files matching `pattern` and installs them as the global
+// config, see Glob.
+func LoadGlob(pattern string) error {
+	return Load(Glob(pattern))
+}
+
+// EnvSource reads the configuration from environment variables.
+type EnvSource struct {
+	// Prefix selects the variables to read, and is stripped from their names.
+	Prefix string
+	// Separator splits the remaining name into groups and a key.
+	Separator string
+	// Lowercase lowercases the groups and key.
+	Lowercase bool
+}
+
+// Env returns a source reading the variables starting with `prefix`, eg. with
+// a prefix of `APP_`, `APP_SERVER_PORT=9090` becomes the `port` key within the
+// `server` group. Values are strings, which are coerced on access.
+func Env(prefix string) *EnvSource {
+	return &EnvSource{Prefix: prefix, Separator: "_", Lowercase: true}
+}
+
+func (s *EnvSource) Read() (Config, error) {
+	m := make(map[string]interface{})
+	for _, kv := range os.Environ() {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 || !strings.HasPrefix(kv[:i], s.Prefix) {
+			continue
+		}
+		name := kv[len(s.Prefix):i]
+		if s.Lowercase {
+			name = strings.ToLower(name)
+		}
+		path := []string{name}
+		if s.Separator != "" {
+			path = strings.Split(name, s.Separator)
+		}
+		if name == "" || contains(path, "") {
+			continue
+		}
+		set(m, path, kv[i+1:])
+	}
+	return Config{m: m, coerce: true}, nil
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// FlagSource reads the configuration from the flags that were set.
+type FlagSource struct {
+	fs *flag.FlagSet
+}
+
+// Flags returns a source of the flags that were set within `fs` (after it was
+// parsed), where dots split a flag name into groups and a key, eg.
+// `-server.port=9090`. Values are those of flag.Getter, when implemented, and
+// strings, which are coerced on access, otherwise.
+func Flags(fs *flag.FlagSet) *FlagSource {
+	return &FlagSource{fs}
+}
+
+func (s *FlagSource) Read() (Config, error) {
+	m := make(map[string]interface{})
+	s.fs.Visit(func(f *flag.Flag) {
+		var v interface{} = f.Value.String()
+		if g, ok := f.Value.(flag.Getter); ok {
+			v = g.Get()
+		}
+		set(m, strings.Split(f.Name, "."), v)
+	})
+	return Config{m: m, coerce: true}, nil
+}
+
+// Remote returns a source reading the configuration served at `url`.
+func Remote(url string) *HTTPSource {
+	return &HTTPSource{URL: url}
+}
@@ -0,0 +1,69 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFileOptions(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "10-base.json")
+	os.WriteFile(f, []byte(`{"port": 80, "port": 9090}`), 0600)
+	if _, err := File(f).Read(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := File(f, WithStrict()).Read(); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("File with WithStrict: %v, want %v", err, ErrDuplicateKey)
+	}
+	if _, err := Glob(filepath.Join(dir, "*.json"), WithStrict()).Read(); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Glob with WithStrict: %v, want %v", err, ErrDuplicateKey)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	os.WriteFile(f, []byte(`{"db": {"$sensitive": true, "password": "hunter2"}}`), 0644)
+	os.Chmod(f, 0644)
+	refuse := WithPermissionCheck(PermissionsRefuse)
+	if _, err := File(f, refuse).Read(); !errors.Is(err, ErrPermissions) {
+		t.Errorf("File of a readable secret: %v, want %v", err, ErrPermissions)
+	}
+	if _, err := Glob(filepath.Join(dir, "*.json"), refuse).Read(); !errors.Is(err, ErrPermissions) {
+		t.Errorf("Glob of a readable secret: %v, want %v", err, ErrPermissions)
+	}
+}
+
+// pathDecoder decodes documents to the path of their file.
+type pathDecoder struct{}
+
+func (pathDecoder) Decode(b []byte) (map[string]interface{}, error) {
+	return map[string]interface{}{"path": ""}, nil
+}
+
+func (pathDecoder) DecodeFile(path string, b []byte) (map[string]interface{}, error) {
+	return map[string]interface{}{"path": path}, nil
+}
+
+func TestFileDecoder(t *testing.T) {
+	RegisterFormat(".pathtest", pathDecoder{})
+	defer RegisterFormat(".pathtest", nil)
+	f := filepath.Join(t.TempDir(), "config.pathtest")
+	os.WriteFile(f, []byte("x"), 0600)
+	c, err := File(f).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := c.String("path"); path != f {
+		t.Errorf("decoded the path %q, want %q", path, f)
+	}
+	if c, _ = ReadFormat(".pathtest", []byte("x")); c.m["path"] != "" {
+		t.Errorf("ReadFormat decoded the path %q, want none", c.m["path"])
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// ClientConfig holds the settings of an http.Client, bound from a group like:
+//
+//	"upstream": {
+//		"timeout": "10s",
+//		"proxy": "http://proxy:3128",
+//		"keep_alive": "30s",
+//		"max_idle_conns": 100,
+//		"max_idle_conns_per_host": 10,
+//		"idle_conn_timeout": "90s",
+//		"tls": {
+//			"ca_file": "/etc/ssl/upstream.pem",
+//			"cert_file": "client.pem",
+//			"key_file": "client.key",
+//			"server_name": "upstream.internal",
+//			"min_version": "1.2"
+//		}
+//	}
+//
+// A `proxy` of "" uses the environment's proxy (HTTP_PROXY etc.), while
+// "direct" disables proxying. A negative `keep_alive` disables keep-alives.
+type ClientConfig struct {
+	Timeout             time.Duration `config:"timeout"`
+	Proxy               string        `config:"proxy"`
+	KeepAlive           time.Duration `config:"keep_alive"`
+	MaxIdleConns        int           `config:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `config:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `config:"idle_conn_timeout"`
+	TLSHandshakeTimeout time.Duration `config:"tls_handshake_timeout"`
+	TLS                 TLSConfig     `config:"tls"`
+}
+
+// TLSConfig holds the TLS settings of a ClientConfig.
+type TLSConfig struct {
+	CAFile             string `config:"ca_file"`
+	CertFile           string `config:"cert_file"`
+	KeyFile            string `config:"key_file"`
+	ServerName         string `config:"server_name"`
+	MinVersion         string `config:"min_version"`
+	InsecureSkipVerify bool   `config:"insecure_skip_verify"`
+	// CipherSuites are the TLS 1.2 cipher suites allowed, by name, eg.
+	// `TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256`, Go's defaults when empty.
+	CipherSuites []string `config:"cipher_suites"`
+}
+
+// DefaultClientConfig holds the settings used for any missing from the
+// group, matching http.DefaultTransport except for the bounded timeout.
+var DefaultClientConfig = ClientConfig{
+	Timeout:             30 * time.Second,
+	KeepAlive:           30 * time.Second,
+	MaxIdleConns:        100,
+	IdleConnTimeout:     90 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Transport returns the http.Transport for the settings, loading any
+// certificates they refer to.
+func (cc ClientConfig) Transport() (*http.Transport, error) {
+	if cc.Timeout < 0 || cc.IdleConnTimeout < 0 || cc.TLSHandshakeTimeout < 0 {
+		return nil, fmt.Errorf("invalid client timeout, must not be negative")
+	}
+	if cc.MaxIdleConns < 0 || cc.MaxIdleConnsPerHost < 0 {
+		return nil, fmt.Errorf("invalid client max idle conns, must not be negative")
+	}
+	proxy := http.ProxyFromEnvironment
+	switch cc.Proxy {
+	case "":
+	case "direct":
+		proxy = nil
+	default:
+		u, err := url.Parse(cc.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client proxy %q: %s", cc.Proxy, err)
+		}
+		proxy = http.ProxyURL(u)
+	}
+	tc, err := cc.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: cc.KeepAlive}
+	return &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tc,
+		TLSHandshakeTimeout: cc.TLSHandshakeTimeout,
+		DisableKeepAlives:   cc.KeepAlive < 0,
+		MaxIdleConns:        cc.MaxIdleConns,
+		MaxIdleConnsPerHost: cc.MaxIdleConnsPerHost,
+		IdleConnTimeout:     cc.IdleConnTimeout,
+		ForceAttemptHTTP2:   true,
+	}, nil
+}
+
+func (t TLSConfig) config() (*tls.Config, error) {
+	tc := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.MinVersion != "" {
+		v, ok := tlsVersions[t.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls min_version %q", t.MinVersion)
+		}
+		tc.MinVersion = v
+	}
+	for _, name := range t.CipherSuites {
+		id, ok := cipherSuite(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid tls cipher suite %q", name)
+		}
+		if FIPS && !isFIPSCipherSuite(id) {
+			return nil, fmt.Errorf("tls cipher suite %s: %w", name, ErrFIPS)
+		}
+		tc.CipherSuites = append(tc.CipherSuites, id)
+	}
+	if FIPS {
+		if tc.MinVersion != 0 && tc.MinVersion < tls.VersionTLS12 {
+			return nil, fmt.Errorf("tls min_version %s: %w", t.MinVersion, ErrFIPS)
+		}
+		if tc.MinVersion == 0 {
+			tc.MinVersion = tls.VersionTLS12
+		}
+		if tc.CipherSuites == nil {
+			tc.CipherSuites = fipsCipherSuites
+		}
+		tc.CurvePreferences = fipsCurves
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca_file: %w", err)
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found within tls ca_file %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
+
+func cipherSuite(name string) (uint16, bool) {
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if s.Name == name {
+			return s.ID, true
+		}
+	}
+	return 0, false
+}
+
+// HTTPClient returns an http.Client configured by the `group`, see
+// ClientConfig. Missing settings are taken from DefaultClientConfig.
+func (c Config) HTTPClient(group string) (*http.Client, error) {
+	cc := DefaultClientConfig
+	if err := c.Group(group).Bind(&cc); err != nil {
+		return nil, fmt.Errorf("failed to read '%s' client config: %w", group, err)
+	}
+	t, err := cc.Transport()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s' client config: %w", group, err)
+	}
+	return &http.Client{Transport: t, Timeout: cc.Timeout}, nil
+}
+
+// HTTPClient returns an http.Client configured by the `group` of the current
+// config, see Config.HTTPClient.
+func HTTPClient(group string) (*http.Client, error) {
+	return cfg.HTTPClient(group)
+}
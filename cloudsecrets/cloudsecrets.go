@@ -0,0 +1,183 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cloudsecrets registers resolvers (see config.RegisterResolver)
+// reading values from GCP Secret Manager, and Azure Key Vault, eg.
+//
+//	import _ "code.minty.io/config/cloudsecrets"
+//
+//	{"db": {"password": "gcp-sm:projects/p/secrets/db-password"},
+//	 "api": {"key": "azkv:my-vault/api-key"}}
+//
+// References are:
+//
+//	gcp-sm:projects/<p>/secrets/<s>[/versions/<v>]
+//	azkv:<vault>/<secret>[/<version>]
+//
+// Credentials are found as by the kms package: a GCP access token from
+// `GOOGLE_OAUTH_ACCESS_TOKEN`, or else the GCE metadata server, and an Azure
+// access token from `AZURE_ACCESS_TOKEN`, or else the managed identity of the
+// instance metadata service.
+//
+// References to the latest version lease their value for RefreshInterval,
+// so a SecretsSource renews them as they're rotated; references to a version
+// never expire. Azure secrets expiring sooner lease their value until then.
+package cloudsecrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"code.minty.io/config"
+)
+
+var (
+	// DefaultClient is the client used to call the clouds.
+	DefaultClient = &http.Client{Timeout: 10 * time.Second}
+	// RefreshInterval is how long the latest version of a secret is leased.
+	RefreshInterval = 5 * time.Minute
+
+	// GCPEndpoint is the Secret Manager endpoint, AzureVaultURL the URL of
+	// the named vault.
+	GCPEndpoint   = "https://secretmanager.googleapis.com"
+	AzureVaultURL = func(vault string) string { return "https://" + vault + ".vault.azure.net" }
+)
+
+func init() {
+	config.RegisterResolver("gcp-sm", config.ResolverFunc(GCP))
+	config.RegisterResolver("azkv", config.ResolverFunc(Azure))
+}
+
+// GCP resolves the `gcp-sm` reference `ref`, see the package docs.
+func GCP(ctx context.Context, ref string) (config.Lease, error) {
+	l := strings.Split(ref, "/")
+	if (len(l) != 4 && len(l) != 6) || l[0] != "projects" || l[2] != "secrets" || (len(l) == 6 && l[4] != "versions") {
+		return config.Lease{}, fmt.Errorf("cloudsecrets: invalid reference %q, expected gcp-sm:projects/p/secrets/s", "gcp-sm:"+ref)
+	}
+	ttl := time.Duration(0)
+	if len(l) == 4 || l[5] == "latest" {
+		ref, ttl = strings.Join(l[:4], "/")+"/versions/latest", RefreshInterval
+	}
+	token, err := gcpToken(ctx)
+	if err != nil {
+		return config.Lease{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(GCPEndpoint, "/")+"/v1/"+ref+":access", nil)
+	if err != nil {
+		return config.Lease{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	var resp struct {
+		Payload struct{ Data string }
+	}
+	if err = do(req, &resp); err != nil {
+		return config.Lease{}, err
+	}
+	b, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return config.Lease{}, fmt.Errorf("cloudsecrets: %s: %w", ref, err)
+	}
+	return config.Lease{Value: string(b), TTL: ttl}, nil
+}
+
+// Azure resolves the `azkv` reference `ref`, see the package docs.
+func Azure(ctx context.Context, ref string) (config.Lease, error) {
+	l := strings.Split(ref, "/")
+	if len(l) < 2 || len(l) > 3 || l[0] == "" || l[1] == "" {
+		return config.Lease{}, fmt.Errorf("cloudsecrets: invalid reference %q, expected azkv:vault/secret", "azkv:"+ref)
+	}
+	ttl := time.Duration(0)
+	if len(l) == 2 || l[2] == "" {
+		ttl = RefreshInterval
+	}
+	token, err := azureToken(ctx)
+	if err != nil {
+		return config.Lease{}, err
+	}
+	u := AzureVaultURL(l[0]) + "/secrets/" + url.PathEscape(l[1])
+	if len(l) == 3 {
+		u += "/" + url.PathEscape(l[2])
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u+"?api-version=7.4", nil)
+	if err != nil {
+		return config.Lease{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	var resp struct {
+		Value      string
+		Attributes struct{ Exp int64 }
+	}
+	if err = do(req, &resp); err != nil {
+		return config.Lease{}, err
+	}
+	if resp.Attributes.Exp > 0 {
+		if d := time.Until(time.Unix(resp.Attributes.Exp, 0)); d > 0 && (ttl == 0 || d < ttl) {
+			ttl = d
+		}
+	}
+	return config.Lease{Value: resp.Value, TTL: ttl}, nil
+}
+
+const (
+	// gceTokenURL is the metadata server's URL of the default service
+	// account's access token.
+	gceTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	// azureTokenURL is the instance metadata service's URL of the managed
+	// identity's Key Vault access token.
+	azureTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01&resource=https%3A%2F%2Fvault.azure.net"
+)
+
+func gcpToken(ctx context.Context) (string, error) {
+	return token(ctx, "GOOGLE_OAUTH_ACCESS_TOKEN", gceTokenURL, "Metadata-Flavor", "Google")
+}
+
+func azureToken(ctx context.Context) (string, error) {
+	return token(ctx, "AZURE_ACCESS_TOKEN", azureTokenURL, "Metadata", "true")
+}
+
+// token returns the access token of the environment variable `env`, or else
+// the one the metadata server returns.
+func token(ctx context.Context, env, metadataURL, header, value string) (string, error) {
+	if token := os.Getenv(env); token != "" {
+		return token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(header, value)
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = do(req, &resp); err != nil {
+		return "", fmt.Errorf("cloudsecrets: no access token: %w", err)
+	}
+	return resp.AccessToken, nil
+}
+
+func do(req *http.Request, v interface{}) error {
+	resp, err := DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cloudsecrets: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return json.Unmarshal(b, v)
+}
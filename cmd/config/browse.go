@@ -0,0 +1,214 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.minty.io/config"
+)
+
+// runBrowse loads the config and browses it interactively, showing each
+// group's keys with their (redacted) values and the source each came from.
+// Commands are read a line at a time from stdin.
+func runBrowse(args []string) error {
+	fs := flag.NewFlagSet("browse", flag.ExitOnError)
+	src := sourceFlags(fs)
+	interval := fs.Duration("interval", 0, "reload the config every `interval`, redrawing on changes")
+	fs.Parse(args)
+
+	b := &browser{src: src, out: os.Stdout, clear: isTerminal(os.Stdout)}
+	if err := b.reload(); err != nil {
+		return err
+	}
+	b.render()
+	if *interval > 0 {
+		go func() {
+			for range time.Tick(*interval) {
+				b.mu.Lock()
+				if changed := b.reloadChanged(); changed {
+					b.render()
+				}
+				b.mu.Unlock()
+			}
+		}()
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		b.mu.Lock()
+		quit := b.command(strings.TrimSpace(in.Text()))
+		if !quit {
+			b.render()
+		}
+		b.mu.Unlock()
+		if quit {
+			return nil
+		}
+	}
+	return in.Err()
+}
+
+// browser is the state of a browse session.
+type browser struct {
+	mu    sync.Mutex
+	src   *sources
+	out   io.Writer
+	clear bool
+
+	c      config.Config
+	values map[string]interface{}
+	layers map[string][]config.Layer
+	loaded time.Time
+	err    error
+
+	// cwd is the path of the group being browsed, and key the key whose
+	// layers are shown.
+	cwd []string
+	key string
+}
+
+// reload re-reads the config and the provenance of its keys.
+func (b *browser) reload() error {
+	c, err := b.src.load()
+	if err != nil {
+		return err
+	}
+	layers, err := b.src.chain().Provenance()
+	if err != nil {
+		return err
+	}
+	b.c, b.values, b.layers, b.loaded = c, c.Redact(), layers, time.Now()
+	return nil
+}
+
+// reloadChanged reloads the config, returning whether anything changed.
+// Failures are shown, while the previous config is kept.
+func (b *browser) reloadChanged() bool {
+	values, layers, prev := b.values, b.layers, b.err
+	b.err = b.reload()
+	if b.err != nil {
+		return prev == nil || b.err.Error() != prev.Error()
+	}
+	return prev != nil || !reflect.DeepEqual(values, b.values) || !reflect.DeepEqual(layers, b.layers)
+}
+
+// command runs the command `cmd`, returning whether to quit.
+func (b *browser) command(cmd string) bool {
+	b.key = ""
+	switch cmd {
+	case "":
+	case "q", "quit":
+		return true
+	case "r", "reload":
+		b.reloadChanged()
+	case "..":
+		if len(b.cwd) > 0 {
+			b.cwd = b.cwd[:len(b.cwd)-1]
+		}
+	case "/":
+		b.cwd = nil
+	default:
+		path := append(append([]string(nil), b.cwd...), strings.Split(strings.Trim(cmd, "."), ".")...)
+		switch v := lookup(b.values, path); v.(type) {
+		case map[string]interface{}:
+			b.cwd = path
+		case nil:
+			b.err = fmt.Errorf("%s not found", strings.Join(path, "."))
+		default:
+			b.key = strings.Join(path, ".")
+		}
+	}
+	return false
+}
+
+func (b *browser) render() {
+	w := b.out
+	if b.clear {
+		fmt.Fprint(w, "\033[H\033[2J")
+	}
+	fmt.Fprintf(w, "/%s (loaded %s)\n", strings.Join(b.cwd, "."), b.loaded.Format("15:04:05"))
+	if b.err != nil {
+		fmt.Fprintf(w, "error: %s\n", b.err)
+		b.err = nil
+	}
+	g, _ := lookup(b.values, b.cwd).(map[string]interface{})
+	names := make([]string, 0, len(g))
+	for name := range g {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := strings.Join(append(append([]string(nil), b.cwd...), name), ".")
+		if sub, ok := g[name].(map[string]interface{}); ok {
+			fmt.Fprintf(w, "  %s/ (%d)\n", name, len(sub))
+			continue
+		}
+		fmt.Fprintf(w, "  %s = %s  [%s]\n", name, format(g[name]), b.source(path))
+	}
+	if b.key != "" {
+		fmt.Fprintf(w, "\n%s:\n", b.key)
+		for i, l := range b.layers[b.key] {
+			v := l.Value
+			if b.c.IsSensitive(b.key) {
+				v = config.Redacted
+			}
+			mark := " "
+			if i == 0 {
+				mark = "*"
+			}
+			fmt.Fprintf(w, " %s %s = %s\n", mark, l.Source, format(v))
+		}
+	}
+	fmt.Fprintln(w, "\nenter a group or key, .. up, / root, r reload, q quit")
+}
+
+// source describes where the value at `path` came from.
+func (b *browser) source(path string) string {
+	layers := b.layers[path]
+	switch len(layers) {
+	case 0:
+		return "computed"
+	case 1:
+		return layers[0].Source
+	}
+	return fmt.Sprintf("%s, overrides %d", layers[0].Source, len(layers)-1)
+}
+
+// lookup returns the value at `path` within `m`, nil when missing.
+func lookup(m map[string]interface{}, path []string) interface{} {
+	var v interface{} = m
+	for _, key := range path {
+		g, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = g[key]
+	}
+	return v
+}
+
+func format(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,51 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runExec loads the config, exports it as environment variables (see
+// config.ExportEnv), and replaces this process with the given command.
+func runExec(args []string) error {
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	src := sourceFlags(fs)
+	prefix := fs.String("prefix", "", "prefix the exported variable names with `prefix`")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("missing command")
+	}
+
+	c, err := src.load()
+	if err != nil {
+		return err
+	}
+	path, err := exec.LookPath(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return execve(path, fs.Args(), mergeEnv(os.Environ(), c.ExportEnv(*prefix)))
+}
+
+// mergeEnv returns `env` with the variables of `overrides` replacing, or
+// added to, those of the same name.
+func mergeEnv(env, overrides []string) []string {
+	seen := make(map[string]bool, len(overrides))
+	for _, kv := range overrides {
+		seen[kv[:strings.IndexByte(kv, '=')]] = true
+	}
+	merged := make([]string, 0, len(env)+len(overrides))
+	for _, kv := range env {
+		if i := strings.IndexByte(kv, '='); i < 0 || !seen[kv[:i]] {
+			merged = append(merged, kv)
+		}
+	}
+	return append(merged, overrides...)
+}
@@ -0,0 +1,13 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package main
+
+import "syscall"
+
+func execve(path string, args, env []string) error {
+	return syscall.Exec(path, args, env)
+}
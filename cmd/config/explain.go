@@ -0,0 +1,131 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"code.minty.io/config"
+)
+
+// explanation is the effective value of a key, and every source defining it.
+type explanation struct {
+	Path   string         `json:"path"`
+	Value  interface{}    `json:"value"`
+	Type   string         `json:"type"`
+	Layers []explainLayer `json:"layers"`
+}
+
+type explainLayer struct {
+	config.Layer
+	Won bool `json:"won"`
+}
+
+// runExplain loads the config and prints the value of the key at the given
+// path, its type, and every source defining it, and where when known,
+// marking the one in effect.
+// Values of sensitive groups are redacted.
+func runExplain(args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	src := sourceFlags(fs)
+	asJSON := fs.Bool("json", false, "print the explanation as JSON")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("expected a single key path, eg. server.port")
+	}
+	path := fs.Arg(0)
+
+	c, err := src.load()
+	if err != nil {
+		return err
+	}
+	layers, err := src.chain().Provenance()
+	if err != nil {
+		return err
+	}
+	v := lookup(c.Redact(), strings.Split(path, "."))
+	if v == nil {
+		// Computed values aren't within the dump, see config.Provide.
+		v = computed(c, path)
+	}
+	if v == nil {
+		return fmt.Errorf("%s not found", path)
+	}
+	e := explanation{Path: path, Value: v, Type: typeName(v), Layers: []explainLayer{}}
+	for i, l := range layers[path] {
+		if c.IsSensitive(path) {
+			l.Value = config.Redacted
+		}
+		e.Layers = append(e.Layers, explainLayer{l, i == 0})
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(e)
+	}
+	fmt.Printf("%s = %s (%s)\n", e.Path, format(e.Value), e.Type)
+	switch {
+	case e.Type == "group":
+		return nil
+	case len(e.Layers) == 0:
+		fmt.Println("  computed, not defined by a source")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, l := range e.Layers {
+		mark := " "
+		if l.Won {
+			mark = "*"
+		}
+		at := ""
+		if l.Position != nil {
+			at = "\t" + l.Position.String()
+		}
+		fmt.Fprintf(w, "  %s %s\t%s\t(%s)%s\n", mark, l.Source, format(l.Value), typeName(l.Value), at)
+	}
+	return w.Flush()
+}
+
+// typeName returns the JSON type of `v`.
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "group"
+	case []interface{}, []string:
+		return "list"
+	}
+	return "number"
+}
+
+// computed returns the value at `path` within `c` read through the accessors,
+// redacted when sensitive, nil when missing.
+func computed(c config.Config, path string) interface{} {
+	keys := strings.Split(path, ".")
+	g := c
+	for _, key := range keys[:len(keys)-1] {
+		g = g.Group(key)
+	}
+	v, ok := g.Val(keys[len(keys)-1])
+	if !ok {
+		return nil
+	}
+	if c.IsSensitive(path) {
+		return config.Redacted
+	}
+	return v
+}
@@ -0,0 +1,68 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runKeys loads the config and prints the paths of its keys, one per line,
+// or, with -format, a shell completion script completing them.
+func runKeys(args []string) error {
+	fs := flag.NewFlagSet("keys", flag.ExitOnError)
+	src := sourceFlags(fs)
+	prefix := fs.String("prefix", "", "only print paths starting with `prefix`")
+	format := fs.String("format", "", "print a completion script for `shell` (bash, zsh, or fish)")
+	fs.Parse(args)
+
+	if *format != "" {
+		script, ok := completions[*format]
+		if !ok {
+			return fmt.Errorf("unknown completion format %q", *format)
+		}
+		_, err := fmt.Fprintf(os.Stdout, script, strings.Join(names, " "))
+		return err
+	}
+	c, err := src.load()
+	if err != nil {
+		return err
+	}
+	for _, path := range c.CompleteKeys(*prefix) {
+		fmt.Println(path)
+	}
+	return nil
+}
+
+// completions are the completion scripts, by shell, completing the command
+// name and then config paths (via `config keys -prefix`), formatted with the
+// space separated command names.
+var completions = map[string]string{
+	"bash": `_config() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -W "$(config keys -prefix "$cur" 2>/dev/null)" -- "$cur"))
+	fi
+}
+complete -o default -F _config config
+`,
+	"zsh": `#compdef config
+_config() {
+	if (( CURRENT == 2 )); then
+		compadd -- %s
+	else
+		compadd -- ${(f)"$(config keys -prefix "$PREFIX" 2>/dev/null)"}
+	fi
+}
+compdef _config config
+`,
+	"fish": `complete -c config -f -n "__fish_use_subcommand" -a "%s"
+complete -c config -f -n "not __fish_use_subcommand" -a "(config keys -prefix (commandline -ct) 2>/dev/null)"
+`,
+}
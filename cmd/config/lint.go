@@ -0,0 +1,185 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"code.minty.io/config"
+)
+
+// runLint loads the config and checks it, and the layers defining each key,
+// against the lint rules (see config.RegisterLintRule), configured by the
+// JSON document at -rules (see config.LintConfig). Findings are printed as
+// text, JSON, or SARIF, eg. for code scanning within CI, and any error fails
+// the command.
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	src := sourceFlags(fs)
+	rules := fs.String("rules", "", "configure the rules per the JSON document at `path`")
+	format := fs.String("format", "text", "print the findings as `format`: text, json, or sarif")
+	fs.Parse(args)
+
+	var lc config.LintConfig
+	if *rules != "" {
+		b, err := ioutil.ReadFile(*rules)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(b, &lc); err != nil {
+			return fmt.Errorf("invalid rules %s: %w", *rules, err)
+		}
+	}
+	findings, err := src.chain().Lint(lc)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "text":
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+	case "json":
+		if findings == nil {
+			findings = []config.Finding{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		err = enc.Encode(findings)
+	case "sarif":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		err = enc.Encode(sarif(findings))
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+	errs := 0
+	for _, f := range findings {
+		if f.Severity == config.SeverityError {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d errors", errs)
+	}
+	return nil
+}
+
+// sarifLog is a SARIF 2.1.0 log, of the parts used to report findings.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name  string      `json:"name"`
+			Rules []sarifRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysical `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogical `json:"logicalLocations"`
+}
+
+type sarifPhysical struct {
+	ArtifactLocation struct {
+		URI string `json:"uri"`
+	} `json:"artifactLocation"`
+	Region *sarifRegion `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+type sarifLogical struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+func sarif(findings []config.Finding) sarifLog {
+	var run sarifRun
+	run.Tool.Driver.Name = "config lint"
+	rules := config.LintRules()
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{name, sarifMessage{rules[name].Description}})
+	}
+	run.Results = []sarifResult{}
+	for _, f := range findings {
+		loc := sarifLocation{LogicalLocations: []sarifLogical{{f.Path, "member"}}}
+		switch file := strings.TrimPrefix(f.Source, "file "); {
+		case f.Position != nil && f.Position.File != "":
+			p := new(sarifPhysical)
+			p.ArtifactLocation.URI = fileURI(f.Position.File)
+			p.Region = &sarifRegion{f.Position.Line, f.Position.Column}
+			loc.PhysicalLocation = p
+		case file != f.Source:
+			loc.PhysicalLocation = new(sarifPhysical)
+			loc.PhysicalLocation.ArtifactLocation.URI = fileURI(file)
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    f.Rule,
+			Level:     string(f.Severity),
+			Message:   sarifMessage{f.Message},
+			Locations: []sarifLocation{loc},
+		})
+	}
+	return sarifLog{"https://json.schemastore.org/sarif-2.1.0.json", "2.1.0", []sarifRun{run}}
+}
+
+// fileURI returns the URI of the file at `path`: a file URI when absolute,
+// otherwise relative to where the command ran, as code scanning expects of
+// paths within the repository.
+func fileURI(path string) string {
+	u := url.URL{Path: filepath.ToSlash(path)}
+	if filepath.IsAbs(path) {
+		u.Scheme = "file"
+		if !strings.HasPrefix(u.Path, "/") {
+			// Windows paths, eg. file:///C:/config.json.
+			u.Path = "/" + u.Path
+		}
+	}
+	return u.String()
+}
@@ -0,0 +1,133 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command config inspects and uses configuration read by the config package.
+//
+// Usage:
+//
+//	config <command> [flags] [args]
+//
+// The commands are:
+//
+//	browse   interactively browse the config, and where each value came from
+//	exec     run a command with the config exported as environment variables
+//	explain  print a key's value, and every source defining it
+//	keys     print the paths of the config's keys, or a shell completion script
+//	lint     check the config against lint rules, printing text, JSON, or SARIF
+//	render   print the config as a service would load it, without applying it
+//
+// Every command loads the config from the same sources, in priority order:
+//
+//	-env PREFIX   environment variables starting with PREFIX (see config.Env)
+//	-remote URL   the config served at URL
+//	-file PATH    the config file at PATH
+//
+// When none are given the config is read as it would be by a program using
+// the config package (see config.Read).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"code.minty.io/config"
+	// Resolvers of secret references, see render.
+	_ "code.minty.io/config/cloudsecrets"
+	_ "code.minty.io/config/keyring"
+	_ "code.minty.io/config/passwords"
+)
+
+type command struct {
+	run   func(args []string) error
+	usage string
+}
+
+var commands = map[string]command{
+	"browse":  {runBrowse, "browse [flags] [-interval duration]"},
+	"exec":    {runExec, "exec [flags] -- command [args...]"},
+	"explain": {runExplain, "explain [flags] [-json] path"},
+	"keys":    {runKeys, "keys [flags] [-prefix prefix] [-format bash|zsh|fish]"},
+	"lint":    {runLint, "lint [flags] [-rules path] [-format text|json|sarif]"},
+	"render":  {runRender, "render [flags] [-environment name] [-set key=value...] [-resolve|-stub-secrets] [-sensitive]"},
+}
+
+// names are the sorted names of the commands, set by main, as the commands
+// referring to them (eg. for completion, see runKeys) would otherwise be an
+// initialization cycle.
+var names []string
+
+func main() {
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "config %s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: config <command> [flags] [args]")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\tconfig %s\n", commands[name].usage)
+	}
+	os.Exit(2)
+}
+
+// sources holds the flags selecting where the config is loaded from.
+type sources struct {
+	env, remote, file string
+}
+
+func sourceFlags(fs *flag.FlagSet) *sources {
+	s := new(sources)
+	fs.StringVar(&s.env, "env", "", "read environment variables starting with `prefix`")
+	fs.StringVar(&s.remote, "remote", "", "read the config served at `url`")
+	fs.StringVar(&s.file, "file", "", "read the config file at `path`")
+	return s
+}
+
+// load reads, and merges, the config from the selected sources.
+func (s *sources) load() (config.Config, error) {
+	if s.env == "" && s.remote == "" && s.file == "" {
+		return config.Read()
+	}
+	return s.chain().Read()
+}
+
+// chain returns the chain of the selected sources, or of a source reading
+// the config as config.Read does when none are.
+func (s *sources) chain() *config.Chain {
+	if s.env == "" && s.remote == "" && s.file == "" {
+		return config.NewChain(readSource{})
+	}
+	var chain []config.Source
+	if s.env != "" {
+		chain = append(chain, config.Env(s.env))
+	}
+	if s.remote != "" {
+		chain = append(chain, config.Remote(s.remote))
+	}
+	if s.file != "" {
+		chain = append(chain, config.File(s.file))
+	}
+	return config.NewChain(chain...)
+}
+
+// readSource reads the config as config.Read does.
+type readSource struct{}
+
+func (readSource) Read() (config.Config, error) { return config.Read() }
+
+func (readSource) Name() string { return "file " + config.ConfigFile() }
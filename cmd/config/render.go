@@ -0,0 +1,120 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"code.minty.io/config"
+)
+
+// setFlags collects repeated `-set key=value` flags.
+type setFlags []string
+
+func (s *setFlags) String() string { return strings.Join(*s, ", ") }
+
+func (s *setFlags) Set(v string) error {
+	if i := strings.IndexByte(v, '='); i <= 0 {
+		return fmt.Errorf("expected key=value, got %q", v)
+	}
+	*s = append(*s, v)
+	return nil
+}
+
+// runRender loads the config as a service would, with every layer (and the
+// `$when` conditions and host overrides) applied, sets any -set values over
+// it, and prints the resulting document without installing it anywhere.
+// With -resolve, secret references are resolved (see config.RegisterResolver),
+// or with -stub-secrets replaced by stubs, eg. `[op://vault/db/password]`,
+// so the config renders without access to the secret stores. Values of
+// sensitive groups, and resolved secrets, are redacted, unless -sensitive is
+// set.
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	src := sourceFlags(fs)
+	environment := fs.String("environment", "", "render for the `name`d ENVIRONMENT, eg. prod (see config.ConfigFile)")
+	sensitive := fs.Bool("sensitive", false, "print the values of sensitive groups, rather than redacting them")
+	resolve := fs.Bool("resolve", false, "resolve secret references")
+	stub := fs.Bool("stub-secrets", false, "resolve secret references to stubs, rather than their values (implies -resolve)")
+	var sets setFlags
+	fs.Var(&sets, "set", "set the `key=value`, eg. server.port=9090, over the config (repeatable)")
+	fs.Parse(args)
+
+	if *environment != "" {
+		// The file names, and `$when` conditions, are chosen by it.
+		os.Setenv("ENVIRONMENT", *environment)
+	}
+	c, err := src.load()
+	if err != nil {
+		return err
+	}
+	if len(sets) > 0 {
+		pairs := make([]interface{}, 0, 2*len(sets))
+		for _, kv := range sets {
+			i := strings.IndexByte(kv, '=')
+			pairs = append(pairs, kv[:i], parseValue(kv[i+1:]))
+		}
+		p, err := config.FromPairs(pairs...)
+		if err != nil {
+			return err
+		}
+		patch, _ := json.Marshal(p.Map())
+		if c, err = c.Patch(patch, config.MergePatch); err != nil {
+			return err
+		}
+	}
+	if *stub {
+		for _, scheme := range config.ResolverSchemes() {
+			scheme := scheme
+			config.RegisterResolver(scheme, config.ResolverFunc(func(ctx context.Context, ref string) (config.Lease, error) {
+				return config.Lease{Value: "[" + scheme + ":" + ref + "]"}, nil
+			}))
+		}
+	}
+	if *resolve || *stub {
+		if c, err = c.Resolve(context.Background()); err != nil {
+			return err
+		}
+	}
+	if *sensitive {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(reveal(c.Map()))
+	}
+	return c.Dump(os.Stdout)
+}
+
+// reveal returns `v` with the values of its secrets, which encode as
+// config.Redacted.
+func reveal(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = reveal(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = reveal(val)
+		}
+	case *config.SecretString:
+		return v.Reveal()
+	}
+	return v
+}
+
+// parseValue parses `s` as JSON, eg. numbers, bools, and lists, falling back
+// to the string itself.
+func parseValue(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	return v
+}
@@ -0,0 +1,129 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cobra binds the pflags of cobra commands to config keys, both
+// ways: flags that were set override the config, and config values become
+// the defaults of flags that weren't, eg.
+//
+//	root := &cobra.Command{
+//		Use: "app",
+//		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+//			return cobraconfig.Bind(cmd, config.Env("APP_"), config.File("config.json"))
+//		},
+//	}
+//	root.PersistentFlags().Int("server.port", 8080, "port to listen on")
+//
+// Flags are named by the dot separated path of their key, eg.
+// `--server.port=9090` is the `port` key within the `server` group.
+package cobra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"code.minty.io/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// FlagSource reads the configuration from the pflags that were set.
+type FlagSource struct {
+	fs *pflag.FlagSet
+}
+
+// Flags returns a source of the flags that were set within `fs` (after it was
+// parsed), see config.Flags. List flags are read as lists, and other values
+// as strings, which are coerced on access.
+func Flags(fs *pflag.FlagSet) *FlagSource {
+	return &FlagSource{fs}
+}
+
+func (s *FlagSource) Read() (config.Config, error) {
+	var pairs []interface{}
+	s.fs.Visit(func(f *pflag.Flag) {
+		var v interface{} = f.Value.String()
+		if l, ok := f.Value.(pflag.SliceValue); ok {
+			v = l.GetSlice()
+		}
+		pairs = append(pairs, f.Name, v)
+	})
+	c, err := config.FromPairs(pairs...)
+	if err != nil {
+		return c, err
+	}
+	return c.Coerce(), nil
+}
+
+// SetDefaults sets the value, and default, of every flag within `fs` that
+// wasn't set to the value of its key within `c`, so help output and flag
+// lookups reflect the config.
+func SetDefaults(fs *pflag.FlagSet, c config.Config) error {
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+		v, ok := lookup(c, f.Name)
+		if !ok {
+			return
+		}
+		if l, isList := v.([]interface{}); isList {
+			if sv, isSlice := f.Value.(pflag.SliceValue); isSlice {
+				s := make([]string, len(l))
+				for i, v := range l {
+					s[i] = flagValue(v)
+				}
+				if err = sv.Replace(s); err != nil {
+					err = fmt.Errorf("failed to set flag %s from config: %w", f.Name, err)
+				}
+				f.DefValue = f.Value.String()
+				return
+			}
+		}
+		if err = f.Value.Set(flagValue(v)); err != nil {
+			err = fmt.Errorf("failed to set flag %s from config: %w", f.Name, err)
+			return
+		}
+		f.DefValue = f.Value.String()
+	})
+	return err
+}
+
+// Bind loads the flags that were set within `cmd` (including those inherited
+// from its parents), layered over `sources`, as the global config, then sets
+// the defaults of the remaining flags from it, see SetDefaults. It's meant to
+// be called from a command's PersistentPreRunE.
+func Bind(cmd *cobra.Command, sources ...config.Source) error {
+	fs := cmd.Flags()
+	chain := config.NewChain(append([]config.Source{Flags(fs)}, sources...)...)
+	if err := config.Load(chain); err != nil {
+		return err
+	}
+	return SetDefaults(fs, config.Current())
+}
+
+// lookup returns the value at the dot separated `path` within `c`.
+// flagValue returns the flag value of `v`, numbers without exponents, eg.
+// 10000000 rather than 1e+07, which int flags reject.
+func flagValue(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	if s, err := config.CoerceString(v); err == nil {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func lookup(c config.Config, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	for _, g := range keys[:len(keys)-1] {
+		c = c.Group(g)
+	}
+	return c.Val(keys[len(keys)-1])
+}
@@ -0,0 +1,209 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CoerceError is returned when a value can't be coerced to a type.
+type CoerceError struct {
+	Value interface{}
+	To    string
+}
+
+func (e *CoerceError) Error() string {
+	return fmt.Sprintf("cannot coerce %T %v to %s", e.Value, e.Value, e.To)
+}
+
+// The coercions of values, as used by the accessors (strings only being
+// coerced by a coercing config, see Config.Coerce), are:
+//
+//	from \ to   int                    float64              bool              string
+//	integers    as is, when in range   exact                -                 decimal
+//	float64     when whole, in range   as is                -                 shortest repr
+//	bool        -                      -                    as is             true, false
+//	string      strconv.Atoi           strconv.ParseFloat   strconv.ParseBool as is
+//	                                                        (ParseBool, see LenientBools)
+//
+// Integers are any of Go's integer types, as set by SetConfig, or flags.
+// float32 coerces as float64, and json.Number as a string. Strings are
+// trimmed of spaces first. Floats are never truncated, so 1.5 isn't an int.
+
+// CoerceInt returns `v` as an int, see the coercion table.
+func CoerceInt(v interface{}) (int, error) {
+	switch x := v.(type) {
+	case int:
+		return x, nil
+	case int8:
+		return int(x), nil
+	case int16:
+		return int(x), nil
+	case int32:
+		return int(x), nil
+	case int64:
+		if x < math.MinInt || x > math.MaxInt {
+			break
+		}
+		return int(x), nil
+	case uint:
+		if x > math.MaxInt {
+			break
+		}
+		return int(x), nil
+	case uint8:
+		return int(x), nil
+	case uint16:
+		return int(x), nil
+	case uint32:
+		if uint64(x) > math.MaxInt {
+			break
+		}
+		return int(x), nil
+	case uint64:
+		if x > math.MaxInt {
+			break
+		}
+		return int(x), nil
+	case float32:
+		return CoerceInt(float64(x))
+	case float64:
+		// MaxInt isn't exactly representable, -MinInt is the exclusive bound.
+		if x != math.Trunc(x) || x < math.MinInt || x >= -math.MinInt {
+			break
+		}
+		return int(x), nil
+	case json.Number:
+		return CoerceInt(string(x))
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(x)); err == nil {
+			return i, nil
+		}
+	}
+	return 0, &CoerceError{v, "int"}
+}
+
+// CoerceFloat64 returns `v` as a float64, see the coercion table.
+func CoerceFloat64(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case json.Number:
+		return CoerceFloat64(string(x))
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(x), 64); err == nil {
+			return f, nil
+		}
+	case bool, nil:
+	default:
+		if i, err := CoerceInt(v); err == nil {
+			return float64(i), nil
+		}
+	}
+	return 0, &CoerceError{v, "float64"}
+}
+
+// LenientBools returns the config coercing strings to bools via ParseBool,
+// rather than strconv.ParseBool, so the `yes`/`no` and `on`/`off` forms
+// common to environment variables, and key/value stores, are accepted too.
+// Strings are only coerced by a coercing config, see Coerce.
+func (c Config) LenientBools() Config {
+	c = c.with(c.m)
+	c.lenientBools = true
+	return c
+}
+
+// SetLenientBools sets whether the current config coerces strings to bools
+// via ParseBool, see Config.LenientBools, logging the failure once frozen, see
+// TrySetLenientBools.
+func SetLenientBools(lenient bool) {
+	if err := TrySetLenientBools(lenient); err != nil {
+		log.Printf("config: failed to set lenient bools: %s", err)
+	}
+}
+
+// TrySetLenientBools sets whether the current config coerces strings to bools
+// via ParseBool, failing once frozen, see Freeze.
+func TrySetLenientBools(lenient bool) error {
+	return setLenientBools(nil, lenient)
+}
+
+func setLenientBools(a *Admin, lenient bool) error {
+	return update(a, "coerce", func() error {
+		cfg.lenientBools = lenient
+		return nil
+	})
+}
+
+// CoerceBool returns `v` as a bool, see the coercion table.
+func CoerceBool(v interface{}) (bool, error) {
+	return coerceBool(v, false)
+}
+
+// coerceBool returns `v` as a bool, strings via ParseBool when `lenient`.
+func coerceBool(v interface{}, lenient bool) (bool, error) {
+	switch x := v.(type) {
+	case bool:
+		return x, nil
+	case string:
+		parse := strconv.ParseBool
+		if lenient {
+			parse = ParseBool
+		}
+		if b, err := parse(strings.TrimSpace(x)); err == nil {
+			return b, nil
+		}
+	}
+	return false, &CoerceError{v, "bool"}
+}
+
+// ParseBool returns the bool of `s`, case-insensitively, which is true for
+// `1`, `t`, `true`, `y`, `yes`, and `on`, and false for `0`, `f`, `false`,
+// `n`, `no`, and `off`. Any other string is an error.
+func ParseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, nil
+	case "0", "f", "false", "n", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid bool %q", s)
+}
+
+// CoerceString returns `v` as a string, see the coercion table.
+func CoerceString(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case *SecretString:
+		if x != nil {
+			return x.Reveal(), nil
+		}
+	case json.Number:
+		return string(x), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32), nil
+	case nil:
+	default:
+		if i, err := CoerceInt(v); err == nil {
+			return strconv.Itoa(i), nil
+		}
+		if u, ok := v.(uint64); ok {
+			return strconv.FormatUint(u, 10), nil
+		}
+	}
+	return "", &CoerceError{v, "string"}
+}
@@ -0,0 +1,305 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"testing/quick"
+)
+
+func TestCoerceTable(t *testing.T) {
+	tests := []struct {
+		v     interface{}
+		i     interface{}
+		f     interface{}
+		b     interface{}
+		s     interface{}
+		label string
+	}{
+		{42, 42, 42.0, nil, "42", "int"},
+		{int64(-7), -7, -7.0, nil, "-7", "int64"},
+		{uint8(255), 255, 255.0, nil, "255", "uint8"},
+		{uint64(math.MaxUint64), nil, nil, nil, "18446744073709551615", "uint64 overflow"},
+		{3.0, 3, 3.0, nil, "3", "whole float"},
+		{1.5, nil, 1.5, nil, "1.5", "fractional float"},
+		{1e300, nil, 1e300, nil, "1e+300", "huge float"},
+		{float32(0.5), nil, 0.5, nil, "0.5", "float32"},
+		{json.Number("12"), 12, 12.0, nil, "12", "json.Number"},
+		{true, nil, nil, true, "true", "bool"},
+		{" 12 ", 12, 12.0, nil, " 12 ", "padded string"},
+		{"1.25", nil, 1.25, nil, "1.25", "float string"},
+		{"false", nil, nil, false, "false", "bool string"},
+		{"x", nil, nil, nil, "x", "string"},
+		{nil, nil, nil, nil, nil, "nil"},
+		{[]interface{}{1}, nil, nil, nil, nil, "list"},
+	}
+	for _, test := range tests {
+		for _, c := range []struct {
+			to   string
+			want interface{}
+			fn   func(interface{}) (interface{}, error)
+		}{
+			{"int", test.i, func(v interface{}) (interface{}, error) { return CoerceInt(v) }},
+			{"float64", test.f, func(v interface{}) (interface{}, error) { return CoerceFloat64(v) }},
+			{"bool", test.b, func(v interface{}) (interface{}, error) { return CoerceBool(v) }},
+			{"string", test.s, func(v interface{}) (interface{}, error) { return CoerceString(v) }},
+		} {
+			got, err := c.fn(test.v)
+			if c.want == nil {
+				if err == nil {
+					t.Errorf("%s: coercing to %s = %v, want error", test.label, c.to, got)
+				}
+				continue
+			}
+			if err != nil || got != c.want {
+				t.Errorf("%s: coercing to %s = %v, %v, want %v", test.label, c.to, got, err, c.want)
+			}
+		}
+	}
+}
+
+func TestCoerceIntProperties(t *testing.T) {
+	// Ints survive every coercion, and their string forms.
+	ints := func(i int) bool {
+		s, _ := CoerceString(i)
+		a, errA := CoerceInt(i)
+		b, errB := CoerceInt(s)
+		c, errC := CoerceInt(" " + strconv.Itoa(i) + "\t")
+		return errA == nil && errB == nil && errC == nil && a == i && b == i && c == i
+	}
+	if err := quick.Check(ints, nil); err != nil {
+		t.Error(err)
+	}
+	// Floats coerce to ints only when whole, and never lose their value.
+	floats := func(f float64) bool {
+		i, err := CoerceInt(f)
+		if f != math.Trunc(f) || math.Abs(f) >= math.MaxInt64 {
+			return err != nil
+		}
+		return err == nil && float64(i) == f
+	}
+	if err := quick.Check(floats, nil); err != nil {
+		t.Error(err)
+	}
+	fractions := func(i int32, frac uint16) bool {
+		f := float64(i) + (float64(frac)+1)/(math.MaxUint16+2)
+		_, err := CoerceInt(f)
+		return err != nil
+	}
+	if err := quick.Check(fractions, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCoerceFloatAndBoolProperties(t *testing.T) {
+	floats := func(f float64) bool {
+		s, err := CoerceString(f)
+		if err != nil {
+			return false
+		}
+		g, err := CoerceFloat64(s)
+		return err == nil && g == f
+	}
+	if err := quick.Check(floats, nil); err != nil {
+		t.Error(err)
+	}
+	bools := func(b bool) bool {
+		s, _ := CoerceString(b)
+		got, err := CoerceBool(s)
+		return err == nil && got == b
+	}
+	if err := quick.Check(bools, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAccessorsDontTruncate(t *testing.T) {
+	c := FromMap(map[string]interface{}{"whole": 2.0, "frac": 2.5, "s": "3"})
+	if i, ok := c.Int("whole"); !ok || i != 2 {
+		t.Errorf("Int(whole) = %d, %t, want 2, true", i, ok)
+	}
+	if i, ok := c.Int("frac"); ok {
+		t.Errorf("Int(frac) = %d, want not ok", i)
+	}
+	if _, ok := c.Int("s"); ok {
+		t.Error("Int(s) is ok without coercion")
+	}
+	if i, ok := c.Coerce().Int("s"); !ok || i != 3 {
+		t.Errorf("Coerce().Int(s) = %d, %t, want 3, true", i, ok)
+	}
+}
+
+func TestSecretStrings(t *testing.T) {
+	c := FromMap(map[string]interface{}{"password": NewSecretString("hunter2")})
+	if s, err := CoerceString(NewSecretString("hunter2")); err != nil || s != "hunter2" {
+		t.Errorf("CoerceString = %q, %v, want hunter2", s, err)
+	}
+	if s, ok := c.String("password"); !ok || s != "hunter2" {
+		t.Errorf("String(password) = %q, %t, want hunter2", s, ok)
+	}
+	var v struct {
+		Password string
+		Secret   *SecretString `config:"password"`
+	}
+	if err := c.Bind(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Password != "hunter2" || v.Secret == nil || v.Secret.Reveal() != "hunter2" {
+		t.Errorf("bound %q and %v, want hunter2", v.Password, v.Secret)
+	}
+
+	if env := c.ExportEnv("APP_"); len(env) != 1 || env[0] != "APP_PASSWORD=hunter2" {
+		t.Errorf("exported %v, want the secret revealed", env)
+	}
+	// Saving doesn't overwrite the stored secret with Redacted.
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"password": "hunter2"}`), 0600)
+	if err := c.SaveAs(path); err == nil {
+		t.Error("saved a secret")
+	}
+	if b, _ := os.ReadFile(path); string(b) != `{"password": "hunter2"}` {
+		t.Errorf("saving a secret wrote %s", b)
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	for _, s := range []string{"1", "t", "T", "true", "TRUE", "True", "y", "yes", "YES", "on", "On"} {
+		if b, err := ParseBool(s); err != nil || !b {
+			t.Errorf("ParseBool(%q) = %t, %v, want true", s, b, err)
+		}
+	}
+	for _, s := range []string{"0", "f", "F", "false", "FALSE", "n", "no", "No", "off", "OFF"} {
+		if b, err := ParseBool(s); err != nil || b {
+			t.Errorf("ParseBool(%q) = %t, %v, want false", s, b, err)
+		}
+	}
+	for _, s := range []string{"", "2", "yep", "enabled", " yes"} {
+		if _, err := ParseBool(s); err == nil {
+			t.Errorf("ParseBool(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestLenientBools(t *testing.T) {
+	c := FromMap(map[string]interface{}{"a": "yes", "b": "off", "c": "true"}).Coerce()
+	if _, ok := c.Bool("a"); ok {
+		t.Error(`Bool("a") of "yes" is ok, without LenientBools`)
+	}
+	if b, ok := c.Bool("c"); !ok || !b {
+		t.Errorf(`Bool("c") = %t, %t, want true, true`, b, ok)
+	}
+	lenient := c.LenientBools()
+	if _, ok := c.Bool("a"); ok {
+		t.Error(`Bool("a") of "yes" is ok, for the config LenientBools was called on`)
+	}
+	if b, ok := lenient.Bool("a"); !ok || !b {
+		t.Errorf(`Bool("a") = %t, %t, want true, true`, b, ok)
+	}
+	if b, ok := lenient.Bool("b"); !ok || b {
+		t.Errorf(`Bool("b") = %t, %t, want false, true`, b, ok)
+	}
+	var v struct{ A, B, C bool }
+	if err := lenient.Bind(&v); err != nil || !v.A || v.B || !v.C {
+		t.Errorf("bound %+v, %v, want {A:true B:false C:true}", v, err)
+	}
+	if _, ok := FromMap(map[string]interface{}{"a": "yes"}).LenientBools().Bool("a"); ok {
+		t.Error(`Bool("a") of "yes" is ok without coercion`)
+	}
+
+	// Setting the current config's is a change, as any other.
+	f := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(f, []byte(`{"a": "yes"}`), 0600)
+	a := openTest(t, File(f))
+	defer func() {
+		cfg.mu.Lock()
+		defer cfg.mu.Unlock()
+		cfg.lenientBools = false
+	}()
+	if err := TrySetLenientBools(true); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("TrySetLenientBools under an Admin: %v, want %v", err, ErrReadOnly)
+	}
+	var watched int
+	stop := Watch(func(Config) { watched++ })
+	defer stop()
+	if err := a.SetCoerce(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetLenientBools(true); err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := Bool("a"); !ok || !b || watched != 2 {
+		t.Errorf(`Bool("a") = %t, %t after %d changes, want true, true after 2`, b, ok, watched)
+	}
+}
+
+func TestMissingDefaults(t *testing.T) {
+	c := FromMap(map[string]interface{}{"n": -1.0, "s": "x", "g": map[string]interface{}{}})
+	if i, ok := c.Int("n"); !ok || i != -1 {
+		t.Errorf(`Int("n") = %d, %t, want -1, true`, i, ok)
+	}
+	if i, ok := c.Int("missing"); ok || i != 0 {
+		t.Errorf(`Int("missing") = %d, %t, want 0, false`, i, ok)
+	}
+	if f, ok := c.Float64("missing"); ok || f != 0 {
+		t.Errorf(`Float64("missing") = %g, %t, want 0, false`, f, ok)
+	}
+	c = c.WithMissingInt(math.MinInt64).WithMissingFloat64(math.Inf(-1))
+	for _, key := range []string{"missing", "s"} {
+		if i, _ := c.Int(key); i != math.MinInt64 {
+			t.Errorf("Int(%q) = %d, want %d", key, i, math.MinInt64)
+		}
+		if f, _ := c.Float64(key); !math.IsInf(f, -1) {
+			t.Errorf("Float64(%q) = %g, want -Inf", key, f)
+		}
+	}
+	for _, group := range []string{"g", "missing"} {
+		if i, _ := c.GroupInt(group, "missing"); i != math.MinInt64 {
+			t.Errorf("GroupInt(%q) = %d, want %d", group, i, math.MinInt64)
+		}
+		if f, _ := c.GroupFloat64(group, "missing"); !math.IsInf(f, -1) {
+			t.Errorf("GroupFloat64(%q) = %g, want -Inf", group, f)
+		}
+	}
+	if i, _ := c.Group("g").Int("missing"); i != math.MinInt64 {
+		t.Errorf(`Group("g").Int("missing") = %d, want %d`, i, math.MinInt64)
+	}
+
+	// Setting the current config's is a change, as any other.
+	f := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(f, []byte(`{}`), 0600)
+	a := openTest(t, File(f))
+	defer func() {
+		cfg.mu.Lock()
+		defer cfg.mu.Unlock()
+		cfg.missingInt, cfg.missingFloat64 = 0, 0
+	}()
+	if err := TrySetMissingInt(-1); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("TrySetMissingInt under an Admin: %v, want %v", err, ErrReadOnly)
+	}
+	if err := TrySetMissingFloat64(-1); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("TrySetMissingFloat64 under an Admin: %v, want %v", err, ErrReadOnly)
+	}
+	var watched int
+	stop := Watch(func(Config) { watched++ })
+	defer stop()
+	if err := a.SetMissingInt(-1); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetMissingFloat64(-2); err != nil {
+		t.Fatal(err)
+	}
+	if i, _ := Int("missing"); i != -1 || watched != 2 {
+		t.Errorf(`Int("missing") = %d after %d changes, want -1 after 2`, i, watched)
+	}
+	if f, _ := Float64("missing"); f != -2 {
+		t.Errorf(`Float64("missing") = %g, want -2`, f)
+	}
+}
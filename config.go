@@ -6,17 +6,27 @@
 // By default it reads the file `config.json` during init. The name
 // of the file can be overriden, and the file can also be re-loaded
 // when necessary.
+// When `APP_CONFIG_FD` is set, the config is instead read from that inherited
+// file descriptor (eg. `0` for stdin), and when `APP_CONFIG_JSON` is set the
+// whole config can be provided within the environment.
 // All values are stored in memory and can be looked up, or overriden
-// to a different value. Changes are not persisted.
+// to a different value. Changes are only persisted by Save.
 package config
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -24,6 +34,25 @@ import (
 type Config struct {
 	mu sync.Mutex
 	m  map[string]interface{}
+	// root and kind hold documents whose root isn't an object.
+	root interface{}
+	kind Kind
+	// allowSensitive permits reading sensitive groups, see AllowSensitive.
+	allowSensitive bool
+	// coerce converts string values on access, see Coerce, and lenientBools
+	// strings to bools by ParseBool, see LenientBools.
+	coerce       bool
+	lenientBools bool
+	// missingInt and missingFloat64 are returned for missing, or invalid,
+	// numbers, see WithMissingInt and WithMissingFloat64.
+	missingInt     int
+	missingFloat64 float64
+	// path is the dot separated path of the group within its root config,
+	// for computed values, see Provide.
+	path string
+	// pos holds the positions of the keys, by their path within the root
+	// config, see Position.
+	pos map[string]Location
 }
 
 var cfg, _ = Read()
@@ -36,18 +65,222 @@ func ConfigFile() string {
 	return fmt.Sprintf("config.%s.json", env)
 }
 
-func ReadFrom(b []byte) (Config, error) {
+// ReadFrom returns a new Config from the JSON document `b`, whose size,
+// nesting, and number of keys are bounded by the Default limits unless
+// overridden by `opts`. The document may hold `//` and `/* */` comments
+// (JSONC), which SaveAs keeps. The top-level `overrides` group is reserved
+// for host-specific overrides, keyed by hostname pattern, eg.
+// `{"overrides": {"web-*": {"cache": {"size": 512}}}}`, which are merged over
+// the config of matching hosts, and then removed.
+func ReadFrom(b []byte, opts ...Option) (Config, error) {
+	return newOptions(opts).readFrom(b)
+}
+
+func (o *options) readFrom(b []byte) (Config, error) {
+	if err := o.check(b); err != nil {
+		return *new(Config), err
+	}
+	b = uncomment(b)
+	if o.strict {
+		if err := strictKeys(b); err != nil {
+			return *new(Config), err
+		}
+	}
 	var j interface{}
-	err := json.Unmarshal(b, &j)
+	err := decode(b, &j, o.numbers)
 	if err != nil {
 		return *new(Config), err
 	}
 	//return j.(map[string]interface{}), nil
-	m := j.(map[string]interface{})
-	return Config{sync.Mutex{}, m}, nil
+	m, ok := j.(map[string]interface{})
+	if !ok {
+		// Keep the document, so it's still available via Root.
+		k := kindOf(j)
+		return Config{root: j, kind: k}, &RootError{k}
+	}
+	c, err := o.build(m)
+	if err == nil {
+		c.pos = keyPositions(b)
+	}
+	return c, err
+}
+
+// build returns the Config of the decoded document `m`, once its `$when`
+// groups and host overrides are applied, and it's migrated.
+func (o *options) build(m map[string]interface{}) (Config, error) {
+	var err error
+	if m, err = when(m); err != nil {
+		return *new(Config), err
+	}
+	if m, err = overrides(m); err != nil {
+		return *new(Config), err
+	}
+	if _, versioned := m[VersionKey]; versioned || !o.partial {
+		if o.migrated, err = migrate(m); err != nil {
+			return *new(Config), err
+		}
+	}
+	if o.refs && !o.deferRefs {
+		if m, err = references(m); err != nil {
+			return *new(Config), err
+		}
+	}
+	return Config{m: m}, nil
+}
+
+// decode unmarshals the JSON document `b`, with numbers as json.Number when
+// `numbers` is set.
+func decode(b []byte, v interface{}, numbers bool) error {
+	if !numbers {
+		return json.Unmarshal(b, v)
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
 }
 
-func Read() (Config, error) {
+// uncomment returns the JSON document `b` with its `//` and `/* */` comments
+// blanked, keeping their newlines so the positions of keys are unchanged.
+// `b` is returned as is when it has none.
+func uncomment(b []byte) []byte {
+	var out []byte
+	for i := 0; i < len(b); i++ {
+		switch {
+		case b[i] == '"':
+			// Skip strings, which may hold slashes.
+			for i++; i < len(b) && b[i] != '"'; i++ {
+				if b[i] == '\\' {
+					i++
+				}
+			}
+		case b[i] == '/' && i+1 < len(b) && (b[i+1] == '/' || b[i+1] == '*'):
+			end := len(b)
+			if b[i+1] == '/' {
+				if j := bytes.IndexByte(b[i:], '\n'); j >= 0 {
+					end = i + j
+				}
+			} else if j := bytes.Index(b[i+2:], []byte("*/")); j >= 0 {
+				end = i + j + 4
+			} else {
+				// Unterminated, left for the decoder to reject.
+				continue
+			}
+			if out == nil {
+				out = append([]byte(nil), b...)
+			}
+			for ; i < end; i++ {
+				if b[i] != '\n' && b[i] != '\r' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+	if out == nil {
+		return b
+	}
+	return out
+}
+
+// FromMap returns a new Config of the values within `m`, which is used as is.
+func FromMap(m map[string]interface{}) Config {
+	return Config{m: m}
+}
+
+// FromPairs returns a new Config from alternating keys and values, where keys
+// are dot separated paths of groups and a key, eg.
+//
+//	config.FromPairs("server.port", 9090, "host", "localhost")
+func FromPairs(pairs ...interface{}) (Config, error) {
+	if len(pairs)%2 != 0 {
+		return *new(Config), fmt.Errorf("odd number of key/value pairs: %d", len(pairs))
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok || key == "" {
+			return *new(Config), fmt.Errorf("invalid key %v at %d", pairs[i], i)
+		}
+		set(m, strings.Split(key, "."), pairs[i+1])
+	}
+	return Config{m: m}, nil
+}
+
+// ReadFromReader returns a new Config read from `r`, see ReadFrom.
+func ReadFromReader(r io.Reader, opts ...Option) (Config, error) {
+	o := newOptions(opts)
+	data, err := o.readAll(r)
+	if err != nil {
+		return *new(Config), err
+	}
+	return o.readFrom(data)
+}
+
+// readFD reads the configuration from the inherited file descriptor within
+// `APP_CONFIG_FD`, so supervisors can pipe the config in (`0` being stdin)
+// rather than writing it to a file.
+func readFD(env string, o *options) (Config, error) {
+	fd, err := strconv.Atoi(env)
+	if err != nil || fd < 0 {
+		return *new(Config), fmt.Errorf("invalid APP_CONFIG_FD %s", env)
+	}
+	f := os.NewFile(uintptr(fd), "APP_CONFIG_FD")
+	if f == nil {
+		return *new(Config), fmt.Errorf("invalid APP_CONFIG_FD %s", env)
+	}
+	defer f.Close()
+	data, err := o.readAll(f)
+	if err != nil {
+		return *new(Config), err
+	}
+	if err = verify(data, "", true, false); err != nil {
+		return *new(Config), err
+	}
+	c, err := o.readFrom(data)
+	if err != nil {
+		err = fmt.Errorf("failed to read configuration from APP_CONFIG_FD %s: %w", env, err)
+	}
+	return c, err
+}
+
+// readInline reads the configuration from the contents of `APP_CONFIG_JSON`,
+// either as raw JSON or base64 encoded JSON.
+func readInline(env string, o *options) (Config, error) {
+	data := []byte(strings.TrimSpace(env))
+	if len(data) > 0 && data[0] != '{' {
+		encodings := []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding,
+			base64.URLEncoding, base64.RawURLEncoding}
+		var err error
+		for _, enc := range encodings {
+			var b []byte
+			if b, err = enc.DecodeString(string(data)); err == nil {
+				data = b
+				break
+			}
+		}
+		if err != nil {
+			return *new(Config), fmt.Errorf("failed to decode APP_CONFIG_JSON: %s", err)
+		}
+	}
+	if err := verify(data, "", true, false); err != nil {
+		return *new(Config), err
+	}
+	c, err := o.readFrom(data)
+	if err != nil {
+		err = fmt.Errorf("failed to read configuration from APP_CONFIG_JSON: %w", err)
+	}
+	return c, err
+}
+
+// findFile returns the path of the config file, which is looked for beside
+// the running executable, and then within the CWD.
+func findFile() (string, error) {
 	cfgFile := ConfigFile()
 	// Grab the path for the the running executable.
 	p := filepath.Dir(os.Args[0])
@@ -60,136 +293,452 @@ func Read() (Config, error) {
 		f = filepath.Join(p, cfgFile)
 		_, err = os.Stat(f)
 	}
+	return f, err
+}
 
+func readFile(f string, o *options) (Config, error) {
 	var c Config
+	// Read the file bytes.
+	file, err := openFile(f, o)
 	if err != nil {
 		return c, err
 	}
-	// Read the file bytes.
-	data, err := ioutil.ReadFile(f)
+	data, err := o.readAll(file)
+	fi, _ := file.Stat()
+	file.Close()
 	if err != nil {
-		return c, err
+		return c, fmt.Errorf("failed to read configuration file %s: %w", f, err)
+	}
+	// Make sure the file hasn't been tampered with.
+	if err = verify(data, f, !o.sidecars, o.verified); err != nil {
+		return c, fmt.Errorf("failed to verify configuration file %s: %w", f, err)
+	}
+	// Load the configuration from the file, resolving its references once
+	// its includes are.
+	no := *o
+	no.deferRefs = true
+	if d := formatOf(f); d != nil {
+		c, err = no.readFormat(d, data, f)
+	} else {
+		c, err = no.readFrom(data)
 	}
-	// Load the configuration from the file.
-	c, err = ReadFrom(data)
 	if err != nil {
-		err = fmt.Errorf("failed to read configuration file %s", f)
+		return c, fmt.Errorf("failed to read configuration file %s: %w", f, err)
 	}
-	return c, err
+	filePositions(c.pos, f)
+	if err = o.checkPermissions(f, fi, c); err != nil {
+		return *new(Config), err
+	}
+	if no.migrated && o.writeBack {
+		if err = c.SaveAs(f, WithBackup()); err != nil {
+			return c, fmt.Errorf("failed to write migrated configuration file %s: %w", f, err)
+		}
+	}
+	// Includes are resolved once migrated, so they aren't written back.
+	if err = no.includes(f, &c); err != nil {
+		return *new(Config), err
+	}
+	if o.refs && !o.deferRefs {
+		if c.m, err = references(c.m); err != nil {
+			return *new(Config), fmt.Errorf("failed to read configuration file %s: %w", f, err)
+		}
+	}
+	return c, nil
 }
 
+// Read reads the configuration from the first of:
+//
+//	- the file descriptor within `APP_CONFIG_FD`
+//	- the JSON document within `APP_CONFIG_JSON`
+//	- the config file (see ConfigFile)
+//
+// Setting `APP_CONFIG_PRECEDENCE=file` makes the config file take precedence
+// over `APP_CONFIG_JSON`, which is then only used when no file is found.
+//
+// When read from the config file, a per-user override file is deep-merged
+// over it when found, either UserConfigFile beside the config file, or
+// `<app>/overrides.json` within os.UserConfigDir. Setting `APP_CONFIG_NO_USER`
+// ignores them. When the config file is pinned by `APP_CONFIG_SHA256`, they
+// must be verified by their own sidecars.
+//
+// The parser limits can be overridden by `opts`, see ReadFrom.
+func Read(opts ...Option) (Config, error) {
+	o := newOptions(opts)
+	if env := os.Getenv("APP_CONFIG_FD"); env != "" {
+		return readFD(env, o)
+	}
+	inline := os.Getenv("APP_CONFIG_JSON")
+	precedence := os.Getenv("APP_CONFIG_PRECEDENCE")
+	switch precedence {
+	case "", "env", "file":
+	default:
+		return *new(Config), fmt.Errorf("invalid APP_CONFIG_PRECEDENCE %s", precedence)
+	}
+	if inline != "" && precedence != "file" {
+		return readInline(inline, o)
+	}
+
+	f, err := findFile()
+	if err != nil {
+		if inline != "" && os.IsNotExist(err) {
+			return readInline(inline, o)
+		}
+		return *new(Config), err
+	}
+	c, err := readFile(f, o)
+	if err != nil {
+		return c, err
+	}
+	return readUser(c, filepath.Dir(f), o)
+}
+
+// SetConfig replaces the current config with `m`, logging the failure once
+// frozen (see Freeze), or invalid (see OnValidate), see TrySetConfig.
 func SetConfig(m map[string]interface{}) {
+	if err := TrySetConfig(m); err != nil {
+		log.Printf("config: failed to set the config: %s", err)
+	}
+}
+
+// TrySetConfig replaces the current config with `m`, failing once frozen, see
+// Freeze, or when it's invalid, see OnValidate.
+func TrySetConfig(m map[string]interface{}) error {
+	return update(nil, "set", func() error {
+		cfg.m, cfg.pos = m, nil
+		return nil
+	})
+}
+
+// SetCoerce sets whether the current config converts string values on
+// access, see Config.Coerce, logging the failure once frozen, see
+// TrySetCoerce.
+func SetCoerce(coerce bool) {
+	if err := TrySetCoerce(coerce); err != nil {
+		log.Printf("config: failed to set coercion: %s", err)
+	}
+}
+
+// TrySetCoerce sets whether the current config converts string values on
+// access, failing once frozen, see Freeze.
+func TrySetCoerce(coerce bool) error {
+	return update(nil, "coerce", func() error {
+		cfg.coerce = coerce
+		return nil
+	})
+}
+
+// install sets `c` as the current config, along with its settings.
+func install(c Config) error {
+	return update(nil, "load", func() error {
+		cfg.m, cfg.coerce, cfg.pos = c.m, c.coerce, c.pos
+		return nil
+	})
+}
+
+// Map returns a copy of the current config, as set by SetConfig.
+func Map() map[string]interface{} {
 	cfg.mu.Lock()
 	defer cfg.mu.Unlock()
-	cfg.m = m
+	return cfg.Map()
+}
+
+// copyVal returns a deep copy of the groups and lists within `v`.
+func copyVal(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[key] = copyVal(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = copyVal(val)
+		}
+		return l
+	}
+	return v
 }
 
 // accessors
-func colBool(key string, col map[string]interface{}) (bool, bool) {
-	if v, ok := col[key]; ok {
-		b, ok := v.(bool)
-		return b, ok
+func colBool(key string, col map[string]interface{}, coerce, lenient bool) (bool, bool) {
+	v, ok := col[key]
+	return boolVal(v, ok, coerce, lenient)
+}
+
+func boolVal(v interface{}, ok, coerce, lenient bool) (bool, bool) {
+	if ok {
+		v = revealed(v)
+		if _, isString := v.(string); isString && !coerce {
+			return false, false
+		}
+		b, err := coerceBool(v, lenient)
+		return b, err == nil
 	}
 	return false, false
 }
 
 func colString(key string, col map[string]interface{}) (string, bool) {
-	if v, ok := col[key]; ok {
+	v, ok := col[key]
+	return stringVal(v, ok)
+}
+
+func stringVal(v interface{}, ok bool) (string, bool) {
+	if ok {
+		if s, isSecret := v.(*SecretString); isSecret && s != nil {
+			return s.Reveal(), true
+		}
 		s, ok := v.(string)
 		return s, ok
 	}
 	return *new(string), false
 }
 
-func colInt(key string, col map[string]interface{}) (int, bool) {
-	if v, ok := col[key]; ok {
-		switch v.(type) {
-		case int:
-			return v.(int), true
-		case float64:
-			return int(v.(float64)), true
+func colInt(key string, col map[string]interface{}, coerce bool) (int, bool) {
+	v, ok := col[key]
+	return intVal(v, ok, coerce)
+}
+
+func intVal(v interface{}, ok, coerce bool) (int, bool) {
+	if ok {
+		v = revealed(v)
+		if _, isString := v.(string); isString && !coerce {
+			return 0, false
+		}
+		if i, err := CoerceInt(v); err == nil {
+			return i, true
 		}
 	}
-	return -1, false
+	return 0, false
+}
+
+func colFloat64(key string, col map[string]interface{}, coerce bool) (float64, bool) {
+	v, ok := col[key]
+	return float64Val(v, ok, coerce)
 }
 
-func colFloat64(key string, col map[string]interface{}) (float64, bool) {
-	if v, ok := col[key]; ok {
-		switch v.(type) {
-		case float64:
-			return v.(float64), true
-		case int:
-			return float64(v.(int)), true
+func float64Val(v interface{}, ok, coerce bool) (float64, bool) {
+	if ok {
+		v = revealed(v)
+		if _, isString := v.(string); isString && !coerce {
+			return 0, false
+		}
+		if f, err := CoerceFloat64(v); err == nil {
+			return f, true
 		}
 	}
-	return -1.0, false
+	return 0, false
 }
 
-func colVal(key string, col map[string]interface{}) (interface{}, bool) {
-	if v, ok := col[key]; ok {
-		return v, true
+// colStrings returns a list of strings, or a comma separated string when
+// coercing.
+func colStrings(key string, col map[string]interface{}, coerce bool) ([]string, bool) {
+	v, ok := col[key]
+	return stringsVal(v, ok, coerce)
+}
+
+func stringsVal(v interface{}, ok, coerce bool) ([]string, bool) {
+	if ok {
+		switch v := revealed(v).(type) {
+		case []interface{}:
+			l := make([]string, len(v))
+			for i, s := range v {
+				if l[i], ok = s.(string); !ok {
+					return nil, false
+				}
+			}
+			return l, true
+		case []string:
+			return v, true
+		case string:
+			if !coerce {
+				return nil, false
+			}
+			l := strings.Split(v, ",")
+			for i := range l {
+				l[i] = strings.TrimSpace(l[i])
+			}
+			return l, true
+		}
 	}
 	return nil, false
 }
 
+func colVal(key string, col map[string]interface{}) (interface{}, bool) {
+	v, ok := col[key]
+	return v, ok
+}
+
 func keys(m map[string]interface{}) []string {
 	var keys []string
 	for key := range m {
-		keys = append(keys, key)
+		if key != sensitiveKey {
+			keys = append(keys, key)
+		}
 	}
 	return keys
 }
 
-func (c Config) Keys() []string {
-	return keys(cfg.m)
+// Map returns a copy of the config's values, changes to it don't affect the
+// config.
+func (c Config) Map() map[string]interface{} {
+	if c.m == nil {
+		return nil
+	}
+	return copyVal(c.visible(c.m)).(map[string]interface{})
+}
+
+// Keys returns the keys within the root level, or within the group at `path`
+// when given, eg. `c.Keys("server", "tls")`. Nil is returned when the group is
+// missing.
+func (c Config) Keys(path ...string) []string {
+	for _, name := range path {
+		c = c.Group(name)
+	}
+	return keys(c.m)
+}
+
+// Len returns the number of keys within the root level.
+func (c Config) Len() int {
+	return len(c.Keys())
+}
+
+// IsEmpty returns whether the root level has no keys.
+func (c Config) IsEmpty() bool {
+	return c.Len() == 0
 }
 
 func (c Config) GroupKeys(group string) []string {
-	if m, exists := c.m[group]; exists {
-		if col, isMap := m.(map[string]interface{}); isMap {
-			return keys(col)
-		}
+	if col, exists := c.group(group); exists {
+		return keys(col)
 	}
 	return nil
 }
 
+// KeysSorted returns the keys, see Keys, sorted.
+func (c Config) KeysSorted(path ...string) []string {
+	l := c.Keys(path...)
+	sort.Strings(l)
+	return l
+}
+
+// KeysMatching returns the sorted keys within the root level matching
+// `glob`, eg. `db_*` (see path.Match). Nil is returned when no key matches,
+// or `glob` is malformed.
+func (c Config) KeysMatching(glob string) []string {
+	var l []string
+	for _, key := range c.KeysSorted() {
+		ok, err := path.Match(glob, key)
+		if err != nil {
+			return nil
+		}
+		if ok {
+			l = append(l, key)
+		}
+	}
+	return l
+}
+
+// GroupKeysSorted returns the keys within `group`, see GroupKeys, sorted.
+func (c Config) GroupKeysSorted(group string) []string {
+	l := c.GroupKeys(group)
+	sort.Strings(l)
+	return l
+}
+
+// Scoped returns a view of the group at `prefix`, a dot separated path of
+// groups (eg. `mylib` or `mylib.cache`), so that all lookups are relative to
+// it. Reusable libraries can use it to consume their own subtree of the host
+// application's config. An empty config is returned when the group is missing.
+func (c Config) Scoped(prefix string) Config {
+	for _, key := range strings.Split(prefix, ".") {
+		c = c.Group(key)
+	}
+	return c
+}
+
+// Group returns the group `name` as a Config, so that nested groups can be
+// traversed to any depth, eg. `c.Group("a").Group("b").String("c")`.
+// An empty config is returned when the group is missing.
+func (c Config) Group(name string) Config {
+	m, _ := c.group(name)
+	g := c.with(m)
+	g.path = join(c.path, name)
+	return g
+}
+
+// Coerce returns the config converting string values on access, as values
+// from string-typed sources (eg. environment variables) always are, so that
+// `"true"`, `"42"`, `"3.14"`, and `"a,b,c"` can be read via Bool, Int,
+// Float64, and Strings just like their JSON typed counterparts.
+func (c Config) Coerce() Config {
+	c = c.with(c.m)
+	c.coerce = true
+	return c
+}
+
+// with returns a config of `m`, carrying over the settings of `c`.
+func (c *Config) with(m map[string]interface{}) Config {
+	return Config{m: m, allowSensitive: c.allowSensitive, coerce: c.coerce, lenientBools: c.lenientBools, path: c.path,
+		missingInt: c.missingInt, missingFloat64: c.missingFloat64, pos: c.pos}
+}
+
 // Bool returns the boolean value for the `key` within the root level.
 // The value, or default value, is returned along with boolean of wether the key was found.
 func (c Config) Bool(key string) (bool, bool) {
-	return colBool(key, c.m)
+	v, ok, coerce := c.valueCoerce(key)
+	return boolVal(v, ok, coerce, c.lenientBools)
 }
 
 // String returns the string value for the `key` within the root level.
 // The value, or default value, is returned along with boolean of wether the key was found.
 func (c Config) String(key string) (string, bool) {
-	return colString(key, c.m)
+	return stringVal(c.value(key))
 }
 
 // Int returns the int value for the `key` within the root level.
-// The value, or default value, is returned along with boolean of wether the key was found.
+// The value, or 0 (see WithMissingInt), is returned along with boolean of wether the key was found.
 func (c Config) Int(key string) (int, bool) {
-	return colInt(key, c.m)
+	if i, ok := intVal(c.valueCoerce(key)); ok {
+		return i, true
+	}
+	return c.missingInt, false
 }
 
 // Float64 returns the float64 value for the `key` within the root level.
-// The value, or default value, is returned along with boolean of wether the key was found.
+// The value, or 0 (see WithMissingFloat64), is returned along with boolean of wether the key was found.
 func (c Config) Float64(key string) (float64, bool) {
-	return colFloat64(key, c.m)
+	if f, ok := float64Val(c.valueCoerce(key)); ok {
+		return f, true
+	}
+	return c.missingFloat64, false
+}
+
+// Strings returns the list of strings for the `key` within the root level.
+// The value, or nil, is returned along with boolean of wether the key was found.
+func (c Config) Strings(key string) ([]string, bool) {
+	return stringsVal(c.valueCoerce(key))
 }
 
 // Val returns the value, as an interface{}, for the `key` within the root level.
 // The value, or nil, is returned along with boolean of wether the key was found.
 func (c Config) Val(key string) (interface{}, bool) {
-	return colVal(key, c.m)
+	v, ok := c.value(key)
+	if g, isGroup := v.(map[string]interface{}); isGroup && c.gated() {
+		if isSensitive(g) {
+			return nil, false
+		}
+		v = c.visible(g)
+	}
+	return v, ok
 }
 
 // GroupBool returns the boolean value for the `key` within the group level.
 // The boolean, or false, is returned along with boolean of wether the key was found.
 func (c Config) GroupBool(group, key string) (v bool, ok bool) {
-	if m, exists := c.m[group]; exists {
-		if col, isMap := m.(map[string]interface{}); isMap {
-			v, ok = colBool(key, col)
-		}
+	if col, exists := c.group(group); exists {
+		v, ok = colBool(key, col, c.coerce, c.lenientBools)
 	}
 	return
 }
@@ -197,32 +746,32 @@ func (c Config) GroupBool(group, key string) (v bool, ok bool) {
 // GroupBool returns the boolean value for the `key` within the group level.
 // The string, or empty string, is returned along with boolean of wether the key was found.
 func (c Config) GroupString(group, key string) (v string, ok bool) {
-	if m, exists := c.m[group]; exists {
-		if col, isMap := m.(map[string]interface{}); isMap {
-			v, ok = colString(key, col)
-		}
+	if col, exists := c.group(group); exists {
+		v, ok = colString(key, col)
 	}
 	return
 }
 
 // GroupBool returns the boolean value for the `key` within the group level
-// The int, or 0, is returned along with boolean of wether the key was found.
+// The int, or 0 (see WithMissingInt), is returned along with boolean of wether the key was found.
 func (c Config) GroupInt(group, key string) (v int, ok bool) {
-	if m, exists := c.m[group]; exists {
-		if col, isMap := m.(map[string]interface{}); isMap {
-			v, ok = colInt(key, col)
-		}
+	if col, exists := c.group(group); exists {
+		v, ok = colInt(key, col, c.coerce)
+	}
+	if !ok {
+		v = c.missingInt
 	}
 	return
 }
 
 // GroupBool returns the boolean value for the `key` within the group level
-// The float64, or 0, is returned along with boolean of wether the key was found.
+// The float64, or 0 (see WithMissingFloat64), is returned along with boolean of wether the key was found.
 func (c Config) GroupFloat64(group, key string) (v float64, ok bool) {
-	if m, exists := c.m[group]; exists {
-		if col, isMap := m.(map[string]interface{}); isMap {
-			v, ok = colFloat64(key, col)
-		}
+	if col, exists := c.group(group); exists {
+		v, ok = colFloat64(key, col, c.coerce)
+	}
+	if !ok {
+		v = c.missingFloat64
 	}
 	return
 }
@@ -230,10 +779,8 @@ func (c Config) GroupFloat64(group, key string) (v float64, ok bool) {
 // GroupVal returns the value, as an interface{}, for the `key` within the group level
 // The value, or nil, is returned along with boolean of wether the key was found.
 func (c Config) GroupVal(group, key string) (v interface{}, ok bool) {
-	if m, exists := c.m[group]; exists {
-		if col, isMap := m.(map[string]interface{}); isMap {
-			v, ok = colVal(key, col)
-		}
+	if col, exists := c.group(group); exists {
+		v, ok = colVal(key, col)
 	}
 	return
 }
@@ -274,6 +821,15 @@ func (c Config) RequiredFloat64(key string) float64 {
 	return f
 }
 
+// Strings returns the list of strings, within the root, and exits when not found.
+func (c Config) RequiredStrings(key string) []string {
+	l, ok := c.Strings(key)
+	if !ok {
+		log.Fatalf("failed to retrieve '%s' strings from config", key)
+	}
+	return l
+}
+
 // Val returns the interface{} value, within the root, and exits when not found.
 func (c Config) RequiredVal(key string) interface{} {
 	o, ok := c.Val(key)
@@ -328,14 +884,53 @@ func (c Config) RequiredGroupVal(group, key string) interface{} {
 	return o
 }
 
-func Keys() []string {
-	return cfg.Keys()
+// Keys returns the keys of the current config, see Config.Keys.
+func Keys(path ...string) []string {
+	return cfg.Keys(path...)
+}
+
+// Len returns the number of keys within the current config's root level.
+func Len() int {
+	return cfg.Len()
+}
+
+// IsEmpty returns whether the current config's root level has no keys.
+func IsEmpty() bool {
+	return cfg.IsEmpty()
 }
 
 func GroupKeys(group string) []string {
 	return cfg.GroupKeys(group)
 }
 
+// KeysSorted returns the keys of the current config, see Config.KeysSorted.
+func KeysSorted(path ...string) []string {
+	return cfg.KeysSorted(path...)
+}
+
+// KeysMatching returns the keys of the current config matching `glob`, see
+// Config.KeysMatching.
+func KeysMatching(glob string) []string {
+	return cfg.KeysMatching(glob)
+}
+
+// GroupKeysSorted returns the keys within `group` of the current config, see
+// Config.GroupKeysSorted.
+func GroupKeysSorted(group string) []string {
+	return cfg.GroupKeysSorted(group)
+}
+
+// Group returns the group `name` within the current config, see Config.Group.
+func Group(name string) Config {
+	return cfg.Group(name)
+}
+
+// Scoped returns a view of the group at `prefix` within the current config,
+// see Config.Scoped. The view doesn't follow later calls to SetConfig.
+func Scoped(prefix string) Config {
+	return cfg.Scoped(prefix)
+}
+
 // Bool returns the boolean value for the `key` within the root level.
 // The value, or default value, is returned along with boolean of wether the key was found.
 func Bool(key string) (bool, bool) {
@@ -349,17 +944,23 @@ func String(key string) (string, bool) {
 }
 
 // Int returns the int value for the `key` within the root level.
-// The value, or default value, is returned along with boolean of wether the key was found.
+// The value, or 0 (see SetMissingInt), is returned along with boolean of wether the key was found.
 func Int(key string) (int, bool) {
 	return cfg.Int(key)
 }
 
 // Float64 returns the float64 value for the `key` within the root level.
-// The value, or default value, is returned along with boolean of wether the key was found.
+// The value, or 0 (see SetMissingFloat64), is returned along with boolean of wether the key was found.
 func Float64(key string) (float64, bool) {
 	return cfg.Float64(key)
 }
 
+// Strings returns the list of strings for the `key` within the root level.
+// The value, or nil, is returned along with boolean of wether the key was found.
+func Strings(key string) ([]string, bool) {
+	return cfg.Strings(key)
+}
+
 // Val returns the value, as an interface{}, for the `key` within the root level.
 // The value, or nil, is returned along with boolean of wether the key was found.
 func Val(key string) (interface{}, bool) {
@@ -416,6 +1017,11 @@ func RequiredFloat64(key string) float64 {
 	return cfg.RequiredFloat64(key)
 }
 
+// Strings returns the list of strings, within the root, and exits when not found.
+func RequiredStrings(key string) []string {
+	return cfg.RequiredStrings(key)
+}
+
 // Val returns the interface{} value, within the root, and exits when not found.
 func RequiredVal(key string) interface{} {
 	return cfg.RequiredVal(key)
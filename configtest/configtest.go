@@ -0,0 +1,59 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package configtest provides helpers for installing a temporary global config
+// for the duration of a test, and for comparing rendered configs against
+// golden files.
+//
+// The config package holds a single global config, so tests using these
+// helpers must not run in parallel with other tests reading the config.
+package configtest
+
+import (
+	"strings"
+	"testing"
+
+	"code.minty.io/config"
+)
+
+// New installs `m` as the global config, restoring the previous config once
+// the test completes.
+func New(t testing.TB, m map[string]interface{}) {
+	t.Helper()
+	restore(t)
+	config.SetConfig(m)
+}
+
+// Override sets the value at `key`, a dot separated path of groups and a key
+// (eg. `server.port`), within the global config, restoring the previous config
+// once the test completes. Missing groups are created.
+func Override(t testing.TB, key string, value interface{}) {
+	t.Helper()
+	m := restore(t)
+	if m == nil {
+		m = make(map[string]interface{})
+	}
+	path := strings.Split(key, ".")
+	g := m
+	for _, k := range path[:len(path)-1] {
+		next, ok := g[k].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			g[k] = next
+		}
+		g = next
+	}
+	g[path[len(path)-1]] = value
+	config.SetConfig(m)
+}
+
+// restore registers the restoration of the current config, and returns a copy
+// of it.
+func restore(t testing.TB) map[string]interface{} {
+	prev := config.Map()
+	t.Cleanup(func() {
+		config.SetConfig(prev)
+	})
+	return config.Map()
+}
@@ -0,0 +1,99 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"code.minty.io/config"
+)
+
+// UpdateEnv is the environment variable which, when set, makes Golden write
+// the golden files rather than compare against them, eg.
+//
+//	CONFIGTEST_UPDATE=1 go test ./...
+const UpdateEnv = "CONFIGTEST_UPDATE"
+
+// Render returns the effective config of `sources`, layered in priority
+// order as a config.Chain, failing the test when it can't be read.
+func Render(t testing.TB, sources ...config.Source) config.Config {
+	t.Helper()
+	c, err := config.NewChain(sources...).Read()
+	if err != nil {
+		t.Fatalf("failed to render config: %s", err)
+	}
+	return c
+}
+
+// Golden compares the values of `c`, as indented JSON, against the golden
+// file at `path`, failing the test with the keys that differ. Computed values
+// (see config.Provide) aren't included. The file is written instead when
+// UpdateEnv is set, eg.
+//
+//	c := configtest.Render(t, config.File("testdata/prod.json"), config.File("config.json"))
+//	configtest.Golden(t, c, "testdata/prod.golden.json")
+func Golden(t testing.TB, c config.Config, path string) {
+	t.Helper()
+	m := c.Map()
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+	got, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		t.Fatalf("failed to render config: %s", err)
+	}
+	got = append(got, '\n')
+	if os.Getenv(UpdateEnv) != "" {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			err = os.WriteFile(path, got, 0644)
+		}
+		if err != nil {
+			t.Fatalf("failed to update golden file: %s", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (set %s=1 to create it): %s", UpdateEnv, err)
+	}
+	if string(want) == string(got) {
+		return
+	}
+	var wm map[string]interface{}
+	if err = json.Unmarshal(want, &wm); err != nil {
+		t.Fatalf("invalid golden file %s: %s", path, err)
+	}
+	changes := config.Diff(config.FromMap(wm), config.FromMap(m))
+	if len(changes) == 0 {
+		// Only the formatting differs.
+		t.Errorf("config doesn't match %s, only in formatting (set %s=1 to update it)", path, UpdateEnv)
+		return
+	}
+	var b strings.Builder
+	for _, ch := range changes {
+		switch {
+		case ch.Old == nil:
+			fmt.Fprintf(&b, "\n\t+ %s: %s", ch.Path, format(ch.New))
+		case ch.New == nil:
+			fmt.Fprintf(&b, "\n\t- %s: %s", ch.Path, format(ch.Old))
+		default:
+			fmt.Fprintf(&b, "\n\t~ %s: %s, want %s", ch.Path, format(ch.New), format(ch.Old))
+		}
+	}
+	t.Errorf("config doesn't match %s (set %s=1 to update it):%s", path, UpdateEnv, b.String())
+}
+
+func format(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
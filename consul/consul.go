@@ -0,0 +1,484 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package consul rolls config reloads out across a fleet gradually, a wave
+// of instances at a time, gated by their health, using Consul sessions and
+// its KV store for coordination, rather than every instance reloading at once.
+//
+// Every instance runs a Coordinator, registering itself under the prefix. One
+// is elected leader, and when a new version is rolled out (see Rollout) it
+// assigns it to a percentage of the instances at a time. Assigned instances
+// reload via their config.Admin, check their health, and report back; the
+// next wave starts once every instance of the current one is healthy. An
+// instance failing to reload, or its health check, rolls its config back,
+// and halts the rollout.
+//
+// The keys used, under the prefix, are:
+//
+//	leader           held by the leader's session
+//	target           the version being rolled out
+//	halted           why the rollout of the target halted, when it did
+//	instances/<id>   held by each instance's session
+//	assign/<id>      the version assigned to each instance
+//	status/<id>      each instance's version, and health, as JSON
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.minty.io/config"
+)
+
+// Coordinator coordinates the reloads of this instance with the fleet's.
+type Coordinator struct {
+	// Addr is the address of the Consul agent, `http://127.0.0.1:8500` when
+	// empty.
+	Addr string
+	// Prefix is the KV prefix shared by the fleet, eg. `config/myapp`.
+	Prefix string
+	// ID identifies this instance, its hostname when empty.
+	ID string
+	// Admin reloads, and rolls back, this instance's config.
+	Admin *config.Admin
+	// Percent is the percentage of instances reloaded per wave, 25 when
+	// zero. Waves have at least one instance.
+	Percent float64
+	// Health checks the reloaded config, eg. that its database can be
+	// reached. Instances are healthy once reloaded when nil.
+	Health func(c config.Config) error
+	// Timeout bounds each wave, 2 minutes when zero.
+	Timeout time.Duration
+	// TTL is the time to live of the instance's session, 15 seconds when
+	// zero.
+	TTL time.Duration
+	// Client is the client used for requests, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// Status is the version, and health, last reported by an instance.
+type Status struct {
+	Version string `json:"version"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// wait is how long blocking queries wait for a change.
+const wait = 30 * time.Second
+
+// Rollout starts rolling `version` out across the fleet, replacing any
+// rollout in progress. It only needs Addr and Prefix set, eg. for deploy
+// tooling.
+func (c *Coordinator) Rollout(ctx context.Context, version string) error {
+	if err := c.delete(ctx, "halted"); err != nil {
+		return err
+	}
+	_, err := c.put(ctx, "target", []byte(version), "")
+	return err
+}
+
+// Run registers the instance, takes part in leader election, and applies
+// the versions assigned to it, until `ctx` is done.
+func (c *Coordinator) Run(ctx context.Context) error {
+	if c.Admin == nil {
+		return errors.New("consul: coordinator requires an Admin")
+	}
+	if c.ID == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		c.ID = host
+	}
+	session, err := c.session(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.do(context.Background(), http.MethodPut, "/v1/session/destroy/"+session, nil, nil)
+	go c.renew(ctx, session)
+
+	if ok, err := c.put(ctx, "instances/"+c.ID, []byte(c.ID), session); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("consul: instance %s is already registered", c.ID)
+	}
+	// The config was just read, so it's at the target, and any assignment
+	// left from before is stale.
+	target, _, _, err := c.get(ctx, "target", 0)
+	if err != nil {
+		return err
+	}
+	if err = c.report(ctx, Status{Version: string(target.Value), Healthy: true}); err != nil {
+		return err
+	}
+	assigned, _, index, err := c.get(ctx, "assign/"+c.ID, 0)
+	if err != nil {
+		return err
+	}
+	last := string(assigned.Value)
+
+	go c.lead(ctx, session)
+	for ctx.Err() == nil {
+		p, found, next, err := c.get(ctx, "assign/"+c.ID, index)
+		if err != nil {
+			c.backoff(ctx, err)
+			continue
+		}
+		index = next
+		if found && string(p.Value) != last {
+			last = string(p.Value)
+			c.apply(ctx, last)
+		}
+	}
+	return nil
+}
+
+// apply reloads the config for `version`, rolling it back when unhealthy,
+// and reports the result.
+func (c *Coordinator) apply(ctx context.Context, version string) {
+	gen := config.Generation()
+	err := c.Admin.Reload()
+	if err == nil && c.Health != nil {
+		if err = c.Health(c.Admin.Config()); err != nil {
+			if rerr := c.Admin.Rollback(gen); rerr != nil {
+				log.Printf("consul: failed to roll back to generation %d: %s", gen, rerr)
+			}
+		}
+	}
+	s := Status{Version: version, Healthy: err == nil}
+	if err != nil {
+		s.Error = err.Error()
+		log.Printf("consul: failed to apply version %s: %s", version, err)
+	}
+	if err = c.report(ctx, s); err != nil {
+		log.Printf("consul: failed to report version %s: %s", version, err)
+	}
+}
+
+func (c *Coordinator) report(ctx context.Context, s Status) error {
+	b, _ := json.Marshal(s)
+	_, err := c.put(ctx, "status/"+c.ID, b, "")
+	return err
+}
+
+// lead campaigns for leadership, and while leading, rolls out every new
+// target.
+func (c *Coordinator) lead(ctx context.Context, session string) {
+	var index uint64
+	for ctx.Err() == nil {
+		ok, err := c.put(ctx, "leader", []byte(c.ID), session)
+		if err != nil {
+			c.backoff(ctx, err)
+			continue
+		}
+		if !ok {
+			// Wait for the leader to change, before campaigning again.
+			_, _, index, err = c.get(ctx, "leader", index)
+			if err != nil {
+				c.backoff(ctx, err)
+			}
+			continue
+		}
+		c.follow(ctx, session)
+	}
+}
+
+// follow rolls out every new target, while the session holds the lead.
+func (c *Coordinator) follow(ctx context.Context, session string) {
+	var index uint64
+	for ctx.Err() == nil {
+		leader, _, _, err := c.get(ctx, "leader", 0)
+		if err != nil || leader.Session != session {
+			return
+		}
+		target, found, next, err := c.get(ctx, "target", index)
+		if err != nil {
+			c.backoff(ctx, err)
+			continue
+		}
+		index = next
+		if !found {
+			continue
+		}
+		if _, halted, _, err := c.get(ctx, "halted", 0); err != nil || halted {
+			continue
+		}
+		if err = c.rollout(ctx, string(target.Value)); err != nil {
+			log.Printf("consul: halted rollout of %s: %s", target.Value, err)
+			c.put(ctx, "halted", []byte(err.Error()), "")
+		}
+	}
+}
+
+// rollout assigns `version` to the instances not yet at it, a wave at a
+// time, until they all are, or a wave fails.
+func (c *Coordinator) rollout(ctx context.Context, version string) error {
+	for {
+		instances, _, err := c.list(ctx, "instances/", 0)
+		if err != nil {
+			return err
+		}
+		statuses, _, err := c.list(ctx, "status/", 0)
+		if err != nil {
+			return err
+		}
+		var pending []string
+		for id := range instances {
+			if s := status(statuses[id]); s.Version != version {
+				pending = append(pending, id)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		sort.Strings(pending)
+		percent := c.Percent
+		if percent <= 0 {
+			percent = 25
+		}
+		n := int(math.Ceil(float64(len(instances)) * percent / 100))
+		if n > len(pending) {
+			n = len(pending)
+		}
+		wave := pending[:n]
+		for _, id := range wave {
+			if _, err = c.put(ctx, "assign/"+id, []byte(version), ""); err != nil {
+				return err
+			}
+		}
+		if err = c.await(ctx, wave, version); err != nil {
+			return err
+		}
+	}
+}
+
+// await waits for every instance of `wave` to report `version`, failing when
+// one is unhealthy, or the wave times out. Instances leaving are skipped.
+func (c *Coordinator) await(ctx context.Context, wave []string, version string) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	deadline := time.Now().Add(timeout)
+	var index uint64
+	for {
+		statuses, next, err := c.list(ctx, "status/", index)
+		if err != nil {
+			return err
+		}
+		index = next
+		instances, _, err := c.list(ctx, "instances/", 0)
+		if err != nil {
+			return err
+		}
+		done := true
+		for _, id := range wave {
+			s := status(statuses[id])
+			_, alive := instances[id]
+			switch {
+			case !alive:
+			case s.Version != version:
+				done = false
+			case !s.Healthy:
+				return fmt.Errorf("instance %s is unhealthy: %s", id, s.Error)
+			}
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("wave of %s timed out after %s", strings.Join(wave, ", "), timeout)
+		}
+	}
+}
+
+func status(b []byte) Status {
+	var s Status
+	json.Unmarshal(b, &s)
+	return s
+}
+
+func (c *Coordinator) session(ctx context.Context) (string, error) {
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = 15 * time.Second
+	}
+	body, _ := json.Marshal(map[string]string{
+		"Name":      "config-" + c.ID,
+		"TTL":       ttl.String(),
+		"Behavior":  "delete",
+		"LockDelay": "0s",
+	})
+	b, _, err := c.do(ctx, http.MethodPut, "/v1/session/create", nil, body)
+	if err != nil {
+		return "", err
+	}
+	var s struct{ ID string }
+	if err = json.Unmarshal(b, &s); err != nil {
+		return "", fmt.Errorf("consul: invalid session: %w", err)
+	}
+	return s.ID, nil
+}
+
+// renew keeps the session alive until `ctx` is done.
+func (c *Coordinator) renew(ctx context.Context, session string) {
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = 15 * time.Second
+	}
+	t := time.NewTicker(ttl / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, _, err := c.do(ctx, http.MethodPut, "/v1/session/renew/"+session, nil, nil); err != nil {
+				log.Printf("consul: failed to renew session: %s", err)
+			}
+		}
+	}
+}
+
+func (c *Coordinator) backoff(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	log.Printf("consul: %s", err)
+	select {
+	case <-ctx.Done():
+	case <-time.After(config.DefaultRetryPolicy.Delay(1)):
+	}
+}
+
+type pair struct {
+	Key         string
+	Value       []byte
+	Session     string
+	ModifyIndex uint64
+}
+
+func (c *Coordinator) key(k string) string {
+	return "/v1/kv/" + strings.Trim(c.Prefix, "/") + "/" + k
+}
+
+// get returns the pair at `k`, blocking until it changes from `index` when
+// it's set, along with the index to wait on next.
+func (c *Coordinator) get(ctx context.Context, k string, index uint64) (pair, bool, uint64, error) {
+	var pairs []pair
+	next, found, err := c.query(ctx, c.key(k), index, nil, &pairs)
+	if err != nil || !found || len(pairs) == 0 {
+		return pair{}, false, next, err
+	}
+	return pairs[0], true, next, nil
+}
+
+// list returns the values of the keys under `prefix`, by their name within
+// it, see get.
+func (c *Coordinator) list(ctx context.Context, prefix string, index uint64) (map[string][]byte, uint64, error) {
+	var pairs []pair
+	next, _, err := c.query(ctx, c.key(prefix), index, url.Values{"recurse": {""}}, &pairs)
+	if err != nil {
+		return nil, next, err
+	}
+	m := make(map[string][]byte, len(pairs))
+	full := strings.TrimPrefix(c.key(prefix), "/v1/kv/")
+	for _, p := range pairs {
+		if name := strings.TrimPrefix(p.Key, full); name != "" {
+			m[name] = p.Value
+		}
+	}
+	return m, next, nil
+}
+
+func (c *Coordinator) query(ctx context.Context, path string, index uint64, q url.Values, v interface{}) (uint64, bool, error) {
+	if q == nil {
+		q = url.Values{}
+	}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", wait.String())
+	}
+	b, h, err := c.do(ctx, http.MethodGet, path, q, nil)
+	next, _ := strconv.ParseUint(h.Get("X-Consul-Index"), 10, 64)
+	if next < index {
+		// The index went backwards, eg. the agent restarted, so start over.
+		next = 0
+	}
+	if errors.Is(err, errNotFound) {
+		return next, false, nil
+	}
+	if err != nil {
+		return index, false, err
+	}
+	return next, true, json.Unmarshal(b, v)
+}
+
+// put sets `k`, acquiring it for `session` when set, returning whether it
+// was set.
+func (c *Coordinator) put(ctx context.Context, k string, value []byte, session string) (bool, error) {
+	var q url.Values
+	if session != "" {
+		q = url.Values{"acquire": {session}}
+	}
+	b, _, err := c.do(ctx, http.MethodPut, c.key(k), q, value)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(b)) == "true", nil
+}
+
+func (c *Coordinator) delete(ctx context.Context, k string) error {
+	_, _, err := c.do(ctx, http.MethodDelete, c.key(k), nil, nil)
+	return err
+}
+
+var errNotFound = errors.New("consul: key not found")
+
+func (c *Coordinator) do(ctx context.Context, method, path string, q url.Values, body []byte) ([]byte, http.Header, error) {
+	addr := c.Addr
+	if addr == "" {
+		addr = "http://127.0.0.1:8500"
+	}
+	u := strings.TrimSuffix(addr, "/") + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, http.Header{}, err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, http.Header{}, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	switch {
+	case err != nil:
+		return nil, resp.Header, err
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, resp.Header, errNotFound
+	case resp.StatusCode/100 != 2:
+		return nil, resp.Header, fmt.Errorf("consul: %s %s: %s %s", method, path, resp.Status, bytes.TrimSpace(b))
+	}
+	return b, resp.Header, nil
+}
@@ -0,0 +1,394 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"code.minty.io/config"
+)
+
+// fakeConsul serves the KV store, with blocking queries, and the sessions
+// used by Coordinator. As with Consul, the index of a query is the latest
+// change to the keys it matches.
+type fakeConsul struct {
+	mu       sync.Mutex
+	index    uint64
+	kv       map[string]pair
+	deleted  map[string]uint64
+	sessions int
+	changed  chan struct{}
+}
+
+func newFakeConsul(t *testing.T) (*fakeConsul, string) {
+	f := &fakeConsul{kv: map[string]pair{}, deleted: map[string]uint64{}, changed: make(chan struct{})}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+// write changes the store via `fn`, under a new index, waking the blocked
+// queries.
+func (f *fakeConsul) write(fn func(index uint64)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.index++
+	fn(f.index)
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) set(key, value string) {
+	f.write(func(index uint64) {
+		p := f.kv[key]
+		p.Key, p.Value, p.ModifyIndex = key, []byte(value), index
+		f.kv[key] = p
+	})
+}
+
+func (f *fakeConsul) remove(key string) {
+	f.write(func(index uint64) {
+		delete(f.kv, key)
+		f.deleted[key] = index
+	})
+}
+
+func (f *fakeConsul) value(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.kv[key]
+	return string(p.Value), ok
+}
+
+// await waits for `cond` to hold over the store.
+func (f *fakeConsul) await(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		f.mu.Lock()
+		changed := f.changed
+		f.mu.Unlock()
+		if cond() {
+			return
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func (f *fakeConsul) query(key string, recurse bool) ([]pair, uint64) {
+	var pairs []pair
+	var index uint64
+	matches := func(k string) bool { return k == key || (recurse && strings.HasPrefix(k, key)) }
+	for k, p := range f.kv {
+		if matches(k) {
+			pairs = append(pairs, p)
+			index = max(index, p.ModifyIndex)
+		}
+	}
+	for k, i := range f.deleted {
+		if matches(k) {
+			index = max(index, i)
+		}
+	}
+	if index == 0 {
+		index = f.index
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+	return pairs, index
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	switch path := r.URL.Path; {
+	case path == "/v1/session/create":
+		f.mu.Lock()
+		f.sessions++
+		id := fmt.Sprintf("session-%d", f.sessions)
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"ID": id})
+	case strings.HasPrefix(path, "/v1/session/renew/"):
+	case strings.HasPrefix(path, "/v1/session/destroy/"):
+		session := strings.TrimPrefix(path, "/v1/session/destroy/")
+		f.write(func(index uint64) {
+			for k, p := range f.kv {
+				if p.Session == session {
+					delete(f.kv, k)
+					f.deleted[k] = index
+				}
+			}
+		})
+	case strings.HasPrefix(path, "/v1/kv/"):
+		key := strings.TrimPrefix(path, "/v1/kv/")
+		switch r.Method {
+		case http.MethodGet:
+			_, recurse := q["recurse"]
+			after, _ := strconv.ParseUint(q.Get("index"), 10, 64)
+			f.mu.Lock()
+			pairs, index := f.query(key, recurse)
+			for index <= after {
+				changed := f.changed
+				f.mu.Unlock()
+				select {
+				case <-changed:
+				case <-r.Context().Done():
+					return
+				}
+				f.mu.Lock()
+				pairs, index = f.query(key, recurse)
+			}
+			f.mu.Unlock()
+			w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+			if len(pairs) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(pairs)
+		case http.MethodPut:
+			b, _ := io.ReadAll(r.Body)
+			acquire := q.Get("acquire")
+			ok := true
+			f.write(func(index uint64) {
+				p := f.kv[key]
+				if acquire != "" {
+					if p.Session != "" && p.Session != acquire {
+						ok = false
+						return
+					}
+					p.Session = acquire
+				}
+				p.Key, p.Value, p.ModifyIndex = key, b, index
+				f.kv[key] = p
+			})
+			fmt.Fprint(w, ok)
+		case http.MethodDelete:
+			f.remove(key)
+			fmt.Fprint(w, true)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fleet registers the instances `ids` under `prefix`, at `version`, as their
+// own coordinators would, so their waves are driven by the test.
+func fleet(f *fakeConsul, prefix, version string, ids ...string) {
+	for _, id := range ids {
+		f.set(prefix+"/instances/"+id, id)
+		report(f, prefix, id, Status{Version: version, Healthy: true})
+	}
+}
+
+func report(f *fakeConsul, prefix, id string, s Status) {
+	b, _ := json.Marshal(s)
+	f.set(prefix+"/status/"+id, string(b))
+}
+
+// assigned returns the condition of `ids` being assigned `version`.
+func assigned(f *fakeConsul, prefix, version string, ids ...string) func() bool {
+	return func() bool {
+		for _, id := range ids {
+			if v, _ := f.value(prefix + "/assign/" + id); v != version {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// leader starts leading the fleet via `c`, until the test is done.
+func leader(t *testing.T, c *Coordinator) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	session, err := c.session(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go c.lead(ctx, session)
+	return ctx
+}
+
+func TestRolloutWaves(t *testing.T) {
+	f, addr := newFakeConsul(t)
+	fleet(f, "app", "v1", "a", "b", "c", "d")
+	c := &Coordinator{Addr: addr, Prefix: "app", ID: "a", Percent: 50}
+	ctx := leader(t, c)
+
+	if err := c.Rollout(ctx, "v2"); err != nil {
+		t.Fatal(err)
+	}
+	f.await(t, "the first wave", assigned(f, "app", "v2", "a", "b"))
+	if _, ok := f.value("app/assign/c"); ok {
+		t.Fatal("assigned c before the first wave reported")
+	}
+	report(f, "app", "a", Status{Version: "v2", Healthy: true})
+	if _, ok := f.value("app/assign/c"); ok {
+		t.Fatal("assigned c before the first wave was healthy")
+	}
+	report(f, "app", "b", Status{Version: "v2", Healthy: true})
+	f.await(t, "the second wave", assigned(f, "app", "v2", "c", "d"))
+
+	// Instances leaving are skipped.
+	f.remove("app/instances/d")
+	report(f, "app", "c", Status{Version: "v2", Healthy: true})
+
+	// Once rolled out, the next target is rolled out across the instances
+	// left.
+	if err := c.Rollout(ctx, "v3"); err != nil {
+		t.Fatal(err)
+	}
+	f.await(t, "the first wave of v3", assigned(f, "app", "v3", "a", "b"))
+	if v, _ := f.value("app/assign/c"); v != "v2" {
+		t.Errorf("c assigned %s within the first wave of v3, want 2 of the 3 instances", v)
+	}
+	if _, halted := f.value("app/halted"); halted {
+		t.Error("halted a healthy rollout")
+	}
+}
+
+func TestRolloutHalts(t *testing.T) {
+	f, addr := newFakeConsul(t)
+	fleet(f, "app", "v1", "a", "b", "c", "d")
+	c := &Coordinator{Addr: addr, Prefix: "app", ID: "a", Percent: 50}
+	ctx := leader(t, c)
+
+	if err := c.Rollout(ctx, "v2"); err != nil {
+		t.Fatal(err)
+	}
+	f.await(t, "the first wave", assigned(f, "app", "v2", "a", "b"))
+	report(f, "app", "a", Status{Version: "v2", Healthy: true})
+	report(f, "app", "b", Status{Version: "v2", Error: "database unreachable"})
+	f.await(t, "the rollout to halt", func() bool {
+		_, halted := f.value("app/halted")
+		return halted
+	})
+	if why, _ := f.value("app/halted"); !strings.Contains(why, "instance b is unhealthy: database unreachable") {
+		t.Errorf("halted as %q, want b's error", why)
+	}
+	for _, id := range []string{"c", "d"} {
+		if v, ok := f.value("app/assign/" + id); ok {
+			t.Errorf("%s assigned %s by a halted rollout", id, v)
+		}
+	}
+
+	// Rolling the previous version back out clears the halt.
+	if err := c.Rollout(ctx, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, halted := f.value("app/halted"); halted {
+		t.Error("halted once rolled out again")
+	}
+	f.await(t, "the rollback", assigned(f, "app", "v1", "a", "b"))
+	for _, id := range []string{"c", "d"} {
+		if v, ok := f.value("app/assign/" + id); ok {
+			t.Errorf("%s assigned %s, while at the version rolled back to", id, v)
+		}
+	}
+}
+
+// admin is the Admin of the instance's config file, opened once, as only one
+// Admin may be.
+var admin struct {
+	sync.Once
+	a    *config.Admin
+	file string
+	err  error
+}
+
+// openAdmin returns the Admin, with its file at `doc`.
+func openAdmin(t *testing.T, doc string) (*config.Admin, string) {
+	admin.Do(func() {
+		var dir string
+		if dir, admin.err = os.MkdirTemp("", "consul"); admin.err != nil {
+			return
+		}
+		admin.file = filepath.Join(dir, "config.json")
+		os.WriteFile(admin.file, []byte(doc), 0600)
+		admin.a, admin.err = config.Open(config.File(admin.file))
+	})
+	if admin.err != nil {
+		t.Fatal(admin.err)
+	}
+	os.WriteFile(admin.file, []byte(doc), 0600)
+	if err := admin.a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	return admin.a, admin.file
+}
+
+func TestRunRollsBack(t *testing.T) {
+	f, addr := newFakeConsul(t)
+	a, file := openAdmin(t, `{"port": 9090}`)
+	c := &Coordinator{Addr: addr, Prefix: "app", ID: "a", Admin: a, Health: func(c config.Config) error {
+		if port, _ := c.Int("port"); port == 80 {
+			return fmt.Errorf("port %d is unreachable", port)
+		}
+		return nil
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+	reported := func() Status {
+		b, _ := f.value("app/status/a")
+		return status([]byte(b))
+	}
+	f.await(t, "the instance to register", func() bool {
+		_, ok := f.value("app/status/a")
+		return ok
+	})
+
+	// A reload failing its health check is rolled back, halting the rollout.
+	os.WriteFile(file, []byte(`{"port": 80}`), 0600)
+	if err := c.Rollout(ctx, "v2"); err != nil {
+		t.Fatal(err)
+	}
+	f.await(t, "the rollout to halt", func() bool {
+		_, halted := f.value("app/halted")
+		return halted
+	})
+	if port, _ := config.Current().Int("port"); port != 9090 {
+		t.Errorf("port = %d, want 9090, as rolled back to", port)
+	}
+	if s := reported(); s.Version != "v2" || s.Healthy || s.Error != "port 80 is unreachable" {
+		t.Errorf("reported %+v, want v2 unhealthy", s)
+	}
+
+	os.WriteFile(file, []byte(`{"port": 8080}`), 0600)
+	if err := c.Rollout(ctx, "v3"); err != nil {
+		t.Fatal(err)
+	}
+	f.await(t, "v3 to be applied", func() bool { return reported().Version == "v3" })
+	if s := reported(); !s.Healthy {
+		t.Errorf("reported %+v, want v3 healthy", s)
+	}
+	if port, _ := config.Current().Int("port"); port != 8080 {
+		t.Errorf("port = %d, want 8080, as reloaded", port)
+	}
+	if _, halted := f.value("app/halted"); halted {
+		t.Error("halted a healthy rollout")
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"strings"
+)
+
+type overridesCtxKey struct{}
+
+// WithOverrides returns a copy of `ctx` carrying `overrides`, which shadow
+// the current config for lookups via Ctx, eg. a request's experiment
+// parameters. Keys may be dot separated paths, eg. `search.limit`, and groups
+// are deep-merged. Overrides already within `ctx` are kept, beneath the new
+// ones. The current config itself is never changed.
+func WithOverrides(ctx context.Context, overrides map[string]interface{}) context.Context {
+	m := make(map[string]interface{}, len(overrides))
+	for key, v := range overrides {
+		if g, ok := v.(map[string]interface{}); ok {
+			v = copyVal(g)
+		}
+		set(m, strings.Split(key, "."), v)
+	}
+	if prev, ok := ctx.Value(overridesCtxKey{}).(map[string]interface{}); ok {
+		m = merge(prev, m)
+	}
+	return context.WithValue(ctx, overridesCtxKey{}, m)
+}
+
+// Overrides returns the overrides carried by `ctx`, see WithOverrides.
+func Overrides(ctx context.Context) map[string]interface{} {
+	m, _ := ctx.Value(overridesCtxKey{}).(map[string]interface{})
+	return m
+}
+
+// Ctx returns the current config with the overrides carried by `ctx` merged
+// over it, eg.
+//
+//	limit, _ := config.Ctx(r.Context()).Group("search").Int("limit")
+func Ctx(ctx context.Context) Config {
+	return Current().Ctx(ctx)
+}
+
+// Ctx returns the config with the overrides carried by `ctx` merged over it.
+func (c Config) Ctx(ctx context.Context) Config {
+	o := Overrides(ctx)
+	if len(o) == 0 {
+		return c
+	}
+	return c.with(merge(c.m, o))
+}
@@ -0,0 +1,112 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CredentialsSource reads the configuration from a directory of credential
+// files, each the value of the key it's named by, where dots split a name
+// into groups and a key, eg. `db.password`.
+type CredentialsSource struct {
+	Dir string
+	// Lazy defers reading each credential to the first use of its value, eg.
+	// so a socket activated service only reads the credentials of the
+	// requests it ends up serving. Failures to read are logged, leaving the
+	// value empty.
+	Lazy bool
+}
+
+// Credentials returns a source of the systemd credentials passed to the
+// service (see LoadCredential= and LoadCredentialEncrypted= of
+// systemd.exec), within `$CREDENTIALS_DIRECTORY`, so secrets don't need
+// world readable files, eg. with
+//
+//	LoadCredential=db.password:/etc/app/db.password
+//
+// `db.password` is the `password` key within the `db` group, so a `db`
+// credential alongside it is an error. Values are SecretStrings, less a
+// trailing newline, so they're redacted from dumps, diffs, and audit events,
+// and are coerced on access. Without a credentials directory, the source
+// doesn't exist, so a Chain skips it.
+func Credentials() *CredentialsSource {
+	return &CredentialsSource{Dir: os.Getenv("CREDENTIALS_DIRECTORY")}
+}
+
+func (s *CredentialsSource) Read() (Config, error) {
+	names, err := s.names()
+	if err != nil {
+		return *new(Config), err
+	}
+	m := make(map[string]interface{})
+	for _, name := range names {
+		var v *SecretString
+		if s.Lazy {
+			name := name
+			v = lazySecret(func() []byte {
+				b, err := s.read(name)
+				if err != nil {
+					log.Printf("config: %s", err)
+				}
+				return b
+			})
+		} else if b, err := s.read(name); err != nil {
+			return *new(Config), err
+		} else {
+			v = newSecret(b)
+		}
+		set(m, strings.Split(name, "."), v)
+	}
+	return Config{m: m, coerce: true}, nil
+}
+
+func (s *CredentialsSource) Name() string {
+	return "credentials " + s.Dir
+}
+
+// names returns the names of the credentials.
+func (s *CredentialsSource) names() ([]string, error) {
+	if s.Dir == "" {
+		return nil, fmt.Errorf("no credentials directory: %w", fs.ErrNotExist)
+	}
+	entries, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if name := e.Name(); !e.IsDir() && !strings.HasPrefix(name, ".") && !contains(strings.Split(name, "."), "") {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	// A credential can't be a key, and the group of another.
+	for _, name := range names {
+		for i := range name {
+			if name[i] == '.' && seen[name[:i]] {
+				return nil, fmt.Errorf("credential %s conflicts with %s, a key within it", name[:i], name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// read returns the value of the credential `name`, less a trailing newline.
+func (s *CredentialsSource) read(name string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.Dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential %s: %w", name, err)
+	}
+	return bytes.TrimSuffix(b, []byte("\n")), nil
+}
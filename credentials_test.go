@@ -0,0 +1,52 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredentials(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "db.password"), []byte("hunter2\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "db.port"), []byte("5432"), 0600)
+	s := &CredentialsSource{Dir: dir}
+	c, err := s.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := c.Group("db")
+	if password, _ := db.String("password"); password != "hunter2" {
+		t.Errorf("password = %q, want hunter2", password)
+	}
+	if port, _ := db.Int("port"); port != 5432 {
+		t.Errorf("port = %d, want 5432", port)
+	}
+	var buf bytes.Buffer
+	c.Dump(&buf)
+	if strings.Contains(buf.String(), "hunter2") || !c.IsSensitive("db.password") {
+		t.Errorf("dumped %s, want the credentials redacted", &buf)
+	}
+
+	// Lazy credentials are read on first use.
+	s.Lazy = true
+	if c, err = s.Read(); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "db.password"), []byte("hunter3\n"), 0600)
+	if password, _ := c.Group("db").String("password"); password != "hunter3" {
+		t.Errorf("lazy password = %q, want hunter3, as read on use", password)
+	}
+
+	// A key can't also be a group.
+	os.WriteFile(filepath.Join(dir, "db"), []byte("postgres://db"), 0600)
+	if _, err = s.Read(); err == nil {
+		t.Error("read the credentials db, and db.password")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cue reads configuration written in CUE, whose constraints, and
+// types, validate the values defined alongside them, so a config needs no
+// separate schema, eg.
+//
+//	import _ "code.minty.io/config/cue"
+//
+//	// config.cue
+//	server: {
+//		host: string | *"localhost"
+//		port: int & >0 & <65536 | *8080
+//	}
+//	server: port: 9090
+//
+// Importing the package registers the `.cue` format (see
+// config.RegisterFormat), so File and Glob read `.cue` files. Files evaluates
+// several files together, eg. a schema and the environment's values.
+//
+// Documents must evaluate to concrete values: unresolved disjunctions,
+// incomplete values, or violated constraints fail the read, with the
+// positions of the offending values.
+package cue
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"code.minty.io/config"
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+)
+
+func init() {
+	config.RegisterFormat(".cue", config.DecoderFunc(Decode))
+}
+
+// Decode evaluates the CUE document `b`, returning its concrete values.
+func Decode(b []byte) (map[string]interface{}, error) {
+	return eval(map[string][]byte{"-": b}, []string{"-"})
+}
+
+// Source reads the configuration from CUE files, evaluated together.
+type Source struct {
+	Paths []string
+}
+
+// Files returns a source unifying the CUE files at `paths`, eg.
+//
+//	cue.Files("schema.cue", "prod.cue")
+func Files(paths ...string) *Source {
+	return &Source{paths}
+}
+
+func (s *Source) Read() (config.Config, error) {
+	files := make(map[string][]byte, len(s.Paths))
+	for _, path := range s.Paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return *new(config.Config), err
+		}
+		files[path] = b
+	}
+	m, err := eval(files, s.Paths)
+	if err != nil {
+		return *new(config.Config), err
+	}
+	return config.FromMap(m), nil
+}
+
+func (s *Source) Name() string {
+	return "cue " + strings.Join(s.Paths, ", ")
+}
+
+// eval unifies the files, by name, in the order of `names`.
+func eval(files map[string][]byte, names []string) (map[string]interface{}, error) {
+	ctx := cuecontext.New()
+	var v cue.Value
+	for i, name := range names {
+		f := ctx.CompileBytes(files[name], cue.Filename(name))
+		if err := f.Err(); err != nil {
+			return nil, cueError(err)
+		}
+		if i == 0 {
+			v = f
+		} else {
+			v = v.Unify(f)
+		}
+	}
+	if err := v.Validate(cue.Concrete(true), cue.Final()); err != nil {
+		return nil, cueError(err)
+	}
+	var m map[string]interface{}
+	if err := v.Decode(&m); err != nil {
+		return nil, cueError(err)
+	}
+	return m, nil
+}
+
+// cueError returns `err` with the details of every error it holds, with
+// their positions.
+func cueError(err error) error {
+	return fmt.Errorf("cue: %s", strings.TrimSpace(errors.Details(err, nil)))
+}
@@ -0,0 +1,272 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Dashboard is an http.Handler serving a small page to inspect the current
+// config, as redacted by Redact, diff its generations (see History), toggle
+// feature flags, and reload it. Paths are relative to where it's mounted, eg.
+//
+//	mux.Handle("/debug/config/", http.StripPrefix("/debug/config", &config.Dashboard{
+//		Admin: admin,
+//		Auth:  requireOperator,
+//	}))
+type Dashboard struct {
+	// Admin reloads, and changes, the current config. When nil, reloads
+	// aren't available, and flags are toggled via ApplyPatch.
+	Admin *Admin
+	// Auth wraps every request, eg. checking credentials, and must be set;
+	// requests are refused otherwise. See AllowAll.
+	Auth func(http.Handler) http.Handler
+	// FlagsGroup is the dot separated path of the group holding the feature
+	// flags, its bools, `features` when empty.
+	FlagsGroup string
+}
+
+// AllowAll is a Dashboard Auth allowing every request, for when the handler
+// is only reachable by operators, eg. served on a loopback address.
+func AllowAll(h http.Handler) http.Handler {
+	return h
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if d.Auth == nil {
+		http.Error(w, "config dashboard has no Auth", http.StatusForbidden)
+		return
+	}
+	d.Auth(http.HandlerFunc(d.serve)).ServeHTTP(w, r)
+}
+
+func (d *Dashboard) serve(w http.ResponseWriter, r *http.Request) {
+	route := r.Method + " " + r.URL.Path
+	if r.Method == http.MethodPost && !isJSON(r) {
+		// Forms can't send JSON, so requests can't be forged cross-site.
+		http.Error(w, "expected a JSON request", http.StatusUnsupportedMediaType)
+		return
+	}
+	switch route {
+	case "GET /":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, dashboardHTML)
+	case "GET /api/config":
+		c := Current()
+		writeJSON(w, map[string]interface{}{"generation": Generation(), "config": c.Redact()})
+	case "GET /api/history":
+		writeJSON(w, History())
+	case "GET /api/diff":
+		d.diff(w, r)
+	case "GET /api/flags":
+		writeJSON(w, d.flags())
+	case "POST /api/flags":
+		d.toggle(w, r)
+	case "POST /api/reload":
+		if d.Admin == nil {
+			http.Error(w, "reloading requires the config's Admin", http.StatusNotImplemented)
+			return
+		}
+		if err := d.Admin.Reload(); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"generation": Generation()})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// diff writes the changes between the generations `from` and `to`,
+// defaulting to the current generation and the one before it.
+func (d *Dashboard) diff(w http.ResponseWriter, r *http.Request) {
+	to, err := queryGeneration(r, "to", Generation())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := queryGeneration(r, "from", to-1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a, okFrom := RevisionAt(from)
+	b, okTo := RevisionAt(to)
+	if !okFrom || !okTo {
+		http.Error(w, "generation not within the history", http.StatusNotFound)
+		return
+	}
+	changes := Diff(a.Config(), b.Config())
+	if changes == nil {
+		changes = []Change{}
+	}
+	writeJSON(w, changes)
+}
+
+func (d *Dashboard) flagsGroup() string {
+	if d.FlagsGroup == "" {
+		return "features"
+	}
+	return d.FlagsGroup
+}
+
+// flags returns the feature flags, by their path within the flags group.
+func (d *Dashboard) flags() map[string]bool {
+	flags := make(map[string]bool)
+	c := Current()
+	c.Scoped(d.flagsGroup()).Walk(func(path string, v interface{}) bool {
+		if b, ok := v.(bool); ok {
+			flags[path] = b
+		}
+		return true
+	})
+	return flags
+}
+
+// toggle sets an existing feature flag, from a `{"path": "beta", "enabled":
+// true}` request.
+func (d *Dashboard) toggle(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path    string `json:"path"`
+		Enabled bool   `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := d.flags()[req.Path]; !ok {
+		http.Error(w, fmt.Sprintf("unknown feature flag %q", req.Path), http.StatusNotFound)
+		return
+	}
+	m := make(map[string]interface{})
+	set(m, strings.Split(d.flagsGroup()+"."+req.Path, "."), req.Enabled)
+	patch, _ := json.Marshal(m)
+	var err error
+	if d.Admin != nil {
+		err = d.Admin.Patch(patch, MergePatch)
+	} else {
+		err = ApplyPatch(patch, MergePatch)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"generation": Generation()})
+}
+
+func queryGeneration(r *http.Request, name string, def uint64) (uint64, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	gen, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid generation %q", s)
+	}
+	return gen, nil
+}
+
+func isJSON(r *http.Request) bool {
+	t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && t == "application/json"
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes `err`, as a conflict when the config can't be changed.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrFrozen) || errors.Is(err, ErrReadOnly) {
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>config</title>
+<style>
+body { font: 14px sans-serif; margin: 2em; }
+pre { background: #f4f4f4; padding: 1em; overflow: auto; }
+section { margin-bottom: 2em; }
+</style>
+</head>
+<body>
+<h1>config <small id="gen"></small> <button onclick="reload()">Reload</button></h1>
+<p id="error" style="color: #c00"></p>
+<section><h2>Feature flags</h2><div id="flags"></div></section>
+<section>
+<h2>Diff</h2>
+<select id="from"></select> &rarr; <select id="to"></select> <button onclick="diff()">Diff</button>
+<pre id="diff"></pre>
+</section>
+<section><h2>Config</h2><pre id="config"></pre></section>
+<script>
+function api(method, path, body) {
+	var opts = {method: method, headers: {"Content-Type": "application/json"}};
+	if (body !== undefined) opts.body = JSON.stringify(body);
+	return fetch("api/" + path, opts).then(function(r) {
+		if (!r.ok) return r.text().then(function(t) { throw new Error(t); });
+		return r.json();
+	}).catch(function(e) { document.getElementById("error").textContent = e.message; throw e; });
+}
+function text(v) { return JSON.stringify(v, null, 2); }
+function refresh() {
+	document.getElementById("error").textContent = "";
+	api("GET", "config").then(function(r) {
+		document.getElementById("gen").textContent = "generation " + r.generation;
+		document.getElementById("config").textContent = text(r.config);
+	});
+	api("GET", "flags").then(function(flags) {
+		var div = document.getElementById("flags");
+		div.innerHTML = "";
+		Object.keys(flags).sort().forEach(function(path) {
+			var label = document.createElement("label"), box = document.createElement("input");
+			box.type = "checkbox";
+			box.checked = flags[path];
+			box.onchange = function() { api("POST", "flags", {path: path, enabled: box.checked}).then(refresh); };
+			label.appendChild(box);
+			label.appendChild(document.createTextNode(" " + path));
+			div.appendChild(label);
+			div.appendChild(document.createElement("br"));
+		});
+	});
+	api("GET", "history").then(function(revs) {
+		["from", "to"].forEach(function(id, i) {
+			var sel = document.getElementById(id);
+			sel.innerHTML = "";
+			revs.forEach(function(rev) {
+				var opt = document.createElement("option");
+				opt.value = rev.generation;
+				opt.textContent = rev.generation + " (" + rev.time + ")";
+				sel.appendChild(opt);
+			});
+			sel.selectedIndex = Math.max(0, revs.length - 2 + i);
+		});
+	});
+}
+function diff() {
+	var from = document.getElementById("from").value, to = document.getElementById("to").value;
+	api("GET", "diff?from=" + from + "&to=" + to).then(function(changes) {
+		document.getElementById("diff").textContent = changes.length ? text(changes) : "no changes";
+	});
+}
+function reload() { api("POST", "reload").then(refresh); }
+refresh();
+</script>
+</body>
+</html>
+`
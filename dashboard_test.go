@@ -0,0 +1,87 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(f, []byte(`{"port": 9090, "db": {"$sensitive": true, "password": "hunter2"}, "features": {"beta": false, "new_ui": {"enabled": true}}}`), 0600)
+	a := openTest(t, File(f))
+	d := &Dashboard{Admin: a, Auth: AllowAll}
+	do := func(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do(&Dashboard{Admin: a}, "GET", "/", ""); w.Code != http.StatusForbidden {
+		t.Errorf("without Auth: %d, want forbidden", w.Code)
+	}
+	if w := do(d, "POST", "/api/reload", ""); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("POST without JSON: %d, want refused", w.Code)
+	}
+	w := do(d, "GET", "/api/config", "")
+	if strings.Contains(w.Body.String(), "hunter2") {
+		t.Errorf("config %s, want the password redacted", w.Body)
+	}
+
+	var flags map[string]bool
+	json.Unmarshal(do(d, "GET", "/api/flags", "").Body.Bytes(), &flags)
+	if len(flags) != 2 || flags["beta"] || !flags["new_ui.enabled"] {
+		t.Errorf("flags = %v", flags)
+	}
+	if w = do(d, "POST", "/api/flags", `{"path": "missing", "enabled": true}`); w.Code != http.StatusNotFound {
+		t.Errorf("toggling an unknown flag: %d, want not found", w.Code)
+	}
+	before := Generation()
+	if w = do(d, "POST", "/api/flags", `{"path": "beta", "enabled": true}`); w.Code != http.StatusOK {
+		t.Fatalf("toggling beta: %d %s", w.Code, w.Body)
+	}
+	if beta, _ := Current().Group("features").Bool("beta"); !beta {
+		t.Error("beta not enabled")
+	}
+
+	var changes []Change
+	json.Unmarshal(do(d, "GET", "/api/diff", "").Body.Bytes(), &changes)
+	if len(changes) != 1 || changes[0].Path != "features.beta" || changes[0].New != true {
+		t.Errorf("diff = %+v, want beta enabled", changes)
+	}
+	if w = do(d, "GET", fmt.Sprintf("/api/diff?from=%d&to=%d", before, before), ""); w.Body.String() != "[]\n" {
+		t.Errorf("diff of a generation with itself = %s", w.Body)
+	}
+	if w = do(d, "GET", "/api/diff?from=x", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("diff from an invalid generation: %d", w.Code)
+	}
+
+	// Reloading re-reads the file, keeping the toggle, a runtime override.
+	os.WriteFile(f, []byte(`{"port": 80, "features": {"beta": false}}`), 0600)
+	if w = do(d, "POST", "/api/reload", "{}"); w.Code != http.StatusOK {
+		t.Fatalf("reloading: %d %s", w.Code, w.Body)
+	}
+	c := Current()
+	if port, _ := c.Int("port"); port != 80 {
+		t.Errorf("port = %d after reloading, want the file's 80", port)
+	}
+	if beta, _ := c.Group("features").Bool("beta"); !beta {
+		t.Error("beta disabled by reloading, want the toggle kept")
+	}
+	if w = do(&Dashboard{Auth: AllowAll}, "POST", "/api/reload", "{}"); w.Code != http.StatusNotImplemented {
+		t.Errorf("reloading without an Admin: %d", w.Code)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// WithNumbers decodes numbers as json.Number rather than float64, so they
+// keep every digit, eg. for Decimal. The accessors, and Bind, convert them as
+// needed.
+func WithNumbers() Option {
+	return func(o *options) { o.numbers = true }
+}
+
+// Decimal returns the exact decimal for the `key` within the root level, for
+// values such as prices and fees, which must not be rounded by float64.
+// Strings (eg. "19.99") and json.Numbers (see WithNumbers) are exact, as are
+// ints. Other numbers were decoded as float64, and are exact only up to 15
+// significant digits.
+func (c Config) Decimal(key string) (*big.Rat, error) {
+	v, ok := c.Val(key)
+	if !ok {
+		return nil, fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = strings.TrimSpace(v)
+	case json.Number:
+		s = string(v)
+	case float64:
+		// The shortest representation is what was written, for up to 15
+		// significant digits.
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), nil
+	default:
+		return nil, fmt.Errorf("invalid '%s' decimal %v", key, v)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/xXoObB_") {
+		return nil, fmt.Errorf("invalid '%s' decimal %q", key, s)
+	}
+	return r, nil
+}
+
+// RequiredDecimal returns the decimal, within the root, and exits when not
+// found or invalid.
+func (c Config) RequiredDecimal(key string) *big.Rat {
+	r, err := c.Decimal(key)
+	if err != nil {
+		log.Fatalf("failed to retrieve '%s' decimal from config: %s", key, err)
+	}
+	return r
+}
+
+// Decimal returns the exact decimal for the `key` within the root level.
+func Decimal(key string) (*big.Rat, error) {
+	return cfg.Decimal(key)
+}
+
+// RequiredDecimal returns the decimal, within the root, and exits when not
+// found or invalid.
+func RequiredDecimal(key string) *big.Rat {
+	return cfg.RequiredDecimal(key)
+}
@@ -0,0 +1,100 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// DerivedCacheSize is the number of parse results kept by Derived, the least
+// recently used being dropped first.
+var DerivedCacheSize = 256
+
+// DeriveFunc parses a config value into some more expensive form, eg. a
+// regexp, a template, or a certificate.
+type DeriveFunc func(v interface{}) (interface{}, error)
+
+type derivedKey struct {
+	path  string
+	parse uintptr
+	sum   [sha256.Size]byte
+}
+
+type derivedEntry struct {
+	key derivedKey
+	v   interface{}
+	err error
+}
+
+var (
+	derivedMu      sync.Mutex
+	derivedOrder   = list.New()
+	derivedEntries = make(map[derivedKey]*list.Element)
+)
+
+// Derived returns the result of `parse` over the value of `key`, eg.
+//
+//	v, err := c.Derived("pattern", func(v interface{}) (interface{}, error) {
+//		s, _ := v.(string)
+//		return regexp.Compile(s)
+//	})
+//	re := v.(*regexp.Regexp)
+//
+// Results, errors included, are cached by the key, the value's hash, and the
+// function `parse`, so a changed value is parsed again while an unchanged one
+// never is. As closures share their function, `parse` must only depend on the
+// value it's given.
+func (c Config) Derived(key string, parse DeriveFunc) (interface{}, error) {
+	v, ok := c.value(key)
+	if !ok {
+		return nil, fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	// Secrets are hashed by their values, so rotated ones are parsed again.
+	b, err := marshalCanonical(v, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash '%s': %w", key, err)
+	}
+	path := key
+	if c.path != "" {
+		path = c.path + "." + key
+	}
+	k := derivedKey{path, reflect.ValueOf(parse).Pointer(), sha256.Sum256(b)}
+
+	derivedMu.Lock()
+	if e, ok := derivedEntries[k]; ok {
+		derivedOrder.MoveToFront(e)
+		d := e.Value.(*derivedEntry)
+		derivedMu.Unlock()
+		return d.v, d.err
+	}
+	derivedMu.Unlock()
+
+	// Parse without the lock, concurrent misses at worst parse twice.
+	d := &derivedEntry{key: k}
+	d.v, d.err = parse(copyVal(v))
+
+	derivedMu.Lock()
+	defer derivedMu.Unlock()
+	if e, ok := derivedEntries[k]; ok {
+		derivedOrder.Remove(e)
+	}
+	derivedEntries[k] = derivedOrder.PushFront(d)
+	for derivedOrder.Len() > DerivedCacheSize && derivedOrder.Len() > 1 {
+		e := derivedOrder.Back()
+		derivedOrder.Remove(e)
+		delete(derivedEntries, e.Value.(*derivedEntry).key)
+	}
+	return d.v, d.err
+}
+
+// Derived returns the result of `parse` over the value of `key`, see
+// Config.Derived.
+func Derived(key string, parse DeriveFunc) (interface{}, error) {
+	return Current().Derived(key, parse)
+}
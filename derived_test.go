@@ -0,0 +1,82 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"container/list"
+	"errors"
+	"testing"
+)
+
+func TestDerived(t *testing.T) {
+	derivedMu.Lock()
+	derivedOrder.Init()
+	derivedEntries = make(map[derivedKey]*list.Element)
+	derivedMu.Unlock()
+
+	var parses int
+	parse := func(v interface{}) (interface{}, error) {
+		parses++
+		s, _ := v.(string)
+		if sec, ok := v.(*SecretString); ok {
+			s = sec.Reveal()
+		}
+		if s == "" {
+			return nil, errors.New("empty")
+		}
+		return len(s), nil
+	}
+	derive := func(c Config, key string) (interface{}, error) {
+		return c.Derived(key, parse)
+	}
+
+	c := FromMap(map[string]interface{}{"name": "app", "empty": ""})
+	for i := 0; i < 2; i++ {
+		if v, err := derive(c, "name"); err != nil || v != 3 {
+			t.Fatalf("derived %v, %v, want 3", v, err)
+		}
+	}
+	if parses != 1 {
+		t.Errorf("%d parses of an unchanged value, want 1", parses)
+	}
+	if v, _ := derive(FromMap(map[string]interface{}{"name": "service"}), "name"); v != 7 || parses != 2 {
+		t.Errorf("derived %v after %d parses, want a changed value parsed again", v, parses)
+	}
+	// Errors are cached too.
+	for i := 0; i < 2; i++ {
+		if _, err := derive(c, "empty"); err == nil {
+			t.Error("derived an empty value")
+		}
+	}
+	if parses != 3 {
+		t.Errorf("%d parses, want the error cached", parses)
+	}
+	if _, err := derive(c, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing key: error = %v, want ErrNotFound", err)
+	}
+
+	// Secrets are told apart by their values, though they're dumped alike.
+	s := FromMap(map[string]interface{}{"cert": NewSecretString("abc")})
+	rotated := FromMap(map[string]interface{}{"cert": NewSecretString("abcdef")})
+	if a, _ := derive(s, "cert"); a != 3 {
+		t.Errorf("derived %v from the secret, want 3", a)
+	}
+	if b, _ := derive(rotated, "cert"); b != 6 {
+		t.Errorf("derived %v from the rotated secret, want 6", b)
+	}
+
+	// The least recently used results are dropped.
+	defer func(n int) { DerivedCacheSize = n }(DerivedCacheSize)
+	DerivedCacheSize = 1
+	x := FromMap(map[string]interface{}{"name": "x"})
+	derive(x, "name")
+	parses = 0
+	derive(x, "name")
+	derive(c, "empty")
+	derive(x, "name")
+	if parses != 2 {
+		t.Errorf("%d parses with a cache of 1, want 2", parses)
+	}
+}
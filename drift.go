@@ -0,0 +1,96 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Report lists the differences between the keys of a config and those of a
+// reference, by their dot separated paths, sorted.
+type Report struct {
+	// Missing are the keys of the reference which the config lacks.
+	Missing []string
+	// Extra are the keys of the config which the reference lacks, eg. keys
+	// that were renamed, or removed, by an upgrade.
+	Extra []string
+	// Mismatched are the keys that are a group within one, but a value within
+	// the other.
+	Mismatched []string
+}
+
+// OK returns whether the config is in sync with the reference.
+func (r Report) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// WriteTo writes the report as a list of differences, one per line, eg.
+//
+//	missing: server.idle_timeout
+//	extra: server.keepalive
+func (r Report) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, l := range []struct {
+		name  string
+		paths []string
+	}{{"missing", r.Missing}, {"extra", r.Extra}, {"mismatched", r.Mismatched}} {
+		for _, p := range l.paths {
+			i, err := fmt.Fprintf(w, "%s: %s\n", l.name, p)
+			n += int64(i)
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// CompareToReference compares the keys of the config with those of the
+// reference config `example`, eg. the example config shipped with a release.
+// Only the keys are compared, not their values; lists are compared as values.
+func (c Config) CompareToReference(example []byte) (Report, error) {
+	ref, err := ReadFrom(example)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to read reference config: %w", err)
+	}
+	var r Report
+	compare("", c.m, ref.m, &r)
+	sort.Strings(r.Missing)
+	sort.Strings(r.Extra)
+	sort.Strings(r.Mismatched)
+	return r, nil
+}
+
+func compare(prefix string, m, ref map[string]interface{}, r *Report) {
+	for _, key := range keys(ref) {
+		path := prefix + key
+		v, ok := m[key]
+		if !ok {
+			r.Missing = append(r.Missing, path)
+			continue
+		}
+		g, isGroup := v.(map[string]interface{})
+		rg, refGroup := ref[key].(map[string]interface{})
+		switch {
+		case isGroup && refGroup:
+			compare(path+".", g, rg, r)
+		case isGroup != refGroup:
+			r.Mismatched = append(r.Mismatched, path)
+		}
+	}
+	for _, key := range keys(m) {
+		if _, ok := ref[key]; !ok {
+			r.Extra = append(r.Extra, prefix+key)
+		}
+	}
+}
+
+// CompareToReference compares the current config with the reference config
+// `example`, see Config.CompareToReference.
+func CompareToReference(example []byte) (Report, error) {
+	return cfg.CompareToReference(example)
+}
@@ -0,0 +1,64 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrNotFound is returned by accessors reporting errors, when the key isn't
+// found.
+var ErrNotFound = errors.New("config key not found")
+
+// EnumError is returned by Enum when a value isn't one of the allowed values.
+type EnumError struct {
+	Key     string
+	Value   string
+	Allowed []string
+}
+
+func (e *EnumError) Error() string {
+	return fmt.Sprintf("invalid '%s' value %q, must be one of: %s", e.Key, e.Value, strings.Join(e.Allowed, ", "))
+}
+
+// Enum returns the string for the `key` within the root level, ensuring it's
+// one of `allowed`.
+func (c Config) Enum(key string, allowed ...string) (string, error) {
+	s, ok := c.String(key)
+	if !ok {
+		return "", fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	for _, a := range allowed {
+		if s == a {
+			return s, nil
+		}
+	}
+	return "", &EnumError{key, s, allowed}
+}
+
+// RequiredEnum returns the enum string, within the root, and exits when not
+// found or not allowed.
+func (c Config) RequiredEnum(key string, allowed ...string) string {
+	s, err := c.Enum(key, allowed...)
+	if err != nil {
+		log.Fatalf("failed to retrieve '%s' enum from config: %s", key, err)
+	}
+	return s
+}
+
+// Enum returns the string for the `key` within the root level, ensuring it's
+// one of `allowed`.
+func Enum(key string, allowed ...string) (string, error) {
+	return cfg.Enum(key, allowed...)
+}
+
+// RequiredEnum returns the enum string, within the root, and exits when not
+// found or not allowed.
+func RequiredEnum(key string, allowed ...string) string {
+	return cfg.RequiredEnum(key, allowed...)
+}
@@ -0,0 +1,49 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+)
+
+// Equal returns whether `a` and `b` hold the same values, numbers being equal
+// regardless of their type, eg. an int and the float64 of a decoded document.
+// Their settings (eg. Coerce) aren't compared.
+func Equal(a, b Config) bool {
+	return reflect.DeepEqual(normalize(a.doc()), normalize(b.doc()))
+}
+
+// Hash returns the hex encoded SHA-256 of the config's canonical encoding (see
+// MarshalCanonical), which is the same for any two Equal configs, across
+// platforms and Go versions, eg. to compare the configs in use across a fleet,
+// or to skip reloading an unchanged one. Unlike the encoding, the hash
+// covers the values of SecretStrings, so it changes once they're rotated.
+func (c Config) Hash() string {
+	b, err := marshalCanonical(c.doc(), true)
+	if err != nil {
+		// Only values set programmatically (eg. by SetConfig) can fail, fmt
+		// prints maps sorted by key as well.
+		b = []byte(fmt.Sprintf("%#v", normalize(c.doc())))
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// doc returns the config's document, including one whose root isn't an
+// object.
+func (c Config) doc() interface{} {
+	if c.m == nil && c.root != nil {
+		return c.root
+	}
+	return c.m
+}
+
+// Hash returns the hash of the current config, see Config.Hash.
+func Hash() string {
+	return Current().Hash()
+}
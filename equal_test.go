@@ -0,0 +1,53 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	a := FromMap(map[string]interface{}{
+		"int64": int64(1), "uint8": uint8(2), "float32": float32(0.5), "number": json.Number("3"),
+		"list": []string{"x"},
+	})
+	b := FromMap(map[string]interface{}{
+		"int64": 1.0, "uint8": 2.0, "float32": 0.5, "number": 3.0,
+		"list": []interface{}{"x"},
+	})
+	if !Equal(a, b) {
+		t.Error("numbers of other types, or lists of strings, aren't Equal")
+	}
+	if a.Hash() != b.Hash() {
+		t.Error("Equal configs hash differently")
+	}
+	if Equal(FromMap(map[string]interface{}{"a": "1"}), FromMap(map[string]interface{}{"a": 1.0})) {
+		t.Error("a string is Equal to a number")
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	// Keys sort by their UTF-16 code units, so the surrogates of U+1F600
+	// sort before U+FF61.
+	b, err := MarshalCanonical(map[string]interface{}{"｡": 1, "\U0001F600": 2, "a": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"a\":3,\"\U0001F600\":2,\"｡\":1}"; string(b) != want {
+		t.Errorf("encoded %s, want %s", b, want)
+	}
+
+	// The hash changes once a secret is rotated, though it's encoded as
+	// Redacted.
+	a := FromMap(map[string]interface{}{"password": NewSecretString("a")})
+	rotated := FromMap(map[string]interface{}{"password": NewSecretString("b")})
+	if a.Hash() == rotated.Hash() {
+		t.Error("rotated secret hashes the same")
+	}
+	if b, _ := a.MarshalCanonical(); string(b) != `{"password":"`+Redacted+`"}` {
+		t.Errorf("encoded %s, want the secret redacted", b)
+	}
+}
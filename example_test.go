@@ -5,7 +5,11 @@
 package config_test
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net/url"
+	"strings"
 
 	"code.minty.io/config"
 )
@@ -77,3 +81,336 @@ func Examplerequired_GroupString() {
 	//
 	// panics when not found within `config.json`
 }
+
+func ExampleScoped() {
+	// for a `config.json` file like:
+	/*
+		{
+			"host": "google.com",
+			"links": {
+				"google": "https://google.com"
+			}
+		}
+	*/
+	links := config.Scoped("links")
+	google, ok := links.String("google")
+	fmt.Println(google, ok)
+	// Output:
+	// https://google.com true
+}
+
+func ExampleFromPairs() {
+	c, err := config.FromPairs("server.port", 9090, "host", "localhost")
+	if err != nil {
+		panic(err)
+	}
+	port, ok := c.GroupInt("server", "port")
+	fmt.Println(port, ok)
+	// Output:
+	// 9090 true
+}
+
+func ExampleConfig_Has() {
+	c, err := config.ReadFrom([]byte(`{"cache": null}`))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(c.Has("cache"), c.IsNull("cache"))
+	fmt.Println(c.Has("queue"), c.IsNull("queue"))
+	// Output:
+	// true true
+	// false false
+}
+
+func ExampleGroup() {
+	// for a `config.json` file like:
+	/*
+		{
+			"host": "google.com",
+			"links": {
+				"google": "https://google.com"
+			}
+		}
+	*/
+	google, ok := config.Group("links").String("google")
+	fmt.Println(google, ok)
+	// Output:
+	// https://google.com true
+}
+
+func ExampleWalk() {
+	// for a `config.json` file like:
+	/*
+		{
+			"host": "google.com",
+			"links": {
+				"google": "https://google.com"
+			}
+		}
+	*/
+	config.Walk(func(path string, v interface{}) bool {
+		fmt.Println(path)
+		return true
+	})
+	// Output:
+	// host
+	// links
+	// links.google
+}
+
+func ExampleExportEnv() {
+	// for a `config.json` file like:
+	/*
+		{
+			"host": "google.com",
+			"links": {
+				"google": "https://google.com"
+			}
+		}
+	*/
+	for _, kv := range config.ExportEnv("APP_") {
+		fmt.Println(kv)
+	}
+	// Output:
+	// APP_HOST=google.com
+	// APP_LINKS_GOOGLE=https://google.com
+}
+
+func ExampleBind() {
+	// for a `config.json` file like:
+	/*
+		{
+			"host": "google.com",
+			"links": {
+				"google": "https://google.com"
+			}
+		}
+	*/
+	var c struct {
+		Host  string
+		Links struct {
+			Google *url.URL
+		}
+	}
+	if err := config.Bind(&c); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(c.Host, c.Links.Google.Scheme)
+	// Output:
+	// google.com https
+}
+
+func ExampleDerived() {
+	// for a `config.json` file like:
+	/*
+		{
+			"host": "google.com"
+		}
+	*/
+	// The URL is only parsed again once `host` changes.
+	v, err := config.Derived("host", func(v interface{}) (interface{}, error) {
+		s, _ := v.(string)
+		return url.Parse("https://" + s)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(v.(*url.URL).Hostname())
+	// Output:
+	// google.com
+}
+
+func ExamplePath() {
+	// for a `config.json` file like:
+	/*
+		{
+			"host": "google.com",
+			"links": {
+				"google": "https://google.com"
+			}
+		}
+	*/
+	google := config.Path("links.google")
+	s, _ := google.String()
+	fmt.Println(s)
+	// Output:
+	// https://google.com
+}
+
+func ExampleConfig_Keys() {
+	c := config.FromMap(map[string]interface{}{
+		"server": map[string]interface{}{
+			"tls": map[string]interface{}{"cert": "server.pem"},
+		},
+	})
+	fmt.Println(c.Keys(), c.Keys("server", "tls"), c.Len(), c.Group("missing").IsEmpty())
+	// Output:
+	// [server] [cert] 1 true
+}
+
+func ExampleConfig_KeysMatching() {
+	c := config.FromMap(map[string]interface{}{
+		"db_host": "localhost",
+		"db_port": 5432,
+		"host":    "google.com",
+	})
+	fmt.Println(c.KeysSorted())
+	fmt.Println(c.KeysMatching("db_*"))
+	// Output:
+	// [db_host db_port host]
+	// [db_host db_port]
+}
+
+func ExampleEqual() {
+	a := config.FromMap(map[string]interface{}{"port": 9090})
+	b, _ := config.ReadFrom([]byte(`{"port": 9090}`))
+	fmt.Println(config.Equal(a, b), a.Hash() == b.Hash())
+	// Output:
+	// true true
+}
+
+func ExampleMarshalCanonical() {
+	b, _ := config.MarshalCanonical(map[string]interface{}{
+		"port":  9090.0,
+		"host":  "google.com",
+		"ratio": 0.50,
+	})
+	fmt.Println(string(b))
+	// Output:
+	// {"host":"google.com","port":9090,"ratio":0.5}
+}
+
+func ExampleRegisterResolver() {
+	config.RegisterResolver("env", config.ResolverFunc(func(ctx context.Context, ref string) (config.Lease, error) {
+		return config.Lease{Value: "resolved " + ref}, nil
+	}))
+	defer config.RegisterResolver("env", nil)
+
+	c := config.FromMap(map[string]interface{}{"password": "env:DB_PASSWORD"})
+	c, err := c.Resolve(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(c.RequiredString("password"))
+	// Output:
+	// resolved DB_PASSWORD
+}
+
+func ExampleConfig_Secret() {
+	c := config.FromMap(map[string]interface{}{"password": "hunter2"})
+	s, _ := c.Secret("password")
+	defer s.Zero()
+	fmt.Println(s, s.Reveal())
+	// Output:
+	// [REDACTED] hunter2
+}
+
+// memBackend is a Backend holding a document in memory.
+type memBackend struct {
+	doc string
+}
+
+func (b *memBackend) Load(ctx context.Context) ([]byte, error) { return []byte(b.doc), nil }
+func (b *memBackend) Watch(ctx context.Context) (<-chan struct{}, error) {
+	return make(chan struct{}), nil
+}
+func (b *memBackend) Name() string { return "mem" }
+
+func ExampleRegisterBackend() {
+	config.RegisterBackend("mem", func(uri *url.URL) (config.Backend, error) {
+		return &memBackend{doc: `{"server": {"port": 9090}}`}, nil
+	})
+	defer config.RegisterBackend("mem", nil)
+
+	s, err := config.OpenBackend("mem://app")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	layers, _ := config.NewChain(s).Provenance()
+	fmt.Println(layers["server.port"])
+	// Output: [{mem 9090 1:13}]
+}
+
+func ExampleRegisterFormat() {
+	// A format of `key = value` lines.
+	config.RegisterFormat(".kv", config.DecoderFunc(func(b []byte) (map[string]interface{}, error) {
+		m := make(map[string]interface{})
+		for _, line := range strings.Split(string(b), "\n") {
+			if i := strings.IndexByte(line, '='); i > 0 {
+				m[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+			}
+		}
+		return m, nil
+	}))
+	defer config.RegisterFormat(".kv", nil)
+
+	c, err := config.ReadFormat(".kv", []byte("host = localhost\nport = 9090"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(c.String("host"))
+	fmt.Println(c.Coerce().Int("port"))
+	// Output:
+	// localhost true
+	// 9090 true
+}
+
+func ExampleReadXML() {
+	c, err := config.ReadXML([]byte(`
+<configuration>
+	<appSettings>
+		<add key="timeout" value="30"/>
+	</appSettings>
+	<server host="localhost"><port>9090</port></server>
+	<peer>a</peer>
+	<peer>b</peer>
+</configuration>`), nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(c.Group("appSettings").Int("timeout"))
+	fmt.Println(c.Group("server").String("host"))
+	fmt.Println(c.Group("server").Int("port"))
+	fmt.Println(c.Strings("peer"))
+	// Output:
+	// 30 true
+	// localhost true
+	// 9090 true
+	// [a b] true
+}
+
+func ExampleReadCBOR() {
+	// {"server": {"port": 9090}}
+	c, err := config.ReadCBOR([]byte("\xa1\x66server\xa1\x64port\x19\x23\x82"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(c.Group("server").Int("port"))
+	// Output: 9090 true
+}
+
+func ExampleReadMsgpack() {
+	// {"server": {"port": 9090}}
+	c, err := config.ReadMsgpack([]byte("\x81\xa6server\x81\xa4port\xcd\x23\x82"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(c.Group("server").Int("port"))
+	// Output: 9090 true
+}
+
+func ExampleConfig_Lint() {
+	c, _ := config.ReadFrom([]byte(`{"db": {"password": "hunter2", "idleTimeout": 30}}`))
+	rules := config.LintConfig{"snake-case-keys": {Severity: config.SeverityOff}}
+	for _, f := range c.Lint(rules) {
+		fmt.Println(f)
+	}
+	// Output:
+	// 1:32: error: db.idleTimeout: duration 30 has no unit, eg. 30s (duration-units)
+	// 1:9: error: db.password: plaintext secret, use a reference to a secret store instead (no-plaintext-secrets)
+}
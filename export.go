@@ -0,0 +1,97 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExportEnv converts the flattened config into `KEY=VALUE` pairs, sorted by
+// name, suitable for the environment of a child process (eg. exec.Cmd.Env).
+//
+// Names are `prefix` followed by the upper-cased path of the key, with dots
+// (and any other character that isn't a letter, digit, or underscore) replaced
+// by underscores, eg. `server.port` with a prefix of `APP_` is `APP_SERVER_PORT`.
+// This is the reverse of the mapping used by Env.
+//
+// Values are stringified as follows:
+//
+//	string   as is, as are secrets (see SecretString)
+//	bool     "true" or "false"
+//	number   decimal, without an exponent, eg. "9090" or "0.5"
+//	null     ""
+//	other    JSON encoded, eg. lists as `["a","b"]`
+func (c Config) ExportEnv(prefix string) []string {
+	flat := c.Flatten()
+	env := make([]string, 0, len(flat))
+	for path, v := range flat {
+		env = append(env, envName(prefix, path)+"="+envValue(v))
+	}
+	sort.Strings(env)
+	return env
+}
+
+// SetEnv sets the variables returned by ExportEnv within the environment of
+// the current process, so they're inherited by child processes.
+func (c Config) SetEnv(prefix string) error {
+	for _, kv := range c.ExportEnv(prefix) {
+		i := strings.IndexByte(kv, '=')
+		if err := os.Setenv(kv[:i], kv[i+1:]); err != nil {
+			return fmt.Errorf("failed to set %s: %s", kv[:i], err)
+		}
+	}
+	return nil
+}
+
+func envName(prefix, path string) string {
+	return prefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, path)
+}
+
+func envValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case *SecretString:
+		return v.Reveal()
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case json.Number:
+		return string(v)
+	case nil:
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// ExportEnv exports the current config, see Config.ExportEnv.
+func ExportEnv(prefix string) []string {
+	return cfg.ExportEnv(prefix)
+}
+
+// SetEnv sets the current config within the environment, see Config.SetEnv.
+func SetEnv(prefix string) error {
+	return cfg.SetEnv(prefix)
+}
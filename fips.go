@@ -0,0 +1,46 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+)
+
+// ErrFIPS is returned when a config requests an algorithm that isn't FIPS
+// approved, while FIPS is set.
+var ErrFIPS = errors.New("algorithm not allowed in FIPS mode")
+
+// FIPS, when true, restricts the crypto of the package to FIPS approved
+// algorithms, failing with ErrFIPS when a config requests any other: TLS
+// settings (see TLSConfig) must use TLS 1.2 or later, with AES-GCM cipher
+// suites, and the NIST curves. The encrypted cache (AES-GCM), signatures
+// (Ed25519), and webhook signatures (HMAC-SHA256) are approved already.
+//
+// It's true when built with the `fips` tag, and may be set at startup
+// otherwise. It doesn't make the Go crypto implementation FIPS validated, see
+// the toolchain's FIPS 140 support for that.
+var FIPS = fipsBuild
+
+// fipsCipherSuites are the FIPS approved TLS 1.2 cipher suites, TLS 1.3 ones
+// aren't configurable.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves are the FIPS approved key exchange curves.
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+func isFIPSCipherSuite(id uint16) bool {
+	for _, s := range fipsCipherSuites {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
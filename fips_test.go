@@ -0,0 +1,48 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+)
+
+func TestFIPS(t *testing.T) {
+	defer func(fips bool) { FIPS = fips }(FIPS)
+	const cbc = "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA"
+
+	FIPS = false
+	tc, err := TLSConfig{MinVersion: "1.0", CipherSuites: []string{cbc}}.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.MinVersion != tls.VersionTLS10 || len(tc.CipherSuites) != 1 || tc.CurvePreferences != nil {
+		t.Errorf("without FIPS: %+v", tc)
+	}
+
+	FIPS = true
+	for _, s := range []TLSConfig{
+		{MinVersion: "1.0"},
+		{CipherSuites: []string{cbc}},
+	} {
+		if _, err := s.config(); !errors.Is(err, ErrFIPS) {
+			t.Errorf("%+v: error = %v, want ErrFIPS", s, err)
+		}
+	}
+	if tc, err = (TLSConfig{}).config(); err != nil {
+		t.Fatal(err)
+	}
+	if tc.MinVersion != tls.VersionTLS12 || len(tc.CipherSuites) != len(fipsCipherSuites) || len(tc.CurvePreferences) != len(fipsCurves) {
+		t.Errorf("FIPS defaults: %+v", tc)
+	}
+	tc, err = TLSConfig{MinVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}}.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.MinVersion != tls.VersionTLS13 || len(tc.CipherSuites) != 1 {
+		t.Errorf("FIPS approved settings: %+v", tc)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Decoder decodes the documents of a configuration format, see
+// RegisterFormat, into the values a JSON document decodes to: maps of
+// strings, slices, strings, float64s (or json.Numbers), bools, and nils.
+// Other values are kept as is; they're returned by Val, and bound as by Bind.
+//
+// Decoders of formats whose values are all strings, eg. XML, implement
+// `Coerce() bool`, returning true, so their configs coerce values on access
+// (see Config.Coerce).
+type Decoder interface {
+	Decode(b []byte) (map[string]interface{}, error)
+}
+
+// FileDecoder is implemented by the Decoders of formats whose documents refer
+// to other files, eg. by imports, relative to their own, so the documents of
+// files are decoded by DecodeFile, given the file's `path`.
+type FileDecoder interface {
+	Decoder
+	DecodeFile(path string, b []byte) (map[string]interface{}, error)
+}
+
+// DecoderFunc adapts a func to a Decoder.
+type DecoderFunc func(b []byte) (map[string]interface{}, error)
+
+func (f DecoderFunc) Decode(b []byte) (map[string]interface{}, error) {
+	return f(b)
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]Decoder{
+		".plist": DecoderFunc(func(b []byte) (map[string]interface{}, error) {
+			c, err := ReadPlist(b)
+			return c.m, err
+		}),
+	}
+)
+
+// RegisterFormat registers `d` as decoding the config files whose extension
+// is `ext`, eg. `.cue`, so File, Glob, and the config file (see ConfigFile)
+// read them; other files are read as JSON. A nil `d` removes the format.
+//
+// Decoded documents are bounded by the limits of the options they're read
+// with, have their `$when` groups and host `overrides` applied, and are
+// migrated, as JSON documents are.
+func RegisterFormat(ext string, d Decoder) {
+	ext = strings.ToLower(ext)
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	if d == nil {
+		delete(formats, ext)
+		return
+	}
+	formats[ext] = d
+}
+
+// formatOf returns the decoder of the file at `path`, nil for JSON.
+func formatOf(path string) Decoder {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return formats[strings.ToLower(filepath.Ext(path))]
+}
+
+// ReadFormat returns a new Config from the document `b`, decoded by the
+// format registered for `ext` (see RegisterFormat), or as JSON.
+func ReadFormat(ext string, b []byte, opts ...Option) (Config, error) {
+	formatsMu.RLock()
+	d := formats[strings.ToLower(ext)]
+	formatsMu.RUnlock()
+	o := newOptions(opts)
+	if d == nil {
+		return o.readFrom(b)
+	}
+	return o.readFormat(d, b, "")
+}
+
+// readFormat decodes the document `b` by `d`, as read from the file at
+// `path`, when not empty.
+func (o *options) readFormat(d Decoder, b []byte, path string) (Config, error) {
+	if o.maxSize > 0 && len(b) > o.maxSize {
+		return *new(Config), fmt.Errorf("%w: larger than %d bytes", ErrLimit, o.maxSize)
+	}
+	var m map[string]interface{}
+	var err error
+	if fd, ok := d.(FileDecoder); ok && path != "" {
+		m, err = fd.DecodeFile(path, b)
+	} else {
+		m, err = d.Decode(b)
+	}
+	if err != nil {
+		return *new(Config), err
+	}
+	keys := 0
+	if err = o.checkVal(m, 1, &keys); err != nil {
+		return *new(Config), err
+	}
+	c, err := o.build(m)
+	if d, ok := d.(interface{ Coerce() bool }); ok && err == nil {
+		c.coerce = d.Coerce()
+	}
+	return c, err
+}
+
+// checkVal checks the decoded value `v`, nested `depth` levels, for the
+// limits, counting its keys to `keys`.
+func (o *options) checkVal(v interface{}, depth int, keys *int) error {
+	var l []interface{}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if *keys += len(v); o.maxKeys > 0 && *keys > o.maxKeys {
+			return fmt.Errorf("%w: more than %d keys", ErrLimit, o.maxKeys)
+		}
+		for _, val := range v {
+			l = append(l, val)
+		}
+	case []interface{}:
+		l = v
+	default:
+		return nil
+	}
+	if o.maxDepth > 0 && depth > o.maxDepth {
+		return fmt.Errorf("%w: nested deeper than %d levels", ErrLimit, o.maxDepth)
+	}
+	for _, val := range l {
+		if err := o.checkVal(val, depth+1, keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import "errors"
+
+// ErrFrozen is returned when changing the current config after Freeze.
+var ErrFrozen = errors.New("config is frozen")
+
+// PanicWhenFrozen, when true, makes changes to the current config after
+// Freeze panic, rather than return ErrFrozen.
+var PanicWhenFrozen = false
+
+// frozen is guarded by cfg.mu.
+var frozen bool
+
+// Freeze makes the current config immutable, so TrySetConfig, and the other
+// Try setters (eg. TrySetCoerce, or TrySetMissingInt), ApplyPatch, Load, and
+// the watchers reloading it, fail with ErrFrozen from then on (or panic, see
+// PanicWhenFrozen), while SetConfig, and the other setters, log the failure.
+// It's meant to be called once initialization is done, and can't be undone.
+func Freeze() {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	frozen = true
+}
+
+// Frozen returns whether Freeze has been called.
+func Frozen() bool {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return frozen
+}
+
+// mutable returns ErrFrozen once frozen, and ErrReadOnly when the current
+// config is owned by an Admin other than `a`. Callers must hold cfg.mu.
+func mutable(a *Admin) error {
+	if frozen {
+		if PanicWhenFrozen {
+			panic(ErrFrozen)
+		}
+		return ErrFrozen
+	}
+	if owner != nil && (a == nil || owner != a.admin) {
+		return ErrReadOnly
+	}
+	return nil
+}
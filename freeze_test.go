@@ -0,0 +1,85 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFreeze(t *testing.T) {
+	cfg.mu.Lock()
+	prev := cfg.m
+	cfg.mu.Unlock()
+	defer func() {
+		cfg.mu.Lock()
+		frozen = false
+		cfg.mu.Unlock()
+		SetConfig(prev)
+	}()
+
+	if err := TrySetConfig(map[string]interface{}{"port": 1.0}); err != nil {
+		t.Fatal(err)
+	}
+	Freeze()
+	if !Frozen() {
+		t.Fatal("not frozen")
+	}
+	if err := TrySetConfig(map[string]interface{}{"port": 2.0}); !errors.Is(err, ErrFrozen) {
+		t.Errorf("TrySetConfig: %v, want %v", err, ErrFrozen)
+	}
+	if err := TrySetCoerce(true); !errors.Is(err, ErrFrozen) {
+		t.Errorf("TrySetCoerce: %v, want %v", err, ErrFrozen)
+	}
+	if err := TrySetLenientBools(true); !errors.Is(err, ErrFrozen) {
+		t.Errorf("TrySetLenientBools: %v, want %v", err, ErrFrozen)
+	}
+	if err := TrySetMissingInt(-1); !errors.Is(err, ErrFrozen) {
+		t.Errorf("TrySetMissingInt: %v, want %v", err, ErrFrozen)
+	}
+	if err := TrySetMissingFloat64(-1); !errors.Is(err, ErrFrozen) {
+		t.Errorf("TrySetMissingFloat64: %v, want %v", err, ErrFrozen)
+	}
+	if err := ApplyPatch([]byte(`{"port": 3}`), MergePatch); !errors.Is(err, ErrFrozen) {
+		t.Errorf("ApplyPatch: %v, want %v", err, ErrFrozen)
+	}
+	SetConfig(map[string]interface{}{"port": 4.0})
+	if port, _ := Int("port"); port != 1 {
+		t.Errorf("port = %d, want 1, as frozen", port)
+	}
+
+	PanicWhenFrozen = true
+	defer func() { PanicWhenFrozen = false }()
+	func() {
+		defer func() {
+			if r := recover(); r != ErrFrozen {
+				t.Errorf("recovered %v, want %v", r, ErrFrozen)
+			}
+		}()
+		TrySetConfig(nil)
+	}()
+	// Nor do validators keep the config locked once recovered.
+	remove := OnValidate(func(Config) error { return nil })
+	defer remove()
+	func() {
+		defer func() {
+			if r := recover(); r != ErrFrozen {
+				t.Errorf("validated: recovered %v, want %v", r, ErrFrozen)
+			}
+		}()
+		TrySetConfig(nil)
+	}()
+	done := make(chan struct{})
+	go func() {
+		Current()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the config is locked after a recovered panic")
+	}
+}
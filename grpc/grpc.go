@@ -0,0 +1,187 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package grpc reads the configuration from a control plane serving the
+// ConfigService of config.proto, and keeps it fresh by watching its stream of
+// updates, eg.
+//
+//	s, err := grpcconfig.Dial("control-plane:443", "billing", grpc.WithTransportCredentials(creds))
+//	...
+//	a, err := config.Open(config.NewChain(config.Env("APP_"), s))
+//	...
+//	go s.Watch(ctx, a)
+//
+// Documents are JSON, or of the format named by the server, see
+// config.RegisterFormat.
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"code.minty.io/config"
+	"google.golang.org/grpc"
+)
+
+// Method names of the ConfigService.
+const (
+	getMethod   = "/minty.config.v1.ConfigService/GetConfig"
+	watchMethod = "/minty.config.v1.ConfigService/WatchConfig"
+)
+
+// Timeout bounds the GetConfig calls of Read.
+var Timeout = 10 * time.Second
+
+// Source reads a document of a ConfigService.
+type Source struct {
+	// Document is the name of the document read.
+	Document string
+	// Retry is the backoff between reconnects to the watch stream, and the
+	// policy of GetConfig calls. Defaults to config.DefaultRetryPolicy.
+	Retry config.RetryPolicy
+
+	conn *grpc.ClientConn
+
+	mu sync.Mutex
+	// last is the last document received by Watch, read instead of calling
+	// GetConfig.
+	last *document
+}
+
+// Dial returns a source of the document `name`, served at `target`.
+func Dial(target, name string, opts ...grpc.DialOption) (*Source, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewSource(conn, name), nil
+}
+
+// NewSource returns a source of the document `name`, served via `conn`.
+func NewSource(conn *grpc.ClientConn, name string) *Source {
+	return &Source{Document: name, Retry: config.DefaultRetryPolicy, conn: conn}
+}
+
+// Close closes the connection of the source.
+func (s *Source) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Source) Name() string {
+	return "grpc " + s.Document
+}
+
+// Read returns the document last received by Watch, or calls GetConfig.
+func (s *Source) Read() (config.Config, error) {
+	s.mu.Lock()
+	last := s.last
+	s.mu.Unlock()
+	if last != nil {
+		return s.decode(last)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	var doc document
+	err := s.Retry.Do(ctx, func(ctx context.Context) error {
+		return s.conn.Invoke(ctx, getMethod, &getRequest{s.Document}, &doc, grpc.ForceCodec(codec{}))
+	})
+	if err != nil {
+		return *new(config.Config), fmt.Errorf("failed to get config %s: %w", s.Document, err)
+	}
+	return s.decode(&doc)
+}
+
+func (s *Source) decode(doc *document) (config.Config, error) {
+	c, err := config.ReadFormat(doc.Format, doc.Document)
+	if err != nil {
+		return c, fmt.Errorf("failed to read config %s at revision %d: %w", s.Document, doc.Revision, err)
+	}
+	return c, nil
+}
+
+// Watch streams the updates of the document, installing each into the
+// current config, via `a` when an Admin owns it (see Admin.Reload, which
+// re-reads the whole source it was opened with, eg. a Chain holding the
+// source), or via config.Load otherwise, until `ctx` is done. Broken streams
+// are reconnected, backing off per Retry, resuming from the last revision.
+// Failures are logged, and keep the current config.
+func (s *Source) Watch(ctx context.Context, a *config.Admin) error {
+	for failures := 0; ; {
+		received, err := s.watch(ctx, a)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if received {
+			failures = 0
+		}
+		failures++
+		log.Printf("config: %s stream broke: %s", s.Name(), err)
+		delay := s.Retry.Delay(failures)
+		if delay <= 0 {
+			delay = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// watch streams the updates until the stream breaks, reporting whether any
+// were received.
+func (s *Source) watch(ctx context.Context, a *config.Admin) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := s.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, watchMethod, grpc.ForceCodec(codec{}))
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	req := &watchRequest{Name: s.Document}
+	if s.last != nil {
+		req.Revision = s.last.Revision
+	}
+	s.mu.Unlock()
+	if err = stream.SendMsg(req); err != nil {
+		return false, err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return false, err
+	}
+	received := false
+	for {
+		doc := new(document)
+		if err = stream.RecvMsg(doc); err != nil {
+			return received, err
+		}
+		received = true
+		if _, err = s.decode(doc); err != nil {
+			log.Printf("config: %s", err)
+			continue
+		}
+		s.mu.Lock()
+		prev := s.last
+		s.last = doc
+		s.mu.Unlock()
+		if a != nil {
+			err = a.Reload()
+		} else {
+			err = config.Load(s)
+		}
+		if err != nil {
+			// Keep serving the previous document, the current config
+			// wasn't changed.
+			s.mu.Lock()
+			if s.last == doc {
+				s.last = prev
+			}
+			s.mu.Unlock()
+			log.Printf("config: failed to reload %s at revision %d: %s", s.Name(), doc.Revision, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package grpc
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of config.proto, encoded by codec, rather than generated code,
+// as they're few, and small.
+type (
+	getRequest struct {
+		Name string
+	}
+	watchRequest struct {
+		Name     string
+		Revision uint64
+	}
+	document struct {
+		Document []byte
+		Format   string
+		Revision uint64
+	}
+)
+
+// codec encodes the messages as protobufs.
+type codec struct{}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	var b []byte
+	switch m := v.(type) {
+	case *getRequest:
+		b = appendString(b, 1, m.Name)
+	case *watchRequest:
+		b = appendString(b, 1, m.Name)
+		b = appendVarint(b, 2, m.Revision)
+	default:
+		return nil, fmt.Errorf("grpc: can't marshal %T", v)
+	}
+	return b, nil
+}
+
+func (codec) Unmarshal(b []byte, v interface{}) error {
+	m, ok := v.(*document)
+	if !ok {
+		return fmt.Errorf("grpc: can't unmarshal %T", v)
+	}
+	*m = document{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			var p []byte
+			p, n = protowire.ConsumeBytes(b)
+			m.Document = append([]byte(nil), p...)
+		case num == 2 && typ == protowire.BytesType:
+			m.Format, n = protowire.ConsumeString(b)
+		case num == 3 && typ == protowire.VarintType:
+			m.Revision, n = protowire.ConsumeVarint(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// appendString and appendVarint append a field, omitted when it's the zero
+// value, as proto3 does.
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return protowire.AppendString(protowire.AppendTag(b, num, protowire.BytesType), s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return protowire.AppendVarint(protowire.AppendTag(b, num, protowire.VarintType), v)
+}
@@ -0,0 +1,99 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Handle is a precompiled lookup of a key within the current config, see
+// Path.
+type Handle struct {
+	groups []string
+	key    string
+	state  atomic.Value // *handleState
+}
+
+// handleState is the group holding a handle's key, as of a generation.
+type handleState struct {
+	gen   uint64
+	group Config
+}
+
+// Path returns a handle to the key at `path`, a dot separated path of groups
+// and a key, eg. `server.tls.cert`, for keys read so often that walking the
+// groups on every lookup matters, eg.
+//
+//	var port = config.Path("server.port")
+//
+//	func handler() {
+//		p, _ := port.Int()
+//	}
+//
+// The groups are walked once per generation of the current config (see
+// Generation), later lookups being a single map lookup. It panics when
+// `path` is invalid.
+func Path(path string) *Handle {
+	l := strings.Split(path, ".")
+	if path == "" || contains(l, "") {
+		panic(fmt.Sprintf("config: invalid path %q", path))
+	}
+	return &Handle{groups: l[:len(l)-1], key: l[len(l)-1]}
+}
+
+// group returns the group holding the handle's key within the current
+// config, walking the groups again once the generation changed.
+func (h *Handle) group() Config {
+	s, _ := h.state.Load().(*handleState)
+	if s != nil && s.gen == atomic.LoadUint64(&generation) {
+		return s.group
+	}
+	cfg.mu.Lock()
+	s = &handleState{generation, cfg.with(cfg.m)}
+	cfg.mu.Unlock()
+	for _, g := range h.groups {
+		s.group = s.group.Group(g)
+	}
+	h.state.Store(s)
+	return s.group
+}
+
+// Has returns whether the handle's key is set, see Config.Has.
+func (h *Handle) Has() bool {
+	return h.group().Has(h.key)
+}
+
+// Bool returns the boolean value of the handle's key, see Config.Bool.
+func (h *Handle) Bool() (bool, bool) {
+	return h.group().Bool(h.key)
+}
+
+// String returns the string value of the handle's key, see Config.String.
+func (h *Handle) String() (string, bool) {
+	return h.group().String(h.key)
+}
+
+// Int returns the int value of the handle's key, see Config.Int.
+func (h *Handle) Int() (int, bool) {
+	return h.group().Int(h.key)
+}
+
+// Float64 returns the float64 value of the handle's key, see Config.Float64.
+func (h *Handle) Float64() (float64, bool) {
+	return h.group().Float64(h.key)
+}
+
+// Strings returns the list of strings of the handle's key, see
+// Config.Strings.
+func (h *Handle) Strings() ([]string, bool) {
+	return h.group().Strings(h.key)
+}
+
+// Val returns the value of the handle's key, see Config.Val.
+func (h *Handle) Val() (interface{}, bool) {
+	return h.group().Val(h.key)
+}
@@ -0,0 +1,49 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(f, []byte(`{"server": {"tls": {"port": 443}}}`), 0600)
+	a := openTest(t, File(f))
+	port := Path("server.tls.port")
+	if p, _ := port.Int(); p != 443 {
+		t.Fatalf("port = %d, want 443", p)
+	}
+	if n := testing.AllocsPerRun(100, func() { port.Int() }); n != 0 {
+		t.Errorf("lookup within a generation: %v allocs, want 0", n)
+	}
+
+	// Later generations are walked again.
+	if err := a.Set(map[string]interface{}{"server": map[string]interface{}{"tls": map[string]interface{}{"port": 8443.0}}}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := port.Int(); p != 8443 {
+		t.Errorf("port = %d after a change, want 8443", p)
+	}
+	if err := a.Set(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if port.Has() {
+		t.Error("port set after its removal")
+	}
+
+	for _, path := range []string{"", "server..port", "server."} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Path(%q) didn't panic", path)
+				}
+			}()
+			Path(path)
+		}()
+	}
+}
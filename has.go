@@ -0,0 +1,59 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+// Has returns whether the `key` is set within the root level, including when
+// it's set to null. Where null and missing differ, eg. null disabling a
+// feature that's defaulted when missing, use it along with IsNull:
+//
+//	switch {
+//	case !c.Has("cache"):
+//		// Use the default cache.
+//	case c.IsNull("cache"):
+//		// The cache is disabled.
+//	}
+func (c Config) Has(key string) bool {
+	_, ok := c.value(key)
+	return ok
+}
+
+// IsNull returns whether the `key` is explicitly set to null within the root
+// level. It's false when the key is missing.
+func (c Config) IsNull(key string) bool {
+	v, ok := c.value(key)
+	return ok && v == nil
+}
+
+// GroupHas returns whether the `key` is set within `group`, see Has.
+func (c Config) GroupHas(group, key string) bool {
+	return c.Group(group).Has(key)
+}
+
+// GroupIsNull returns whether the `key` is null within `group`, see IsNull.
+func (c Config) GroupIsNull(group, key string) bool {
+	return c.Group(group).IsNull(key)
+}
+
+// Has returns whether the `key` is set within the root level, see
+// Config.Has.
+func Has(key string) bool {
+	return cfg.Has(key)
+}
+
+// IsNull returns whether the `key` is null within the root level, see
+// Config.IsNull.
+func IsNull(key string) bool {
+	return cfg.IsNull(key)
+}
+
+// GroupHas returns whether the `key` is set within `group`, see Has.
+func GroupHas(group, key string) bool {
+	return cfg.GroupHas(group, key)
+}
+
+// GroupIsNull returns whether the `key` is null within `group`, see IsNull.
+func GroupIsNull(group, key string) bool {
+	return cfg.GroupIsNull(group, key)
+}
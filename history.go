@@ -0,0 +1,150 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// HistorySize is the number of revisions of the current config kept, see
+// History.
+var HistorySize = 32
+
+// generation and history are guarded by cfg.mu, generation also being
+// loaded atomically by handles (see Path).
+var (
+	generation uint64
+	history    []Revision
+)
+
+// Revision is a past, or the current, generation of the current config.
+type Revision struct {
+	Generation uint64    `json:"generation"`
+	Time       time.Time `json:"time"`
+	m          map[string]interface{}
+}
+
+// Config returns the config as of the revision.
+func (r Revision) Config() Config {
+	return Config{m: r.m}
+}
+
+func init() {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	record()
+}
+
+// Generation returns the generation of the current config, 1 as read at
+// startup, and incremented by every change to it.
+func Generation() uint64 {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return generation
+}
+
+// History returns the last HistorySize revisions of the current config,
+// oldest first, the last being the current config.
+func History() []Revision {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return append([]Revision(nil), history...)
+}
+
+// RevisionAt returns the revision at generation `gen`, if it's still within
+// the History.
+func RevisionAt(gen uint64) (Revision, bool) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	for _, r := range history {
+		if r.Generation == gen {
+			return r, true
+		}
+	}
+	return Revision{}, false
+}
+
+// Rollback reinstalls the config of generation `gen`, which must still be
+// within the History, as a new generation.
+func Rollback(gen uint64) error {
+	return rollback(nil, gen)
+}
+
+// Rollback reinstalls the config of generation `gen`, see Rollback, as the
+// config last read from the source. Runtime overrides are within it, so
+// they're dropped, and later ones merged over it, until the next Reload.
+func (a *Admin) Rollback(gen uint64) error {
+	return rollback(a, gen)
+}
+
+func rollback(a *Admin, gen uint64) error {
+	r, ok := RevisionAt(gen)
+	if !ok {
+		return fmt.Errorf("generation %d is not within the config history", gen)
+	}
+	return update(a, "rollback", func() error {
+		m, _ := copyVal(r.m).(map[string]interface{})
+		if a != nil {
+			a.base, a.pos, a.overrides = m, nil, nil
+		}
+		cfg.m, cfg.pos = m, nil
+		return nil
+	})
+}
+
+// record increments the generation, and records the current config within
+// the history. Callers must hold cfg.mu.
+func record() uint64 {
+	atomic.AddUint64(&generation, 1)
+	m, _ := copyVal(cfg.m).(map[string]interface{})
+	history = append(history, Revision{generation, time.Now(), m})
+	if n := len(history) - HistorySize; n > 0 {
+		history = append(history[:0:0], history[n:]...)
+	}
+	return generation
+}
+
+// Change is a difference between two configs, see Diff. Old is nil for added
+// keys, and New for removed ones.
+type Change struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// Diff returns the keys, by their dot separated path, whose values differ
+// from `old` to `new`, sorted by path. Values within sensitive groups are
+// compared, but reported as Redacted.
+func Diff(old, new Config) []Change {
+	before, after := flatten(old.m), flatten(new.m)
+	paths := make(map[string]bool, len(after))
+	for path := range before {
+		paths[path] = true
+	}
+	for path := range after {
+		paths[path] = true
+	}
+	var changes []Change
+	for path := range paths {
+		o, hadOld := before[path]
+		n, hasNew := after[path]
+		if hadOld == hasNew && reflect.DeepEqual(normalize(o), normalize(n)) {
+			continue
+		}
+		if hadOld && old.IsSensitive(path) {
+			o = Redacted
+		}
+		if hasNew && new.IsSensitive(path) {
+			n = Redacted
+		}
+		changes = append(changes, Change{path, o, n})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
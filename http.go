@@ -0,0 +1,270 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the response header holding the ed25519 signature of a
+// remote config.
+const SignatureHeader = "X-Config-Signature"
+
+// DefaultHTTPClient is used by HTTPSource when no Client is set.
+var DefaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// HTTPSource reads the configuration from a URL.
+type HTTPSource struct {
+	URL string
+	// PublicKey, when set, is the pinned ed25519 key that remote configs must
+	// be signed with. The signature is taken from the SignatureHeader, or from
+	// the companion `.sig` file, of the URL's path with `.sig` appended (eg.
+	// `/config.json.sig?env=prod` for `/config.json?env=prod`), and may be
+	// raw, hex, or base64 encoded.
+	// When nil, the key is taken from `APP_CONFIG_PUBLIC_KEY`.
+	PublicKey ed25519.PublicKey
+	// Client is the client used for requests, DefaultHTTPClient when nil.
+	Client *http.Client
+	// Cache, when set, keeps an encrypted copy of the last good config.
+	Cache *Cache
+	// Retry is the policy for fetching, DefaultRetryPolicy when nil.
+	Retry *RetryPolicy
+	// Breaker, when set, stops refreshes from hammering a failing source.
+	Breaker *Breaker
+	// Accept lists the media types to negotiate, in order of preference, eg.
+	// `application/cbor` or `application/msgpack` for compact payloads, JSON
+	// being accepted after them. Responses are decoded per their
+	// Content-Type, see mediaTypes.
+	Accept []string
+}
+
+// mediaTypes are the formats (see RegisterFormat) of the media types served
+// by remote configs, others are read as JSON.
+var mediaTypes = map[string]string{
+	"application/cbor":        ".cbor",
+	"application/msgpack":     ".msgpack",
+	"application/x-msgpack":   ".msgpack",
+	"application/vnd.msgpack": ".msgpack",
+	"application/protobuf":    ".pb",
+	"application/x-protobuf":  ".pb",
+}
+
+// ReadURL returns the configuration served at `url`.
+func ReadURL(url string) (Config, error) {
+	return (&HTTPSource{URL: url}).Read()
+}
+
+// Read fetches, verifies, and returns the remote configuration. When a Cache
+// is set the config is persisted on success, and served from the cache when
+// the source can't be fetched from.
+func (s *HTTPSource) Read() (Config, error) {
+	return s.ReadContext(context.Background())
+}
+
+// ReadContext is Read, bounded by `ctx`.
+func (s *HTTPSource) ReadContext(ctx context.Context) (Config, error) {
+	return s.read(ctx, true)
+}
+
+// Watch reads the configuration, installs it via SetConfig, and then re-reads
+// it every `interval` until the returned stop func is called. Failed refreshes
+// are logged and keep the current config.
+func (s *HTTPSource) Watch(interval time.Duration) (stop func(), err error) {
+	c, err := s.Read()
+	if err != nil {
+		return nil, err
+	}
+	if err = TrySetConfig(c.m); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			c, err := s.read(ctx, false)
+			if err == nil {
+				err = TrySetConfig(c.m)
+			}
+			switch {
+			case err == nil:
+			case err != ErrCircuitOpen && ctx.Err() == nil:
+				log.Printf("failed to refresh configuration from %s: %s", s.URL, err)
+			}
+		}
+	}()
+	return cancel, nil
+}
+
+func (s *HTTPSource) retry() RetryPolicy {
+	if s.Retry != nil {
+		return *s.Retry
+	}
+	return DefaultRetryPolicy
+}
+
+func (s *HTTPSource) read(ctx context.Context, cached bool) (Config, error) {
+	now := time.Now()
+	var c Config
+	var ext string
+	var data []byte
+	err := s.Breaker.Do(func() error {
+		return s.retry().Do(ctx, func(ctx context.Context) (err error) {
+			c, ext, data, err = s.fetch(ctx)
+			return err
+		})
+	})
+	if err == nil {
+		if s.Cache != nil {
+			if cerr := s.Cache.store(s.URL, ext, data, now); cerr != nil {
+				log.Printf("failed to cache configuration from %s: %s", s.URL, cerr)
+			}
+		}
+		setStatus(Status{Source: s.URL, Fetched: now, Attempted: now})
+		return c, nil
+	}
+
+	st := Status{Source: s.URL, Attempted: now, Err: err}
+	if s.Cache != nil && cached {
+		if ext, data, fetched, cerr := s.Cache.load(s.URL); cerr == nil {
+			if cached, cerr := ReadFormat(ext, data); cerr == nil {
+				st.Cached, st.Fetched = true, fetched
+				setStatus(st)
+				return cached, nil
+			}
+		}
+	}
+	setStatus(st)
+	return c, err
+}
+
+// fetch returns the verified remote configuration along with its raw bytes,
+// and their format (see RegisterFormat), empty for JSON.
+func (s *HTTPSource) fetch(ctx context.Context) (Config, string, []byte, error) {
+	data, header, err := s.get(ctx, s.URL)
+	if err != nil {
+		return *new(Config), "", nil, err
+	}
+	if err = s.verify(ctx, data, header.Get(SignatureHeader)); err != nil {
+		return *new(Config), "", nil, fmt.Errorf("failed to verify configuration from %s: %w", s.URL, err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	ext := mediaTypes[mediaType]
+	c, err := ReadFormat(ext, data)
+	if err != nil {
+		return c, ext, data, fmt.Errorf("failed to read configuration from %s: %w", s.URL, err)
+	}
+	return c, ext, data, nil
+}
+
+// accept returns the Accept header of the negotiated media types.
+func (s *HTTPSource) accept() string {
+	if len(s.Accept) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, t := range append(s.Accept[:len(s.Accept):len(s.Accept)], "application/json") {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(t)
+		if i > 0 {
+			// Lower the quality of each type after the first, to 0.1.
+			q := 10 - i
+			if q < 1 {
+				q = 1
+			}
+			fmt.Fprintf(&b, ";q=0.%d", q)
+		}
+	}
+	return b.String()
+}
+
+func (s *HTTPSource) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return DefaultHTTPClient
+}
+
+// get returns the body of `url`, along with the response's headers.
+func (s *HTTPSource) get(ctx context.Context, url string) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if accept := s.accept(); accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to fetch configuration from %s: %s", url, resp.Status)
+	}
+	data, err := newOptions(nil).readAll(resp.Body)
+	return data, resp.Header, err
+}
+
+func (s *HTTPSource) verify(ctx context.Context, data []byte, sig string) error {
+	pub := s.PublicKey
+	if pub == nil {
+		env := os.Getenv("APP_CONFIG_PUBLIC_KEY")
+		if env == "" {
+			return nil
+		}
+		b, err := decodeKey(env, ed25519.PublicKeySize)
+		if err != nil {
+			return fmt.Errorf("invalid APP_CONFIG_PUBLIC_KEY: %s", err)
+		}
+		pub = b
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: invalid public key", ErrSignature)
+	}
+	if sig == "" {
+		u, err := sigURL(s.URL)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrSignature, err)
+		}
+		b, _, err := s.get(ctx, u)
+		if sig = strings.TrimSpace(string(b)); err != nil || sig == "" {
+			return fmt.Errorf("%w: missing signature", ErrSignature)
+		}
+	}
+	return verifySignature(data, []byte(sig), pub)
+}
+
+// sigURL returns the URL of the signature of the config at `raw`, with
+// `.sig` appended to its path, keeping its query.
+func sigURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	u.Path += ".sig"
+	if u.RawPath != "" {
+		u.RawPath += ".sig"
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.String(), nil
+}
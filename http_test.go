@@ -0,0 +1,86 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestHTTPCacheFormat(t *testing.T) {
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/cbor")
+		// {"key": h'0102'}
+		w.Write([]byte{0xA1, 0x63, 'k', 'e', 'y', 0x42, 1, 2})
+	}))
+	defer srv.Close()
+
+	s := &HTTPSource{
+		URL:    srv.URL,
+		Cache:  &Cache{File: filepath.Join(t.TempDir(), "cache"), Key: make([]byte, 16)},
+		Retry:  &RetryPolicy{MaxAttempts: 1},
+		Accept: make([]string, 1, 2),
+	}
+	s.Accept[0] = "application/cbor"
+	if _, err := s.read(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	up = false
+	c, err := s.read(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cached as served, rather than re-encoded as JSON.
+	if v, _ := c.Val("key"); !bytes.Equal(asBytes(v), []byte{1, 2}) {
+		t.Errorf("cached key = %#v, want the bytes 0102", v)
+	}
+	// Negotiating doesn't append to the caller's Accept.
+	if s.Accept[:2][1] != "" {
+		t.Errorf("Accept appended to: %q", s.Accept[:2])
+	}
+}
+
+func TestHTTPSignatureURL(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	doc := []byte(`{"port": 9090}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("env") != "prod":
+			http.NotFound(w, r)
+		case r.URL.Path == "/config.json":
+			w.Write(doc)
+		case r.URL.Path == "/config.json.sig":
+			w.Write([]byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, doc))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	s := &HTTPSource{URL: srv.URL + "/config.json?env=prod", PublicKey: pub, Retry: &RetryPolicy{MaxAttempts: 1}}
+	c, err := s.read(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port, _ := c.Int("port"); port != 9090 {
+		t.Errorf("port = %d, want 9090", port)
+	}
+}
+
+func asBytes(v interface{}) []byte {
+	b, _ := v.([]byte)
+	return b
+}
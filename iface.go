@@ -0,0 +1,56 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+// Getter looks up values, implemented by Config and Global, so code can
+// depend on it rather than the package-level functions, and tests can supply
+// a fake (or a Config from FromMap).
+type Getter interface {
+	Bool(key string) (bool, bool)
+	String(key string) (string, bool)
+	Int(key string) (int, bool)
+	Float64(key string) (float64, bool)
+	Strings(key string) ([]string, bool)
+	Val(key string) (interface{}, bool)
+	Group(name string) Config
+}
+
+// Binder decodes the config into a struct, implemented by Config and Global.
+type Binder interface {
+	Bind(v interface{}) error
+}
+
+// Watcher calls `fn` on every change to the config until `stop` is called,
+// implemented by Config and Global.
+type Watcher interface {
+	Watch(fn func(Config)) (stop func())
+}
+
+var (
+	_ Getter  = Config{}
+	_ Binder  = Config{}
+	_ Watcher = Config{}
+	_ Getter  = Global{}
+	_ Binder  = Global{}
+	_ Watcher = Global{}
+)
+
+// Global is the current config, as a Getter, Binder, and Watcher, for
+// injecting it, eg. with fx:
+//
+//	fx.Provide(func() config.Getter { return config.Global{} })
+//
+// Unlike a Config, lookups always see the latest changes.
+type Global struct{}
+
+func (Global) Bool(key string) (bool, bool)        { return Current().Bool(key) }
+func (Global) String(key string) (string, bool)    { return Current().String(key) }
+func (Global) Int(key string) (int, bool)          { return Current().Int(key) }
+func (Global) Float64(key string) (float64, bool)  { return Current().Float64(key) }
+func (Global) Strings(key string) ([]string, bool) { return Current().Strings(key) }
+func (Global) Val(key string) (interface{}, bool)  { return Current().Val(key) }
+func (Global) Group(name string) Config            { return Current().Group(name) }
+func (Global) Bind(v interface{}) error            { return Current().Bind(v) }
+func (Global) Watch(fn func(Config)) (stop func()) { return Watch(fn) }
@@ -0,0 +1,194 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// includeKey includes config files within a group, eg.
+//
+//	"db": {"$include": "db.json", "pool": 20}
+const includeKey = "$include"
+
+// ErrInclude is returned when an include is invalid, or escapes its root.
+var ErrInclude = errors.New("invalid include")
+
+// WithIncludeRoot confines included files to `dir`, rather than the directory
+// of the config file read.
+//
+// Groups of config files include others via `$include`, a path, or a list of
+// paths, relative to the including file, eg. `"db": {"$include": "db.json"}`.
+// Included files are deep-merged in order, beneath the group's own values.
+// Includes can't escape their root, neither via `..`, absolute paths, nor
+// symlinks, so whomever can edit one config file can't have another file,
+// eg. `/etc/shadow`, read into the config. Includes nest up to
+// MaxReferenceDepth files deep, and cycles are rejected, see ReferenceError.
+// Included files are verified by their own `.sha256` and `.sig` sidecars,
+// as the digest and signature within the environment are of the config file.
+func WithIncludeRoot(dir string) Option {
+	return func(o *options) { o.includeRoot = dir }
+}
+
+// includes resolves the includes of `c`, read from the file `f`, along with
+// the positions of the included keys.
+func (o *options) includes(f string, c *Config) error {
+	if !hasInclude(c.m) {
+		return nil
+	}
+	root := o.includeRoot
+	if root == "" {
+		root = filepath.Dir(f)
+	}
+	root, err := filepath.Abs(root)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: root %s: %s", ErrInclude, o.includeRoot, err)
+	}
+	if len(o.including) == 0 {
+		// Start the chain with the file itself, so it can't include itself.
+		self, err := filepath.Abs(f)
+		if err == nil {
+			self, err = filepath.EvalSymlinks(self)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInclude, err)
+		}
+		no := *o
+		no.including = refChain{self}
+		o = &no
+	}
+	if c.pos == nil {
+		c.pos = make(map[string]Location)
+	}
+	m, err := o.includeGroup(root, f, "", c.m, c.pos)
+	if err != nil {
+		return err
+	}
+	c.m = m
+	return nil
+}
+
+func hasInclude(m map[string]interface{}) bool {
+	if _, ok := m[includeKey]; ok {
+		return true
+	}
+	for _, v := range m {
+		if g, ok := v.(map[string]interface{}); ok && hasInclude(g) {
+			return true
+		}
+	}
+	return false
+}
+
+// includeGroup resolves the includes of the group `m`, at `path`, adding the
+// positions of the included keys to `pos`.
+func (o *options) includeGroup(root, f, path string, m map[string]interface{}, pos map[string]Location) (map[string]interface{}, error) {
+	g := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if sub, ok := v.(map[string]interface{}); ok {
+			var err error
+			if v, err = o.includeGroup(root, f, join(path, k), sub, pos); err != nil {
+				return nil, err
+			}
+		}
+		g[k] = v
+	}
+	inc, ok := g[includeKey]
+	if !ok {
+		return g, nil
+	}
+	delete(g, includeKey)
+	var paths []string
+	switch inc := inc.(type) {
+	case string:
+		paths = []string{inc}
+	case []interface{}:
+		for _, p := range inc {
+			s, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s includes %v, not a path", ErrInclude, f, p)
+			}
+			paths = append(paths, s)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s includes %v, not a path", ErrInclude, f, inc)
+	}
+	base := make(map[string]interface{})
+	var included map[string]Location
+	for _, p := range paths {
+		file, err := confine(root, filepath.Dir(f), p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s includes %s: %s", ErrInclude, f, p, err)
+		}
+		chain, err := o.including.enter(file)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInclude, err)
+		}
+		no := *o
+		no.includeRoot, no.including = root, chain
+		no.included, no.sidecars = true, true
+		c, err := readFile(file, &no)
+		if err != nil {
+			return nil, err
+		}
+		base = merge(base, c.m)
+		included = mergePositions(included, c)
+	}
+	// The group's own keys are kept over those included.
+	prefixPositions(pos, included, path)
+	return merge(base, g), nil
+}
+
+// confine returns the real path of `p`, relative to `dir`, when it's within
+// `root`, itself a real path.
+func confine(root, dir, p string) (string, error) {
+	if p == "" {
+		return "", errors.New("empty path")
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+	if !within(root, p) {
+		return "", fmt.Errorf("outside of %s", root)
+	}
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return "", err
+	}
+	if !within(root, resolved) {
+		return "", fmt.Errorf("links to %s, outside of %s", resolved, root)
+	}
+	return resolved, nil
+}
+
+// openFile opens the config file `f`, and included files within their root,
+// so a symlink swapped in once confined can't escape it.
+func openFile(f string, o *options) (*os.File, error) {
+	if !o.included {
+		return os.Open(f)
+	}
+	rel, err := filepath.Rel(o.includeRoot, f)
+	if err != nil {
+		return nil, err
+	}
+	return os.OpenInRoot(o.includeRoot, rel)
+}
+
+// within returns whether `path` is within the directory `root`.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
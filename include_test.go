@@ -0,0 +1,104 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIncludes(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	files := map[string]string{
+		"main.json":     `{"db": {"$include": ["db.json", "sub/port.json"], "pool": 20}}`,
+		"db.json":       `{"host": "db", "pool": 5}`,
+		"sub/port.json": `{"port": 5432}`,
+		"dotdot.json":   `{"a": {"$include": "../secret.json"}}`,
+		"abs.json":      `{"a": {"$include": "` + filepath.Join(outside, "secret.json") + `"}}`,
+		"symlink.json":  `{"a": {"$include": "link.json"}}`,
+		"loop.json":     `{"$include": "loop.json"}`,
+	}
+	os.Mkdir(filepath.Join(dir, "sub"), 0700)
+	for name, doc := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(doc), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(outside, "secret.json"), []byte(`{"secret": 1}`), 0600)
+	if err := os.Symlink(filepath.Join(outside, "secret.json"), filepath.Join(dir, "link.json")); err != nil {
+		t.Skip(err)
+	}
+
+	c, err := File(filepath.Join(dir, "main.json")).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := c.Group("db")
+	if host, _ := db.String("host"); host != "db" {
+		t.Errorf("host = %q, want db", host)
+	}
+	if pool, _ := db.Int("pool"); pool != 20 {
+		t.Errorf("pool = %d, want the including group's 20", pool)
+	}
+	if port, _ := db.Int("port"); port != 5432 {
+		t.Errorf("port = %d, want 5432", port)
+	}
+	if db.Has(includeKey) {
+		t.Errorf("%s kept", includeKey)
+	}
+
+	for _, name := range []string{"dotdot.json", "abs.json", "symlink.json", "loop.json"} {
+		if _, err := File(filepath.Join(dir, name)).Read(); !errors.Is(err, ErrInclude) {
+			t.Errorf("%s: error = %v, want ErrInclude", name, err)
+		}
+	}
+	// A root of the sub directory confines includes to it.
+	_, err = readFile(filepath.Join(dir, "main.json"), newOptions([]Option{WithIncludeRoot(filepath.Join(dir, "sub"))}))
+	if !errors.Is(err, ErrInclude) {
+		t.Errorf("rooted: error = %v, want ErrInclude", err)
+	}
+}
+
+func TestVerifiedIncludes(t *testing.T) {
+	dir := t.TempDir()
+	main := []byte(`{"db": {"$include": "db.json"}}`)
+	os.WriteFile(filepath.Join(dir, "main.json"), main, 0600)
+	os.WriteFile(filepath.Join(dir, "db.json"), []byte(`{"host": "db"}`), 0600)
+
+	// The digest within the environment is of the config file, not those it
+	// includes.
+	sum := sha256.Sum256(main)
+	os.Setenv("APP_CONFIG_SHA256", hex.EncodeToString(sum[:]))
+	defer os.Unsetenv("APP_CONFIG_SHA256")
+	c, err := File(filepath.Join(dir, "main.json")).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host, _ := c.Group("db").String("host"); host != "db" {
+		t.Errorf("host = %q, want db", host)
+	}
+	// Included files are verified by their sidecars.
+	os.WriteFile(filepath.Join(dir, "db.json.sha256"), []byte(hex.EncodeToString(sum[:])), 0600)
+	if _, err = File(filepath.Join(dir, "main.json")).Read(); !errors.Is(err, ErrChecksum) {
+		t.Errorf("error = %v, want ErrChecksum of db.json", err)
+	}
+
+	// Included files are opened within their root, even once confined.
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.json"), []byte(`{}`), 0600)
+	if err := os.Symlink(filepath.Join(outside, "secret.json"), filepath.Join(dir, "swapped.json")); err != nil {
+		t.Skip(err)
+	}
+	root, _ := filepath.EvalSymlinks(dir)
+	if f, err := openFile(filepath.Join(root, "swapped.json"), &options{includeRoot: root, included: true}); err == nil {
+		f.Close()
+		t.Error("opened a symlink out of the root")
+	}
+}
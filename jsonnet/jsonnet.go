@@ -0,0 +1,110 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jsonnet reads configuration written in Jsonnet, evaluated to the
+// JSON document it's then read as, so configs can share the libraries, and
+// conventions, of other Jsonnet authored configuration, eg. Kubernetes', eg.
+//
+//	local base = import 'base.libsonnet';
+//	base {
+//		server+: {port: std.parseInt(std.extVar('PORT'))},
+//	}
+//
+//	s := jsonnet.File("config.jsonnet")
+//	s.ExtVars = map[string]string{"PORT": "9090"}
+//	a, err := config.Open(s)
+//
+// Imports are resolved relative to the importing file, and then the JPaths.
+// Importing the package registers the `.jsonnet` format (see
+// config.RegisterFormat), so File and Glob evaluate `.jsonnet` files, with
+// the environment's `JSONNET_PATH`, and without ext vars. Documents read
+// other than from files, eg. by config.ReadFormat, resolve relative imports
+// from the working directory.
+package jsonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"code.minty.io/config"
+	"github.com/google/go-jsonnet"
+)
+
+func init() {
+	config.RegisterFormat(".jsonnet", decoder{})
+}
+
+// decoder evaluates `.jsonnet` documents, see the package docs.
+type decoder struct{}
+
+func (decoder) Decode(b []byte) (map[string]interface{}, error) {
+	return decoder{}.DecodeFile("-", b)
+}
+
+// DecodeFile evaluates the document `b` of the file at `path`, so its
+// imports are resolved relative to it.
+func (decoder) DecodeFile(path string, b []byte) (map[string]interface{}, error) {
+	out, err := (&Source{}).vm().EvaluateAnonymousSnippet(path, string(b))
+	if err != nil {
+		return nil, fmt.Errorf("jsonnet: %w", err)
+	}
+	var m map[string]interface{}
+	if err = json.Unmarshal([]byte(out), &m); err != nil {
+		return nil, fmt.Errorf("jsonnet: document isn't an object: %w", err)
+	}
+	return m, nil
+}
+
+// Source reads the configuration from a Jsonnet file.
+type Source struct {
+	Path string
+	// JPaths are the library directories imports are looked up in, along
+	// with those of `JSONNET_PATH`.
+	JPaths []string
+	// ExtVars are the external variables, read via `std.extVar`, as strings,
+	// and ExtCode those evaluated as Jsonnet.
+	ExtVars, ExtCode map[string]string
+	// Options bound the evaluated document, see config.ReadFrom.
+	Options []config.Option
+}
+
+// File returns a source evaluating the Jsonnet file at `path`.
+func File(path string) *Source {
+	return &Source{Path: path}
+}
+
+func (s *Source) Read() (config.Config, error) {
+	if _, err := os.Stat(s.Path); err != nil {
+		// Missing files are skipped by chains.
+		return *new(config.Config), err
+	}
+	out, err := s.vm().EvaluateFile(s.Path)
+	if err != nil {
+		return *new(config.Config), fmt.Errorf("jsonnet: %w", err)
+	}
+	c, err := config.ReadFrom([]byte(out), s.Options...)
+	if err != nil {
+		return c, fmt.Errorf("jsonnet %s: %w", s.Path, err)
+	}
+	return c, nil
+}
+
+func (s *Source) Name() string {
+	return "jsonnet " + s.Path
+}
+
+func (s *Source) vm() *jsonnet.VM {
+	vm := jsonnet.MakeVM()
+	jpaths := filepath.SplitList(os.Getenv("JSONNET_PATH"))
+	vm.Importer(&jsonnet.FileImporter{JPaths: append(jpaths, s.JPaths...)})
+	for k, v := range s.ExtVars {
+		vm.ExtVar(k, v)
+	}
+	for k, v := range s.ExtCode {
+		vm.ExtCode(k, v)
+	}
+	return vm
+}
@@ -0,0 +1,147 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package kafka publishes config change events to a Kafka topic, so a fleet
+// of services can invalidate their caches when the central config changes.
+//
+// Events are produced via a Kafka REST proxy (v2 API), such as Confluent's,
+// rather than the broker protocol. A Publisher is a config.AuditSink,
+// publishing the config.Notification of every change as the JSON value of a
+// record, eg.
+//
+//	stop := config.AddAuditSink(kafka.NewPublisher("http://kafka-rest:8082", "config-changes"))
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"code.minty.io/config"
+)
+
+var (
+	// DefaultTimeout bounds publishing each change event, see
+	// Publisher.Timeout.
+	DefaultTimeout = 30 * time.Second
+	// QueueSize is the number of change events a Publisher queues.
+	QueueSize = 64
+)
+
+// Publisher publishes change events to a topic.
+type Publisher struct {
+	// Key is the key of every record, so events share a partition and stay
+	// ordered. Defaults to `config`.
+	Key string
+	// Client is the client used for requests, config.DefaultHTTPClient when
+	// nil.
+	Client *http.Client
+	// Retry is the policy for each publish, defaults to
+	// config.DefaultRetryPolicy.
+	Retry config.RetryPolicy
+	// Timeout bounds publishing each change event, retries included,
+	// DefaultTimeout when zero.
+	Timeout time.Duration
+
+	proxy, topic string
+	once         sync.Once
+	events       chan config.Notification
+}
+
+// NewPublisher returns a publisher to `topic`, via the REST proxy at
+// `proxy`, which can be tuned before being added as a sink.
+func NewPublisher(proxy, topic string) *Publisher {
+	return &Publisher{
+		Key:   "config",
+		Retry: config.DefaultRetryPolicy,
+		proxy: strings.TrimSuffix(proxy, "/"),
+		topic: topic,
+	}
+}
+
+// Audit queues the notification of `e` to be published, unless nothing
+// changed. Events are published in order, in the background, and failures
+// are logged; events are dropped while QueueSize are queued.
+func (p *Publisher) Audit(e config.AuditEvent) error {
+	if len(e.Changes) == 0 {
+		return nil
+	}
+	p.once.Do(func() {
+		p.events = make(chan config.Notification, QueueSize)
+		go p.publish()
+	})
+	select {
+	case p.events <- config.NewNotification(e):
+		return nil
+	default:
+		return fmt.Errorf("kafka: %d events queued, dropped generation %d", QueueSize, e.Generation)
+	}
+}
+
+func (p *Publisher) publish() {
+	for n := range p.events {
+		timeout := p.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := p.Publish(ctx, n); err != nil {
+			log.Printf("kafka: failed to publish generation %d: %s", n.Generation, err)
+		}
+		cancel()
+	}
+}
+
+// Publish produces a record of `v`, as JSON, to the topic, retried per the
+// policy.
+func (p *Publisher) Publish(ctx context.Context, v interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]interface{}{{"key": p.Key, "value": v}},
+	})
+	if err != nil {
+		return err
+	}
+	client := p.Client
+	if client == nil {
+		client = config.DefaultHTTPClient
+	}
+	u := p.proxy + "/topics/" + url.PathEscape(p.topic)
+	return p.Retry.Do(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+		req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		var reply struct {
+			Offsets []struct {
+				ErrorCode *int   `json:"error_code"`
+				Error     string `json:"error"`
+			} `json:"offsets"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&reply)
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("kafka: producing to %s: %s %s", p.topic, resp.Status, reply.Message)
+		}
+		for _, o := range reply.Offsets {
+			if o.ErrorCode != nil {
+				return fmt.Errorf("kafka: producing to %s: %s", p.topic, o.Error)
+			}
+		}
+		return nil
+	})
+}
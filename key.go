@@ -0,0 +1,120 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// KeyFunc fetches the key at `uri`, see RegisterKeyScheme.
+type KeyFunc func(ctx context.Context, uri *url.URL) ([]byte, error)
+
+var (
+	keySchemesMu sync.RWMutex
+	keySchemes   = map[string]KeyFunc{
+		"env":  envKey,
+		"file": fileKey,
+	}
+)
+
+// RegisterKeyScheme registers `fn` as fetching the keys of URIs with
+// `scheme`, see FetchKey, eg. `pkcs11` for keys held by an HSM. A nil `fn`
+// removes the scheme.
+func RegisterKeyScheme(scheme string, fn KeyFunc) {
+	keySchemesMu.Lock()
+	defer keySchemesMu.Unlock()
+	if fn == nil {
+		delete(keySchemes, scheme)
+		return
+	}
+	keySchemes[scheme] = fn
+}
+
+// FetchKey returns the encryption key at `uri`, a keyring URI whose scheme
+// selects where it's kept:
+//
+//	env:NAME?encoding=hex       the environment variable NAME, hex encoded
+//	file:///path                the file at path, raw
+//	file:///path?encoding=hex   the file at path, hex encoded
+//
+// The encoding is `hex` or `base64` (standard or URL, with or without
+// padding), and is required by env URIs, as variables can't hold raw keys.
+//
+// Other schemes are registered via RegisterKeyScheme, eg. `awskms` and
+// `gcpkms` by the kms package, or a PKCS#11 HSM by its own package.
+func FetchKey(ctx context.Context, uri string) ([]byte, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key URI %q: %w", uri, err)
+	}
+	keySchemesMu.RLock()
+	fn := keySchemes[u.Scheme]
+	keySchemesMu.RUnlock()
+	if fn == nil {
+		return nil, fmt.Errorf("unknown key URI scheme %q", u.Scheme)
+	}
+	key, err := fn(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key %s: %w", u.Redacted(), err)
+	}
+	return key, nil
+}
+
+func envKey(ctx context.Context, u *url.URL) ([]byte, error) {
+	s, ok := os.LookupEnv(u.Opaque)
+	if !ok {
+		return nil, fmt.Errorf("%s is not set", u.Opaque)
+	}
+	enc := u.Query().Get("encoding")
+	if enc == "" {
+		return nil, fmt.Errorf("%s has no encoding, eg. env:%s?encoding=hex", u.Opaque, u.Opaque)
+	}
+	return decodeKeyAs(enc, []byte(s))
+}
+
+func fileKey(ctx context.Context, u *url.URL) ([]byte, error) {
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if enc := u.Query().Get("encoding"); enc != "" {
+		return decodeKeyAs(enc, b)
+	}
+	return b, nil
+}
+
+// decodeKeyAs decodes the key within `b`, encoded as `enc`, hex or base64.
+func decodeKeyAs(enc string, b []byte) ([]byte, error) {
+	s := string(bytes.TrimSpace(b))
+	switch enc {
+	case "hex":
+		k, err := hex.DecodeString(s)
+		if err != nil || len(k) == 0 {
+			return nil, errors.New("key isn't hex encoded")
+		}
+		return k, nil
+	case "base64":
+		for _, e := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding,
+			base64.URLEncoding, base64.RawURLEncoding} {
+			if k, err := e.DecodeString(s); err == nil && len(k) > 0 {
+				return k, nil
+			}
+		}
+		return nil, errors.New("key isn't base64 encoded")
+	}
+	return nil, fmt.Errorf("unknown key encoding %q, expected hex or base64", enc)
+}
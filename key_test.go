@@ -0,0 +1,37 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchKey(t *testing.T) {
+	ctx := context.Background()
+	// A raw 32 byte key, which happens to be valid hex too.
+	raw := "0123456789abcdef0123456789abcdef"
+	f := filepath.Join(t.TempDir(), "key")
+	os.WriteFile(f, []byte(raw), 0600)
+	if k, err := FetchKey(ctx, "file://"+f); err != nil || string(k) != raw {
+		t.Errorf("raw file key = %x, %v, want the file as is", k, err)
+	}
+	if k, err := FetchKey(ctx, "file://"+f+"?encoding=hex"); err != nil || len(k) != 16 {
+		t.Errorf("hex file key = %x, %v, want 16 bytes", k, err)
+	}
+
+	t.Setenv("KEYTEST_KEY", "c2VjcmV0")
+	if _, err := FetchKey(ctx, "env:KEYTEST_KEY"); err == nil {
+		t.Error("fetched an env key without an encoding")
+	}
+	if k, err := FetchKey(ctx, "env:KEYTEST_KEY?encoding=base64"); err != nil || string(k) != "secret" {
+		t.Errorf("base64 env key = %q, %v, want secret", k, err)
+	}
+	if _, err := FetchKey(ctx, "env:KEYTEST_KEY?encoding=hex"); err == nil {
+		t.Error("fetched a base64 key as hex")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package keyring registers the `keyring` resolver (see
+// config.RegisterResolver), reading values from the OS credential store, so
+// desktop and CLI tools can keep tokens out of their config files, eg.
+//
+//	import _ "code.minty.io/config/keyring"
+//
+//	{"token": "keyring:github/octocat"}
+//
+// References are `keyring:<service>/<account>`, looked up in:
+//
+//	Linux    the kernel keyring, as the `user` key `<service>:<account>`
+//	         (eg. `keyctl add user github:octocat TOKEN @u`), falling back
+//	         to the Secret Service (via `secret-tool`, attributes `service`
+//	         and `username`)
+//	macOS    the Keychain, as the generic password of the service and account
+//	Windows  the Credential Manager, as the generic credential
+//	         `<service>:<account>`
+package keyring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"code.minty.io/config"
+)
+
+// ErrNotFound is returned when the credential store holds no such value.
+var ErrNotFound = errors.New("keyring: secret not found")
+
+func init() {
+	config.RegisterResolver("keyring", config.ResolverFunc(Resolve))
+}
+
+// Resolve returns the value of the reference `ref`, `<service>/<account>`.
+func Resolve(ctx context.Context, ref string) (config.Lease, error) {
+	i := strings.IndexByte(ref, '/')
+	if i <= 0 || i == len(ref)-1 {
+		return config.Lease{}, fmt.Errorf("keyring: invalid reference %q, expected service/account", ref)
+	}
+	s, err := Get(ctx, ref[:i], ref[i+1:])
+	if err != nil {
+		return config.Lease{}, err
+	}
+	return config.Lease{Value: s}, nil
+}
+
+// Get returns the secret of `account` within `service`.
+func Get(ctx context.Context, service, account string) (string, error) {
+	return get(ctx, service, account)
+}
+
+// run returns the output of the command, less its trailing newline,
+// ErrNotFound when it exits unsuccessfully, as the lookup tools do.
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	b, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("keyring: %s: %w", name, err)
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
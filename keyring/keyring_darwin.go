@@ -0,0 +1,11 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package keyring
+
+import "context"
+
+func get(ctx context.Context, service, account string) (string, error) {
+	return run(ctx, "/usr/bin/security", "find-generic-password", "-s", service, "-a", account, "-w")
+}
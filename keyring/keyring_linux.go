@@ -0,0 +1,66 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package keyring
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	keySpecUserKeyring = ^uintptr(3) // -4, KEY_SPEC_USER_KEYRING
+	keyctlSearch       = 10
+	keyctlRead         = 11
+)
+
+func get(ctx context.Context, service, account string) (string, error) {
+	s, err := kernelKey(service + ":" + account)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return s, err
+	}
+	if _, lerr := exec.LookPath("secret-tool"); lerr != nil {
+		return "", err
+	}
+	return run(ctx, "secret-tool", "lookup", "service", service, "username", account)
+}
+
+// kernelKey reads the `user` key `desc`, searching the process' keyrings,
+// and then the user keyring.
+func kernelKey(desc string) (string, error) {
+	typ, _ := syscall.BytePtrFromString("user")
+	d, err := syscall.BytePtrFromString(desc)
+	if err != nil {
+		return "", err
+	}
+	id, _, errno := syscall.Syscall6(syscall.SYS_REQUEST_KEY,
+		uintptr(unsafe.Pointer(typ)), uintptr(unsafe.Pointer(d)), 0, 0, 0, 0)
+	if errno != 0 {
+		id, _, errno = syscall.Syscall6(syscall.SYS_KEYCTL, keyctlSearch, keySpecUserKeyring,
+			uintptr(unsafe.Pointer(typ)), uintptr(unsafe.Pointer(d)), 0, 0)
+	}
+	if errno != 0 {
+		return "", ErrNotFound
+	}
+	// Read the size first, then the key, retrying should it grow meanwhile.
+	for size := 0; ; {
+		var buf []byte
+		var p uintptr
+		if size > 0 {
+			buf = make([]byte, size)
+			p = uintptr(unsafe.Pointer(&buf[0]))
+		}
+		n, _, errno := syscall.Syscall6(syscall.SYS_KEYCTL, keyctlRead, id, p, uintptr(size), 0, 0)
+		if errno != 0 {
+			return "", errno
+		}
+		if int(n) <= size {
+			return string(buf[:n]), nil
+		}
+		size = int(n)
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !darwin && !windows
+
+package keyring
+
+import (
+	"context"
+	"errors"
+)
+
+func get(ctx context.Context, service, account string) (string, error) {
+	return "", errors.New("keyring: no credential store on this platform")
+}
@@ -0,0 +1,82 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package keyring
+
+import (
+	"context"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+	procCredRead = advapi32.NewProc("CredReadW")
+	procCredFree = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric   = 1
+	errorNotFound     = 1168
+	maxCredentialBlob = 5 * 512
+)
+
+// credential is the CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+func get(ctx context.Context, service, account string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(service + ":" + account)
+	if err != nil {
+		return "", err
+	}
+	var c *credential
+	r, _, err := procCredRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&c)))
+	if r == 0 {
+		if errno, ok := err.(syscall.Errno); ok && errno == errorNotFound {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(c)))
+	n := int(c.CredentialBlobSize)
+	if n == 0 || n > maxCredentialBlob {
+		return "", nil
+	}
+	blob := unsafe.Slice(c.CredentialBlob, n)
+	return decode(blob), nil
+}
+
+// decode returns the credential blob as a string. Credentials saved via the
+// Credential Manager are UTF-16, those of most tools UTF-8.
+func decode(b []byte) string {
+	if len(b)%2 == 0 {
+		u := make([]uint16, len(b)/2)
+		wide := true
+		for i := range u {
+			u[i] = uint16(b[2*i]) | uint16(b[2*i+1])<<8
+			if b[2*i+1] != 0 {
+				wide = false
+			}
+		}
+		if wide {
+			return string(utf16.Decode(u))
+		}
+	}
+	return string(b)
+}
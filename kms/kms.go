@@ -0,0 +1,246 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package kms registers the `awskms` and `gcpkms` keyring URI schemes (see
+// config.FetchKey), fetching data keys by having AWS KMS, or GCP Cloud KMS,
+// decrypt them, eg.
+//
+//	import _ "code.minty.io/config/kms"
+//
+//	c := &config.Cache{File: "config.cache", KeyURI: "awskms:///etc/app/cache.key.enc?region=us-east-1"}
+//
+// The URIs name the file holding the encrypted data key (the ciphertext blob
+// returned by the KMS when the key was generated, or encrypted):
+//
+//	awskms:///path?region=R[&key=ID]
+//	gcpkms://projects/P/locations/L/keyRings/R/cryptoKeys/K?ciphertext=/path
+//
+// AWS credentials are taken from `AWS_ACCESS_KEY_ID`, `AWS_SECRET_ACCESS_KEY`,
+// and `AWS_SESSION_TOKEN`, the region, when not given, from `AWS_REGION` or
+// `AWS_DEFAULT_REGION`. GCP access tokens are taken from
+// `GOOGLE_OAUTH_ACCESS_TOKEN`, or the GCE metadata server. Either takes an
+// `endpoint` parameter, to use another endpoint than the cloud's.
+//
+// PKCS#11 HSMs can't be reached without cgo, and the vendor's module, so
+// aren't supported here; register a `pkcs11` scheme via
+// config.RegisterKeyScheme instead.
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"code.minty.io/config"
+)
+
+// DefaultClient is the client used to call the KMSs.
+var DefaultClient = &http.Client{Timeout: 10 * time.Second}
+
+func init() {
+	config.RegisterKeyScheme("awskms", AWS)
+	config.RegisterKeyScheme("gcpkms", GCP)
+}
+
+// AWS fetches the data key at the `awskms` URI `u`, see the package docs.
+func AWS(ctx context.Context, u *url.URL) ([]byte, error) {
+	blob, err := ciphertext(u, u.Path)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	region := first(q.Get("region"), os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	if region == "" {
+		return nil, errors.New("kms: no AWS region")
+	}
+	creds := credentials{
+		os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.id == "" || creds.secret == "" {
+		return nil, errors.New("kms: no AWS credentials")
+	}
+	body := map[string]string{"CiphertextBlob": base64.StdEncoding.EncodeToString(blob)}
+	if key := q.Get("key"); key != "" {
+		body["KeyId"] = key
+	}
+	b, _ := json.Marshal(body)
+	endpoint := first(q.Get("endpoint"), "https://kms."+region+".amazonaws.com/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+	sign(req, b, region, "kms", creds, time.Now())
+
+	var resp struct{ Plaintext string }
+	if err = do(req, &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Plaintext)
+}
+
+// GCP fetches the data key at the `gcpkms` URI `u`, see the package docs.
+func GCP(ctx context.Context, u *url.URL) ([]byte, error) {
+	q := u.Query()
+	blob, err := ciphertext(u, q.Get("ciphertext"))
+	if err != nil {
+		return nil, err
+	}
+	token, err := gcpToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := u.Host + u.Path
+	b, _ := json.Marshal(map[string]string{"ciphertext": base64.StdEncoding.EncodeToString(blob)})
+	endpoint := strings.TrimSuffix(first(q.Get("endpoint"), "https://cloudkms.googleapis.com"), "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v1/"+name+":decrypt", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var resp struct{ Plaintext string }
+	if err = do(req, &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Plaintext)
+}
+
+// gceTokenURL is the metadata server's URL of the default service account's
+// access token.
+const gceTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+func gcpToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gceTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = do(req, &resp); err != nil {
+		return "", fmt.Errorf("kms: no GCP access token: %w", err)
+	}
+	return resp.AccessToken, nil
+}
+
+// ciphertext reads the encrypted data key from the file at `path`, or the
+// opaque part of `u` for relative paths.
+func ciphertext(u *url.URL, path string) ([]byte, error) {
+	if path == "" {
+		path = u.Opaque
+	}
+	if path == "" {
+		return nil, errors.New("kms: no ciphertext file")
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Ciphertexts are often kept base64 encoded, as the CLIs output them.
+	if d, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b))); err == nil {
+		return d, nil
+	}
+	return b, nil
+}
+
+func do(req *http.Request, v interface{}) error {
+	resp, err := DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kms: %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return json.Unmarshal(b, v)
+}
+
+func first(l ...string) string {
+	for _, s := range l {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+type credentials struct {
+	id, secret, token string
+}
+
+// sign signs `req` with AWS Signature Version 4.
+func sign(req *http.Request, body []byte, region, service string, creds credentials, now time.Time) {
+	date := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", date)
+	if creds.token != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.token)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for name, v := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	fmt.Fprintf(&canonical, "%s\n%s\n%s\n", req.Method, path, req.URL.RawQuery)
+	for _, name := range names {
+		fmt.Fprintf(&canonical, "%s:%s\n", name, headers[name])
+	}
+	signed := strings.Join(names, ";")
+	fmt.Fprintf(&canonical, "\n%s\n%s", signed, hexSum(body))
+
+	scope := date[:8] + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + hexSum([]byte(canonical.String()))
+	key := []byte("AWS4" + creds.secret)
+	for _, s := range []string{date[:8], region, service, "aws4_request"} {
+		key = mac(key, s)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.id, scope, signed, hex.EncodeToString(mac(key, toSign))))
+}
+
+func mac(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+func hexSum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
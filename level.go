@@ -0,0 +1,131 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Level is a normalized log level.
+type Level int
+
+const (
+	DebugLevel Level = iota - 1
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+	FatalLevel: "fatal",
+}
+
+func (l Level) String() string {
+	if s, ok := levelNames[l]; ok {
+		return s
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel parses the case-insensitive level name `s`, one of "debug",
+// "info", "warn" (or "warning"), "error", or "fatal".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	case "fatal":
+		return FatalLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("invalid log level %q", s)
+}
+
+// UnmarshalText parses the level name, so levels can be bound, see Bind.
+func (l *Level) UnmarshalText(b []byte) error {
+	v, err := ParseLevel(string(b))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+// MarshalText returns the level name.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// Slog returns the equivalent slog.Level. As slog doesn't have a fatal level,
+// FatalLevel maps to a level above slog.LevelError.
+func (l Level) Slog() slog.Level {
+	switch l {
+	case DebugLevel:
+		return slog.LevelDebug
+	case InfoLevel:
+		return slog.LevelInfo
+	case WarnLevel:
+		return slog.LevelWarn
+	case ErrorLevel:
+		return slog.LevelError
+	case FatalLevel:
+		return slog.LevelError + 4
+	}
+	return slog.Level(int(l) * 4)
+}
+
+// Zap returns the equivalent zapcore.Level value, so it can be converted
+// without importing zap here, eg. `zapcore.Level(l.Zap())`.
+func (l Level) Zap() int8 {
+	switch l {
+	case FatalLevel:
+		// zapcore.FatalLevel, after DPanicLevel and PanicLevel.
+		return 5
+	}
+	// The remaining levels share zapcore's values.
+	return int8(l)
+}
+
+// LogLevel returns the log level for the `key` within the root level.
+func (c Config) LogLevel(key string) (Level, error) {
+	s, ok := c.String(key)
+	if !ok {
+		return InfoLevel, fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	return ParseLevel(s)
+}
+
+// RequiredLogLevel returns the log level, within the root, and exits when not
+// found or invalid.
+func (c Config) RequiredLogLevel(key string) Level {
+	l, err := c.LogLevel(key)
+	if err != nil {
+		log.Fatalf("failed to retrieve '%s' log level from config: %s", key, err)
+	}
+	return l
+}
+
+// LogLevel returns the log level for the `key` within the root level.
+func LogLevel(key string) (Level, error) {
+	return cfg.LogLevel(key)
+}
+
+// RequiredLogLevel returns the log level, within the root, and exits when not
+// found or invalid.
+func RequiredLogLevel(key string) Level {
+	return cfg.RequiredLogLevel(key)
+}
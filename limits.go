@@ -0,0 +1,141 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ErrLimit is returned when a config exceeds one of the parser limits.
+var ErrLimit = errors.New("config exceeds limit")
+
+// Default parser limits, generous for any hand written config, but enough to
+// stop a hostile, or corrupted, file from taking a service down at startup.
+const (
+	DefaultMaxSize  = 16 << 20
+	DefaultMaxDepth = 64
+	DefaultMaxKeys  = 100000
+)
+
+// Option configures how a config is read, or saved.
+type Option func(*options)
+
+type options struct {
+	maxSize, maxDepth, maxKeys int
+	// backup keeps the previous file on save, see WithBackup.
+	backup bool
+	// writeBack saves migrated files, see WithWriteBack, and migrated is set
+	// once a config has been migrated.
+	writeBack, migrated bool
+	// partial defers migrating unversioned documents, the files of a Glob,
+	// until they're merged.
+	partial bool
+	// numbers decodes numbers as json.Number, see WithNumbers.
+	numbers bool
+	// strict rejects duplicate keys, see WithStrict.
+	strict bool
+	// perms checks the permissions of sensitive files, see
+	// WithPermissionCheck.
+	perms PermissionCheck
+	// includeRoot confines includes, see WithIncludeRoot, and including is
+	// the chain of files being included.
+	includeRoot string
+	including   refChain
+	// included opens the file read within includeRoot, see openFile.
+	included bool
+	// sidecars verifies files by their sidecars only, not the digest and
+	// signature within the environment, see verify, and verified requires
+	// them to be verified, see readUser.
+	sidecars, verified bool
+	// refs resolves references, see WithReferences, and deferRefs defers
+	// resolving them until included files are merged, see readFile.
+	refs, deferRefs bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		maxSize:  DefaultMaxSize,
+		maxDepth: DefaultMaxDepth,
+		maxKeys:  DefaultMaxKeys,
+		perms:    DefaultPermissionCheck,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithMaxSize limits the size of the document to `n` bytes, no limit when
+// `n` is zero or less.
+func WithMaxSize(n int) Option {
+	return func(o *options) { o.maxSize = n }
+}
+
+// WithMaxDepth limits the nesting of groups and lists to `n` levels, no limit
+// when `n` is zero or less.
+func WithMaxDepth(n int) Option {
+	return func(o *options) { o.maxDepth = n }
+}
+
+// WithMaxKeys limits the total number of keys, across all groups, to `n`, no
+// limit when `n` is zero or less.
+func WithMaxKeys(n int) Option {
+	return func(o *options) { o.maxKeys = n }
+}
+
+// readAll reads `r`, failing once more than the max size has been read rather
+// than buffering an unbounded amount.
+func (o *options) readAll(r io.Reader) ([]byte, error) {
+	if o.maxSize <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(o.maxSize)+1))
+	if err == nil && len(b) > o.maxSize {
+		err = fmt.Errorf("%w: larger than %d bytes", ErrLimit, o.maxSize)
+	}
+	return b, err
+}
+
+// check scans the raw JSON document for the limits before it's decoded.
+// Malformed documents are left for the decoder to report.
+func (o *options) check(b []byte) error {
+	if o.maxSize > 0 && len(b) > o.maxSize {
+		return fmt.Errorf("%w: larger than %d bytes", ErrLimit, o.maxSize)
+	}
+	depth, keys := 0, 0
+	inString, escaped := false, false
+	for _, c := range b {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			if depth++; o.maxDepth > 0 && depth > o.maxDepth {
+				return fmt.Errorf("%w: nested deeper than %d levels", ErrLimit, o.maxDepth)
+			}
+		case '}', ']':
+			depth--
+		case ':':
+			// Every member of an object is followed by a colon.
+			if keys++; o.maxKeys > 0 && keys > o.maxKeys {
+				return fmt.Errorf("%w: more than %d keys", ErrLimit, o.maxKeys)
+			}
+		}
+	}
+	return nil
+}
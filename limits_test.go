@@ -0,0 +1,124 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadFromLimits(t *testing.T) {
+	tests := []struct {
+		doc  string
+		opts []Option
+		err  bool
+	}{
+		{`{"a": 1}`, nil, false},
+		{`{"a": 1}`, []Option{WithMaxSize(4)}, true},
+		{`{"a": {"b": [1]}}`, []Option{WithMaxDepth(3)}, false},
+		{`{"a": {"b": [1]}}`, []Option{WithMaxDepth(2)}, true},
+		{`{"a": "{{{{"}`, []Option{WithMaxDepth(1)}, false},
+		{`{"a": 1, "b": {"c": 2}}`, []Option{WithMaxKeys(3)}, false},
+		{`{"a": 1, "b": {"c": 2}}`, []Option{WithMaxKeys(2)}, true},
+		{`{"a": "x:y:z"}`, []Option{WithMaxKeys(1)}, false},
+		{`{"a": "\":"}`, []Option{WithMaxKeys(1)}, false},
+		{strings.Repeat("[", 100) + strings.Repeat("]", 100), nil, true},
+		{strings.Repeat("[", 100) + strings.Repeat("]", 100), []Option{WithMaxDepth(0)}, true},
+	}
+	for _, test := range tests {
+		_, err := ReadFrom([]byte(test.doc), test.opts...)
+		if (err != nil) != test.err {
+			t.Errorf("ReadFrom(%.20q) error = %v, want error %t", test.doc, err, test.err)
+		}
+		if test.err && test.opts != nil && len(test.doc) < 100 && !errors.Is(err, ErrLimit) {
+			t.Errorf("ReadFrom(%.20q) error = %v, want ErrLimit", test.doc, err)
+		}
+	}
+}
+
+func TestReadFromReaderMaxSize(t *testing.T) {
+	r := strings.NewReader(`{"a": "` + strings.Repeat("x", 1024) + `"}`)
+	if _, err := ReadFromReader(r, WithMaxSize(512)); !errors.Is(err, ErrLimit) {
+		t.Errorf("ReadFromReader error = %v, want ErrLimit", err)
+	}
+}
+
+func FuzzReadFrom(f *testing.F) {
+	for _, seed := range []string{
+		`{"host": "google.com", "links": {"google": "https://google.com"}}`,
+		`{"a": [1, 2.5, true, null, {"b": "\"}"}]}`,
+		`[]`,
+		`"string"`,
+		`{"a":`,
+		`{{{{`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		c, err := ReadFrom(b, WithMaxSize(1<<16), WithMaxDepth(32), WithMaxKeys(1024))
+		if err != nil {
+			return
+		}
+		for _, key := range c.Keys() {
+			c.Val(key)
+			c.GroupKeys(key)
+		}
+	})
+}
+
+func TestReadFormatLimits(t *testing.T) {
+	d := DecoderFunc(func(b []byte) (map[string]interface{}, error) {
+		return map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1.0, 2.0}}}, nil
+	})
+	RegisterFormat(".test", d)
+	defer RegisterFormat(".test", nil)
+
+	tests := []struct {
+		opts []Option
+		err  bool
+	}{
+		{nil, false},
+		{[]Option{WithMaxDepth(3)}, false},
+		{[]Option{WithMaxDepth(2)}, true},
+		{[]Option{WithMaxKeys(2)}, false},
+		{[]Option{WithMaxKeys(1)}, true},
+		{[]Option{WithMaxSize(1)}, true},
+	}
+	for i, test := range tests {
+		_, err := ReadFormat(".TEST", []byte("doc"), test.opts...)
+		if (err != nil) != test.err || (err != nil && !errors.Is(err, ErrLimit)) {
+			t.Errorf("%d: ReadFormat error = %v, want ErrLimit %t", i, err, test.err)
+		}
+	}
+}
+
+func TestXMLDepth(t *testing.T) {
+	deep := strings.Repeat("<a>", maxBinaryDepth+2) + strings.Repeat("</a>", maxBinaryDepth+2)
+	if _, err := ReadXML([]byte(deep), &XMLMapping{}, WithMaxDepth(0), WithMaxSize(0)); err == nil || !strings.Contains(err.Error(), "nested too deeply") {
+		t.Errorf("error = %v, want nested too deeply", err)
+	}
+}
+
+func TestStrictDuplicateKeys(t *testing.T) {
+	doc := []byte("{\n\t\"db\": {\"host\": \"a\",\n\t\t\"host\": \"b\"},\n\t\"list\": [{\"k\": 1, \"k\": 2}],\n\t\"db\": {}\n}")
+	if _, err := ReadFrom(doc); err != nil {
+		t.Fatalf("lenient: %s", err)
+	}
+	_, err := ReadFrom(doc, WithStrict())
+	var de *DuplicateKeyError
+	if !errors.As(err, &de) || !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("error = %v, want a DuplicateKeyError", err)
+	}
+	want := []DuplicateKey{
+		{"db.host", 3, 3, 2, 9},
+		{"list.0.k", 4, 20, 4, 12},
+		{"db", 5, 2, 2, 2},
+	}
+	if !reflect.DeepEqual(de.Keys, want) {
+		t.Errorf("duplicates = %+v, want %+v", de.Keys, want)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity is the severity of a lint rule's findings.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	// SeverityOff disables a rule.
+	SeverityOff Severity = "off"
+)
+
+// Finding is a problem found by a lint rule at the dot separated path of a
+// key, eg. `db.password`.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path"`
+	// Source names the layer defining the key, and Position is where, when
+	// known.
+	Source   string    `json:"source,omitempty"`
+	Position *Location `json:"position,omitempty"`
+	Message  string    `json:"message"`
+}
+
+func (f Finding) String() string {
+	s := fmt.Sprintf("%s: %s: %s (%s)", f.Severity, f.Path, f.Message, f.Rule)
+	if f.Position != nil {
+		s = f.Position.String() + ": " + s
+	}
+	return s
+}
+
+// LintInput is what lint rules check: the config, and when linting a chain,
+// the layers defining each key, see Chain.Provenance.
+type LintInput struct {
+	Config Config
+	Layers map[string][]Layer
+}
+
+// LintRule is a rule checking configs, see RegisterLintRule.
+type LintRule struct {
+	Description string
+	// Severity is the severity of the rule's findings, unless configured
+	// otherwise, an error when empty.
+	Severity Severity
+	// Check reports the problems within `in`, by the path of their key.
+	Check func(in LintInput, report func(path, message string))
+}
+
+var (
+	lintRulesMu sync.RWMutex
+	lintRules   = map[string]*LintRule{
+		"no-plaintext-secrets": {
+			Description: "secrets are references, eg. vault:secret/db#password, not plaintext",
+			Check:       lintSecrets,
+		},
+		"duration-units": {
+			Description: "durations have units, eg. 30s rather than 30",
+			Check:       lintDurations,
+		},
+		"snake-case-keys": {
+			Description: "keys are snake_case",
+			Severity:    SeverityWarning,
+			Check:       lintSnakeCase,
+		},
+		"no-duplicate-keys": {
+			Description: "keys are defined by a single layer",
+			Severity:    SeverityWarning,
+			Check:       lintDuplicates,
+		},
+	}
+)
+
+// RegisterLintRule registers `r` as the lint rule `name`, replacing any rule
+// of that name, eg. a built-in one. A nil `r` removes the rule.
+func RegisterLintRule(name string, r *LintRule) {
+	lintRulesMu.Lock()
+	defer lintRulesMu.Unlock()
+	if r == nil {
+		delete(lintRules, name)
+		return
+	}
+	lintRules[name] = r
+}
+
+// LintRules returns the registered lint rules, by name.
+func LintRules() map[string]*LintRule {
+	lintRulesMu.RLock()
+	defer lintRulesMu.RUnlock()
+	rules := make(map[string]*LintRule, len(lintRules))
+	for name, r := range lintRules {
+		rules[name] = r
+	}
+	return rules
+}
+
+// LintConfig configures lint rules, by name, eg. from a JSON document:
+//
+//	{"snake-case-keys": {"severity": "off"}, "duration-units": {"ignore": ["legacy.*"]}}
+type LintConfig map[string]LintRuleConfig
+
+// LintRuleConfig configures a lint rule.
+type LintRuleConfig struct {
+	// Severity overrides the rule's, SeverityOff disabling it.
+	Severity Severity `json:"severity,omitempty"`
+	// Ignore are patterns of the paths whose findings are dropped, matched
+	// per path.Match, eg. `legacy.*`.
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+// Lint checks `c` against the registered lint rules, configured by `lc`,
+// and returns the findings, sorted by path. Rules comparing layers find
+// nothing, see Chain.Lint.
+func (c Config) Lint(lc LintConfig) []Finding {
+	return LintInput{Config: c}.lint(lc)
+}
+
+// Lint reads every source of the chain and checks the merged config, and the
+// layers defining each key, against the registered lint rules, see
+// Config.Lint.
+func (ch *Chain) Lint(lc LintConfig) ([]Finding, error) {
+	c, err := ch.Read()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := ch.Provenance()
+	if err != nil {
+		return nil, err
+	}
+	return LintInput{c, layers}.lint(lc), nil
+}
+
+func (in LintInput) lint(lc LintConfig) []Finding {
+	var findings []Finding
+	for name, r := range LintRules() {
+		rc := lc[name]
+		severity := rc.Severity
+		if severity == "" {
+			severity = r.Severity
+		}
+		if severity == "" {
+			severity = SeverityError
+		}
+		if severity == SeverityOff {
+			continue
+		}
+		r.Check(in, func(p, message string) {
+			for _, pattern := range rc.Ignore {
+				if ok, _ := path.Match(pattern, p); ok {
+					return
+				}
+			}
+			f := Finding{Rule: name, Severity: severity, Path: p, Message: message}
+			if l := in.Layers[p]; len(l) > 0 {
+				f.Source = l[0].Source
+			}
+			if l, ok := in.Config.Position(p); ok {
+				f.Position = &l
+			}
+			findings = append(findings, f)
+		})
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Rule < findings[j].Rule
+	})
+	return findings
+}
+
+// secretKey matches the names of keys holding secrets, and refSyntax the
+// references to secrets, whose schemes are those of registered resolvers (see
+// RegisterResolver), or knownSchemes, so configs lint the same without them.
+var (
+	secretKey = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|private_?key|credentials?)$`)
+	refSyntax = regexp.MustCompile(`^([a-z][a-z0-9+.-]*):\S+$`)
+)
+
+// knownSchemes are the schemes of the resolvers of this module's packages,
+// and Vault's.
+var knownSchemes = map[string]bool{
+	"vault": true, "keyring": true, "op": true, "bw": true, "gcp-sm": true, "azkv": true,
+}
+
+// isReference returns whether `s` is a reference to a secret.
+func isReference(s string) bool {
+	m := refSyntax.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+	if knownSchemes[m[1]] {
+		return true
+	}
+	r, _, _ := reference(s)
+	return r != nil
+}
+
+func lintSecrets(in LintInput, report func(path, message string)) {
+	walk("", in.Config.m, func(p string, v interface{}) bool {
+		s, ok := v.(string)
+		if !ok || s == "" || s == Redacted || !secretKey.MatchString(lastKey(p)) {
+			return true
+		}
+		if !isReference(s) {
+			report(p, "plaintext secret, use a reference to a secret store instead")
+		}
+		return true
+	})
+}
+
+// durationKey matches the names of keys holding durations, but not those
+// naming their unit, eg. `timeout_ms`.
+var durationKey = regexp.MustCompile(`(?i)(timeout|interval|duration|ttl|delay|period|backoff)$`)
+
+func lintDurations(in LintInput, report func(path, message string)) {
+	walk("", in.Config.m, func(p string, v interface{}) bool {
+		if !durationKey.MatchString(lastKey(p)) {
+			return true
+		}
+		switch v := v.(type) {
+		case string:
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				if _, err := time.ParseDuration(v); err != nil {
+					report(p, fmt.Sprintf("invalid duration %q", v))
+				}
+				return true
+			}
+		case map[string]interface{}, []interface{}, bool, nil:
+			return true
+		}
+		report(p, fmt.Sprintf("duration %v has no unit, eg. %vs", v, v))
+		return true
+	})
+}
+
+var snakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+func lintSnakeCase(in LintInput, report func(path, message string)) {
+	walk("", in.Config.m, func(p string, v interface{}) bool {
+		if key := lastKey(p); !strings.HasPrefix(key, "$") && !snakeCase.MatchString(key) {
+			report(p, fmt.Sprintf("key %q isn't snake_case", key))
+		}
+		return true
+	})
+}
+
+func lintDuplicates(in LintInput, report func(path, message string)) {
+	for p, layers := range in.Layers {
+		if len(layers) < 2 {
+			continue
+		}
+		names := make([]string, len(layers))
+		for i, l := range layers {
+			names[i] = l.Source
+		}
+		report(p, fmt.Sprintf("defined by %d layers: %s", len(layers), strings.Join(names, ", ")))
+	}
+}
+
+func lastKey(p string) string {
+	return p[strings.LastIndexByte(p, '.')+1:]
+}
@@ -0,0 +1,30 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLintSecrets(t *testing.T) {
+	RegisterResolver("lintest", ResolverFunc(func(ctx context.Context, ref string) (Lease, error) {
+		return Lease{Value: ref}, nil
+	}))
+	defer RegisterResolver("lintest", nil)
+	c := FromMap(map[string]interface{}{
+		"admin_password": "admin:hunter2",
+		"db_password":    "vault:secret/db#password",
+		"api_key":        "lintest:key",
+		"token":          "hunter2",
+	})
+	var found []string
+	for _, f := range c.Lint(LintConfig{"snake-case-keys": {Severity: SeverityOff}}) {
+		found = append(found, f.Path)
+	}
+	if len(found) != 2 || found[0] != "admin_password" || found[1] != "token" {
+		t.Errorf("plaintext secrets %v, want [admin_password token]", found)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package locale provides accessors for BCP 47 language tags and ISO 4217
+// currency codes, validated by golang.org/x/text, eg.
+//
+//	"i18n": {
+//		"locale": "en-US",
+//		"locales": ["en-US", "fr-CA", "de"],
+//		"currency": "USD",
+//		"currencies": ["USD", "CAD", "EUR"]
+//	}
+//
+// The accessors share the config package's lookup of strings, so a config
+// (or one of its groups) is passed along, eg.
+//
+//	tag, err := locale.Language(config.Group("i18n"), "locale")
+package locale
+
+import (
+	"fmt"
+	"log"
+
+	"code.minty.io/config"
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+)
+
+// ErrNotFound is returned when the key isn't found, and is the config
+// package's, so errors.Is matches either.
+var ErrNotFound = config.ErrNotFound
+
+// Getter looks up strings, and lists of strings, by key, eg. config.Config.
+type Getter interface {
+	String(key string) (string, bool)
+	Strings(key string) ([]string, bool)
+}
+
+// Language returns the language tag for `key`, which must be a well-formed
+// BCP 47 tag.
+func Language(g Getter, key string) (language.Tag, error) {
+	s, ok := g.String(key)
+	if !ok {
+		return language.Und, fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	t, err := language.Parse(s)
+	if err != nil {
+		return language.Und, fmt.Errorf("invalid '%s' language tag %q: %s", key, s, err)
+	}
+	return t, nil
+}
+
+// Languages returns the list of language tags for `key`.
+func Languages(g Getter, key string) ([]language.Tag, error) {
+	l, ok := g.Strings(key)
+	if !ok {
+		return nil, fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	tags := make([]language.Tag, len(l))
+	for i, s := range l {
+		t, err := language.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid '%s' language tag %q: %s", key, s, err)
+		}
+		tags[i] = t
+	}
+	return tags, nil
+}
+
+// Matcher returns a matcher of the language tags for `key`, the first being
+// the default, for matching against a request's Accept-Language.
+func Matcher(g Getter, key string) (language.Matcher, error) {
+	tags, err := Languages(g, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("'%s' has no language tags", key)
+	}
+	return language.NewMatcher(tags), nil
+}
+
+// Currency returns the currency for `key`, which must be an ISO 4217 code.
+func Currency(g Getter, key string) (currency.Unit, error) {
+	s, ok := g.String(key)
+	if !ok {
+		return currency.Unit{}, fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	u, err := currency.ParseISO(s)
+	if err != nil {
+		return currency.Unit{}, fmt.Errorf("invalid '%s' currency %q: %s", key, s, err)
+	}
+	return u, nil
+}
+
+// Currencies returns the list of currencies for `key`, rejecting duplicates.
+func Currencies(g Getter, key string) ([]currency.Unit, error) {
+	l, ok := g.Strings(key)
+	if !ok {
+		return nil, fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	units := make([]currency.Unit, len(l))
+	seen := make(map[currency.Unit]bool, len(l))
+	for i, s := range l {
+		u, err := currency.ParseISO(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid '%s' currency %q: %s", key, s, err)
+		}
+		if seen[u] {
+			return nil, fmt.Errorf("duplicate '%s' currency %q", key, s)
+		}
+		seen[u] = true
+		units[i] = u
+	}
+	return units, nil
+}
+
+// RequiredLanguage returns the language tag for `key`, and exits when not
+// found or invalid.
+func RequiredLanguage(g Getter, key string) language.Tag {
+	t, err := Language(g, key)
+	if err != nil {
+		log.Fatalf("failed to retrieve '%s' language from config: %s", key, err)
+	}
+	return t
+}
+
+// RequiredCurrency returns the currency for `key`, and exits when not found
+// or invalid.
+func RequiredCurrency(g Getter, key string) currency.Unit {
+	u, err := Currency(g, key)
+	if err != nil {
+		log.Fatalf("failed to retrieve '%s' currency from config: %s", key, err)
+	}
+	return u
+}
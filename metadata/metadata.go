@@ -0,0 +1,286 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package metadata exposes cloud instance metadata (EC2, GCE, or Azure)
+// within the reserved `metadata` group of the config, eg.
+//
+//	metadata.Register(metadata.EC2, metadata.GCE, metadata.Azure)
+//	region, _ := config.Group("metadata").String("region")
+//
+// Metadata is fetched once, in the background, from the first cloud that
+// responds within the Timeout, and then kept. Off-cloud, lookups find nothing.
+//
+// The group holds:
+//
+//	provider       "ec2", "gce", or "azure"
+//	region         eg. "us-east-1"
+//	zone           eg. "us-east-1a"
+//	instance_id
+//	instance_type  eg. "m5.large"
+//	tags           a group of the instance's tags
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"code.minty.io/config"
+)
+
+// Group is the reserved group the metadata is exposed as.
+const Group = "metadata"
+
+// Timeout bounds fetching the metadata from each cloud.
+var Timeout = 2 * time.Second
+
+// Client is the client used for metadata requests. Metadata services are
+// link-local, so it never uses a proxy.
+var Client = &http.Client{Transport: &http.Transport{}}
+
+// Metadata describes the instance the process is running on.
+type Metadata struct {
+	Provider     string
+	Region       string
+	Zone         string
+	InstanceID   string
+	InstanceType string
+	Tags         map[string]string
+}
+
+// Map returns the metadata as it's exposed within the config.
+func (m Metadata) Map() map[string]interface{} {
+	tags := make(map[string]interface{}, len(m.Tags))
+	for k, v := range m.Tags {
+		tags[k] = v
+	}
+	return map[string]interface{}{
+		"provider":      m.Provider,
+		"region":        m.Region,
+		"zone":          m.Zone,
+		"instance_id":   m.InstanceID,
+		"instance_type": m.InstanceType,
+		"tags":          tags,
+	}
+}
+
+// Cloud fetches the metadata of a cloud's instance.
+type Cloud func(ctx context.Context) (Metadata, error)
+
+// ErrNotFound is returned when no cloud's metadata service responds.
+var ErrNotFound = errors.New("instance metadata not found")
+
+// Fetch returns the metadata from the first of `clouds` to respond, asking
+// them all at once, so it takes at most the Timeout.
+func Fetch(ctx context.Context, clouds ...Cloud) (Metadata, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	type result struct {
+		m   Metadata
+		err error
+	}
+	results := make(chan result, len(clouds))
+	for _, cloud := range clouds {
+		go func(cloud Cloud) {
+			m, err := cloud(ctx)
+			results <- result{m, err}
+		}(cloud)
+	}
+	for range clouds {
+		if r := <-results; r.err == nil {
+			return r.m, nil
+		}
+	}
+	return Metadata{}, ErrNotFound
+}
+
+// Source is a config.Source reading the metadata into the `metadata` group,
+// eg. for a config.Chain.
+type Source struct {
+	Clouds []Cloud
+}
+
+func (s *Source) Read() (config.Config, error) {
+	m, err := Fetch(context.Background(), s.Clouds...)
+	if err != nil {
+		return *new(config.Config), err
+	}
+	return config.FromMap(map[string]interface{}{Group: m.Map()}), nil
+}
+
+var (
+	once    sync.Once
+	done    = make(chan struct{})
+	fetched map[string]interface{}
+)
+
+// Register exposes the metadata within the `metadata` group of every config,
+// via config.Provide, fetching it once, in the background, from the first of
+// `clouds` to respond. Lookups wait on the fetch, at most the Timeout.
+func Register(clouds ...Cloud) {
+	once.Do(func() {
+		go func() {
+			if m, err := Fetch(context.Background(), clouds...); err == nil {
+				fetched = m.Map()
+			}
+			close(done)
+		}()
+	})
+	for _, key := range []string{"provider", "region", "zone", "instance_id", "instance_type", "tags"} {
+		key := key
+		config.Provide(Group+"."+key, func() interface{} {
+			<-done
+			return fetched[key]
+		})
+	}
+}
+
+func get(ctx context.Context, method, url string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	return b, nil
+}
+
+// EC2Endpoint is the base URL of the EC2 instance metadata service.
+var EC2Endpoint = "http://169.254.169.254"
+
+// EC2 fetches the metadata of an EC2 instance via IMDSv2. Tags are only
+// available when the instance allows access to them within its metadata.
+func EC2(ctx context.Context) (Metadata, error) {
+	token, err := get(ctx, http.MethodPut, EC2Endpoint+"/latest/api/token",
+		http.Header{"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"300"}})
+	if err != nil {
+		return Metadata{}, err
+	}
+	h := http.Header{"X-Aws-Ec2-Metadata-Token": {string(token)}}
+	field := func(path string) (string, error) {
+		b, err := get(ctx, http.MethodGet, EC2Endpoint+"/latest/meta-data/"+path, h)
+		return strings.TrimSpace(string(b)), err
+	}
+	m := Metadata{Provider: "ec2", Tags: map[string]string{}}
+	for _, f := range []struct {
+		path string
+		v    *string
+	}{
+		{"placement/region", &m.Region},
+		{"placement/availability-zone", &m.Zone},
+		{"instance-id", &m.InstanceID},
+		{"instance-type", &m.InstanceType},
+	} {
+		if *f.v, err = field(f.path); err != nil {
+			return Metadata{}, err
+		}
+	}
+	if keys, err := field("tags/instance"); err == nil {
+		for _, k := range strings.Fields(keys) {
+			if v, err := field("tags/instance/" + k); err == nil {
+				m.Tags[k] = v
+			}
+		}
+	}
+	return m, nil
+}
+
+// GCEEndpoint is the base URL of the GCE metadata server.
+var GCEEndpoint = "http://metadata.google.internal"
+
+// GCE fetches the metadata of a GCE instance. Its tags are the instance's
+// network tags, each with an empty value, and its custom metadata attributes.
+func GCE(ctx context.Context) (Metadata, error) {
+	b, err := get(ctx, http.MethodGet, GCEEndpoint+"/computeMetadata/v1/instance/?recursive=true",
+		http.Header{"Metadata-Flavor": {"Google"}})
+	if err != nil {
+		return Metadata{}, err
+	}
+	var inst struct {
+		ID          json.Number       `json:"id"`
+		Zone        string            `json:"zone"`
+		MachineType string            `json:"machineType"`
+		Tags        []string          `json:"tags"`
+		Attributes  map[string]string `json:"attributes"`
+	}
+	if err = json.Unmarshal(b, &inst); err != nil {
+		return Metadata{}, fmt.Errorf("invalid GCE metadata: %s", err)
+	}
+	// Zones and machine types are of the form `projects/1/zones/us-east1-b`.
+	zone := inst.Zone[strings.LastIndexByte(inst.Zone, '/')+1:]
+	m := Metadata{
+		Provider:     "gce",
+		Zone:         zone,
+		InstanceID:   inst.ID.String(),
+		InstanceType: inst.MachineType[strings.LastIndexByte(inst.MachineType, '/')+1:],
+		Tags:         map[string]string{},
+	}
+	if i := strings.LastIndexByte(zone, '-'); i > 0 {
+		m.Region = zone[:i]
+	}
+	for _, t := range inst.Tags {
+		m.Tags[t] = ""
+	}
+	for k, v := range inst.Attributes {
+		m.Tags[k] = v
+	}
+	return m, nil
+}
+
+// AzureEndpoint is the base URL of the Azure instance metadata service.
+var AzureEndpoint = "http://169.254.169.254"
+
+// Azure fetches the metadata of an Azure virtual machine.
+func Azure(ctx context.Context) (Metadata, error) {
+	b, err := get(ctx, http.MethodGet, AzureEndpoint+"/metadata/instance/compute?api-version=2021-02-01",
+		http.Header{"Metadata": {"true"}})
+	if err != nil {
+		return Metadata{}, err
+	}
+	var compute struct {
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+		VMID     string `json:"vmId"`
+		VMSize   string `json:"vmSize"`
+		TagsList []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"tagsList"`
+	}
+	if err = json.Unmarshal(b, &compute); err != nil {
+		return Metadata{}, fmt.Errorf("invalid Azure metadata: %s", err)
+	}
+	m := Metadata{
+		Provider:     "azure",
+		Region:       compute.Location,
+		Zone:         compute.Zone,
+		InstanceID:   compute.VMID,
+		InstanceType: compute.VMSize,
+		Tags:         map[string]string{},
+	}
+	for _, t := range compute.TagsList {
+		m.Tags[t.Name] = t.Value
+	}
+	return m, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"sync"
+)
+
+// VersionKey is the key holding the layout version of a config, upgraded by
+// registered migrations, see RegisterMigration.
+const VersionKey = "config_version"
+
+// Migration upgrades the values of `m`, in place, from one layout version to
+// the next.
+type Migration func(m map[string]interface{}) error
+
+type migration struct {
+	to int
+	fn Migration
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = map[int]migration{}
+)
+
+// RegisterMigration registers `fn` as upgrading configs at version `from` to
+// version `to`, eg. renaming a key:
+//
+//	config.RegisterMigration(1, 2, func(m map[string]interface{}) error {
+//		m["listen"] = m["addr"]
+//		delete(m, "addr")
+//		return nil
+//	})
+//
+// When loaded, configs are upgraded through every migration from their
+// VersionKey to the latest registered version, configs without one being at
+// the lowest version migrated from. Configs newer than the latest version
+// fail to load.
+func RegisterMigration(from, to int, fn Migration) {
+	if to <= from {
+		panic(fmt.Sprintf("config: migration from %d to %d must increase the version", from, to))
+	}
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	if _, ok := migrations[from]; ok {
+		panic(fmt.Sprintf("config: migration from %d already registered", from))
+	}
+	migrations[from] = migration{to, fn}
+}
+
+// WithWriteBack writes migrated config files back, upgraded, keeping the
+// previous file as a backup (see WithBackup).
+func WithWriteBack() Option {
+	return func(o *options) { o.writeBack = true }
+}
+
+// Version returns the layout version of the config, 0 when not set.
+func (c Config) Version() int {
+	return version(c.m)
+}
+
+func version(m map[string]interface{}) int {
+	v, ok := colInt(VersionKey, m, true)
+	if !ok {
+		return 0
+	}
+	return v
+}
+
+// migrate upgrades `m` to the latest version, returning whether it changed.
+func migrate(m map[string]interface{}) (bool, error) {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	if len(migrations) == 0 {
+		return false, nil
+	}
+	lowest, latest := -1, 0
+	for from, mig := range migrations {
+		if lowest < 0 || from < lowest {
+			lowest = from
+		}
+		if mig.to > latest {
+			latest = mig.to
+		}
+	}
+	from := lowest
+	if _, ok := m[VersionKey]; ok {
+		from = version(m)
+	}
+	version := from
+	if version > latest {
+		return false, fmt.Errorf("config version %d is newer than the supported version %d", version, latest)
+	}
+	migrated := false
+	for version < latest {
+		mig, ok := migrations[version]
+		if !ok {
+			return false, fmt.Errorf("no migration from config version %d", version)
+		}
+		if err := mig.fn(m); err != nil {
+			return false, fmt.Errorf("failed to migrate config from version %d to %d: %w", version, mig.to, err)
+		}
+		version = mig.to
+		m[VersionKey] = float64(version)
+		migrated = true
+	}
+	return migrated, nil
+}
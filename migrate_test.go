@@ -0,0 +1,75 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	defer func() {
+		migrationsMu.Lock()
+		defer migrationsMu.Unlock()
+		migrations = map[int]migration{}
+	}()
+	rename := func(from, to string) Migration {
+		return func(m map[string]interface{}) error {
+			if v, ok := m[from]; ok {
+				m[to] = v
+				delete(m, from)
+			}
+			return nil
+		}
+	}
+	RegisterMigration(1, 2, rename("addr", "listen"))
+	RegisterMigration(2, 3, rename("listen", "bind"))
+
+	for _, test := range []struct {
+		name, doc string
+		want      string
+	}{
+		{"unversioned", `{"addr": ":80"}`, ":80"},
+		{"chained", `{"config_version": 1, "addr": ":80"}`, ":80"},
+		{"partially", `{"config_version": 2, "listen": ":80"}`, ":80"},
+		{"current", `{"config_version": 3, "bind": ":80"}`, ":80"},
+	} {
+		c, err := ReadFrom([]byte(test.doc))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if bind, _ := c.String("bind"); bind != test.want || c.Version() != 3 {
+			t.Errorf("%s: bind = %q at version %d, want %q at 3", test.name, bind, c.Version(), test.want)
+		}
+	}
+	if _, err := ReadFrom([]byte(`{"config_version": 4}`)); err == nil {
+		t.Error("read a config newer than the migrations")
+	}
+	if _, err := ReadFrom([]byte(`{"config_version": 0}`)); err == nil {
+		t.Error("read a config older than the migrations")
+	}
+
+	// Unversioned drop-ins are migrated once merged, versioned ones on their
+	// own.
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "10-base.json"), []byte(`{"addr": ":80"}`), 0600)
+	os.WriteFile(filepath.Join(dir, "20-local.json"), []byte(`{"debug": true}`), 0600)
+	c, err := Glob(filepath.Join(dir, "*.json")).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bind, _ := c.String("bind"); bind != ":80" || c.Version() != 3 {
+		t.Errorf("drop-ins: bind = %q at version %d, want :80 at 3", bind, c.Version())
+	}
+	os.WriteFile(filepath.Join(dir, "20-local.json"), []byte(`{"config_version": 2, "listen": ":8080"}`), 0600)
+	if c, err = Glob(filepath.Join(dir, "*.json")).Read(); err != nil {
+		t.Fatal(err)
+	}
+	if bind, _ := c.String("bind"); bind != ":8080" {
+		t.Errorf("versioned drop-in: bind = %q, want :8080", bind)
+	}
+}
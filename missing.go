@@ -0,0 +1,78 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import "log"
+
+// WithMissingInt returns the config returning `def` from Int, and GroupInt,
+// when the key is missing or isn't an int, rather than 0, eg. a sentinel that
+// can't be mistaken for a configured value:
+//
+//	c = c.WithMissingInt(math.MinInt)
+//
+// The boolean they return tells a missing key apart either way.
+func (c Config) WithMissingInt(def int) Config {
+	c = c.with(c.m)
+	c.missingInt = def
+	return c
+}
+
+// WithMissingFloat64 returns the config returning `def` from Float64, and
+// GroupFloat64, when the key is missing or isn't a number, rather than 0, see
+// WithMissingInt.
+func (c Config) WithMissingFloat64(def float64) Config {
+	c = c.with(c.m)
+	c.missingFloat64 = def
+	return c
+}
+
+// SetMissingInt sets the int returned for missing keys of the current config,
+// see Config.WithMissingInt, logging the failure once frozen, see
+// TrySetMissingInt.
+func SetMissingInt(def int) {
+	if err := TrySetMissingInt(def); err != nil {
+		log.Printf("config: failed to set the missing int: %s", err)
+	}
+}
+
+// TrySetMissingInt sets the int returned for missing keys of the current
+// config, failing once frozen, see Freeze.
+func TrySetMissingInt(def int) error {
+	return setMissing(nil, func() { cfg.missingInt = def })
+}
+
+// SetMissingFloat64 sets the float64 returned for missing keys of the current
+// config, see Config.WithMissingFloat64, logging the failure once frozen, see
+// TrySetMissingFloat64.
+func SetMissingFloat64(def float64) {
+	if err := TrySetMissingFloat64(def); err != nil {
+		log.Printf("config: failed to set the missing float64: %s", err)
+	}
+}
+
+// TrySetMissingFloat64 sets the float64 returned for missing keys of the
+// current config, failing once frozen, see Freeze.
+func TrySetMissingFloat64(def float64) error {
+	return setMissing(nil, func() { cfg.missingFloat64 = def })
+}
+
+// SetMissingInt sets the int returned for missing keys of the current config,
+// see TrySetMissingInt.
+func (a *Admin) SetMissingInt(def int) error {
+	return setMissing(a, func() { cfg.missingInt = def })
+}
+
+// SetMissingFloat64 sets the float64 returned for missing keys of the current
+// config, see TrySetMissingFloat64.
+func (a *Admin) SetMissingFloat64(def float64) error {
+	return setMissing(a, func() { cfg.missingFloat64 = def })
+}
+
+func setMissing(a *Admin, set func()) error {
+	return update(a, "defaults", func() error {
+		set()
+		return nil
+	})
+}
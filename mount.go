@@ -0,0 +1,108 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	mountsMu sync.Mutex
+	mounts   = map[string]*MountSource{}
+)
+
+// MountSource reads a group of the configuration from its own source, see
+// Mount.
+type MountSource struct {
+	Group  string
+	Source Source
+}
+
+// Mount returns a source reading `s` as the group `group`, a dot separated
+// path of groups, eg. within a chain of the file, and the groups kept in
+// other backends:
+//
+//	config.NewChain(config.Mount("db", vault), config.File("config.json"))
+//
+// The group can then be re-read on its own by RefreshGroup, the last source
+// mounted at a group being the one used.
+func Mount(group string, s Source) *MountSource {
+	if group == "" || contains(strings.Split(group, "."), "") {
+		panic(fmt.Sprintf("config: invalid mount group %q", group))
+	}
+	ms := &MountSource{group, s}
+	mountsMu.Lock()
+	defer mountsMu.Unlock()
+	mounts[group] = ms
+	return ms
+}
+
+func (s *MountSource) Read() (Config, error) {
+	c, err := s.Source.Read()
+	if err != nil {
+		return c, err
+	}
+	m := make(map[string]interface{})
+	set(m, strings.Split(s.Group, "."), c.m)
+	return Config{m: m, coerce: c.coerce}, nil
+}
+
+func (s *MountSource) Name() string {
+	return sourceName(s.Source) + " at " + s.Group
+}
+
+// RefreshGroup re-reads the source mounted at `group` (see Mount), and
+// replaces the group within the current config with it, leaving the rest of
+// the config as is. The group should come from the source alone, as values
+// other sources had merged into it are dropped. On failure the current config
+// is kept.
+func RefreshGroup(group string) error {
+	return refreshGroup(nil, group)
+}
+
+// RefreshGroup re-reads the source mounted at `group`, see RefreshGroup. The
+// runtime overrides are kept, see Override.
+func (a *Admin) RefreshGroup(group string) error {
+	return refreshGroup(a, group)
+}
+
+func refreshGroup(a *Admin, group string) error {
+	mountsMu.Lock()
+	ms := mounts[group]
+	mountsMu.Unlock()
+	if ms == nil {
+		return fmt.Errorf("config: no source mounted at group %s", group)
+	}
+	c, err := ms.Source.Read()
+	if err != nil {
+		return fmt.Errorf("failed to refresh group %s: %w", group, err)
+	}
+	path := strings.Split(group, ".")
+	return update(a, "refresh", func() error {
+		replace := func(m map[string]interface{}) map[string]interface{} {
+			m, _ = copyVal(m).(map[string]interface{})
+			if m == nil {
+				m = make(map[string]interface{})
+			}
+			g, _ := copyVal(c.m).(map[string]interface{})
+			if g == nil {
+				g = make(map[string]interface{})
+			}
+			set(m, path, g)
+			return m
+		}
+		if a != nil {
+			a.base, a.pos = replace(a.base), dropPositions(a.pos, group)
+			cfg.m = a.overridden()
+		} else {
+			cfg.m = replace(cfg.m)
+		}
+		cfg.pos = dropPositions(cfg.pos, group)
+		cfg.coerce = cfg.coerce || c.coerce
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRefreshGroup(t *testing.T) {
+	dir := t.TempDir()
+	main, db := filepath.Join(dir, "main.json"), filepath.Join(dir, "db.json")
+	os.WriteFile(main, []byte(`{"port": 9090, "db": {"port": 5432}}`), 0600)
+	os.WriteFile(db, []byte(`{"host": "db", "pool": 5}`), 0600)
+	mount := Mount("store.db", File(db))
+	defer func() {
+		mountsMu.Lock()
+		defer mountsMu.Unlock()
+		delete(mounts, "store.db")
+	}()
+	if name := mount.Name(); name != "file "+db+" at store.db" {
+		t.Errorf("name = %q", name)
+	}
+
+	a := openTest(t, NewChain(mount, File(main)))
+	if host, _ := Current().Scoped("store.db").String("host"); host != "db" {
+		t.Fatalf("store.db.host = %q, want the mounted source's", host)
+	}
+	if err := a.Override([]byte(`{"port": 80}`)); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(db, []byte(`{"host": "replica"}`), 0600)
+	os.WriteFile(main, []byte(`{"port": 8080}`), 0600)
+	if err := a.RefreshGroup("store.db"); err != nil {
+		t.Fatal(err)
+	}
+	c := Current()
+	if host, _ := c.Scoped("store.db").String("host"); host != "replica" {
+		t.Errorf("store.db.host = %q, want the refreshed replica", host)
+	}
+	if _, ok := c.Scoped("store.db").Val("pool"); ok {
+		t.Error("store.db.pool kept, want the group replaced")
+	}
+	// The rest of the config is as it was, overrides included, rather than
+	// re-read.
+	if port, _ := c.Int("port"); port != 80 {
+		t.Errorf("port = %d, want the override kept", port)
+	}
+	if port, _ := c.Group("db").Int("port"); port != 5432 {
+		t.Errorf("db.port = %d, want the unmounted group kept", port)
+	}
+
+	// Failures keep the current config.
+	os.WriteFile(db, []byte(`{`), 0600)
+	if err := a.RefreshGroup("store.db"); err == nil {
+		t.Error("refreshed from an invalid document")
+	}
+	if host, _ := Current().Scoped("store.db").String("host"); host != "replica" {
+		t.Errorf("store.db.host = %q after a failed refresh", host)
+	}
+	if err := a.RefreshGroup("missing"); err == nil {
+		t.Error("refreshed a group without a mount")
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrMsgpack is returned when a MessagePack document is malformed.
+var ErrMsgpack = errors.New("malformed MessagePack")
+
+func init() {
+	RegisterFormat(".msgpack", DecoderFunc(decodeMsgpack))
+}
+
+// ReadMsgpack returns a new Config from the MessagePack document `b`, whose
+// root must be a map. See ReadFrom for `opts`.
+//
+// Numbers are read as float64 (matching JSON numbers), binaries as []byte,
+// timestamps as time.Time, and other extensions as []byte.
+func ReadMsgpack(b []byte, opts ...Option) (Config, error) {
+	return newOptions(opts).readFormat(DecoderFunc(decodeMsgpack), b, "")
+}
+
+func decodeMsgpack(b []byte) (map[string]interface{}, error) {
+	d := &msgpackDecoder{b: b}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.off != len(b) {
+		return nil, fmt.Errorf("%s: data after the root value", ErrMsgpack)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: root is not a map", ErrMsgpack)
+	}
+	return m, nil
+}
+
+type msgpackDecoder struct {
+	b   []byte
+	off int
+}
+
+func (d *msgpackDecoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)-d.off) {
+		return nil, fmt.Errorf("%s: unexpected end", ErrMsgpack)
+	}
+	p := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return p, nil
+}
+
+// uint reads a big endian unsigned integer of `n` bytes.
+func (d *msgpackDecoder) uint(n uint64) (uint64, error) {
+	p, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	return beUint(p), nil
+}
+
+func (d *msgpackDecoder) value(depth int) (interface{}, error) {
+	if depth > maxBinaryDepth {
+		return nil, fmt.Errorf("%s: nested too deeply", ErrMsgpack)
+	}
+	p, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := p[0]
+	switch {
+	case c <= 0x7f:
+		return float64(c), nil
+	case c <= 0x8f:
+		return d.mapOf(uint64(c&0x0f), depth)
+	case c <= 0x9f:
+		return d.arrayOf(uint64(c&0x0f), depth)
+	case c <= 0xbf:
+		return d.str(uint64(c & 0x1f))
+	case c >= 0xe0:
+		return float64(int8(c)), nil
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(n)
+		return append([]byte{}, b...), err
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(n)
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (c - 0xcc))
+		return float64(n), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := uint64(1) << (c - 0xd0)
+		n, err := d.uint(size)
+		// Sign extend the integer.
+		shift := 64 - 8*size
+		return float64(int64(n<<shift) >> shift), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.arrayOf(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapOf(n, depth)
+	}
+	return nil, fmt.Errorf("%s: invalid type 0x%02x", ErrMsgpack, c)
+}
+
+func (d *msgpackDecoder) str(n uint64) (interface{}, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(b) {
+		return nil, fmt.Errorf("%s: invalid UTF-8 string", ErrMsgpack)
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) arrayOf(n uint64, depth int) (interface{}, error) {
+	// Every element takes a byte at least, bounding the allocation.
+	if n > uint64(len(d.b)-d.off) {
+		return nil, fmt.Errorf("%s: unexpected end", ErrMsgpack)
+	}
+	l := make([]interface{}, n)
+	for i := range l {
+		v, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		l[i] = v
+	}
+	return l, nil
+}
+
+func (d *msgpackDecoder) mapOf(n uint64, depth int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for i := uint64(0); i < n; i++ {
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: map key %v is not a string", ErrMsgpack, k)
+		}
+		if m[key], err = d.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ext reads an extension of `n` bytes, after its type.
+func (d *msgpackDecoder) ext(n uint64) (interface{}, error) {
+	t, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(t[0]) != -1 {
+		return append([]byte{}, b...), nil
+	}
+	// The timestamp extension.
+	switch n {
+	case 4:
+		return time.Unix(int64(beUint(b)), 0).UTC(), nil
+	case 8:
+		v := beUint(b)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), nil
+	case 12:
+		return time.Unix(int64(beUint(b[4:])), int64(beUint(b[:4]))).UTC(), nil
+	}
+	return nil, fmt.Errorf("%s: invalid timestamp", ErrMsgpack)
+}
@@ -0,0 +1,210 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package nats publishes config change events to a NATS subject, so a fleet
+// of services can invalidate their caches when the central config changes.
+//
+// A Publisher is a config.AuditSink, publishing the config.Notification of
+// every change as JSON, eg.
+//
+//	stop := config.AddAuditSink(nats.NewPublisher("nats://localhost:4222", "config.changes"))
+//
+// The address may be a plain `host:port`, or a URL of the form
+// `nats://[user:password@|token@]host:port`. TLS isn't supported.
+package nats
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"code.minty.io/config"
+)
+
+var (
+	// DialTimeout is the timeout used when connecting to NATS.
+	DialTimeout = 5 * time.Second
+	// DefaultTimeout bounds publishing each change event, see
+	// Publisher.Timeout.
+	DefaultTimeout = 30 * time.Second
+	// QueueSize is the number of change events a Publisher queues.
+	QueueSize = 64
+)
+
+// Publisher publishes change events to a subject.
+type Publisher struct {
+	// Retry is the policy for each publish, defaults to
+	// config.DefaultRetryPolicy.
+	Retry config.RetryPolicy
+	// Timeout bounds publishing each change event, retries included,
+	// DefaultTimeout when zero.
+	Timeout time.Duration
+
+	addr, subject string
+	once          sync.Once
+	events        chan event
+}
+
+// event is a change event queued to be published.
+type event struct {
+	gen     uint64
+	payload []byte
+}
+
+// NewPublisher returns a publisher to `subject`, which can be tuned before
+// being added as a sink.
+func NewPublisher(addr, subject string) *Publisher {
+	return &Publisher{Retry: config.DefaultRetryPolicy, addr: addr, subject: subject}
+}
+
+// Audit queues the notification of `e` to be published, unless nothing
+// changed. Events are published in order, in the background, each
+// acknowledged by the server, and failures are logged; events are dropped
+// while QueueSize are queued.
+func (p *Publisher) Audit(e config.AuditEvent) error {
+	if len(e.Changes) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(config.NewNotification(e))
+	if err != nil {
+		return err
+	}
+	p.once.Do(func() {
+		p.events = make(chan event, QueueSize)
+		go p.publish()
+	})
+	select {
+	case p.events <- event{e.Generation, b}:
+		return nil
+	default:
+		return fmt.Errorf("nats: %d events queued, dropped generation %d", QueueSize, e.Generation)
+	}
+}
+
+func (p *Publisher) publish() {
+	for e := range p.events {
+		timeout := p.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := p.Publish(ctx, e.payload); err != nil {
+			log.Printf("nats: failed to publish generation %d: %s", e.gen, err)
+		}
+		cancel()
+	}
+}
+
+// Publish publishes `payload` to the subject, retried per the policy.
+func (p *Publisher) Publish(ctx context.Context, payload []byte) error {
+	return p.Retry.Do(ctx, func(ctx context.Context) error {
+		c, err := dial(ctx, p.addr)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			c.SetDeadline(deadline)
+		}
+		fmt.Fprintf(c.w, "PUB %s %d\r\n", p.subject, len(payload))
+		c.w.Write(payload)
+		c.w.WriteString("\r\n")
+		// The PONG to a PING confirms the server processed the PUB.
+		return c.ping()
+	})
+}
+
+// conn is a minimal NATS client, just enough to PUB.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func dial(ctx context.Context, addr string) (*conn, error) {
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "config"}
+	if strings.HasPrefix(addr, "nats://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		addr = u.Host
+		if u.User != nil {
+			if password, ok := u.User.Password(); ok {
+				opts["user"], opts["pass"] = u.User.Username(), password
+			} else {
+				opts["auth_token"] = u.User.Username()
+			}
+		}
+	}
+	d := net.Dialer{Timeout: DialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{nc, bufio.NewReader(nc), bufio.NewWriter(nc)}
+	// Don't let the handshake hang past the dial deadline.
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		c.Close()
+		return nil, fmt.Errorf("nats: unexpected greeting %q", strings.TrimSpace(line))
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(line[len("INFO "):]), &info)
+	if info.TLSRequired {
+		c.Close()
+		return nil, errors.New("nats: server requires TLS, which isn't supported")
+	}
+	b, _ := json.Marshal(opts)
+	fmt.Fprintf(c.w, "CONNECT %s\r\n", b)
+	if err = c.ping(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// ping flushes the pending commands, and waits for the server's PONG,
+// answering its PINGs and failing on its errors.
+func (c *conn) ping() error {
+	c.w.WriteString("PING\r\n")
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch line = strings.TrimSpace(line); {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			c.w.WriteString("PONG\r\n")
+			if err := c.w.Flush(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.Trim(strings.TrimSpace(line[len("-ERR"):]), "'"))
+		}
+	}
+}
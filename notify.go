@@ -0,0 +1,140 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// WebhookSignatureHeader is the request header holding the HMAC-SHA256 of a
+// webhook's body, as `sha256=<hex>`, see Notifier.
+const WebhookSignatureHeader = "X-Config-Webhook-Signature"
+
+// Notification is the JSON payload of a webhook, see Notifier, or of a
+// published change event.
+type Notification struct {
+	Generation uint64    `json:"generation"`
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	Actor      string    `json:"actor"`
+	Source     string    `json:"source,omitempty"`
+	// Summary counts the changes, eg. `1 added, 2 changed, 0 removed`.
+	Summary string   `json:"summary"`
+	Changes []Change `json:"changes"`
+}
+
+// NewNotification returns the notification of the change audited by `e`.
+func NewNotification(e AuditEvent) Notification {
+	return Notification{
+		Generation: e.Generation,
+		Time:       e.Time,
+		Action:     e.Action,
+		Actor:      e.Actor,
+		Source:     e.Source,
+		Summary:    summarize(e.Changes),
+		Changes:    e.Changes,
+	}
+}
+
+// Notifier POSTs a Notification to every URL whenever the current config
+// changes, so other systems can react. It's an AuditSink, eg.
+//
+//	stop := config.AddAuditSink(&config.Notifier{
+//		URLs:   []string{"https://chat.example.com/hooks/config"},
+//		Secret: secret,
+//	})
+//
+// Changes leaving the config's values as they were aren't notified. Webhooks
+// are sent in the background, retried per Retry, and failures are logged.
+type Notifier struct {
+	URLs []string
+	// Secret signs each body, see WebhookSignatureHeader, so receivers can
+	// verify it. Bodies aren't signed when empty.
+	Secret []byte
+	// Client is the client used for requests, DefaultHTTPClient when nil.
+	Client *http.Client
+	// Retry is the policy for each request, DefaultRetryPolicy when nil.
+	Retry *RetryPolicy
+}
+
+func (n *Notifier) Audit(e AuditEvent) error {
+	if len(e.Changes) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(NewNotification(e))
+	if err != nil {
+		return err
+	}
+	for _, url := range n.URLs {
+		go func(url string) {
+			if err := n.post(url, b); err != nil {
+				log.Printf("config: failed to notify %s of generation %d: %s", url, e.Generation, err)
+			}
+		}(url)
+	}
+	return nil
+}
+
+func (n *Notifier) post(url string, body []byte) error {
+	client := n.Client
+	if client == nil {
+		client = DefaultHTTPClient
+	}
+	retry := DefaultRetryPolicy
+	if n.Retry != nil {
+		retry = *n.Retry
+	}
+	return retry.Do(context.Background(), func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if len(n.Secret) > 0 {
+			req.Header.Set(WebhookSignatureHeader, SignWebhook(n.Secret, body))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook responded %s", resp.Status)
+		}
+		return nil
+	})
+}
+
+// SignWebhook returns the signature of `body` with `secret`, as sent within
+// the WebhookSignatureHeader. Receivers compare it with hmac.Equal.
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func summarize(changes []Change) string {
+	var added, changed, removed int
+	for _, ch := range changes {
+		switch {
+		case ch.Old == nil:
+			added++
+		case ch.New == nil:
+			removed++
+		default:
+			changed++
+		}
+	}
+	return fmt.Sprintf("%d added, %d changed, %d removed", added, changed, removed)
+}
@@ -0,0 +1,72 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNotifier(t *testing.T) {
+	secret := []byte("s3cret")
+	var attempts int32
+	got := make(chan Notification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails, to be retried.
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		if sig := r.Header.Get(WebhookSignatureHeader); !hmac.Equal([]byte(sig), []byte(SignWebhook(secret, b))) {
+			t.Errorf("signature %q doesn't match the body %s", sig, b)
+		}
+		var n Notification
+		if err := json.Unmarshal(b, &n); err != nil {
+			t.Error(err)
+		}
+		got <- n
+	}))
+	defer srv.Close()
+
+	n := &Notifier{
+		URLs:   []string{srv.URL},
+		Secret: secret,
+		Retry:  &RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond},
+	}
+	// Changes leaving the values as they were aren't notified.
+	if err := n.Audit(AuditEvent{Action: "reload", Generation: 1}); err != nil {
+		t.Fatal(err)
+	}
+	err := n.Audit(AuditEvent{
+		Action:     "set",
+		Actor:      "alice",
+		Generation: 2,
+		Changes: []Change{
+			{Path: "port", Old: 9090.0, New: 80.0},
+			{Path: "host", New: "example.com"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-got:
+		if n.Generation != 2 || n.Actor != "alice" || n.Summary != "1 added, 1 changed, 0 removed" || len(n.Changes) != 2 {
+			t.Errorf("notified %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("not notified")
+	}
+	if a := atomic.LoadInt32(&attempts); a != 2 {
+		t.Errorf("%d attempts, want 2", a)
+	}
+}
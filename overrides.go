@@ -0,0 +1,82 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// overridesKey is the group of host-specific overrides, keyed by hostname
+// glob patterns, eg.
+//
+//	"overrides": {
+//		"web-*": {"cache": {"size": 512}},
+//		"web-canary-01": {"features": {"beta": true}}
+//	}
+//
+// When loaded, the groups whose pattern matches os.Hostname (ignoring case)
+// are deep-merged over the rest of the config, and the `overrides` group is
+// removed. Patterns are applied in sorted order, with an exact hostname
+// applied last, so it has the final say. The key is reserved: a top-level
+// `overrides` that isn't a group of groups fails the read, rather than being
+// dropped.
+const overridesKey = "overrides"
+
+// overrides applies the host-specific overrides within `m`, see overridesKey.
+func overrides(m map[string]interface{}) (map[string]interface{}, error) {
+	v, ok := m[overridesKey]
+	if !ok {
+		return m, nil
+	}
+	g, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid %s, reserved for host-specific overrides, must be a group of groups by hostname pattern", overridesKey)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply overrides: %w", err)
+	}
+	return applyOverrides(m, g, strings.ToLower(hostname))
+}
+
+func applyOverrides(m, g map[string]interface{}, hostname string) (map[string]interface{}, error) {
+	patterns := make([]string, 0, len(g))
+	for p := range g {
+		patterns = append(patterns, p)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		// Exact matches sort last.
+		ei, ej := strings.EqualFold(patterns[i], hostname), strings.EqualFold(patterns[j], hostname)
+		if ei != ej {
+			return ej
+		}
+		return patterns[i] < patterns[j]
+	})
+	base := make(map[string]interface{}, len(m))
+	for key, v := range m {
+		if key != overridesKey {
+			base[key] = v
+		}
+	}
+	for _, p := range patterns {
+		match, err := path.Match(strings.ToLower(p), hostname)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %s", overridesKey, p, err)
+		}
+		// Every pattern is checked, not only those of this host.
+		o, ok := g[p].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid %s for %q, must be a group", overridesKey, p)
+		}
+		if match {
+			base = merge(base, o)
+		}
+	}
+	return base, nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import "testing"
+
+func TestOverrides(t *testing.T) {
+	m := map[string]interface{}{
+		"cache": map[string]interface{}{"size": 64.0},
+		"overrides": map[string]interface{}{
+			"web-*":         map[string]interface{}{"cache": map[string]interface{}{"size": 512.0}},
+			"web-canary-01": map[string]interface{}{"cache": map[string]interface{}{"size": 1024.0}},
+		},
+	}
+	for host, want := range map[string]float64{"web-01": 512, "web-canary-01": 1024, "db-01": 64} {
+		o, err := applyOverrides(m, m["overrides"].(map[string]interface{}), host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := o["overrides"]; ok {
+			t.Errorf("%s: overrides kept", host)
+		}
+		if size := o["cache"].(map[string]interface{})["size"]; size != want {
+			t.Errorf("%s: cache.size = %v, want %v", host, size, want)
+		}
+	}
+
+	// The key is reserved, so other uses of it fail, rather than vanish.
+	for _, doc := range []string{
+		`{"overrides": ["feature-x"]}`,
+		`{"overrides": {"beta": true}}`,
+	} {
+		if _, err := ReadFrom([]byte(doc)); err == nil {
+			t.Errorf("read %s", doc)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package passwords registers resolvers (see config.RegisterResolver) reading
+// values from the 1Password and Bitwarden CLIs, for developer workstation
+// configs, eg.
+//
+//	import _ "code.minty.io/config/passwords"
+//
+//	{"db": {"password": "op://dev/postgres/password"},
+//	 "github": {"token": "bw:github/token"}}
+//
+// References are:
+//
+//	op://<vault>/<item>/<field>   read via `op read`, as 1Password's own
+//	                              secret references
+//	bw:<item>[/<field>]           read via `bw get item`, by the item's name
+//	                              or id; the field is `password` (default),
+//	                              `username`, `notes`, `totp`, or the name of
+//	                              a custom field
+//
+// The CLIs must be signed in beforehand, eg. `eval $(op signin)`, or
+// `export BW_SESSION=$(bw unlock --raw)`. Importing the package is the opt-in,
+// neither CLI is run otherwise.
+package passwords
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"code.minty.io/config"
+)
+
+// OnePasswordCLI and BitwardenCLI are the commands run.
+var (
+	OnePasswordCLI = "op"
+	BitwardenCLI   = "bw"
+)
+
+func init() {
+	config.RegisterResolver("op", config.ResolverFunc(OnePassword))
+	config.RegisterResolver("bw", config.ResolverFunc(Bitwarden))
+}
+
+// OnePassword resolves the `op` reference `ref`, `//<vault>/<item>/<field>`.
+func OnePassword(ctx context.Context, ref string) (config.Lease, error) {
+	if !strings.HasPrefix(ref, "//") || strings.Count(ref, "/") < 4 {
+		return config.Lease{}, fmt.Errorf("passwords: invalid reference %q, expected op://vault/item/field", "op:"+ref)
+	}
+	b, err := run(ctx, OnePasswordCLI, "read", "--no-newline", "op:"+ref)
+	if err != nil {
+		return config.Lease{}, err
+	}
+	return config.Lease{Value: string(b)}, nil
+}
+
+// bitwardenItem is the subset of the items output by `bw get item`.
+type bitwardenItem struct {
+	Notes string
+	Login struct {
+		Username string
+		Password string
+		Totp     string
+	}
+	Fields []struct {
+		Name  string
+		Value string
+	}
+}
+
+// Bitwarden resolves the `bw` reference `ref`, `<item>[/<field>]`.
+func Bitwarden(ctx context.Context, ref string) (config.Lease, error) {
+	item, field := ref, "password"
+	if i := strings.LastIndexByte(ref, '/'); i >= 0 {
+		item, field = ref[:i], ref[i+1:]
+	}
+	if item == "" || field == "" {
+		return config.Lease{}, fmt.Errorf("passwords: invalid reference %q, expected bw:item/field", "bw:"+ref)
+	}
+	b, err := run(ctx, BitwardenCLI, "get", "item", item)
+	if err != nil {
+		return config.Lease{}, err
+	}
+	var it bitwardenItem
+	if err = json.Unmarshal(b, &it); err != nil {
+		return config.Lease{}, fmt.Errorf("passwords: %s: %w", BitwardenCLI, err)
+	}
+	for _, f := range it.Fields {
+		if f.Name == field {
+			return config.Lease{Value: f.Value}, nil
+		}
+	}
+	switch field {
+	case "password":
+		return config.Lease{Value: it.Login.Password}, nil
+	case "username":
+		return config.Lease{Value: it.Login.Username}, nil
+	case "notes":
+		return config.Lease{Value: it.Notes}, nil
+	case "totp":
+		return config.Lease{Value: it.Login.Totp}, nil
+	}
+	return config.Lease{}, fmt.Errorf("passwords: %s has no field %q", item, field)
+}
+
+// run returns the output of the command, or an error with its stderr.
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	b, err := cmd.Output()
+	if err != nil {
+		var exit *exec.ExitError
+		if msg := strings.TrimSpace(stderr.String()); errors.As(err, &exit) && msg != "" {
+			return nil, fmt.Errorf("passwords: %s: %s", name, msg)
+		}
+		return nil, fmt.Errorf("passwords: %s: %w", name, err)
+	}
+	return b, nil
+}
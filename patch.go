@@ -0,0 +1,293 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PatchFormat is the format of a patch document.
+type PatchFormat int
+
+const (
+	// JSONPatch is a list of operations, see RFC 6902.
+	JSONPatch PatchFormat = iota
+	// MergePatch is a document merged over the config, where nulls remove
+	// keys, see RFC 7386.
+	MergePatch
+)
+
+// ErrPatchTest is returned when a JSONPatch `test` operation fails.
+var ErrPatchTest = errors.New("config patch test failed")
+
+// Patch returns a copy of the config with `patch` applied. A JSONPatch is
+// applied whole, or not at all.
+func (c Config) Patch(patch []byte, format PatchFormat) (Config, error) {
+	m, _ := copyVal(c.m).(map[string]interface{})
+	if m == nil {
+		m = make(map[string]interface{})
+	}
+	switch format {
+	case JSONPatch:
+		var ops []patchOp
+		if err := json.Unmarshal(patch, &ops); err != nil {
+			return *new(Config), fmt.Errorf("invalid JSON patch: %w", err)
+		}
+		var doc interface{} = m
+		for i, op := range ops {
+			var err error
+			if doc, err = op.apply(doc); err != nil {
+				return *new(Config), fmt.Errorf("failed to apply patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+			}
+		}
+		if m, _ = doc.(map[string]interface{}); m == nil {
+			return *new(Config), errors.New("failed to apply patch: config root must remain an object")
+		}
+	case MergePatch:
+		var p interface{}
+		if err := json.Unmarshal(patch, &p); err != nil {
+			return *new(Config), fmt.Errorf("invalid merge patch: %w", err)
+		}
+		pm, ok := p.(map[string]interface{})
+		if !ok {
+			return *new(Config), errors.New("invalid merge patch: must be an object")
+		}
+		mergePatch(m, pm)
+	default:
+		return *new(Config), fmt.Errorf("unknown patch format %d", format)
+	}
+	return c.with(m), nil
+}
+
+// ApplyPatch applies `patch` to the current config, see Config.Patch. The
+// result isn't persisted, unless followed by Save.
+func ApplyPatch(patch []byte, format PatchFormat) error {
+	return update(nil, "patch", func() error {
+		c, err := cfg.Patch(patch, format)
+		if err == nil {
+			cfg.m, cfg.pos = c.m, nil
+		}
+		return err
+	})
+}
+
+func mergePatch(m, p map[string]interface{}) {
+	for key, v := range p {
+		switch pv := v.(type) {
+		case nil:
+			delete(m, key)
+		case map[string]interface{}:
+			g, ok := m[key].(map[string]interface{})
+			if !ok {
+				g = make(map[string]interface{})
+				m[key] = g
+			}
+			mergePatch(g, pv)
+		default:
+			m[key] = v
+		}
+	}
+}
+
+type patchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from"`
+	Value interface{} `json:"value"`
+}
+
+func (op patchOp) apply(doc interface{}) (interface{}, error) {
+	switch op.Op {
+	case "add":
+		return pointerSet(doc, op.Path, copyVal(op.Value), true)
+	case "remove":
+		doc, _, err := pointerRemove(doc, op.Path)
+		return doc, err
+	case "replace":
+		if _, err := pointerGet(doc, op.Path); err != nil {
+			return nil, err
+		}
+		return pointerSet(doc, op.Path, copyVal(op.Value), false)
+	case "move":
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, errors.New("cannot move a value into itself")
+		}
+		doc, v, err := pointerRemove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerSet(doc, op.Path, v, true)
+	case "copy":
+		v, err := pointerGet(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerSet(doc, op.Path, copyVal(v), true)
+	case "test":
+		v, err := pointerGet(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(normalize(v), normalize(op.Value)) {
+			return nil, ErrPatchTest
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// normalize converts the numbers within `v` to float64s, whatever their type,
+// as MarshalCanonical writes them, and lists of strings to lists, for
+// comparisons.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[key] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = normalize(val)
+		}
+		return l
+	case []string:
+		l := make([]interface{}, len(v))
+		for i, s := range v {
+			l[i] = s
+		}
+		return l
+	}
+	if f, err := CoerceFloat64(v); err == nil {
+		return f
+	}
+	return v
+}
+
+// pointer splits the JSON pointer `p` into its unescaped tokens, see RFC 6901.
+func pointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+func index(l []interface{}, token string, end bool) (int, error) {
+	if end && token == "-" {
+		return len(l), nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > len(l) || (i == len(l) && !end) || (token != "0" && token[0] == '0') {
+		return 0, fmt.Errorf("invalid index %q", token)
+	}
+	return i, nil
+}
+
+func pointerGet(doc interface{}, p string) (interface{}, error) {
+	tokens, err := pointer(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[t]
+			if !ok {
+				return nil, fmt.Errorf("'%s': %w", p, ErrNotFound)
+			}
+			doc = v
+		case []interface{}:
+			i, err := index(d, t, false)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, fmt.Errorf("'%s': %w", p, ErrNotFound)
+		}
+	}
+	return doc, nil
+}
+
+// pointerSet sets the value at `p`, inserting into lists when `insert` is
+// set, and returns the updated document.
+func pointerSet(doc interface{}, p string, v interface{}, insert bool) (interface{}, error) {
+	tokens, err := pointer(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return v, nil
+	}
+	parent, err := pointerGet(doc, p[:strings.LastIndexByte(p, '/')])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch d := parent.(type) {
+	case map[string]interface{}:
+		d[last] = v
+	case []interface{}:
+		i, err := index(d, last, insert)
+		if err != nil {
+			return nil, err
+		}
+		if insert {
+			d = append(d[:i], append([]interface{}{v}, d[i:]...)...)
+		} else {
+			d[i] = v
+		}
+		return pointerSet(doc, p[:strings.LastIndexByte(p, '/')], d, false)
+	default:
+		return nil, fmt.Errorf("'%s': %w", p, ErrNotFound)
+	}
+	return doc, nil
+}
+
+// pointerRemove removes the value at `p`, returning the updated document and
+// the removed value.
+func pointerRemove(doc interface{}, p string) (interface{}, interface{}, error) {
+	tokens, err := pointer(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil, errors.New("cannot remove the config root")
+	}
+	v, err := pointerGet(doc, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	parentPath := p[:strings.LastIndexByte(p, '/')]
+	parent, _ := pointerGet(doc, parentPath)
+	last := tokens[len(tokens)-1]
+	switch d := parent.(type) {
+	case map[string]interface{}:
+		delete(d, last)
+	case []interface{}:
+		i, _ := index(d, last, false)
+		l := append(append([]interface{}{}, d[:i]...), d[i+1:]...)
+		if doc, err = pointerSet(doc, parentPath, l, false); err != nil {
+			return nil, nil, err
+		}
+	}
+	return doc, v, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testPatch(t *testing.T, format PatchFormat, doc, patch, want string, wantErr error) {
+	t.Helper()
+	c, err := ReadFrom([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := c.Patch([]byte(patch), format)
+	if want == "" {
+		if err == nil || (wantErr != nil && !errors.Is(err, wantErr)) {
+			t.Errorf("%s over %s: error = %v, want %v", patch, doc, err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("%s over %s: %s", patch, doc, err)
+		return
+	}
+	var m map[string]interface{}
+	if err = json.Unmarshal([]byte(want), &m); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Map(); !reflect.DeepEqual(normalize(got), normalize(m)) {
+		t.Errorf("%s over %s = %v, want %s", patch, doc, got, want)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	for _, tt := range []struct {
+		name, doc, patch, want string
+		err                    error
+	}{
+		// The examples of RFC 6902, appendix A, but A.13, as its duplicate
+		// members aren't seen by encoding/json.
+		{"A.1", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"baz": "qux", "foo": "bar"}`, nil},
+		{"A.2", `{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar", "qux", "baz"]}`, nil},
+		{"A.3", `{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`, nil},
+		{"A.4", `{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar", "baz"]}`, nil},
+		{"A.5", `{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz": "boo", "foo": "bar"}`, nil},
+		{"A.6", `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`, `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`, `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`, nil},
+		{"A.7", `{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all", "cows", "eat", "grass"]}`, nil},
+		{"A.8", `{"baz": "qux", "foo": ["a", 2, "c"]}`, `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`, `{"baz": "qux", "foo": ["a", 2, "c"]}`, nil},
+		{"A.9", `{"baz": "qux"}`, `[{"op": "test", "path": "/baz", "value": "bar"}]`, "", ErrPatchTest},
+		{"A.10", `{"foo": "bar"}`, `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`, `{"foo": "bar", "child": {"grandchild": {}}}`, nil},
+		{"A.11", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`, `{"foo": "bar", "baz": "qux"}`, nil},
+		{"A.12", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`, "", ErrNotFound},
+		{"A.14", `{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": 10}]`, `{"/": 9, "~1": 10}`, nil},
+		{"A.15", `{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": "10"}]`, "", ErrPatchTest},
+		{"A.16", `{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, `{"foo": ["bar", ["abc", "def"]]}`, nil},
+
+		{"escapes", `{"a/b": 1, "m~n": 2}`, `[{"op": "replace", "path": "/a~1b", "value": 3}, {"op": "remove", "path": "/m~0n"}, {"op": "add", "path": "/~0~1", "value": 4}]`, `{"a/b": 3, "~/": 4}`, nil},
+		{"copy", `{"a": {"b": [1]}}`, `[{"op": "copy", "from": "/a", "path": "/c"}, {"op": "add", "path": "/c/b/-", "value": 2}]`, `{"a": {"b": [1]}, "c": {"b": [1, 2]}}`, nil},
+		{"copy missing", `{"a": 1}`, `[{"op": "copy", "from": "/b", "path": "/c"}]`, "", ErrNotFound},
+		{"move missing", `{"a": 1}`, `[{"op": "move", "from": "/b", "path": "/c"}]`, "", ErrNotFound},
+		{"move into itself", `{"a": {"b": 1}}`, `[{"op": "move", "from": "/a", "path": "/a/c"}]`, "", nil},
+		{"test group", `{"a": {"b": [1, "x"]}}`, `[{"op": "test", "path": "/a", "value": {"b": [1.0, "x"]}}]`, `{"a": {"b": [1, "x"]}}`, nil},
+		{"test missing", `{"a": 1}`, `[{"op": "test", "path": "/b", "value": 1}]`, "", ErrNotFound},
+		{"replace missing", `{"a": 1}`, `[{"op": "replace", "path": "/b", "value": 1}]`, "", ErrNotFound},
+		{"end of list", `{"a": [1]}`, `[{"op": "replace", "path": "/a/-", "value": 2}]`, "", nil},
+		{"past the list", `{"a": [1]}`, `[{"op": "add", "path": "/a/2", "value": 2}]`, "", nil},
+		{"leading zero", `{"a": [1, 2]}`, `[{"op": "remove", "path": "/a/01"}]`, "", nil},
+		{"root", `{"a": 1}`, `[{"op": "replace", "path": "", "value": {"b": 2}}]`, `{"b": 2}`, nil},
+		{"root list", `{"a": 1}`, `[{"op": "replace", "path": "", "value": [1]}]`, "", nil},
+		{"unknown", `{"a": 1}`, `[{"op": "append", "path": "/a", "value": 2}]`, "", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testPatch(t, JSONPatch, tt.doc, tt.patch, tt.want, tt.err)
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	for _, tt := range []struct {
+		doc, patch, want string
+	}{
+		// The examples of RFC 7396, appendix A, of objects; configs and
+		// merge patches are always objects.
+		{`{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+		{`{"a": "b"}`, `{"a": null}`, `{}`},
+		{`{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+		{`{"a": ["b"]}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "c"}`, `{"a": ["b"]}`, `{"a": ["b"]}`},
+		{`{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`, `{"a": {"b": "d"}}`},
+		{`{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a": [1]}`},
+		{`{"e": null}`, `{"a": 1}`, `{"e": null, "a": 1}`},
+		{`{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a": {"bb": {}}}`},
+		{`{"a": "foo"}`, `"bar"`, ""},
+		{`{"a": "foo"}`, `null`, ""},
+		{`{"a": "foo"}`, `["c"]`, ""},
+		{`{"a": "foo"}`, `{`, ""},
+	} {
+		testPatch(t, MergePatch, tt.doc, tt.patch, tt.want, nil)
+	}
+}
+
+func TestPatchAtomic(t *testing.T) {
+	// A failed operation leaves the config, and its earlier operations,
+	// unapplied.
+	const doc = `{"a": {"b": 1}, "l": [1, 2]}`
+	c, err := ReadFrom([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := c.Map()
+	patch := `[
+		{"op": "add", "path": "/a/c", "value": 2},
+		{"op": "remove", "path": "/l/0"},
+		{"op": "move", "from": "/a/b", "path": "/d"},
+		{"op": "test", "path": "/d", "value": 2}
+	]`
+	if _, err = c.Patch([]byte(patch), JSONPatch); !errors.Is(err, ErrPatchTest) {
+		t.Fatalf("error = %v, want %v", err, ErrPatchTest)
+	}
+	if !reflect.DeepEqual(c.Map(), before) {
+		t.Errorf("config = %v after a failed patch, want %v", c.Map(), before)
+	}
+
+	f := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(f, []byte(doc), 0600)
+	a := openTest(t, File(f))
+	gen := Generation()
+	if err = a.Patch([]byte(patch), JSONPatch); !errors.Is(err, ErrPatchTest) {
+		t.Fatalf("Admin: error = %v, want %v", err, ErrPatchTest)
+	}
+	if got := Current().Map(); !reflect.DeepEqual(got, before) || Generation() != gen {
+		t.Errorf("current config = %v, generation %d, after a failed patch, want %v, generation %d", got, Generation(), before, gen)
+	}
+}
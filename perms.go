@@ -0,0 +1,71 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// ErrPermissions is returned when a config file holding sensitive groups can
+// be read, or changed, by others, see WithPermissionCheck.
+var ErrPermissions = errors.New("unsafe config file permissions")
+
+// PermissionCheck is how the permissions of config files holding sensitive
+// groups are checked, see WithPermissionCheck.
+type PermissionCheck int
+
+const (
+	// PermissionsIgnore doesn't check permissions.
+	PermissionsIgnore PermissionCheck = iota
+	// PermissionsWarn logs unsafe permissions.
+	PermissionsWarn
+	// PermissionsRefuse fails to read files with unsafe permissions.
+	PermissionsRefuse
+)
+
+// DefaultPermissionCheck is the check of files read without a
+// WithPermissionCheck option, eg. by File.
+var DefaultPermissionCheck = PermissionsIgnore
+
+// WithPermissionCheck checks the permissions of the config files holding
+// sensitive groups (see IsSensitive), as ssh's strict modes do: files are
+// unsafe when they're readable by others, writable by their group, or others,
+// or owned by another user than the process', or root. Files of platforms
+// without Unix permissions aren't checked.
+func WithPermissionCheck(check PermissionCheck) Option {
+	return func(o *options) { o.perms = check }
+}
+
+// checkPermissions checks the permissions of the file `f`, read as `c`.
+func (o *options) checkPermissions(f string, fi os.FileInfo, c Config) error {
+	if o.perms == PermissionsIgnore || fi == nil || !hasSensitive(c.m) {
+		return nil
+	}
+	problem := unsafePermissions(fi)
+	if problem == "" {
+		return nil
+	}
+	if o.perms == PermissionsWarn {
+		log.Printf("config: %s holds sensitive values, but %s", f, problem)
+		return nil
+	}
+	return fmt.Errorf("%w: %s holds sensitive values, but %s", ErrPermissions, f, problem)
+}
+
+// hasSensitive returns whether `m` holds a sensitive group, at any level.
+func hasSensitive(m map[string]interface{}) bool {
+	if isSensitive(m) {
+		return true
+	}
+	for _, v := range m {
+		if g, ok := v.(map[string]interface{}); ok && hasSensitive(g) {
+			return true
+		}
+	}
+	return false
+}
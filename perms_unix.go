@@ -0,0 +1,33 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// unsafePermissions describes what's unsafe about the permissions of `fi`,
+// empty when they're safe.
+func unsafePermissions(fi os.FileInfo) string {
+	mode := fi.Mode().Perm()
+	switch {
+	case mode&0o002 != 0:
+		return fmt.Sprintf("is world writable (%#o)", mode)
+	case mode&0o020 != 0:
+		return fmt.Sprintf("is group writable (%#o)", mode)
+	case mode&0o004 != 0:
+		return fmt.Sprintf("is world readable (%#o)", mode)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		if uid := int(st.Uid); uid != 0 && uid != os.Getuid() {
+			return fmt.Sprintf("is owned by uid %d", uid)
+		}
+	}
+	return ""
+}
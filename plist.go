@@ -0,0 +1,380 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ErrPlist is returned when a property list is malformed.
+var ErrPlist = errors.New("malformed property list")
+
+// plistEpoch is the reference date used by binary plist dates.
+var plistEpoch = time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ReadPlist returns a new Config from the bytes of an XML, or binary, property
+// list. The root of the property list must be a dictionary.
+//
+// Integers and reals are read as float64 (matching JSON numbers), dates as
+// time.Time, and data as []byte.
+func ReadPlist(b []byte) (Config, error) {
+	var v interface{}
+	var err error
+	if bytes.HasPrefix(b, []byte("bplist00")) {
+		v, err = readBinaryPlist(b)
+	} else {
+		v, err = readXMLPlist(b)
+	}
+	if err != nil {
+		return *new(Config), err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return *new(Config), fmt.Errorf("%s: root is not a dictionary", ErrPlist)
+	}
+	return Config{m: m}, nil
+}
+
+func readXMLPlist(b []byte) (interface{}, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		t, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("%s: no root value", ErrPlist)
+			}
+			return nil, err
+		}
+		if el, ok := t.(xml.StartElement); ok && el.Name.Local != "plist" {
+			return xmlPlistValue(d, el)
+		}
+	}
+}
+
+func xmlPlistValue(d *xml.Decoder, el xml.StartElement) (interface{}, error) {
+	switch el.Name.Local {
+	case "dict":
+		m := make(map[string]interface{})
+		for {
+			key, ok, err := nextXMLPlistElement(d)
+			if err != nil || !ok {
+				return m, err
+			}
+			if key.Name.Local != "key" {
+				return nil, fmt.Errorf("%s: expected <key>, found <%s>", ErrPlist, key.Name.Local)
+			}
+			var k string
+			if err = d.DecodeElement(&k, &key); err != nil {
+				return nil, err
+			}
+			val, ok, err := nextXMLPlistElement(d)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("%s: missing value for key %s", ErrPlist, k)
+			}
+			if m[k], err = xmlPlistValue(d, val); err != nil {
+				return nil, err
+			}
+		}
+	case "array":
+		a := []interface{}{}
+		for {
+			val, ok, err := nextXMLPlistElement(d)
+			if err != nil || !ok {
+				return a, err
+			}
+			v, err := xmlPlistValue(d, val)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+	case "true", "false":
+		if err := d.Skip(); err != nil {
+			return nil, err
+		}
+		return el.Name.Local == "true", nil
+	}
+
+	var s string
+	if err := d.DecodeElement(&s, &el); err != nil {
+		return nil, err
+	}
+	s = strings.TrimSpace(s)
+	switch el.Name.Local {
+	case "string":
+		return s, nil
+	case "integer":
+		if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return float64(i), nil
+		}
+		u, err := strconv.ParseUint(s, 0, 64)
+		return float64(u), err
+	case "real":
+		return strconv.ParseFloat(s, 64)
+	case "date":
+		return time.Parse(time.RFC3339, s)
+	case "data":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	}
+	return nil, fmt.Errorf("%s: unknown element <%s>", ErrPlist, el.Name.Local)
+}
+
+// nextXMLPlistElement returns the next child element, or false once the
+// parent element ends.
+func nextXMLPlistElement(d *xml.Decoder) (xml.StartElement, bool, error) {
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return xml.StartElement{}, false, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			return t, true, nil
+		case xml.EndElement:
+			return xml.StartElement{}, false, nil
+		}
+	}
+}
+
+// binaryPlist holds the state for decoding a `bplist00` document.
+type binaryPlist struct {
+	b       []byte
+	refSize int
+	offsets []uint64
+	// visiting guards against reference cycles within hostile documents, and
+	// decoded against shared references expanding them exponentially: each
+	// object decoded is referenced by at least a byte of the document, unless
+	// it's decoded again.
+	visiting map[uint64]bool
+	decoded  int
+}
+
+func readBinaryPlist(b []byte) (interface{}, error) {
+	if len(b) < 8+32 {
+		return nil, ErrPlist
+	}
+	trailer := b[len(b)-32:]
+	offsetSize := int(trailer[6])
+	refSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:])
+	top := binary.BigEndian.Uint64(trailer[16:])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:])
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 ||
+		tableOffset >= uint64(len(b)) || numObjects > uint64(len(b)) ||
+		numObjects*uint64(offsetSize) > uint64(len(b))-tableOffset {
+		return nil, ErrPlist
+	}
+
+	p := &binaryPlist{b: b, refSize: refSize, visiting: make(map[uint64]bool)}
+	p.offsets = make([]uint64, numObjects)
+	for i := range p.offsets {
+		start := tableOffset + uint64(i*offsetSize)
+		p.offsets[i] = beUint(b[start : start+uint64(offsetSize)])
+	}
+	return p.object(top)
+}
+
+func beUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+// bytesAt returns `n` bytes at offset `off`, or false when out of range.
+func (p *binaryPlist) bytesAt(off, n uint64) ([]byte, bool) {
+	if off > uint64(len(p.b)) || n > uint64(len(p.b))-off {
+		return nil, false
+	}
+	return p.b[off : off+n], true
+}
+
+// count returns the length of an object, which is either the low nibble of
+// the marker, or an int object following it, along with the data offset.
+func (p *binaryPlist) count(marker byte, off uint64) (uint64, uint64, error) {
+	n := uint64(marker & 0x0F)
+	if n != 0x0F {
+		return n, off, nil
+	}
+	b, ok := p.bytesAt(off, 1)
+	if !ok || b[0]&0xF0 != 0x10 {
+		return 0, 0, ErrPlist
+	}
+	size := uint64(1) << (b[0] & 0x0F)
+	if size > 8 {
+		return 0, 0, ErrPlist
+	}
+	v, ok := p.bytesAt(off+1, size)
+	if !ok {
+		return 0, 0, ErrPlist
+	}
+	// Every item takes at least a byte, so counts beyond the document are
+	// bogus, and would overflow once scaled to the size of their items.
+	if n = beUint(v); n > uint64(len(p.b)) {
+		return 0, 0, ErrPlist
+	}
+	return n, off + 1 + size, nil
+}
+
+func (p *binaryPlist) refs(off, n uint64) ([]uint64, error) {
+	if n > uint64(len(p.b)) {
+		return nil, ErrPlist
+	}
+	b, ok := p.bytesAt(off, n*uint64(p.refSize))
+	if !ok {
+		return nil, ErrPlist
+	}
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = beUint(b[i*p.refSize : (i+1)*p.refSize])
+	}
+	return refs, nil
+}
+
+func (p *binaryPlist) object(ref uint64) (interface{}, error) {
+	if ref >= uint64(len(p.offsets)) || p.visiting[ref] {
+		return nil, ErrPlist
+	}
+	if p.decoded++; p.decoded > len(p.b) {
+		return nil, fmt.Errorf("%s: objects expand beyond the document", ErrPlist)
+	}
+	p.visiting[ref] = true
+	defer delete(p.visiting, ref)
+
+	off := p.offsets[ref]
+	mb, ok := p.bytesAt(off, 1)
+	if !ok {
+		return nil, ErrPlist
+	}
+	marker := mb[0]
+	off++
+
+	switch marker & 0xF0 {
+	case 0x00:
+		switch marker {
+		case 0x00:
+			return nil, nil
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+	case 0x10:
+		size := uint64(1) << (marker & 0x0F)
+		b, ok := p.bytesAt(off, size)
+		if !ok {
+			break
+		}
+		switch size {
+		case 1, 2, 4:
+			return float64(beUint(b)), nil
+		case 8:
+			return float64(int64(beUint(b))), nil
+		case 16:
+			return float64(beUint(b[8:])), nil
+		}
+	case 0x20:
+		size := uint64(1) << (marker & 0x0F)
+		b, ok := p.bytesAt(off, size)
+		if !ok {
+			break
+		}
+		switch size {
+		case 4:
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case 8:
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		}
+	case 0x30:
+		b, ok := p.bytesAt(off, 8)
+		if marker != 0x33 || !ok {
+			break
+		}
+		secs := math.Float64frombits(binary.BigEndian.Uint64(b))
+		return plistEpoch.Add(time.Duration(secs * float64(time.Second))), nil
+	case 0x40, 0x50, 0x60:
+		n, off, err := p.count(marker, off)
+		if err != nil {
+			return nil, err
+		}
+		size := n
+		if marker&0xF0 == 0x60 {
+			size *= 2
+		}
+		b, ok := p.bytesAt(off, size)
+		if !ok {
+			break
+		}
+		switch marker & 0xF0 {
+		case 0x40:
+			data := make([]byte, len(b))
+			copy(data, b)
+			return data, nil
+		case 0x50:
+			return string(b), nil
+		}
+		u := make([]uint16, n)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(b[i*2:])
+		}
+		return string(utf16.Decode(u)), nil
+	case 0xA0:
+		n, off, err := p.count(marker, off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.refs(off, n)
+		if err != nil {
+			return nil, err
+		}
+		a := make([]interface{}, n)
+		for i, r := range refs {
+			if a[i], err = p.object(r); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	case 0xD0:
+		n, off, err := p.count(marker, off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.refs(off, n*2)
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			k, err := p.object(refs[i])
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: dictionary key is not a string", ErrPlist)
+			}
+			if m[key], err = p.object(refs[n+i]); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported object 0x%02x", ErrPlist, marker)
+}
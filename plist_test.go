@@ -0,0 +1,92 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// bplist returns a `bplist00` document of the objects `objects`, the
+// first being the top, with 1 byte offsets and refs.
+func bplist(objects ...[]byte) []byte {
+	b := []byte("bplist00")
+	var offsets []byte
+	for _, o := range objects {
+		offsets = append(offsets, byte(len(b)))
+		b = append(b, o...)
+	}
+	table := len(b)
+	b = append(b, offsets...)
+	trailer := make([]byte, 32)
+	trailer[6], trailer[7] = 1, 1
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(objects)))
+	binary.BigEndian.PutUint64(trailer[24:], uint64(table))
+	return append(b, trailer...)
+}
+
+func TestBinaryPlist(t *testing.T) {
+	c, err := ReadPlist(bplist([]byte{0xD1, 1, 2}, []byte("\x51a"), []byte("\x51b")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := c.String("a"); s != "b" {
+		t.Errorf("a = %q, want b", s)
+	}
+
+	// Counts overflowing once scaled to the size of their items.
+	huge := []byte{0x13, 0x80, 0, 0, 0, 0, 0, 0, 1}
+	for _, marker := range []byte{0x6F, 0xDF, 0xAF, 0x4F} {
+		if _, err := ReadPlist(bplist([]byte{0xD1, 1, 2}, []byte("\x51a"), append([]byte{marker}, huge...))); err == nil {
+			t.Errorf("0x%02x of a huge count: want an error", marker)
+		}
+	}
+}
+
+// sharedPlist returns a `bplist00` document of `n` arrays, each referencing
+// the next twice, which expands to 2^n objects.
+func sharedPlist(n int) []byte {
+	objects := make([][]byte, n+1)
+	for i := 0; i < n; i++ {
+		objects[i] = []byte{0xA2, byte(i + 1), byte(i + 1)}
+	}
+	objects[n] = []byte{0x09}
+	return bplist(objects...)
+}
+
+func TestBinaryPlistShared(t *testing.T) {
+	c, err := ReadPlist(bplist([]byte{0xD1, 1, 2}, []byte("\x51a"), []byte{0xA3, 3, 3, 3}, []byte{0x09}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := c.Val("a"); len(l.([]interface{})) != 3 {
+		t.Errorf("a = %v, want a shared true thrice", l)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ReadPlist(sharedPlist(60))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("read a document expanding to 2^60 objects")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading a document of shared references didn't return")
+	}
+}
+
+func FuzzReadPlist(f *testing.F) {
+	f.Add(bplist([]byte{0xD1, 1, 2}, []byte("\x51a"), []byte("\x51b")))
+	f.Add(bplist([]byte{0xD2, 1, 2, 3, 4}, []byte("\x51a"), []byte("\x51b"), []byte{0xA1, 2}, []byte{0x6F, 0x10, 2, 0, 'x', 0, 'y'}))
+	f.Add(sharedPlist(60))
+	f.Add([]byte(`<?xml version="1.0"?><plist><dict><key>a</key><string>b</string></dict></plist>`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		ReadPlist(b)
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Location is where a key is defined, by the config file (empty when not
+// read from one), and the 1-based line and column, in bytes, of its name.
+type Location struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// String returns the location as `file:line:column`, eg. for editors to jump
+// to.
+func (l Location) String() string {
+	if l.File == "" {
+		return fmt.Sprintf("%d:%d", l.Line, l.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+}
+
+// Position returns where the key, or group, at the dot separated `path`
+// within the group (eg. `db.port`) is defined, along with whether it's
+// known. Positions are kept as JSON documents are read, along with those of
+// included files, and of the layers of a Chain, by the layer in effect. Keys
+// set otherwise, eg. by SetConfig, or read from other formats, have none.
+func (c Config) Position(path string) (Location, bool) {
+	if _, ok := valueAt(c, path); !ok {
+		return Location{}, false
+	}
+	l, ok := c.pos[join(c.path, path)]
+	return l, ok
+}
+
+// Position returns where the key at `path` within the current config is
+// defined, see Config.Position.
+func Position(path string) (Location, bool) {
+	return cfg.Position(path)
+}
+
+// keyPositions returns the positions of the keys of the JSON document `b`,
+// by path, those of the last of any duplicates, as they're the ones decoded.
+func keyPositions(b []byte) map[string]Location {
+	keys, err := scanKeys(b)
+	if err != nil {
+		return nil
+	}
+	pos := make(map[string]Location, len(keys))
+	// Keys are in order, so lines are counted as they go.
+	line, start, at := 1, 0, 0
+	for _, k := range keys {
+		for ; at < k.offset; at++ {
+			if b[at] == '\n' {
+				line, start = line+1, at+1
+			}
+		}
+		pos[k.path] = Location{Line: line, Column: k.offset - start + 1}
+	}
+	return pos
+}
+
+// filePositions sets the file of the positions `pos`.
+func filePositions(pos map[string]Location, f string) {
+	for path, l := range pos {
+		l.File = f
+		pos[path] = l
+	}
+}
+
+// mergePositions returns the positions `pos`, of a config which `c` is
+// deep-merged over, updated by those of the keys of `c`.
+func mergePositions(pos map[string]Location, c Config) map[string]Location {
+	if pos == nil && c.pos == nil {
+		return nil
+	}
+	if pos == nil {
+		pos = make(map[string]Location, len(c.pos))
+	}
+	walk("", c.m, func(path string, v interface{}) bool {
+		if l, ok := c.pos[path]; ok {
+			pos[path] = l
+		} else {
+			delete(pos, path)
+		}
+		return true
+	})
+	return pos
+}
+
+// dropPositions returns a copy of the positions `pos` without those of the
+// group at `prefix`, and the keys within it.
+func dropPositions(pos map[string]Location, prefix string) map[string]Location {
+	if pos == nil {
+		return nil
+	}
+	kept := make(map[string]Location, len(pos))
+	for path, l := range pos {
+		if path != prefix && !strings.HasPrefix(path, prefix+".") {
+			kept[path] = l
+		}
+	}
+	return kept
+}
+
+// prefixPositions adds the positions `from`, of keys within the group at
+// `prefix`, to `pos`, unless already known.
+func prefixPositions(pos, from map[string]Location, prefix string) {
+	for path, l := range from {
+		path = join(prefix, path)
+		if _, ok := pos[path]; !ok {
+			pos[path] = l
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	dir := t.TempDir()
+	main, db := filepath.Join(dir, "main.json"), filepath.Join(dir, "db.json")
+	os.WriteFile(main, []byte("{\n\t\"db\": {\"$include\": \"db.json\",\n\t\t\"pool\": \"many\"},\n\t\"port\": 9090\n}"), 0600)
+	os.WriteFile(db, []byte("{\"host\": \"db\",\n \"pool\": 5}"), 0600)
+
+	os.Setenv("POSTEST_port", "1")
+	defer os.Unsetenv("POSTEST_port")
+	c, err := NewChain(File(main)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]Location{
+		"db":      {main, 2, 2},
+		"db.pool": {main, 3, 3},
+		"db.host": {db, 1, 2},
+		"port":    {main, 4, 2},
+	} {
+		if l, ok := c.Position(path); !ok || l != want {
+			t.Errorf("%s at %v, want %v", path, l, want)
+		}
+	}
+	if l, ok := c.Group("db").Position("host"); !ok || l.File != db {
+		t.Errorf("db group: host at %v, want within %s", l, db)
+	}
+
+	var v struct {
+		DB struct{ Pool int }
+	}
+	var be *BindError
+	if err := c.Bind(&v); !errors.As(err, &be) || be.Position == nil || be.Position.Line != 3 {
+		t.Errorf("bind error = %v, want one at line 3", err)
+	}
+
+	// Keys of higher layers without positions hide those beneath them.
+	c, err = NewChain(Env("POSTEST_"), File(main)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := c.Position("port"); ok {
+		t.Errorf("port at %v, want none, as set by the environment", l)
+	}
+}
+
+func TestComments(t *testing.T) {
+	b := []byte("{\n\t// The port, \"quoted\".\n\t\"port\": 9090, /* multi\n\tline */ \"url\": \"http://host/*x*/\"\n}")
+	c, err := ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url, _ := c.String("url"); url != "http://host/*x*/" {
+		t.Errorf("url = %q, want the string's slashes kept", url)
+	}
+	for path, want := range map[string]Location{"port": {"", 3, 2}, "url": {"", 4, 10}} {
+		if l, ok := c.Position(path); !ok || l != want {
+			t.Errorf("%s at %v, want %v", path, l, want)
+		}
+	}
+	if _, err := ReadFrom([]byte(`{"port": 9090} /* unterminated`)); err == nil {
+		t.Error("read a document with an unterminated comment")
+	}
+
+	// Saving keeps the comments, which read back.
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, b, 0600)
+	if c, err = c.Patch([]byte(`{"port": 80}`), MergePatch); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := os.ReadFile(path)
+	if c, err = ReadFrom(saved); err != nil {
+		t.Fatalf("reading the saved %s: %v", saved, err)
+	}
+	if port, _ := c.Int("port"); port != 80 || !bytes.Contains(saved, []byte("// The port")) {
+		t.Errorf("saved %s, want port 80 and the comments kept", saved)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// preserve returns the JSON document `orig` edited to hold `m`, so that the
+// key order, indentation, and any comments of unchanged values are kept.
+// Changed values are replaced in place, removed keys are cut out, and new
+// keys are appended to their group, sorted.
+func preserve(orig []byte, m map[string]interface{}) ([]byte, error) {
+	s := &scanner{b: orig}
+	s.skip()
+	root, err := s.value()
+	if err != nil {
+		return nil, err
+	}
+	if !root.obj {
+		return nil, errors.New("config root isn't an object")
+	}
+	var edits []edit
+	if err = root.diff(orig, m, &edits); err != nil {
+		return nil, err
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	b := append([]byte(nil), orig...)
+	for _, e := range edits {
+		b = append(b[:e.start], append([]byte(e.text), b[e.end:]...)...)
+	}
+	return b, nil
+}
+
+// edit replaces the bytes from start to end with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+type node struct {
+	start, end int
+	obj        bool
+	members    []member
+}
+
+type member struct {
+	key   string
+	start int
+	val   node
+}
+
+// diff adds the edits turning the object `n` into `m`.
+func (n node) diff(b []byte, m map[string]interface{}, edits *[]edit) error {
+	// Objects left empty are simpler to replace whole.
+	kept := 0
+	for _, mem := range n.members {
+		if _, ok := m[mem.key]; ok {
+			kept++
+		}
+	}
+	if kept == 0 {
+		return replace(b, n, m, edits)
+	}
+
+	seen := make(map[string]bool, len(n.members))
+	lead := true
+	for i, mem := range n.members {
+		seen[mem.key] = true
+		v, ok := m[mem.key]
+		switch {
+		case !ok && lead:
+			// Leading members are cut up to the next kept member.
+			continue
+		case !ok:
+			*edits = append(*edits, edit{n.members[i-1].val.end, mem.val.end, ""})
+			continue
+		case lead && i > 0:
+			*edits = append(*edits, edit{n.members[0].start, mem.start, ""})
+		}
+		lead = false
+		if g, isGroup := v.(map[string]interface{}); isGroup && mem.val.obj {
+			if err := mem.val.diff(b, g, edits); err != nil {
+				return err
+			}
+			continue
+		}
+		var old interface{}
+		if err := json.Unmarshal(b[mem.val.start:mem.val.end], &old); err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(old, v) {
+			if err := replace(b, mem.val, v, edits); err != nil {
+				return err
+			}
+		}
+	}
+
+	var added []string
+	for key := range m {
+		if !seen[key] {
+			added = append(added, key)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	sort.Strings(added)
+	last := n.members[len(n.members)-1]
+	indent, multiline := lineIndent(b, last.start)
+	var text bytes.Buffer
+	for _, key := range added {
+		k, _ := json.Marshal(key)
+		v, err := json.MarshalIndent(m[key], indent, indentUnit(b))
+		if err != nil {
+			return err
+		}
+		if multiline {
+			fmt.Fprintf(&text, ",\n%s%s: %s", indent, k, v)
+		} else {
+			fmt.Fprintf(&text, ", %s: %s", k, v)
+		}
+	}
+	*edits = append(*edits, edit{last.val.end, last.val.end, text.String()})
+	return nil
+}
+
+// replace adds the edit replacing the value `n` with `v`.
+func replace(b []byte, n node, v interface{}, edits *[]edit) error {
+	indent, _ := lineIndent(b, n.start)
+	text, err := json.MarshalIndent(v, indent, indentUnit(b))
+	if err != nil {
+		return err
+	}
+	*edits = append(*edits, edit{n.start, n.end, string(text)})
+	return nil
+}
+
+// lineIndent returns the whitespace starting the line of `pos`, and whether
+// `pos` starts its line (after the whitespace).
+func lineIndent(b []byte, pos int) (string, bool) {
+	start := bytes.LastIndexByte(b[:pos], '\n') + 1
+	end := start
+	for end < pos && (b[end] == ' ' || b[end] == '\t') {
+		end++
+	}
+	return string(b[start:end]), end == pos
+}
+
+// indentUnit returns the indentation used by `b`, a tab unless the first
+// indented line uses spaces.
+func indentUnit(b []byte) string {
+	for i := bytes.IndexByte(b, '\n'); i >= 0 && i+1 < len(b); {
+		j := i + 1
+		for j < len(b) && b[j] == ' ' {
+			j++
+		}
+		if j > i+1 {
+			return string(b[i+1 : j])
+		}
+		if j < len(b) && b[j] == '\t' {
+			return "\t"
+		}
+		next := bytes.IndexByte(b[j:], '\n')
+		if next < 0 {
+			break
+		}
+		i = j + next
+	}
+	return "\t"
+}
+
+// scanner records the positions of the values within a JSON document,
+// allowing for `//` and `/* */` comments.
+type scanner struct {
+	b []byte
+	i int
+}
+
+func (s *scanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("config: offset %d: %s", s.i, fmt.Sprintf(format, args...))
+}
+
+func (s *scanner) skip() {
+	for s.i < len(s.b) {
+		switch c := s.b[s.i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			s.i++
+		case bytes.HasPrefix(s.b[s.i:], []byte("//")):
+			if j := bytes.IndexByte(s.b[s.i:], '\n'); j >= 0 {
+				s.i += j + 1
+			} else {
+				s.i = len(s.b)
+			}
+		case bytes.HasPrefix(s.b[s.i:], []byte("/*")):
+			if j := bytes.Index(s.b[s.i+2:], []byte("*/")); j >= 0 {
+				s.i += j + 4
+			} else {
+				s.i = len(s.b)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *scanner) value() (node, error) {
+	if s.i >= len(s.b) {
+		return node{}, s.errorf("unexpected end of document")
+	}
+	n := node{start: s.i}
+	switch s.b[s.i] {
+	case '{':
+		n.obj = true
+		s.i++
+		s.skip()
+		if s.i < len(s.b) && s.b[s.i] == '}' {
+			break
+		}
+		for {
+			var mem member
+			mem.start = s.i
+			if err := s.string(); err != nil {
+				return node{}, err
+			}
+			if err := json.Unmarshal(s.b[mem.start:s.i], &mem.key); err != nil {
+				return node{}, err
+			}
+			s.skip()
+			if s.i >= len(s.b) || s.b[s.i] != ':' {
+				return node{}, s.errorf("expected ':'")
+			}
+			s.i++
+			s.skip()
+			v, err := s.value()
+			if err != nil {
+				return node{}, err
+			}
+			mem.val = v
+			n.members = append(n.members, mem)
+			s.skip()
+			if s.i < len(s.b) && s.b[s.i] == ',' {
+				s.i++
+				s.skip()
+				continue
+			}
+			break
+		}
+		if s.i >= len(s.b) || s.b[s.i] != '}' {
+			return node{}, s.errorf("expected '}'")
+		}
+	case '[':
+		s.i++
+		s.skip()
+		for s.i < len(s.b) && s.b[s.i] != ']' {
+			if _, err := s.value(); err != nil {
+				return node{}, err
+			}
+			s.skip()
+			if s.i < len(s.b) && s.b[s.i] == ',' {
+				s.i++
+				s.skip()
+			}
+		}
+		if s.i >= len(s.b) {
+			return node{}, s.errorf("expected ']'")
+		}
+	case '"':
+		if err := s.string(); err != nil {
+			return node{}, err
+		}
+		n.end = s.i
+		return n, nil
+	default:
+		for s.i < len(s.b) && bytes.IndexByte([]byte(",]} \t\r\n/"), s.b[s.i]) < 0 {
+			s.i++
+		}
+		if s.i == n.start {
+			return node{}, s.errorf("unexpected %q", s.b[s.i])
+		}
+		n.end = s.i
+		return n, nil
+	}
+	s.i++
+	n.end = s.i
+	return n, nil
+}
+
+func (s *scanner) string() error {
+	if s.i >= len(s.b) || s.b[s.i] != '"' {
+		return s.errorf("expected string")
+	}
+	for s.i++; s.i < len(s.b); s.i++ {
+		switch s.b[s.i] {
+		case '\\':
+			s.i++
+		case '"':
+			s.i++
+			return nil
+		}
+	}
+	return s.errorf("unterminated string")
+}
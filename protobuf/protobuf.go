@@ -0,0 +1,98 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package protobuf reads configuration serialized as a Protocol Buffers
+// message, eg. one generated by another service, described by its generated
+// type, or a descriptor set, eg.
+//
+//	config.RegisterFormat(".pb", protobuf.Message(&pb.Config{}))
+//	a, err := config.Open(config.File("config.pb"))
+//
+// or, without the generated code, via the descriptor set output by
+// `protoc --include_imports --descriptor_set_out=config.desc`:
+//
+//	d, err := protobuf.FromDescriptorSet(desc, "app.v1.Config")
+//	...
+//	config.RegisterFormat(".pb", d)
+//
+// Messages are mapped as by their canonical JSON mapping, keyed by the field
+// names of the .proto file, eg. `max_conns`, rather than their JSON names.
+// Unset fields are missing, so they're defaulted as by the config's users.
+// As 64-bit integers are mapped to strings, the configs coerce values.
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"code.minty.io/config"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// MessageDecoder decodes the serialized messages of a type, see
+// config.Decoder.
+type MessageDecoder struct {
+	desc protoreflect.MessageDescriptor
+}
+
+var _ config.Decoder = (*MessageDecoder)(nil)
+
+// Decoder returns the decoder of the messages described by `desc`.
+func Decoder(desc protoreflect.MessageDescriptor) *MessageDecoder {
+	return &MessageDecoder{desc}
+}
+
+// Message returns the decoder of the messages of the generated type of `m`.
+func Message(m proto.Message) *MessageDecoder {
+	return Decoder(m.ProtoReflect().Descriptor())
+}
+
+// FromDescriptorSet returns the decoder of the messages named `name`,
+// described within the serialized FileDescriptorSet `b`.
+func FromDescriptorSet(b []byte, name string) (*MessageDecoder, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("protobuf: invalid descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf: invalid descriptor set: %w", err)
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("protobuf: %s: %w", name, err)
+	}
+	desc, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("protobuf: %s isn't a message", name)
+	}
+	return Decoder(desc), nil
+}
+
+// Decode decodes the serialized message `b`.
+func (d *MessageDecoder) Decode(b []byte) (map[string]interface{}, error) {
+	msg := dynamicpb.NewMessage(d.desc)
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return nil, fmt.Errorf("protobuf: %s: %w", d.desc.FullName(), err)
+	}
+	j, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf: %s: %w", d.desc.FullName(), err)
+	}
+	var m map[string]interface{}
+	if err = json.Unmarshal(j, &m); err != nil {
+		return nil, fmt.Errorf("protobuf: %s: %w", d.desc.FullName(), err)
+	}
+	return m, nil
+}
+
+// Coerce reports that the configs coerce values, see the package docs.
+func (d *MessageDecoder) Coerce() bool {
+	return true
+}
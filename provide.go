@@ -0,0 +1,195 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider computes the value of a virtual key when it's looked up.
+type Provider func() interface{}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{}
+	// providerGroups counts the providers within each group, by its path, so
+	// lookups of other keys skip the providers altogether.
+	providerGroups = map[string]int{}
+)
+
+// Provide registers `fn` as computing the value at `path`, a dot separated
+// path of groups and a key (eg. `runtime.cpu`), so dynamic values are read
+// through the same accessors as any other, eg.
+//
+//	config.Provide("runtime.cpu", func() interface{} { return runtime.NumCPU() })
+//	cpus, _ := config.Group("runtime").Int("cpu")
+//
+// Values are only computed as their key, or a group holding it, is looked up,
+// and on every such lookup. Keys set within the config take precedence over
+// computed ones, as do keys whose value is computed as nil, which are left
+// unset. A nil `fn` removes the provider.
+func Provide(path string, fn Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	_, exists := providers[path]
+	if fn == nil {
+		if exists {
+			delete(providers, path)
+			countGroups(path, -1)
+		}
+		return
+	}
+	if !exists {
+		countGroups(path, 1)
+	}
+	providers[path] = fn
+}
+
+// countGroups adds `n` to the providers counted within the groups holding
+// `path`.
+func countGroups(path string, n int) {
+	for i := range path {
+		if path[i] != '.' {
+			continue
+		}
+		g := path[:i]
+		if providerGroups[g] += n; providerGroups[g] <= 0 {
+			delete(providerGroups, g)
+		}
+	}
+}
+
+// value returns the value of `key` within the config's group, or computed by
+// the providers when the config lacks it, along with whether it was found.
+// The config's own values are never changed, nor copied unless a provider
+// computes a key within them.
+func (c Config) value(key string) (interface{}, bool) {
+	v, ok := c.m[key]
+	g, isGroup := v.(map[string]interface{})
+	if ok && !isGroup {
+		return v, true
+	}
+	providersMu.RLock()
+	if len(providers) == 0 {
+		providersMu.RUnlock()
+		return v, ok
+	}
+	path := join(c.path, key)
+	fn := providers[path]
+	var within map[string]Provider
+	if providerGroups[path] > 0 {
+		within = make(map[string]Provider)
+		for p, fn := range providers {
+			if strings.HasPrefix(p, path+".") {
+				within[p[len(path)+1:]] = fn
+			}
+		}
+	}
+	providersMu.RUnlock()
+	// Providers are computed without the lock, so they can look up keys.
+	switch {
+	case !ok && fn != nil:
+		if v := fn(); v != nil {
+			return v, true
+		}
+		return nil, false
+	case len(within) == 0:
+		return v, ok
+	}
+	m := computed(g, within)
+	if m == nil {
+		return v, ok
+	}
+	return m, true
+}
+
+// valueCoerce returns the value of `key`, see value, and whether the config
+// coerces values.
+func (c Config) valueCoerce(key string) (interface{}, bool, bool) {
+	v, ok := c.value(key)
+	return v, ok, c.coerce
+}
+
+// values returns the values of the config's group, along with any computed
+// by providers, for reading the group as a whole, eg. by Bind.
+func (c Config) values() map[string]interface{} {
+	providersMu.RLock()
+	if len(providers) == 0 {
+		providersMu.RUnlock()
+		return c.m
+	}
+	within := make(map[string]Provider)
+	for p, fn := range providers {
+		if c.path == "" {
+			within[p] = fn
+		} else if strings.HasPrefix(p, c.path+".") {
+			within[p[len(c.path)+1:]] = fn
+		}
+	}
+	providersMu.RUnlock()
+	if m := computed(c.m, within); m != nil {
+		return m
+	}
+	return c.m
+}
+
+// computed returns a copy of the group `g` along with the values computed by
+// `fns`, by their path within it, for the keys it lacks. Nil is returned when
+// none are computed.
+func computed(g map[string]interface{}, fns map[string]Provider) map[string]interface{} {
+	paths := make([]string, 0, len(fns))
+	for p := range fns {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var m map[string]interface{}
+	for _, p := range paths {
+		keys := strings.Split(p, ".")
+		if hasPath(g, keys) {
+			continue
+		}
+		v := fns[p]()
+		if v == nil {
+			continue
+		}
+		if m == nil {
+			m = make(map[string]interface{}, len(g)+1)
+			for key, val := range g {
+				m[key] = val
+			}
+		}
+		provideVal(m, keys, v)
+	}
+	return m
+}
+
+// hasPath returns whether the value at `path` is set within `g`.
+func hasPath(g map[string]interface{}, path []string) bool {
+	for _, key := range path[:len(path)-1] {
+		var ok bool
+		if g, ok = g[key].(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	_, ok := g[path[len(path)-1]]
+	return ok
+}
+
+// provideVal sets the value at `path` within `m`, copying the groups along
+// the way rather than changing them.
+func provideVal(m map[string]interface{}, path []string, v interface{}) {
+	for _, key := range path[:len(path)-1] {
+		g, _ := m[key].(map[string]interface{})
+		cp := make(map[string]interface{}, len(g)+1)
+		for k, val := range g {
+			cp[k] = val
+		}
+		m[key] = cp
+		m = cp
+	}
+	m[path[len(path)-1]] = v
+}
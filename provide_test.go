@@ -0,0 +1,77 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import "testing"
+
+func TestProvide(t *testing.T) {
+	c := FromMap(map[string]interface{}{
+		"port": 9090.0,
+		"app":  map[string]interface{}{"name": "real"},
+	})
+	Provide("port", func() interface{} { return 1.0 })
+	Provide("app.name", func() interface{} { return "computed" })
+	Provide("app.cpus", func() interface{} { return 4 })
+	// Providers may look up keys themselves.
+	Provide("app.double", func() interface{} {
+		port, _ := c.Int("port")
+		return 2 * port
+	})
+	defer func() {
+		for _, p := range []string{"port", "app.name", "app.cpus", "app.double"} {
+			Provide(p, nil)
+		}
+	}()
+
+	if port, _ := c.Int("port"); port != 9090 {
+		t.Errorf("port = %d, want the config's 9090", port)
+	}
+	app := c.Group("app")
+	if name, _ := app.String("name"); name != "real" {
+		t.Errorf("app.name = %q, want the config's", name)
+	}
+	if cpus, _ := app.Int("cpus"); cpus != 4 {
+		t.Errorf("app.cpus = %d, want 4", cpus)
+	}
+	if double, _ := app.Int("double"); double != 2*9090 {
+		t.Errorf("app.double = %d, want %d", double, 2*9090)
+	}
+	if _, ok := c.m["app"].(map[string]interface{})["cpus"]; ok {
+		t.Error("computed value set within the config")
+	}
+
+	if n := testing.AllocsPerRun(100, func() { c.Val("missing") }); n != 0 {
+		t.Errorf("lookup of a key without providers: %v allocs, want 0", n)
+	}
+}
+
+func TestProvideBuildInfo(t *testing.T) {
+	c := FromMap(map[string]interface{}{"port": 9090.0})
+	if _, ok := c.Group(RuntimeGroup).Val("pid"); ok {
+		t.Fatal("runtime.pid provided without ProvideBuildInfo")
+	}
+	ProvideBuildInfo()
+	defer func() {
+		for _, key := range []string{"go_version", "os", "arch", "revision", "modified", "start_time", "hostname", "pid"} {
+			Provide(RuntimeGroup+"."+key, nil)
+		}
+	}()
+	if pid, _ := c.Group(RuntimeGroup).Int("pid"); pid == 0 {
+		t.Error("runtime.pid not provided")
+	}
+
+	// Walk and Redact agree, neither including computed values.
+	var walked []string
+	c.Walk(func(path string, v interface{}) bool {
+		walked = append(walked, path)
+		return true
+	})
+	if len(walked) != 1 || walked[0] != "port" {
+		t.Errorf("walked %v, want [port]", walked)
+	}
+	if r := c.Redact(); len(r) != 1 || r["port"] != 9090.0 {
+		t.Errorf("redacted %v, want only port", r)
+	}
+}
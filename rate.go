@@ -0,0 +1,149 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate is a rate limit of Events per period, with bursts of up to Burst
+// events.
+type Rate struct {
+	Events int
+	Per    time.Duration
+	Burst  int
+}
+
+var rateUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+}
+
+// ParseRate parses rates like "100/s", "5000/m", or "10/30s", the period being
+// a unit (ms, s, m, h, or d) or a duration. The burst defaults to the number
+// of events.
+func ParseRate(s string) (Rate, error) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q, expected events/period", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(s[:i]))
+	if err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q, events must be a positive integer", s)
+	}
+	unit := strings.TrimSpace(s[i+1:])
+	per, ok := rateUnits[unit]
+	if !ok {
+		if per, err = time.ParseDuration(unit); err != nil || per <= 0 {
+			return Rate{}, fmt.Errorf("invalid rate %q, bad period %q", s, unit)
+		}
+	}
+	return Rate{Events: n, Per: per, Burst: n}, nil
+}
+
+// Limit returns the rate in events per second, eg. for rate.Limit.
+func (r Rate) Limit() float64 {
+	if r.Per <= 0 {
+		return 0
+	}
+	return float64(r.Events) / r.Per.Seconds()
+}
+
+// Interval returns the time between events.
+func (r Rate) Interval() time.Duration {
+	if r.Events <= 0 {
+		return 0
+	}
+	return r.Per / time.Duration(r.Events)
+}
+
+func (r Rate) String() string {
+	per := r.Per.String()
+	for unit, d := range rateUnits {
+		if d == r.Per {
+			per = unit
+		}
+	}
+	return fmt.Sprintf("%d/%s", r.Events, per)
+}
+
+// UnmarshalText parses the rate, so rates can be bound, see Bind.
+func (r *Rate) UnmarshalText(b []byte) error {
+	v, err := ParseRate(string(b))
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+// MarshalText returns the rate, without its burst.
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// RateLimit returns the rate limit for the `key` within the root level, either
+// a rate string (see ParseRate) or a group with the rate and burst, eg.
+//
+//	"api": "100/s"
+//	"login": {"rate": "5/m", "burst": 10}
+func (c Config) RateLimit(key string) (Rate, error) {
+	v, ok := c.Val(key)
+	if !ok {
+		return Rate{}, fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	if _, isGroup := v.(map[string]interface{}); !isGroup {
+		var r Rate
+		err := c.bindKey(key, &r)
+		return r, err
+	}
+	var spec struct {
+		Rate  *Rate `config:"rate"`
+		Burst *int  `config:"burst"`
+	}
+	if err := c.bindKey(key, &spec); err != nil {
+		return Rate{}, err
+	}
+	if spec.Rate == nil {
+		return Rate{}, fmt.Errorf("'%s.rate': %w", key, ErrNotFound)
+	}
+	r := *spec.Rate
+	if spec.Burst != nil {
+		if *spec.Burst <= 0 {
+			return Rate{}, fmt.Errorf("invalid '%s' burst %d, must be greater than 0", key, *spec.Burst)
+		}
+		r.Burst = *spec.Burst
+	}
+	return r, nil
+}
+
+// RequiredRateLimit returns the rate limit, within the root, and exits when
+// not found or invalid.
+func (c Config) RequiredRateLimit(key string) Rate {
+	r, err := c.RateLimit(key)
+	if err != nil {
+		log.Fatalf("failed to retrieve '%s' rate limit from config: %s", key, err)
+	}
+	return r
+}
+
+// RateLimit returns the rate limit for the `key` within the root level, see
+// Config.RateLimit.
+func RateLimit(key string) (Rate, error) {
+	return cfg.RateLimit(key)
+}
+
+// RequiredRateLimit returns the rate limit, within the root, and exits when
+// not found or invalid.
+func RequiredRateLimit(key string) Rate {
+	return cfg.RequiredRateLimit(key)
+}
@@ -0,0 +1,360 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package redis loads configuration from a Redis key, and can keep the
+// in-memory configuration fresh by subscribing to an invalidation channel.
+//
+// The value stored at the key is expected to be the same JSON document that
+// would otherwise live within `config.json`. Whenever a message is published
+// to the channel, the key is re-read, as by config.ReadFrom, and swapped in
+// via config.Load.
+//
+// The address may be a plain `host:port`, or a URL of the form
+// `redis://[:password@]host:port[/db]`.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.minty.io/config"
+)
+
+var (
+	// DialTimeout is the timeout used when connecting to Redis.
+	DialTimeout = 5 * time.Second
+	// RetryDelay is the delay between re-connection attempts while watching.
+	//
+	// Deprecated: set the Watcher's Retry policy instead. When changed from
+	// its default of a second, it's the constant delay of new watchers.
+	RetryDelay = time.Second
+)
+
+// ErrNil is returned when the config key does not exist.
+var ErrNil = errors.New("redis: nil reply")
+
+// Read returns the configuration stored at `key`, retried per
+// config.DefaultRetryPolicy.
+func Read(addr, key string) (config.Config, error) {
+	b, err := fetch(context.Background(), config.DefaultRetryPolicy, addr, key)
+	if err != nil {
+		return *new(config.Config), err
+	}
+	return config.ReadFrom(b)
+}
+
+// Source is a config.Source reading the configuration stored at Key.
+type Source struct {
+	Addr, Key string
+}
+
+func (s *Source) Read() (config.Config, error) {
+	return Read(s.Addr, s.Key)
+}
+
+// Watcher keeps the global configuration in sync with a Redis key.
+type Watcher struct {
+	// Retry is the policy for reading the key, and the backoff between
+	// re-connection attempts. Defaults to config.DefaultRetryPolicy.
+	Retry config.RetryPolicy
+	// Breaker, when set, stops refreshes from hammering a failing server.
+	Breaker *config.Breaker
+
+	addr, key, channel string
+
+	mu     sync.Mutex
+	conn   *conn
+	closed bool
+	done   chan struct{}
+}
+
+// NewWatcher returns a watcher for `key`, which can be tuned before calling
+// Start.
+func NewWatcher(addr, key, channel string) *Watcher {
+	w := &Watcher{
+		Retry:   config.DefaultRetryPolicy,
+		addr:    addr,
+		key:     key,
+		channel: channel,
+		done:    make(chan struct{}),
+	}
+	if RetryDelay != time.Second {
+		w.Retry.Backoff, w.Retry.MaxBackoff = RetryDelay, RetryDelay
+	}
+	return w
+}
+
+// Watch loads the configuration stored at `key`, installs it via config.Load,
+// and then refreshes it every time a message is published on `channel`. The initial load must succeed; afterwards failures are logged and
+// the watcher keeps re-connecting until it is closed.
+func Watch(addr, key, channel string) (*Watcher, error) {
+	w := NewWatcher(addr, key, channel)
+	return w, w.Start()
+}
+
+// Start loads the configuration and starts watching for changes.
+func (w *Watcher) Start() error {
+	if err := w.refresh(); err != nil {
+		return err
+	}
+	go w.loop()
+	return nil
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	if w.conn != nil {
+		return w.conn.Close()
+	}
+	return nil
+}
+
+func (w *Watcher) isClosed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *Watcher) loop() {
+	// The first subscription doesn't need a refresh, Start just loaded the key.
+	for failures := 0; !w.isClosed(); failures++ {
+		if failures > 0 {
+			delay := w.Retry.Delay(failures)
+			if delay <= 0 {
+				delay = time.Second
+			}
+			select {
+			case <-w.done:
+				return
+			case <-time.After(delay):
+			}
+		}
+		subscribed, err := w.subscribe(failures > 0)
+		if subscribed {
+			failures = 0
+		}
+		if err != nil && !w.isClosed() {
+			log.Printf("redis: watching '%s': %s", w.channel, err)
+		}
+	}
+}
+
+// subscribe blocks, refreshing the config on every message, until the
+// connection fails or the watcher is closed. It returns whether the
+// subscription was established.
+func (w *Watcher) subscribe(reload bool) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DialTimeout)
+	c, err := dial(ctx, w.addr)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return false, c.Close()
+	}
+	w.conn = c
+	w.mu.Unlock()
+	defer c.Close()
+
+	if _, err = c.do("SUBSCRIBE", w.channel); err != nil {
+		return false, err
+	}
+	// Anything published while we were disconnected was missed.
+	if reload {
+		w.logRefresh()
+	}
+	for {
+		reply, err := c.read()
+		if err != nil {
+			return true, err
+		}
+		msg, ok := reply.([]interface{})
+		if !ok || len(msg) != 3 || !isBulk(msg[0], "message") {
+			continue
+		}
+		w.logRefresh()
+	}
+}
+
+func (w *Watcher) logRefresh() {
+	if err := w.refresh(); err != nil {
+		log.Printf("redis: refreshing '%s': %s", w.key, err)
+	}
+}
+
+// refresh reads the key and installs it as the global configuration.
+func (w *Watcher) refresh() error {
+	return w.Breaker.Do(func() error {
+		return config.Load(watchedKey{w})
+	})
+}
+
+// watchedKey reads the key of a watcher, as Read does, but retried per the
+// watcher's policy.
+type watchedKey struct {
+	w *Watcher
+}
+
+func (k watchedKey) Read() (config.Config, error) {
+	b, err := fetch(context.Background(), k.w.Retry, k.w.addr, k.w.key)
+	if err != nil {
+		return *new(config.Config), err
+	}
+	c, err := config.ReadFrom(b)
+	if err != nil {
+		return c, fmt.Errorf("failed to read configuration from redis key %s: %w", k.w.key, err)
+	}
+	return c, nil
+}
+
+func fetch(ctx context.Context, p config.RetryPolicy, addr, key string) ([]byte, error) {
+	var b []byte
+	err := p.Do(ctx, func(ctx context.Context) error {
+		c, err := dial(ctx, addr)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			c.SetDeadline(deadline)
+		}
+
+		reply, err := c.do("GET", key)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if b, ok = reply.([]byte); !ok {
+			return ErrNil
+		}
+		return nil
+	})
+	return b, err
+}
+
+func isBulk(v interface{}, s string) bool {
+	b, ok := v.([]byte)
+	return ok && string(b) == s
+}
+
+// conn is a minimal RESP client, just enough to GET and SUBSCRIBE.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func dial(ctx context.Context, addr string) (*conn, error) {
+	var password, db string
+	if strings.HasPrefix(addr, "redis://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		addr = u.Host
+		if u.User != nil {
+			password, _ = u.User.Password()
+		}
+		db = strings.TrimPrefix(u.Path, "/")
+	}
+	d := net.Dialer{Timeout: DialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{nc, bufio.NewReader(nc)}
+	// Don't let the handshake hang past the dial deadline.
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+	if password != "" {
+		if _, err = c.do("AUTH", password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if db != "" && db != "0" {
+		if _, err = c.do("SELECT", db); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *conn) do(args ...string) (interface{}, error) {
+	w := bufio.NewWriter(c.Conn)
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+func (c *conn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, errors.New("redis: " + line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err = io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		a := make([]interface{}, n)
+		for i := range a {
+			if a[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
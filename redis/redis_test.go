@@ -0,0 +1,177 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"code.minty.io/config"
+)
+
+// fakeRedis serves GET and SUBSCRIBE, enough for the package's client.
+type fakeRedis struct {
+	l net.Listener
+
+	mu          sync.Mutex
+	values      map[string]string
+	subscribers map[string][]net.Conn
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeRedis{l: l, values: map[string]string{}, subscribers: map[string][]net.Conn{}}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(c)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) set(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[key] = value
+}
+
+// publish sends `msg` to the subscribers of `channel`, returning how many
+// there were.
+func (r *fakeRedis) publish(channel, msg string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.subscribers[channel] {
+		fmt.Fprintf(c, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(channel), channel, len(msg), msg)
+	}
+	return len(r.subscribers[channel])
+}
+
+func (r *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	for {
+		args, err := readCommand(br)
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			r.mu.Lock()
+			v, ok := r.values[args[1]]
+			r.mu.Unlock()
+			if !ok {
+				fmt.Fprint(c, "$-1\r\n")
+				continue
+			}
+			fmt.Fprintf(c, "$%d\r\n%s\r\n", len(v), v)
+		case "SUBSCRIBE":
+			ch := args[1]
+			r.mu.Lock()
+			fmt.Fprintf(c, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(ch), ch)
+			r.subscribers[ch] = append(r.subscribers[ch], c)
+			r.mu.Unlock()
+		default:
+			fmt.Fprintf(c, "-ERR unknown command '%s'\r\n", args[0])
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		if args[i], err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(args[i], "\r\n")
+	}
+	return args, nil
+}
+
+func TestRead(t *testing.T) {
+	r := newFakeRedis(t)
+	r.set("config", `{"port": 9090}`)
+	c, err := Read(r.l.Addr().String(), "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port, _ := c.Int("port"); port != 9090 {
+		t.Errorf("port = %d, want 9090", port)
+	}
+	p := config.RetryPolicy{MaxAttempts: 1}
+	if _, err = fetch(context.Background(), p, r.l.Addr().String(), "missing"); err != ErrNil {
+		t.Errorf("missing key: error = %v, want %v", err, ErrNil)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	prev := config.Current().AllowSensitive().Map()
+	defer config.SetConfig(prev)
+
+	r := newFakeRedis(t)
+	// Documents are read as config.ReadFrom reads them, comments and all.
+	r.set("config", "{\n\t// The port.\n\t\"port\": 9090\n}")
+	w := NewWatcher(r.l.Addr().String(), "config", "changes")
+	w.Retry = config.RetryPolicy{MaxAttempts: 1}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if port, _ := config.Current().Int("port"); port != 9090 {
+		t.Fatalf("port = %d, want 9090", port)
+	}
+	if l, ok := config.Current().Position("port"); !ok || l.Line != 3 {
+		t.Errorf("port at %v, want line 3, as read from the document", l)
+	}
+
+	r.set("config", `{"port": 80}`)
+	deadline := time.Now().Add(5 * time.Second)
+	for r.publish("changes", "reload") == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for {
+		if port, _ := config.Current().Int("port"); port == 80 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("not refreshed once published")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Invalid documents keep the config.
+	r.set("config", `{`)
+	if err := w.refresh(); err == nil {
+		t.Error("refreshed from an invalid document")
+	}
+	if port, _ := config.Current().Int("port"); port != 80 {
+		t.Errorf("port = %d after a failed refresh, want 80", port)
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// aliasKey makes a group an alias of the value at a dot separated path, eg.
+//
+//	"replica": {"$alias": "db.primary"}
+const aliasKey = "$alias"
+
+// MaxReferenceDepth limits how deeply references nest, eg. an interpolation
+// of a value which is itself an alias of another, or includes within
+// included files.
+var MaxReferenceDepth = 32
+
+var (
+	// ErrCycle is returned when a reference leads back to itself.
+	ErrCycle = errors.New("reference cycle")
+	// ErrReferenceDepth is returned when references nest deeper than
+	// MaxReferenceDepth.
+	ErrReferenceDepth = errors.New("references nested too deeply")
+	// ErrUndefined is returned when a reference is to a missing value.
+	ErrUndefined = errors.New("undefined reference")
+)
+
+// ReferenceError is a failure to resolve a reference, naming the chain of
+// references leading to it, outermost first.
+type ReferenceError struct {
+	Chain []string
+	Err   error
+}
+
+func (e *ReferenceError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err, strings.Join(e.Chain, " -> "))
+}
+
+func (e *ReferenceError) Unwrap() error {
+	return e.Err
+}
+
+// refChain is the chain of references being resolved, shared by includes,
+// interpolation, and aliases.
+type refChain []string
+
+// enter returns the chain extended by `ref`, failing when `ref` is already
+// being resolved, or the chain gets too long.
+func (c refChain) enter(ref string) (refChain, error) {
+	next := append(c[:len(c):len(c)], ref)
+	for _, r := range c {
+		if r == ref {
+			return nil, &ReferenceError{next, ErrCycle}
+		}
+	}
+	if MaxReferenceDepth > 0 && len(next) > MaxReferenceDepth {
+		return nil, &ReferenceError{next, ErrReferenceDepth}
+	}
+	return next, nil
+}
+
+// WithReferences resolves the references within the document, see
+// references. Otherwise strings such as `echo ${HOME}`, and `$alias` groups,
+// are kept as they are.
+func WithReferences() Option {
+	return func(o *options) { o.refs = true }
+}
+
+// references resolves the interpolations and aliases within `m`.
+//
+// Strings interpolate the values at dot separated paths via `${path}`, eg.
+// `"url": "postgres://${db.host}:${db.port}"`, and `$${` is a literal `${`.
+// A string of a single interpolation is replaced by the value, keeping its
+// type. Groups of `{"$alias": "path"}` are replaced by the value at path.
+// Paths are of the document, once included files are merged, or of computed
+// keys (see Provide), computed as the config is read. Referenced values are
+// resolved in turn, up to MaxReferenceDepth, and cycles are rejected.
+func references(m map[string]interface{}) (map[string]interface{}, error) {
+	if !hasReference(m) {
+		return m, nil
+	}
+	r := &refResolver{doc: m, done: make(map[string]refValue)}
+	v, err := r.resolve(m, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]interface{}), nil
+}
+
+func hasReference(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if _, ok := v[aliasKey]; ok {
+			return true
+		}
+		for _, val := range v {
+			if hasReference(val) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, val := range v {
+			if hasReference(val) {
+				return true
+			}
+		}
+	case string:
+		return strings.Contains(v, "${")
+	}
+	return false
+}
+
+type refResolver struct {
+	doc map[string]interface{}
+	// done holds the resolved values by path, and deepest the longest chain
+	// entered while resolving the current one.
+	done    map[string]refValue
+	deepest refChain
+}
+
+// refValue is a resolved value, along with the longest chain of references
+// from it.
+type refValue struct {
+	v     interface{}
+	chain refChain
+}
+
+// value returns the resolved value at `path`.
+func (r *refResolver) value(path string, chain refChain) (interface{}, error) {
+	return r.enter(path, chain, func(chain refChain) (interface{}, error) {
+		v, ok, err := r.lookup(path, chain)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, &ReferenceError{chain, ErrUndefined}
+		}
+		return r.resolve(v, path, chain)
+	})
+}
+
+// enter resolves the value at `path`, via `fn`, given the chain extended by
+// `path`. Values are resolved once; their chains are kept to limit the depth
+// of the references to them later on.
+func (r *refResolver) enter(path string, chain refChain, fn func(refChain) (interface{}, error)) (interface{}, error) {
+	if d, ok := r.done[path]; ok {
+		full := append(chain[:len(chain):len(chain)], d.chain...)
+		if MaxReferenceDepth > 0 && len(full) > MaxReferenceDepth {
+			return nil, &ReferenceError{full, ErrReferenceDepth}
+		}
+		if len(full) > len(r.deepest) {
+			r.deepest = full
+		}
+		return d.v, nil
+	}
+	next, err := chain.enter(path)
+	if err != nil {
+		return nil, err
+	}
+	outer := r.deepest
+	r.deepest = next
+	v, err := fn(next)
+	if err != nil {
+		return nil, err
+	}
+	r.done[path] = refValue{v, r.deepest[len(chain):]}
+	if len(outer) > len(r.deepest) {
+		r.deepest = outer
+	}
+	return v, nil
+}
+
+// lookup returns the unresolved value at `path`, resolving the aliases along
+// the way.
+func (r *refResolver) lookup(path string, chain refChain) (interface{}, bool, error) {
+	var v interface{} = r.doc
+	keys := strings.Split(path, ".")
+	for i, key := range keys {
+		if g, ok := v.(map[string]interface{}); ok && g[aliasKey] != nil && i > 0 {
+			var err error
+			if v, err = r.value(strings.Join(keys[:i], "."), chain); err != nil {
+				return nil, false, err
+			}
+		}
+		switch g := v.(type) {
+		case map[string]interface{}:
+			val, ok := g[key]
+			if !ok {
+				return provided(path)
+			}
+			v = val
+		case []interface{}:
+			n, err := strconv.Atoi(key)
+			if err != nil || n < 0 || n >= len(g) {
+				return nil, false, nil
+			}
+			v = g[n]
+		default:
+			return provided(path)
+		}
+	}
+	return v, true, nil
+}
+
+// provided returns the value computed for `path`, see Provide.
+func provided(path string) (interface{}, bool, error) {
+	providersMu.RLock()
+	fn := providers[path]
+	providersMu.RUnlock()
+	if fn == nil {
+		return nil, false, nil
+	}
+	v := fn()
+	return v, v != nil, nil
+}
+
+// resolve returns a copy of `v`, found at `path`, with its references
+// resolved.
+func (r *refResolver) resolve(v interface{}, path string, chain refChain) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if target, ok := v[aliasKey]; ok {
+			s, ok := target.(string)
+			if !ok || s == "" || len(v) > 1 {
+				return nil, &ReferenceError{append(chain[:len(chain):len(chain)], path),
+					fmt.Errorf("%s must only hold a path", aliasKey)}
+			}
+			return r.value(s, chain)
+		}
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			var err error
+			if m[key], err = r.child(val, join(path, key), chain); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			var err error
+			if l[i], err = r.child(val, join(path, strconv.Itoa(i)), chain); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	case string:
+		return r.interpolate(v, chain)
+	}
+	return v, nil
+}
+
+// child resolves the value `v` at `path` within a group or list, entering it
+// when it holds references, so the chain names it.
+func (r *refResolver) child(v interface{}, path string, chain refChain) (interface{}, error) {
+	if !hasReference(v) {
+		return v, nil
+	}
+	return r.enter(path, chain, func(chain refChain) (interface{}, error) {
+		return r.resolve(v, path, chain)
+	})
+}
+
+// interpolate replaces the `${path}` within `s` by the values.
+func (r *refResolver) interpolate(s string, chain refChain) (interface{}, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	if strings.HasPrefix(s, "${") && strings.IndexByte(s, '}') == len(s)-1 {
+		return r.value(s[2:len(s)-1], chain)
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		j := strings.IndexByte(s[i:], '}')
+		if j < 0 {
+			return nil, &ReferenceError{chain, fmt.Errorf("unterminated interpolation %q", s[i:])}
+		}
+		v, err := r.value(s[i+2:i+j], chain)
+		if err != nil {
+			return nil, err
+		}
+		str, err := CoerceString(v)
+		if err != nil {
+			return nil, &ReferenceError{append(chain[:len(chain):len(chain)], s[i+2:i+j]),
+				errors.New("can't interpolate a group or list")}
+		}
+		b.WriteString(s[:i])
+		b.WriteString(str)
+		s = s[i+j+1:]
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	Provide("runtime.region", func() interface{} { return "eu" })
+	defer Provide("runtime.region", nil)
+	c, err := ReadFrom([]byte(`{
+		"db": {"host": "db.${runtime.region}", "port": 5432},
+		"url": "postgres://${db.host}:${db.port}/$${literal}",
+		"port": "${db.port}",
+		"replica": {"$alias": "db"},
+		"replicaHost": "${replica.host}"
+	}`), WithReferences())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url, _ := c.String("url"); url != "postgres://db.eu:5432/${literal}" {
+		t.Errorf("url = %q", url)
+	}
+	if port, _ := c.Int("port"); port != 5432 {
+		t.Errorf("port = %d, want the number 5432", port)
+	}
+	if host, _ := c.Group("replica").String("host"); host != "db.eu" {
+		t.Errorf("replica.host = %q, want the alias of db.host", host)
+	}
+	if host, _ := c.String("replicaHost"); host != "db.eu" {
+		t.Errorf("replicaHost = %q, want db.eu", host)
+	}
+
+	_, err = ReadFrom([]byte(`{"a": "${b}", "b": {"c": "${a}"}}`), WithReferences())
+	var re *ReferenceError
+	if !errors.Is(err, ErrCycle) || !errors.As(err, &re) {
+		t.Fatalf("cycle: error = %v, want ErrCycle", err)
+	}
+	if chain := strings.Join(re.Chain, " -> "); chain != "a -> b -> b.c -> a" && chain != "b -> b.c -> a -> b" {
+		t.Errorf("chain = %s", chain)
+	}
+	if _, err = ReadFrom([]byte(`{"a": "${missing}"}`), WithReferences()); !errors.Is(err, ErrUndefined) {
+		t.Errorf("undefined: error = %v, want ErrUndefined", err)
+	}
+	defer func(n int) { MaxReferenceDepth = n }(MaxReferenceDepth)
+	MaxReferenceDepth = 3
+	if _, err = ReadFrom([]byte(`{"a": "${b}", "b": "${c}", "c": "${d}", "d": 1}`), WithReferences()); !errors.Is(err, ErrReferenceDepth) {
+		t.Errorf("depth: error = %v, want ErrReferenceDepth", err)
+	}
+
+	// Without WithReferences, strings are kept as they are.
+	c, err = ReadFrom([]byte(`{"cmd": "echo ${HOME}", "password": "a${b"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd, _ := c.String("cmd"); cmd != "echo ${HOME}" {
+		t.Errorf("cmd = %q, want it kept", cmd)
+	}
+}
+
+func TestIncludeReferences(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.json": `{"db": {"$include": "db.json"}, "url": "${db.host}"}`,
+		"db.json":   `{"host": "db", "$include": "a.json"}`,
+		"a.json":    `{"$include": "db.json"}`,
+	}
+	for name, doc := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(doc), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refs := newOptions([]Option{WithReferences()})
+	_, err := readFile(filepath.Join(dir, "main.json"), refs)
+	var re *ReferenceError
+	if !errors.Is(err, ErrInclude) || !errors.Is(err, ErrCycle) || !errors.As(err, &re) {
+		t.Fatalf("error = %v, want an include cycle", err)
+	}
+	if len(re.Chain) != 4 || filepath.Base(re.Chain[3]) != "db.json" {
+		t.Errorf("chain = %v, want main, db, a, db", re.Chain)
+	}
+
+	// References within a file resolve to the values of the files it includes.
+	os.WriteFile(filepath.Join(dir, "db.json"), []byte(`{"host": "db"}`), 0600)
+	c, err := readFile(filepath.Join(dir, "main.json"), refs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url, _ := c.String("url"); url != "db" {
+		t.Errorf("url = %q, want the included db", url)
+	}
+}
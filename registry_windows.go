@@ -0,0 +1,190 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procRegEnumValue    = advapi32.NewProc("RegEnumValueW")
+	procExpandEnvString = kernel32.NewProc("ExpandEnvironmentStringsW")
+)
+
+var registryRoots = map[string]syscall.Handle{
+	"HKLM":                syscall.HKEY_LOCAL_MACHINE,
+	"HKEY_LOCAL_MACHINE":  syscall.HKEY_LOCAL_MACHINE,
+	"HKCU":                syscall.HKEY_CURRENT_USER,
+	"HKEY_CURRENT_USER":   syscall.HKEY_CURRENT_USER,
+	"HKCR":                syscall.HKEY_CLASSES_ROOT,
+	"HKEY_CLASSES_ROOT":   syscall.HKEY_CLASSES_ROOT,
+	"HKU":                 syscall.HKEY_USERS,
+	"HKEY_USERS":          syscall.HKEY_USERS,
+	"HKCC":                syscall.HKEY_CURRENT_CONFIG,
+	"HKEY_CURRENT_CONFIG": syscall.HKEY_CURRENT_CONFIG,
+}
+
+// ReadRegistry reads the registry subtree at `path`, eg. `HKLM\Software\MyApp`
+// or `HKLM\Software\Policies\MyApp` for settings delivered via Group Policy.
+// Values of the key become root level keys, and sub-keys become groups.
+//
+// String values are read as strings (REG_EXPAND_SZ is expanded), DWORD and
+// QWORD values as float64 (matching JSON numbers), REG_MULTI_SZ as a list of
+// strings, and REG_BINARY as []byte.
+func ReadRegistry(path string) (Config, error) {
+	var c Config
+	i := strings.IndexByte(path, '\\')
+	if i < 0 {
+		i = len(path)
+	}
+	root, ok := registryRoots[strings.ToUpper(path[:i])]
+	if !ok {
+		return c, fmt.Errorf("unknown registry root key in %s", path)
+	}
+	h := root
+	if sub := strings.Trim(path[i:], `\`); sub != "" {
+		p, err := syscall.UTF16PtrFromString(sub)
+		if err != nil {
+			return c, err
+		}
+		err = syscall.RegOpenKeyEx(root, p, 0, syscall.KEY_READ, &h)
+		if err != nil {
+			return c, fmt.Errorf("failed to open registry key %s: %s", path, err)
+		}
+		defer syscall.RegCloseKey(h)
+	}
+	m, err := readRegistryKey(h)
+	if err != nil {
+		return c, fmt.Errorf("failed to read registry key %s: %s", path, err)
+	}
+	c.m = m
+	return c, nil
+}
+
+func readRegistryKey(h syscall.Handle) (map[string]interface{}, error) {
+	var subKeys, maxSubKeyLen, values, maxNameLen, maxValueLen uint32
+	err := syscall.RegQueryInfoKey(h, nil, nil, nil, &subKeys, &maxSubKeyLen,
+		nil, &values, &maxNameLen, &maxValueLen, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]interface{}, subKeys+values)
+	name := make([]uint16, maxNameLen+1)
+	data := make([]byte, maxValueLen)
+	for i := uint32(0); i < values; i++ {
+		nameLen := uint32(len(name))
+		dataLen := uint32(len(data))
+		var typ uint32
+		var p *byte
+		if dataLen > 0 {
+			p = &data[0]
+		}
+		r, _, _ := procRegEnumValue.Call(uintptr(h), uintptr(i),
+			uintptr(unsafe.Pointer(&name[0])), uintptr(unsafe.Pointer(&nameLen)), 0,
+			uintptr(unsafe.Pointer(&typ)), uintptr(unsafe.Pointer(p)),
+			uintptr(unsafe.Pointer(&dataLen)))
+		if r != 0 {
+			return nil, syscall.Errno(r)
+		}
+		key := syscall.UTF16ToString(name[:nameLen])
+		if key == "" {
+			// The unnamed (default) value has no config key to map to.
+			continue
+		}
+		if v, ok := registryValue(typ, data[:dataLen]); ok {
+			m[key] = v
+		}
+	}
+
+	name = make([]uint16, maxSubKeyLen+1)
+	for i := uint32(0); i < subKeys; i++ {
+		nameLen := uint32(len(name))
+		err = syscall.RegEnumKeyEx(h, i, &name[0], &nameLen, nil, nil, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var sub syscall.Handle
+		err = syscall.RegOpenKeyEx(h, &name[0], 0, syscall.KEY_READ, &sub)
+		if err != nil {
+			return nil, err
+		}
+		group, err := readRegistryKey(sub)
+		syscall.RegCloseKey(sub)
+		if err != nil {
+			return nil, err
+		}
+		m[syscall.UTF16ToString(name[:nameLen])] = group
+	}
+	return m, nil
+}
+
+func registryValue(typ uint32, data []byte) (interface{}, bool) {
+	switch typ {
+	case syscall.REG_SZ:
+		return utf16String(data), true
+	case syscall.REG_EXPAND_SZ:
+		s := utf16String(data)
+		src, err := syscall.UTF16PtrFromString(s)
+		if err != nil {
+			return s, true
+		}
+		buf := make([]uint16, 1024)
+		for {
+			r, _, _ := procExpandEnvString.Call(uintptr(unsafe.Pointer(src)),
+				uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+			n := uint32(r)
+			if n == 0 {
+				return s, true
+			}
+			if n <= uint32(len(buf)) {
+				return syscall.UTF16ToString(buf[:n]), true
+			}
+			buf = make([]uint16, n)
+		}
+	case syscall.REG_MULTI_SZ:
+		var l []interface{}
+		for _, s := range strings.Split(utf16String(data), "\x00") {
+			if s != "" {
+				l = append(l, s)
+			}
+		}
+		return l, true
+	case syscall.REG_DWORD:
+		if len(data) >= 4 {
+			return float64(binary.LittleEndian.Uint32(data)), true
+		}
+	case syscall.REG_QWORD:
+		if len(data) >= 8 {
+			return float64(binary.LittleEndian.Uint64(data)), true
+		}
+	case syscall.REG_BINARY:
+		b := make([]byte, len(data))
+		copy(b, data)
+		return b, true
+	}
+	return nil, false
+}
+
+// utf16String decodes the little-endian UTF-16 data of a string value,
+// keeping embedded NULs (REG_MULTI_SZ) but dropping the trailing ones.
+func utf16String(data []byte) string {
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	for len(u) > 0 && u[len(u)-1] == 0 {
+		u = u[:len(u)-1]
+	}
+	return string(utf16.Decode(u))
+}
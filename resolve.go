@@ -0,0 +1,407 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lease is a value resolved by a Resolver, valid for TTL, or indefinitely
+// when TTL is zero.
+type Lease struct {
+	Value interface{}
+	TTL   time.Duration
+}
+
+// Resolver resolves the references of a scheme, see RegisterResolver.
+type Resolver interface {
+	Resolve(ctx context.Context, ref string) (Lease, error)
+}
+
+// ResolverFunc adapts a func to a Resolver.
+type ResolverFunc func(ctx context.Context, ref string) (Lease, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context, ref string) (Lease, error) {
+	return f(ctx, ref)
+}
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   = map[string]Resolver{}
+)
+
+// RegisterResolver registers `r` as resolving the references of `scheme`,
+// string values of the form `<scheme>:<ref>`, eg. `vault:secret/db#password`
+// is resolved by the `vault` resolver given `secret/db#password`. Strings of
+// unregistered schemes are left as is. A nil `r` removes the resolver.
+//
+// References are resolved by Config.Resolve, and by SecretsSource, which
+// also renews them as their leases expire.
+func RegisterResolver(scheme string, r Resolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	if r == nil {
+		delete(resolvers, scheme)
+		return
+	}
+	resolvers[scheme] = r
+}
+
+// ResolverSchemes returns the schemes of the registered resolvers, sorted.
+func ResolverSchemes() []string {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	schemes := make([]string, 0, len(resolvers))
+	for scheme := range resolvers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// reference returns the resolver, and the ref, of `v` when it's a reference.
+func reference(v interface{}) (Resolver, string, bool) {
+	s, _ := v.(string)
+	i := strings.IndexByte(s, ':')
+	if i <= 0 {
+		return nil, "", false
+	}
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	r := resolvers[s[:i]]
+	return r, s[i+1:], r != nil
+}
+
+// Resolve returns a copy of the config with every reference resolved, see
+// RegisterResolver. Leases aren't renewed, see SecretsSource for that.
+// Resolved strings are SecretStrings, and groups sensitive, so they're
+// redacted from dumps, diffs, and audit events, while saving the config
+// writes their references back, see SaveAs.
+func (c Config) Resolve(ctx context.Context) (Config, error) {
+	v, err := resolveVal(ctx, time.Now(), c.m, "", "", nil)
+	if err != nil {
+		return *new(Config), err
+	}
+	m, _ := v.(map[string]interface{})
+	return c.with(m), nil
+}
+
+// secretLease is a resolved reference within a config, by its JSON pointer.
+type secretLease struct {
+	pointer, path string
+	ref           string
+	value         interface{}
+	renew         time.Time
+	expires       time.Time
+	failures      int
+}
+
+func (l *secretLease) set(now time.Time, lease Lease) {
+	l.value, l.failures = secretVal(lease.Value, l.ref), 0
+	// Renew two thirds into the lease, leaving time to retry.
+	l.renew = now.Add(lease.TTL * 2 / 3)
+	l.expires = now.Add(lease.TTL)
+}
+
+// resolveVal returns a copy of `v` with its references resolved, adding
+// those whose lease expires to `leases` when not nil.
+func resolveVal(ctx context.Context, now time.Time, v interface{}, pointer, path string, leases *[]*secretLease) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			p := pointer + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+			r, err := resolveVal(ctx, now, val, p, join(path, key), leases)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = r
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			r, err := resolveVal(ctx, now, val, pointer+"/"+strconv.Itoa(i), join(path, strconv.Itoa(i)), leases)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = r
+		}
+		return l, nil
+	}
+	r, ref, ok := reference(v)
+	if !ok {
+		return v, nil
+	}
+	lease, err := r.Resolve(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve '%s': %w", path, err)
+	}
+	if leases != nil && lease.TTL > 0 {
+		l := &secretLease{pointer: pointer, path: path, ref: v.(string)}
+		l.set(now, lease)
+		*leases = append(*leases, l)
+	}
+	return secretVal(lease.Value, v.(string)), nil
+}
+
+// secretVal returns the value `v`, resolved from the reference `ref`, kept
+// out of dumps, diffs, and audit events: strings as a SecretString, and groups
+// marked sensitive. Both keep the reference, which is saved in their place,
+// see SaveAs.
+func secretVal(v interface{}, ref string) interface{} {
+	switch v := v.(type) {
+	case string:
+		s := NewSecretString(v)
+		s.ref = ref
+		return s
+	case map[string]interface{}:
+		s := sensitized(v)
+		s[sensitiveKey] = sensitiveRef(ref)
+		return s
+	}
+	return v
+}
+
+// DefaultResolveTimeout bounds resolving references, see
+// SecretsSource.Timeout.
+var DefaultResolveTimeout = 30 * time.Second
+
+// SecretsSource resolves the references within the values of another source,
+// and can renew them as their leases expire, see Renew.
+type SecretsSource struct {
+	Source Source
+	// OnRenew, when set, is called with the dot separated path, and values,
+	// of every reference whose value changed once renewed, eg. so connection
+	// pools can rotate their credentials. Strings are SecretStrings.
+	OnRenew func(path string, old, new interface{})
+	// Timeout bounds resolving the references on Read, and each renewal,
+	// DefaultResolveTimeout when zero.
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	leases []*secretLease
+	read   chan struct{}
+}
+
+// Secrets returns a source resolving the references within the values read
+// from `s`, see RegisterResolver, eg.
+//
+//	s := config.Secrets(config.File("config.json"))
+//	a, err := config.Open(s)
+//	...
+//	go s.Renew(ctx, a)
+func Secrets(s Source) *SecretsSource {
+	return &SecretsSource{Source: s}
+}
+
+func (s *SecretsSource) Read() (Config, error) {
+	c, err := s.Source.Read()
+	if err != nil {
+		return c, err
+	}
+	var leases []*secretLease
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+	v, err := resolveVal(ctx, time.Now(), c.m, "", "", &leases)
+	if err != nil {
+		return *new(Config), err
+	}
+	s.mu.Lock()
+	s.leases = leases
+	if s.read == nil {
+		s.read = make(chan struct{}, 1)
+	}
+	s.mu.Unlock()
+	// Wake Renew, the leases changed.
+	select {
+	case s.read <- struct{}{}:
+	default:
+	}
+	m, _ := v.(map[string]interface{})
+	return c.with(m), nil
+}
+
+func (s *SecretsSource) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return DefaultResolveTimeout
+}
+
+func (s *SecretsSource) Name() string {
+	return "secrets " + sourceName(s.Source)
+}
+
+// Expires returns when the first of the leases last read expires, the zero
+// time when none do.
+func (s *SecretsSource) Expires() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var t time.Time
+	for _, l := range s.leases {
+		if t.IsZero() || l.expires.Before(t) {
+			t = l.expires
+		}
+	}
+	return t
+}
+
+// Renew re-resolves the references last read as their leases near expiry,
+// and replaces their values within the current config, as changed by `a`
+// when an Admin owns it (see Open), until `ctx` is done. Values changed since
+// they were resolved, eg. by SetConfig, are left alone. Failures are logged,
+// and retried per DefaultRetryPolicy.
+func (s *SecretsSource) Renew(ctx context.Context, a *Admin) error {
+	s.mu.Lock()
+	if s.read == nil {
+		s.read = make(chan struct{}, 1)
+	}
+	read := s.read
+	s.mu.Unlock()
+	for {
+		var next <-chan time.Time
+		var timer *time.Timer
+		if t, ok := s.next(); ok {
+			timer = time.NewTimer(time.Until(t))
+			next = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-read:
+		case <-next:
+			s.renew(ctx, a)
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// next returns when the first lease is due for renewal.
+func (s *SecretsSource) next() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var t time.Time
+	for _, l := range s.leases {
+		if t.IsZero() || l.renew.Before(t) {
+			t = l.renew
+		}
+	}
+	return t, !t.IsZero()
+}
+
+type renewal struct {
+	l     *secretLease
+	lease Lease
+}
+
+func (s *SecretsSource) renew(ctx context.Context, a *Admin) {
+	now := time.Now()
+	s.mu.Lock()
+	var due []*secretLease
+	for _, l := range s.leases {
+		if !l.renew.After(now) {
+			due = append(due, l)
+		}
+	}
+	s.mu.Unlock()
+
+	var renewals []renewal
+	for _, l := range due {
+		lease, err := s.resolve(ctx, l)
+		if err != nil {
+			s.mu.Lock()
+			l.failures++
+			l.renew = now.Add(DefaultRetryPolicy.Delay(l.failures))
+			s.mu.Unlock()
+			log.Printf("config: failed to renew '%s': %s", l.path, err)
+			continue
+		}
+		renewals = append(renewals, renewal{l, lease})
+	}
+	if len(renewals) == 0 {
+		return
+	}
+
+	// The leases of values changed meanwhile are dropped.
+	stale := make(map[*secretLease]bool)
+	err := update(a, "renew", func() error {
+		replace := func(m map[string]interface{}) map[string]interface{} {
+			doc := copyVal(m)
+			for _, r := range renewals {
+				v, err := pointerGet(doc, r.l.pointer)
+				if err != nil || !reflect.DeepEqual(v, r.l.value) {
+					stale[r.l] = true
+					continue
+				}
+				doc, _ = pointerSet(doc, r.l.pointer, secretVal(r.lease.Value, r.l.ref), false)
+			}
+			m, _ = doc.(map[string]interface{})
+			return m
+		}
+		if a != nil {
+			a.base = replace(a.base)
+			cfg.m = a.overridden()
+		} else {
+			cfg.m = replace(cfg.m)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("config: failed to renew secrets: %s", err)
+		return
+	}
+
+	s.mu.Lock()
+	var changed []renewal
+	for _, r := range renewals {
+		if stale[r.l] {
+			continue
+		}
+		if v := secretVal(r.lease.Value, r.l.ref); !reflect.DeepEqual(r.l.value, v) {
+			changed = append(changed, renewal{&secretLease{path: r.l.path, value: r.l.value}, Lease{Value: v}})
+		}
+		r.l.set(now, r.lease)
+		if r.lease.TTL <= 0 {
+			// The value no longer expires.
+			stale[r.l] = true
+		}
+	}
+	leases := s.leases[:0]
+	for _, l := range s.leases {
+		if !stale[l] {
+			leases = append(leases, l)
+		}
+	}
+	s.leases = leases
+	s.mu.Unlock()
+	if s.OnRenew != nil {
+		for _, r := range changed {
+			s.OnRenew(r.l.path, r.l.value, r.lease.Value)
+		}
+	}
+}
+
+func (s *SecretsSource) resolve(ctx context.Context, l *secretLease) (Lease, error) {
+	r, ref, ok := reference(l.ref)
+	if !ok {
+		return Lease{}, fmt.Errorf("no resolver registered for %s", l.ref[:strings.IndexByte(l.ref, ':')])
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+	return r.Resolve(ctx, ref)
+}
@@ -0,0 +1,99 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mapSource is a Source of the values of the map.
+type mapSource map[string]interface{}
+
+func (s mapSource) Read() (Config, error) {
+	return FromMap(s), nil
+}
+
+func TestResolvedSecrets(t *testing.T) {
+	RegisterResolver("test", ResolverFunc(func(ctx context.Context, ref string) (Lease, error) {
+		if ref == "slow" {
+			<-ctx.Done()
+			return Lease{}, ctx.Err()
+		}
+		return Lease{Value: "hunter2"}, nil
+	}))
+	defer RegisterResolver("test", nil)
+
+	before := FromMap(map[string]interface{}{"db": map[string]interface{}{"password": "test:db"}})
+	c, err := before.Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := c.Group("db").String("password"); p != "hunter2" {
+		t.Errorf("password = %q, want hunter2", p)
+	}
+	if !c.IsSensitive("db.password") {
+		t.Error("resolved password isn't sensitive")
+	}
+	var b bytes.Buffer
+	c.Dump(&b)
+	if strings.Contains(b.String(), "hunter2") {
+		t.Errorf("dump reveals the password: %s", b.String())
+	}
+	for _, ch := range Diff(before, c) {
+		if ch.New != Redacted {
+			t.Errorf("diff of %s = %v, want %s", ch.Path, ch.New, Redacted)
+		}
+	}
+
+	s := Secrets(mapSource{"password": "test:slow"})
+	s.Timeout = 10 * time.Millisecond
+	if _, err := s.Read(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slow resolver: error = %v, want a timeout", err)
+	}
+}
+
+func TestSaveResolvedSecrets(t *testing.T) {
+	RegisterResolver("test", ResolverFunc(func(ctx context.Context, ref string) (Lease, error) {
+		if ref == "db" {
+			return Lease{Value: map[string]interface{}{"user": "app", "password": "hunter2"}}, nil
+		}
+		return Lease{Value: "s3cret"}, nil
+	}))
+	defer RegisterResolver("test", nil)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	doc := `{"port": 9090, "token": "test:token", "db": "test:db"}`
+	os.WriteFile(path, []byte(doc), 0600)
+	c, err := NewChain(Secrets(File(path))).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := strings.Join(c.ExportEnv("APP_"), " "); !strings.Contains(env, "APP_TOKEN=s3cret") || !strings.Contains(env, "APP_DB_PASSWORD=hunter2") {
+		t.Errorf("exported %s, want the resolved values", env)
+	}
+	if c, err = c.Patch([]byte(`{"port": 80}`), MergePatch); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	saved, err := ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := saved.String("token")
+	db, _ := saved.String("db")
+	if port, _ := saved.Int("port"); port != 80 || token != "test:token" || db != "test:db" {
+		t.Errorf("saved %s, want the references kept", b)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a Breaker rejects a call.
+var ErrCircuitOpen = errors.New("config source circuit breaker is open")
+
+// DefaultRetryPolicy is used by remote sources without their own policy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     200 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+	Timeout:     10 * time.Second,
+	Jitter:      0.2,
+}
+
+// RetryPolicy controls how often, and how fast, a remote source is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts per operation, one when zero.
+	MaxAttempts int
+	// Backoff is the delay after the first failed attempt, and is doubled
+	// after every attempt, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Timeout bounds each attempt, no timeout when zero.
+	Timeout time.Duration
+	// Jitter randomizes each delay by up to the given fraction (0-1), so a
+	// fleet doesn't retry in lock-step.
+	Jitter float64
+}
+
+// Delay returns the delay following the failed `attempt` (starting at 1).
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && d > 0; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// Do calls `fn` until it succeeds, the attempts run out, or `ctx` is done.
+// Each attempt gets its own context, bounded by the policy's Timeout.
+func (p RetryPolicy) Do(ctx context.Context, fn func(context.Context) error) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for i := 1; i <= attempts; i++ {
+		actx, cancel := ctx, context.CancelFunc(func() {})
+		if p.Timeout > 0 {
+			actx, cancel = context.WithTimeout(ctx, p.Timeout)
+		}
+		err = fn(actx)
+		cancel()
+		if err == nil || i == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.Delay(i)):
+		}
+	}
+	return err
+}
+
+// Breaker is a circuit breaker for refresh loops. After Threshold consecutive
+// failures it opens, rejecting calls for Cooldown, after which a single trial
+// call is let through; success closes the breaker, failure re-opens it.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewBreaker returns a breaker opening after `threshold` consecutive failures.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+// Open returns whether the breaker is currently rejecting calls.
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open()
+}
+
+func (b *Breaker) open() bool {
+	return b.Threshold > 0 && b.failures >= b.Threshold &&
+		(b.trial || time.Since(b.openedAt) < b.Cooldown)
+}
+
+// Do calls `fn` unless the breaker is open, recording its outcome.
+func (b *Breaker) Do(fn func() error) error {
+	if b == nil {
+		return fn()
+	}
+	b.mu.Lock()
+	if b.open() {
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	}
+	b.trial = b.Threshold > 0 && b.failures >= b.Threshold
+	b.mu.Unlock()
+
+	err := fn()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if err == nil {
+		b.failures = 0
+		return nil
+	}
+	b.failures++
+	if b.Threshold > 0 && b.failures >= b.Threshold {
+		b.openedAt = time.Now()
+	}
+	return err
+}
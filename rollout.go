@@ -0,0 +1,97 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Percent returns the percentage, from 0 to 100, for the `key` within the
+// root level. Besides numbers, strings like "12.5%" are accepted.
+func (c Config) Percent(key string) (float64, error) {
+	v, ok := c.Val(key)
+	if !ok {
+		return 0, fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	var p float64
+	switch v := v.(type) {
+	case float64:
+		p = v
+	case int:
+		p = float64(v)
+	case json.Number:
+		var err error
+		if p, err = v.Float64(); err != nil {
+			return 0, fmt.Errorf("invalid '%s' percentage %v", key, v)
+		}
+	case string:
+		var err error
+		if p, err = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "%"), 64); err != nil {
+			return 0, fmt.Errorf("invalid '%s' percentage %q", key, v)
+		}
+	default:
+		return 0, fmt.Errorf("invalid '%s' percentage %v", key, v)
+	}
+	if !(p >= 0 && p <= 100) {
+		return 0, fmt.Errorf("invalid '%s' percentage %v, must be from 0 to 100", key, p)
+	}
+	return p, nil
+}
+
+// RequiredPercent returns the percentage, within the root, and exits when not
+// found or invalid.
+func (c Config) RequiredPercent(key string) float64 {
+	p, err := c.Percent(key)
+	if err != nil {
+		log.Fatalf("failed to retrieve '%s' percentage from config: %s", key, err)
+	}
+	return p
+}
+
+// RolloutEnabled returns whether `stableID` (eg. a user or account ID) falls
+// within the percentage for the `key`, see Percent. The ID is hashed along
+// with the key, so each ID gets the same answer for a key every time, and
+// raising the percentage only ever adds IDs. A missing, or invalid,
+// percentage is 0.
+func (c Config) RolloutEnabled(key, stableID string) bool {
+	p, err := c.Percent(key)
+	if err != nil || p <= 0 {
+		return false
+	}
+	return bucket(key, stableID) < p*100
+}
+
+// bucket hashes `id` for `key` into one of 10000 buckets, so percentages have
+// a resolution of 0.01%.
+func bucket(key, id string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(id))
+	return float64(h.Sum64() % 10000)
+}
+
+// Percent returns the percentage for the `key` within the root level, see
+// Config.Percent.
+func Percent(key string) (float64, error) {
+	return cfg.Percent(key)
+}
+
+// RequiredPercent returns the percentage, within the root, and exits when not
+// found or invalid.
+func RequiredPercent(key string) float64 {
+	return cfg.RequiredPercent(key)
+}
+
+// RolloutEnabled returns whether `stableID` falls within the percentage for
+// the `key`, see Config.RolloutEnabled.
+func RolloutEnabled(key, stableID string) bool {
+	return cfg.RolloutEnabled(key, stableID)
+}
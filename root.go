@@ -0,0 +1,79 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import "encoding/json"
+
+// Kind is the kind of a JSON value.
+type Kind int
+
+const (
+	InvalidKind Kind = iota
+	ObjectKind
+	ArrayKind
+	StringKind
+	NumberKind
+	BoolKind
+	NullKind
+)
+
+var kindNames = []string{
+	InvalidKind: "invalid",
+	ObjectKind:  "object",
+	ArrayKind:   "array",
+	StringKind:  "string",
+	NumberKind:  "number",
+	BoolKind:    "bool",
+	NullKind:    "null",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return kindNames[InvalidKind]
+	}
+	return kindNames[k]
+}
+
+func kindOf(v interface{}) Kind {
+	switch v.(type) {
+	case map[string]interface{}:
+		return ObjectKind
+	case []interface{}:
+		return ArrayKind
+	case string:
+		return StringKind
+	case float64, int, json.Number:
+		return NumberKind
+	case bool:
+		return BoolKind
+	case nil:
+		return NullKind
+	}
+	return InvalidKind
+}
+
+// RootError is returned when the root of a document isn't an object, and so
+// has no keys to look up. The document is still available via Root.
+type RootError struct {
+	Kind Kind
+}
+
+func (e *RootError) Error() string {
+	return "config root is " + e.Kind.String() + ", not an object"
+}
+
+// Root returns the root of the document, along with its kind. For documents
+// whose root is an object, that's the map of all values.
+func (c Config) Root() (interface{}, Kind) {
+	if c.m != nil {
+		return c.m, ObjectKind
+	}
+	return c.root, c.kind
+}
+
+// Root returns the root of the current document, see Config.Root.
+func Root() (interface{}, Kind) {
+	return cfg.Root()
+}
@@ -0,0 +1,128 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// RotateFunc rotates to the `new` value of a secret, from `old`, eg. by
+// reconnecting a connection pool with the new credentials.
+type RotateFunc func(old, new string) error
+
+type rotator struct {
+	path string
+	fn   RotateFunc
+}
+
+type rotation struct {
+	r        *rotator
+	old, new string
+}
+
+var (
+	rotatorsMu sync.Mutex
+	rotators   []*rotator
+	// rotations are queued to the rotation goroutine, in the order of the
+	// changes, and signaled by rotating.
+	rotations []rotation
+	rotating  chan struct{}
+)
+
+// OnRotate registers `fn` to be called whenever the value at `path`, a dot
+// separated path of groups and a key (eg. `db.password`), changes within the
+// current config, eg. once a SecretsSource renews it, or the config is
+// reloaded, until `remove` is called. Values are passed as strings, empty when
+// missing. An error is retried per DefaultRetryPolicy until it gives up, and
+// logged.
+//
+// Rotations are made by a single goroutine, in the order of the changes, and
+// for each change, in the order the funcs were registered, so a change is
+// only rotated to once the ones before it were.
+func OnRotate(path string, fn RotateFunc) (remove func()) {
+	rotatorsMu.Lock()
+	defer rotatorsMu.Unlock()
+	r := &rotator{path, fn}
+	rotators = append(rotators, r)
+	if rotating == nil {
+		rotating = make(chan struct{}, 1)
+		go rotate()
+	}
+	return func() {
+		rotatorsMu.Lock()
+		defer rotatorsMu.Unlock()
+		for i, v := range rotators {
+			if v == r {
+				rotators = append(rotators[:i:i], rotators[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// queueRotations queues the rotations of the values changed from `before` to
+// `after`.
+func queueRotations(before, after Config) {
+	rotatorsMu.Lock()
+	defer rotatorsMu.Unlock()
+	// Rotators are given secrets, whether or not RequireAllowSensitive is set.
+	before, after = before.AllowSensitive(), after.AllowSensitive()
+	queued := false
+	for _, r := range rotators {
+		old, _ := valueAt(before, r.path)
+		v, _ := valueAt(after, r.path)
+		if reflect.DeepEqual(old, v) {
+			continue
+		}
+		rotations = append(rotations, rotation{r, rotateString(old), rotateString(v)})
+		queued = true
+	}
+	if queued {
+		select {
+		case rotating <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// rotate makes the queued rotations, forever.
+func rotate() {
+	for range rotating {
+		for {
+			rotatorsMu.Lock()
+			if len(rotations) == 0 {
+				rotatorsMu.Unlock()
+				break
+			}
+			r := rotations[0]
+			rotations = rotations[1:]
+			rotatorsMu.Unlock()
+			err := DefaultRetryPolicy.Do(context.Background(), func(context.Context) error {
+				return r.r.fn(r.old, r.new)
+			})
+			if err != nil {
+				log.Printf("config: failed to rotate '%s': %s", r.r.path, err)
+			}
+		}
+	}
+}
+
+// valueAt returns the value at the dot separated `path` within `c`.
+func valueAt(c Config, path string) (interface{}, bool) {
+	l := strings.Split(path, ".")
+	for _, g := range l[:len(l)-1] {
+		c = c.Group(g)
+	}
+	return c.Val(l[len(l)-1])
+}
+
+func rotateString(v interface{}) string {
+	s, _ := CoerceString(v)
+	return s
+}
@@ -0,0 +1,36 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRotateSensitive(t *testing.T) {
+	defer func(b bool) { RequireAllowSensitive = b }(RequireAllowSensitive)
+	RequireAllowSensitive = true
+	rotated := make(chan [2]string, 1)
+	remove := OnRotate("db.password", func(old, new string) error {
+		rotated <- [2]string{old, new}
+		return nil
+	})
+	defer remove()
+
+	db := func(password string) Config {
+		return FromMap(map[string]interface{}{
+			"db": map[string]interface{}{sensitiveKey: true, "password": password},
+		})
+	}
+	queueRotations(db("a"), db("b"))
+	select {
+	case r := <-rotated:
+		if r != [2]string{"a", "b"} {
+			t.Errorf("rotated %q, want from a to b", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sensitive value not rotated")
+	}
+}
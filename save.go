@@ -0,0 +1,161 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// WithBackup keeps the previous version of a saved file, as `<file>.bak`.
+func WithBackup() Option {
+	return func(o *options) { o.backup = true }
+}
+
+// SaveAs writes the config to `path` as indented JSON.
+//
+// The write is crash-safe: the config is written to a temp file within the
+// same directory, synced, and renamed over `path`, so readers only ever see
+// the previous, or the new, config in full. The file keeps the permissions of
+// the file it replaces, as does its formatting: the key order, indentation,
+// and comments of unchanged values are kept, while new keys are appended to
+// their group. With WithBackup, the previous file is kept as
+// `<path>.bak`.
+//
+// Values resolved from references (see Resolve) are saved as the references,
+// rather than their values. Other secrets (see SecretString) aren't saved, as
+// they'd be written as Redacted over the values they were read from, and fail
+// the save.
+func (c Config) SaveAs(path string, opts ...Option) error {
+	v, err := saved(c.m, "")
+	if err != nil {
+		return err
+	}
+	m, _ := v.(map[string]interface{})
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+		prev, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if newOptions(opts).backup {
+			if err = writeFile(path+".bak", prev, mode); err != nil {
+				return err
+			}
+		}
+		// Keep the formatting of the file, when it can be followed.
+		if p, err := preserve(prev, m); err == nil {
+			b = p
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return writeFile(path, b, mode)
+}
+
+// saved returns a copy of `v`, at the dot separated `path`, as it's saved:
+// with the references of resolved values in their place, failing on other
+// secrets.
+func saved(v interface{}, path string) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return nil, nil
+		}
+		if ref, ok := v[sensitiveKey].(sensitiveRef); ok {
+			return string(ref), nil
+		}
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			s, err := saved(val, join(path, key))
+			if err != nil {
+				return nil, err
+			}
+			m[key] = s
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			s, err := saved(val, join(path, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			l[i] = s
+		}
+		return l, nil
+	case *SecretString:
+		if v.ref != "" {
+			return v.ref, nil
+		}
+		return nil, fmt.Errorf("config: '%s' is a secret, which isn't saved", path)
+	}
+	return v, nil
+}
+
+// writeFile atomically replaces `path` with `data`, see SaveAs.
+func writeFile(path string, data []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// Sync the directory, so the rename itself survives a crash. Not every
+	// platform supports syncing directories, so failures are ignored.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// Save writes the current config over the config file (see ConfigFile), found
+// as it is by Read, or within the CWD when there isn't one yet. See
+// Config.SaveAs.
+func Save(opts ...Option) error {
+	f, err := findFile()
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	return SaveAs(f, opts...)
+}
+
+// SaveAs writes the current config to `path`, see Config.SaveAs. Sensitive
+// groups are saved too, whether or not RequireAllowSensitive hides them.
+func SaveAs(path string, opts ...Option) error {
+	cfg.mu.Lock()
+	c := cfg.with(cfg.m)
+	cfg.mu.Unlock()
+	return c.SaveAs(path, opts...)
+}
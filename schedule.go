@@ -0,0 +1,264 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression, see ParseSchedule.
+type CronSchedule struct {
+	spec  string
+	every time.Duration
+	// fields are bit sets of the minutes, hours, days of the month, months,
+	// and days of the week.
+	fields [5]uint64
+	// domAny and dowAny are set when the day fields are `*`.
+	domAny, dowAny bool
+}
+
+type cronField struct {
+	min, max int
+	names    []string
+}
+
+var cronFields = [5]cronField{
+	{0, 59, nil},
+	{0, 23, nil},
+	{1, 31, nil},
+	{1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSchedule parses the standard five field cron expression `spec`
+// (minute, hour, day of month, month, day of week), supporting lists, ranges,
+// steps, and month and weekday names, eg. `*/15 9-17 * * mon-fri`. The macros
+// @yearly, @monthly, @weekly, @daily, and @hourly are supported, as is
+// `@every <duration>`, eg. `@every 90s`.
+//
+// As with cron, when both the day of month and day of week are restricted,
+// either matching is enough.
+func ParseSchedule(spec string) (CronSchedule, error) {
+	s := CronSchedule{spec: spec}
+	expr := strings.TrimSpace(spec)
+	if d := strings.TrimPrefix(expr, "@every "); d != expr {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || every <= 0 {
+			return CronSchedule{}, fmt.Errorf("invalid schedule %q: bad @every duration", spec)
+		}
+		s.every = every
+		return s, nil
+	}
+	if m, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = m
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return CronSchedule{}, fmt.Errorf("invalid schedule %q: expected %d fields, found %d", spec, len(cronFields), len(fields))
+	}
+	for i, f := range fields {
+		bits, err := cronFields[i].parse(f)
+		if err != nil {
+			return CronSchedule{}, fmt.Errorf("invalid schedule %q: %s", spec, err)
+		}
+		s.fields[i] = bits
+	}
+	// Sunday may be either 0 or 7.
+	if s.fields[4]&(1<<7) != 0 {
+		s.fields[4] = s.fields[4]&^(1<<7) | 1
+	}
+	s.domAny, s.dowAny = fields[2] == "*", fields[4] == "*"
+	return s, nil
+}
+
+func (f cronField) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		lo, hi := f.min, f.max
+		switch i := strings.IndexByte(rng, '-'); {
+		case rng == "*":
+		case i >= 0:
+			var err error
+			if lo, err = f.value(rng[:i]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(rng[i+1:]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("bad range %q", rng)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			// A single value with a step runs to the maximum, eg `5/15`.
+			if step == 1 {
+				hi = lo
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return i + f.min, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %q out of range %d-%d", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (s CronSchedule) String() string {
+	return s.spec
+}
+
+// UnmarshalText parses the schedule, so schedules can be bound, see Bind.
+func (s *CronSchedule) UnmarshalText(b []byte) error {
+	v, err := ParseSchedule(string(b))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// MarshalText returns the schedule's expression.
+func (s CronSchedule) MarshalText() ([]byte, error) {
+	return []byte(s.spec), nil
+}
+
+// Next returns the first time the schedule runs after `t`, within the
+// location of `t`, or the zero time when it never does (eg. `0 0 30 2 *`).
+func (s CronSchedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	if s.fields[0] == 0 {
+		return time.Time{}
+	}
+	loc := t.Location()
+	t = t.Truncate(time.Second).Add(time.Minute - time.Duration(t.Second())*time.Second)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !has(s.fields[3], int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !has(s.fields[1], t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !has(s.fields[0], t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s CronSchedule) matchDay(t time.Time) bool {
+	dom, dow := has(s.fields[2], t.Day()), has(s.fields[4], int(t.Weekday()))
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// Schedule returns the parsed cron schedule for the `key` within the root
+// level, see ParseSchedule.
+func (c Config) Schedule(key string) (CronSchedule, error) {
+	s, ok := c.String(key)
+	if !ok {
+		return CronSchedule{}, fmt.Errorf("'%s': %w", key, ErrNotFound)
+	}
+	return ParseSchedule(s)
+}
+
+// RequiredSchedule returns the schedule, within the root, and exits when not
+// found or invalid.
+func (c Config) RequiredSchedule(key string) CronSchedule {
+	s, err := c.Schedule(key)
+	if err != nil {
+		log.Fatalf("failed to retrieve '%s' schedule from config: %s", key, err)
+	}
+	return s
+}
+
+// ValidateSchedules checks the schedules at `paths`, dot separated paths of
+// groups and a key (eg. `jobs.cleanup.schedule`), within the current config,
+// and then within every candidate config (see OnValidate) until `remove` is
+// called, so configs holding invalid schedules fail to load, rather than once
+// they're scheduled. Missing keys are skipped. When the current config fails,
+// its error is returned, and the schedules aren't validated.
+func ValidateSchedules(paths ...string) (remove func(), err error) {
+	check := func(c Config) error {
+		for _, path := range paths {
+			v, ok := valueAt(c, path)
+			if !ok {
+				continue
+			}
+			s, ok := stringVal(v, ok)
+			if !ok {
+				return fmt.Errorf("'%s': schedule isn't a string", path)
+			}
+			if _, err := ParseSchedule(s); err != nil {
+				return fmt.Errorf("'%s': %w", path, err)
+			}
+		}
+		return nil
+	}
+	if err := check(Current()); err != nil {
+		return nil, err
+	}
+	return OnValidate(check), nil
+}
+
+// Schedule returns the parsed cron schedule for the `key` within the root
+// level, see ParseSchedule.
+func Schedule(key string) (CronSchedule, error) {
+	return cfg.Schedule(key)
+}
+
+// RequiredSchedule returns the schedule, within the root, and exits when not
+// found or invalid.
+func RequiredSchedule(key string) CronSchedule {
+	return cfg.RequiredSchedule(key)
+}
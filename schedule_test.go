@@ -0,0 +1,54 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	c := FromMap(map[string]interface{}{"hourly": "@hourly", "often": "@every 90s", "weekdays": "*/15 9-17 * * mon-fri", "bad": "61 * * * *"})
+	from := time.Date(2026, 10, 16, 17, 50, 0, 0, time.UTC) // a Friday
+	for key, want := range map[string]time.Time{
+		"hourly":   time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC),
+		"often":    from.Add(90 * time.Second),
+		"weekdays": time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+	} {
+		s, err := c.Schedule(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := s.Next(from); !next.Equal(want) {
+			t.Errorf("%s: next %v, want %v", key, next, want)
+		}
+	}
+	if _, err := c.Schedule("bad"); err == nil {
+		t.Error("parsed a schedule of minute 61")
+	}
+}
+
+func TestValidateSchedules(t *testing.T) {
+	cfg.mu.Lock()
+	prev := cfg.m
+	cfg.mu.Unlock()
+	defer SetConfig(prev)
+
+	SetConfig(map[string]interface{}{"jobs": map[string]interface{}{"cleanup": "@daily"}})
+	remove, err := ValidateSchedules("jobs.cleanup", "jobs.missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remove()
+	err = TrySetConfig(map[string]interface{}{"jobs": map[string]interface{}{"cleanup": "@fortnightly"}})
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Errorf("setting an invalid schedule: %v, want a ValidationError", err)
+	}
+	if s, _ := Current().Group("jobs").String("cleanup"); s != "@daily" {
+		t.Errorf("cleanup = %q, want the valid @daily kept", s)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Definition describes a known config key.
+type Definition struct {
+	// Path is the dot separated path of groups and a key, eg. `server.port`.
+	Path string
+	// Default is the value used when the key isn't set.
+	Default interface{}
+	// Usage describes the key.
+	Usage string
+}
+
+var (
+	definitionsMu sync.RWMutex
+	definitions   = map[string]Definition{}
+)
+
+// Define registers the key at `path`, with its default value and a
+// description, eg.
+//
+//	config.Define("server.port", 8080, "port to listen on")
+//
+// Defaults are typed by their value, and must be a bool, int, float64,
+// string, time.Duration, or []string. Define panics when `path` is already
+// defined, or the default is of another type.
+func Define(path string, def interface{}, usage string) {
+	if path == "" || contains(strings.Split(path, "."), "") {
+		panic(fmt.Sprintf("config: invalid definition path %q", path))
+	}
+	switch def.(type) {
+	case bool, int, float64, string, time.Duration, []string:
+	default:
+		panic(fmt.Sprintf("config: unsupported default type %T for %s", def, path))
+	}
+	definitionsMu.Lock()
+	defer definitionsMu.Unlock()
+	if _, ok := definitions[path]; ok {
+		panic(fmt.Sprintf("config: %s already defined", path))
+	}
+	definitions[path] = Definition{path, def, usage}
+}
+
+// Definitions returns every defined key, sorted by path.
+func Definitions() []Definition {
+	definitionsMu.RLock()
+	defer definitionsMu.RUnlock()
+	l := make([]Definition, 0, len(definitions))
+	for _, d := range definitions {
+		l = append(l, d)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Path < l[j].Path })
+	return l
+}
+
+// DefaultsSource reads the defaults of the defined keys.
+type DefaultsSource struct{}
+
+// Defaults returns a source of the defaults of every defined key, for the end
+// of a Chain, eg.
+//
+//	config.NewChain(config.Flags(fs), config.File("config.json"), config.Defaults())
+func Defaults() *DefaultsSource {
+	return &DefaultsSource{}
+}
+
+func (s *DefaultsSource) Read() (Config, error) {
+	m := make(map[string]interface{})
+	for _, d := range Definitions() {
+		v := d.Default
+		if l, ok := v.([]string); ok {
+			v = append([]string(nil), l...)
+		}
+		set(m, strings.Split(d.Path, "."), v)
+	}
+	return Config{m: m}, nil
+}
+
+// RegisterFlags creates a flag within `fs` for every defined key, named by its
+// path and typed, defaulted, and described by its definition, eg.
+// `-server.port=9090`. Read the flags that were set with Flags, so flags and
+// keys never drift apart:
+//
+//	config.RegisterFlags(flag.CommandLine)
+//	flag.Parse()
+//	config.Load(config.NewChain(config.Flags(flag.CommandLine), config.File("config.json"), config.Defaults()))
+//
+// List flags are comma separated. RegisterFlags panics, as flag does, when a
+// flag of the same name already exists.
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, d := range Definitions() {
+		switch def := d.Default.(type) {
+		case bool:
+			fs.Bool(d.Path, def, d.Usage)
+		case int:
+			fs.Int(d.Path, def, d.Usage)
+		case float64:
+			fs.Float64(d.Path, def, d.Usage)
+		case string:
+			fs.String(d.Path, def, d.Usage)
+		case time.Duration:
+			fs.Duration(d.Path, def, d.Usage)
+		case []string:
+			l := stringsValue(append([]string(nil), def...))
+			fs.Var(&l, d.Path, d.Usage)
+		}
+	}
+}
+
+// stringsValue is a comma separated list flag.
+type stringsValue []string
+
+func (l *stringsValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringsValue) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		*l = append(*l, strings.TrimSpace(v))
+	}
+	return nil
+}
+
+func (l *stringsValue) Get() interface{} {
+	return []string(*l)
+}
@@ -0,0 +1,89 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDefine(t *testing.T) {
+	defs := map[string]interface{}{
+		"server.port":    8080,
+		"server.debug":   false,
+		"server.timeout": 30 * time.Second,
+		"server.hosts":   []string{"a", "b"},
+		"name":           "app",
+	}
+	for path, def := range defs {
+		Define(path, def, "the "+path)
+	}
+	defer func() {
+		definitionsMu.Lock()
+		defer definitionsMu.Unlock()
+		for path := range defs {
+			delete(definitions, path)
+		}
+	}()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("defined server.port twice")
+			}
+		}()
+		Define("server.port", 9090, "")
+	}()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterFlags(fs)
+	if f := fs.Lookup("server.port"); f == nil || f.DefValue != "8080" || f.Usage != "the server.port" {
+		t.Fatalf("server.port flag = %+v", f)
+	}
+	if err := fs.Parse([]string{"-server.port=9090", "-server.hosts=c, d"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewChain(Flags(fs), Defaults()).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Name   string
+		Server struct {
+			Port    int
+			Debug   bool
+			Timeout time.Duration
+			Hosts   []string
+		}
+	}
+	if err = c.Bind(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "app" || v.Server.Port != 9090 || v.Server.Debug || v.Server.Timeout != 30*time.Second || !reflect.DeepEqual(v.Server.Hosts, []string{"c", "d"}) {
+		t.Errorf("bound %+v, want the flags over the defaults", v)
+	}
+}
+
+func TestCompleteKeys(t *testing.T) {
+	Define("server.tls.cert", "", "the certificate file")
+	defer func() {
+		definitionsMu.Lock()
+		defer definitionsMu.Unlock()
+		delete(definitions, "server.tls.cert")
+	}()
+	c := FromMap(map[string]interface{}{
+		"server": map[string]interface{}{"host": "localhost", "port": 8080.0},
+		"sentry": "dsn",
+		"name":   "app",
+	})
+	want := []string{"sentry", "server", "server.host", "server.port", "server.tls", "server.tls.cert"}
+	if got := c.CompleteKeys("se"); !reflect.DeepEqual(got, want) {
+		t.Errorf("completed se to %v, want %v", got, want)
+	}
+	if got := c.CompleteKeys("server.t"); !reflect.DeepEqual(got, want[4:]) {
+		t.Errorf("completed server.t to %v, want %v", got, want[4:])
+	}
+}
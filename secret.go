@@ -0,0 +1,141 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"log"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// SecretString is a secret value, eg. a password, kept out of dumps and logs:
+// it formats, and encodes as JSON or text, as Redacted. The value is held
+// within its own buffer, which Zero overwrites, and which is zeroed once the
+// secret is garbage collected otherwise.
+type SecretString struct {
+	b []byte
+	// load, when set, reads the secret on its first use, see lazySecret.
+	once sync.Once
+	load func() []byte
+	// ref is the reference the secret was resolved from, saved in its place,
+	// see SaveAs.
+	ref string
+}
+
+// NewSecretString returns the secret `s`, eg. for a Resolver to return as a
+// Lease's Value.
+func NewSecretString(s string) *SecretString {
+	return newSecret([]byte(s))
+}
+
+// newSecret returns the secret of `b`, which it owns.
+func newSecret(b []byte) *SecretString {
+	s := &SecretString{b: b}
+	runtime.SetFinalizer(s, (*SecretString).Zero)
+	return s
+}
+
+// lazySecret returns the secret read by `load` on its first use, which owns
+// the buffer returned.
+func lazySecret(load func() []byte) *SecretString {
+	s := &SecretString{load: load}
+	runtime.SetFinalizer(s, (*SecretString).Zero)
+	return s
+}
+
+// value returns the secret's buffer, once it's loaded.
+func (s *SecretString) value() []byte {
+	if s.load != nil {
+		s.once.Do(func() { s.b = s.load() })
+	}
+	return s.b
+}
+
+// Reveal returns the secret, as a string, which can't be zeroed, see Bytes.
+func (s *SecretString) Reveal() string {
+	return string(s.value())
+}
+
+// Bytes returns the secret's own buffer, without a copy, so it's zeroed along
+// with the secret. It mustn't be retained, nor changed.
+func (s *SecretString) Bytes() []byte {
+	return s.value()
+}
+
+// Zero overwrites the secret, from then on it's empty.
+func (s *SecretString) Zero() {
+	// Secrets not yet loaded never are.
+	s.once.Do(func() {})
+	for i := range s.b {
+		s.b[i] = 0
+	}
+	s.b = nil
+}
+
+// String returns Redacted, so the secret doesn't end up in logs.
+func (s *SecretString) String() string {
+	return Redacted
+}
+
+// GoString returns Redacted, for `%#v`.
+func (s *SecretString) GoString() string {
+	return Redacted
+}
+
+// MarshalJSON encodes the secret as Redacted, eg. within Dump.
+func (s *SecretString) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(Redacted)), nil
+}
+
+// MarshalText encodes the secret as Redacted.
+func (s *SecretString) MarshalText() ([]byte, error) {
+	return []byte(Redacted), nil
+}
+
+// Secret returns the string value for the `key` within the root level as a
+// SecretString, copied from the config, whose own copy can't be zeroed. The
+// secret, or nil, is returned along with boolean of wether the key was found.
+func (c Config) Secret(key string) (*SecretString, bool) {
+	v, _ := c.value(key)
+	switch v := v.(type) {
+	case string:
+		return NewSecretString(v), true
+	case *SecretString:
+		return newSecret(append([]byte(nil), v.value()...)), true
+	}
+	return nil, false
+}
+
+// revealed returns the string of `v` when it's a SecretString, so it's
+// coerced as strings are, otherwise `v`.
+func revealed(v interface{}) interface{} {
+	if s, ok := v.(*SecretString); ok && s != nil {
+		return s.Reveal()
+	}
+	return v
+}
+
+// RequiredSecret returns the secret, within the root, and exits when not
+// found.
+func (c Config) RequiredSecret(key string) *SecretString {
+	s, ok := c.Secret(key)
+	if !ok {
+		log.Fatalf("failed to retrieve '%s' secret from config", key)
+	}
+	return s
+}
+
+// Secret returns the string value for the `key` within the root level as a
+// SecretString, see Config.Secret.
+func Secret(key string) (*SecretString, bool) {
+	return cfg.Secret(key)
+}
+
+// RequiredSecret returns the secret, within the root, and exits when not
+// found.
+func RequiredSecret(key string) *SecretString {
+	return cfg.RequiredSecret(key)
+}
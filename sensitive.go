@@ -0,0 +1,195 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// sensitiveKey marks a group as sensitive, eg.
+//
+//	"db": {"$sensitive": true, "password": "..."}
+const sensitiveKey = "$sensitive"
+
+// Redacted replaces the values of sensitive groups within dumps.
+const Redacted = "[REDACTED]"
+
+// RequireAllowSensitive, when true, hides sensitive groups from accessors
+// (Group, Val, Map, Walk, Flatten, ExportEnv, and Bind) unless they're read
+// from a config returned by AllowSensitive.
+var RequireAllowSensitive = false
+
+func isSensitive(m map[string]interface{}) bool {
+	switch v := m[sensitiveKey].(type) {
+	case bool:
+		return v
+	case sensitiveRef:
+		return true
+	}
+	return false
+}
+
+// sensitiveRef marks a group resolved from a reference, which it holds, as
+// sensitive, see secretVal. It encodes as true, as other marks do.
+type sensitiveRef string
+
+func (sensitiveRef) MarshalJSON() ([]byte, error) {
+	return []byte("true"), nil
+}
+
+// group returns the group `name`, unless it's a sensitive group that may not
+// be read.
+func (c Config) group(name string) (map[string]interface{}, bool) {
+	v, _ := c.value(name)
+	g, ok := v.(map[string]interface{})
+	if ok && c.gated() && isSensitive(g) {
+		return nil, false
+	}
+	return g, ok
+}
+
+// gated returns whether sensitive groups are hidden from the config's
+// accessors, see RequireAllowSensitive.
+func (c Config) gated() bool {
+	return RequireAllowSensitive && !c.allowSensitive
+}
+
+// visible returns the values `m`, of the config, without the sensitive groups
+// within them when they're hidden. `m` is copied only when it holds any.
+func (c Config) visible(m map[string]interface{}) map[string]interface{} {
+	if c.gated() {
+		m, _ = hideSensitive(m)
+	}
+	return m
+}
+
+// hideSensitive returns `m` without its sensitive groups, and whether it had
+// any.
+func hideSensitive(m map[string]interface{}) (map[string]interface{}, bool) {
+	var h map[string]interface{}
+	for key, v := range m {
+		g, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		hg, hid := hideSensitive(g)
+		if !hid && !isSensitive(g) {
+			continue
+		}
+		if h == nil {
+			h = make(map[string]interface{}, len(m))
+			for key, v := range m {
+				h[key] = v
+			}
+		}
+		if isSensitive(g) {
+			delete(h, key)
+		} else {
+			h[key] = hg
+		}
+	}
+	if h == nil {
+		return m, false
+	}
+	return h, true
+}
+
+// AllowSensitive returns the config permitting reads of sensitive groups, for
+// when RequireAllowSensitive is set.
+func (c Config) AllowSensitive() Config {
+	c = c.with(c.m)
+	c.allowSensitive = true
+	return c
+}
+
+// IsSensitive returns whether the value at `path`, a dot separated path of
+// groups and a key, is within a sensitive group, or is a SecretString.
+func (c Config) IsSensitive(path string) bool {
+	m := c.m
+	for _, key := range strings.Split(path, ".") {
+		if isSensitive(m) {
+			return true
+		}
+		v := m[key]
+		if isSecret(v) {
+			return true
+		}
+		if m, _ = v.(map[string]interface{}); m == nil {
+			return false
+		}
+	}
+	return isSensitive(m)
+}
+
+// Redact returns a copy of the config's values, with every value within a
+// sensitive group replaced by Redacted. As with Walk, computed values (see
+// Provide) aren't included.
+func (c Config) Redact() map[string]interface{} {
+	if c.m == nil {
+		return nil
+	}
+	return redact(c.m)
+}
+
+func redact(m map[string]interface{}) map[string]interface{} {
+	sensitive := isSensitive(m)
+	r := make(map[string]interface{}, len(m))
+	for key, v := range m {
+		switch g, isGroup := v.(map[string]interface{}); {
+		case key == sensitiveKey:
+		case isGroup && sensitive:
+			r[key] = redact(sensitized(g))
+		case isGroup:
+			r[key] = redact(g)
+		case sensitive || isSecret(v):
+			r[key] = Redacted
+		default:
+			r[key] = copyVal(v)
+		}
+	}
+	return r
+}
+
+func isSecret(v interface{}) bool {
+	_, ok := v.(*SecretString)
+	return ok
+}
+
+// sensitized returns a copy of `m` marked as sensitive, so groups nested
+// within a sensitive group are redacted too.
+func sensitized(m map[string]interface{}) map[string]interface{} {
+	s := make(map[string]interface{}, len(m)+1)
+	for key, v := range m {
+		s[key] = v
+	}
+	s[sensitiveKey] = true
+	return s
+}
+
+// Dump writes the config to `w` as indented JSON, with sensitive groups
+// redacted.
+func (c Config) Dump(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(c.Redact())
+}
+
+// AllowSensitive returns the current config permitting reads of sensitive
+// groups, see Config.AllowSensitive.
+func AllowSensitive() Config {
+	return cfg.AllowSensitive()
+}
+
+// IsSensitive returns whether `path` is sensitive within the current config.
+func IsSensitive(path string) bool {
+	return cfg.IsSensitive(path)
+}
+
+// Dump writes the current config to `w`, see Config.Dump.
+func Dump(w io.Writer) error {
+	return cfg.Dump(w)
+}
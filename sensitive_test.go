@@ -0,0 +1,88 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRequireAllowSensitive(t *testing.T) {
+	defer func(b bool) { RequireAllowSensitive = b }(RequireAllowSensitive)
+	RequireAllowSensitive = true
+	c := FromMap(map[string]interface{}{
+		"port": 9090.0,
+		"app": map[string]interface{}{
+			"name": "app",
+			"db":   map[string]interface{}{"$sensitive": true, "password": "hunter2"},
+		},
+	})
+
+	if _, ok := c.Group("app").Val("db"); ok {
+		t.Error("Val returned the sensitive group")
+	}
+	if app, _ := c.Val("app"); strings.Contains(fmt.Sprint(app), "hunter2") {
+		t.Errorf("Val returned %v, want the sensitive group hidden", app)
+	}
+	for name, s := range map[string]string{
+		"Map":       fmt.Sprint(c.Map()),
+		"Flatten":   fmt.Sprint(c.Flatten()),
+		"ExportEnv": strings.Join(c.ExportEnv("APP_"), " "),
+	} {
+		if strings.Contains(s, "hunter2") {
+			t.Errorf("%s revealed the password: %s", name, s)
+		}
+	}
+	var v struct {
+		App struct {
+			DB struct{ Password string }
+		}
+	}
+	if err := c.Bind(&v); err != nil || v.App.DB.Password != "" {
+		t.Errorf("Bind set the password %q, %v", v.App.DB.Password, err)
+	}
+	// The config itself is unchanged.
+	if _, ok := c.m["app"].(map[string]interface{})["db"]; !ok {
+		t.Error("hiding removed the group from the config")
+	}
+
+	a := c.AllowSensitive()
+	if flat := a.Flatten(); flat["app.db.password"] != "hunter2" {
+		t.Errorf("flattened %v, want the password when allowed", flat)
+	}
+	if err := a.Bind(&v); err != nil || v.App.DB.Password != "hunter2" {
+		t.Errorf("Bind set the password %q, %v, want it when allowed", v.App.DB.Password, err)
+	}
+	// Diffs compare sensitive values, while redacting them.
+	changed := FromMap(map[string]interface{}{"port": 9090.0, "app": map[string]interface{}{
+		"name": "app",
+		"db":   map[string]interface{}{"$sensitive": true, "password": "hunter3"},
+	}})
+	if d := Diff(c, changed); len(d) != 1 || d[0].New != Redacted {
+		t.Errorf("diff %v, want the redacted password", d)
+	}
+}
+
+func TestSaveSensitive(t *testing.T) {
+	defer func(b bool) { RequireAllowSensitive = b }(RequireAllowSensitive)
+	RequireAllowSensitive = true
+	f := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(f, []byte(`{"host": "x", "db": {"$sensitive": true, "password": "hunter2"}}`), 0600)
+	openTest(t, File(f))
+	if err := SaveAs(f); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(f)
+	c, err := ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pw, _ := c.AllowSensitive().Group("db").String("password"); pw != "hunter2" {
+		t.Errorf("saved %s, want the sensitive group kept", b)
+	}
+}
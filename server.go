@@ -0,0 +1,97 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ServerConfig holds the settings of an http.Server, bound from a group like:
+//
+//	"server": {
+//		"addr": ":9090",
+//		"read_timeout": "30s",
+//		"read_header_timeout": "10s",
+//		"write_timeout": "30s",
+//		"idle_timeout": "2m",
+//		"max_header_bytes": 1048576
+//	}
+//
+// Durations may also be numbers of seconds.
+type ServerConfig struct {
+	Addr              string        `config:"addr"`
+	ReadTimeout       time.Duration `config:"read_timeout"`
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout"`
+	WriteTimeout      time.Duration `config:"write_timeout"`
+	IdleTimeout       time.Duration `config:"idle_timeout"`
+	MaxHeaderBytes    int           `config:"max_header_bytes"`
+}
+
+// DefaultServerConfig holds the settings used for any missing from the
+// group. Unlike the zero http.Server, every timeout is bounded.
+var DefaultServerConfig = ServerConfig{
+	Addr:              ":8080",
+	ReadTimeout:       30 * time.Second,
+	ReadHeaderTimeout: 10 * time.Second,
+	WriteTimeout:      30 * time.Second,
+	IdleTimeout:       2 * time.Minute,
+	MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+}
+
+// Validate returns an error when the address is malformed, or a timeout or
+// limit is negative.
+func (s ServerConfig) Validate() error {
+	if _, _, err := net.SplitHostPort(s.Addr); err != nil {
+		return fmt.Errorf("invalid server addr %q: %s", s.Addr, err)
+	}
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"read_timeout", s.ReadTimeout},
+		{"read_header_timeout", s.ReadHeaderTimeout},
+		{"write_timeout", s.WriteTimeout},
+		{"idle_timeout", s.IdleTimeout},
+	} {
+		if t.d < 0 {
+			return fmt.Errorf("invalid server %s %s, must not be negative", t.name, t.d)
+		}
+	}
+	if s.MaxHeaderBytes < 0 {
+		return fmt.Errorf("invalid server max_header_bytes %d, must not be negative", s.MaxHeaderBytes)
+	}
+	return nil
+}
+
+// HTTPServer returns an http.Server serving `handler`, configured by the
+// `group`, see ServerConfig. Missing settings are taken from
+// DefaultServerConfig.
+func (c Config) HTTPServer(group string, handler http.Handler) (*http.Server, error) {
+	s := DefaultServerConfig
+	if err := c.Group(group).Bind(&s); err != nil {
+		return nil, fmt.Errorf("failed to read '%s' server config: %w", group, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to read '%s' server config: %w", group, err)
+	}
+	return &http.Server{
+		Addr:              s.Addr,
+		Handler:           handler,
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+	}, nil
+}
+
+// HTTPServer returns an http.Server configured by the `group` of the current
+// config, see Config.HTTPServer.
+func HTTPServer(group string, handler http.Handler) (*http.Server, error) {
+	return cfg.HTTPServer(group, handler)
+}
@@ -0,0 +1,225 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package starlark reads configuration produced by a Starlark script, for
+// configs with conditional logic JSON can't express, eg.
+//
+//	# config.star
+//	prod = env.get("ENVIRONMENT") == "prod"
+//	config = {
+//		"server": {"host": hostname, "port": 443 if prod else 8080},
+//		"debug": not prod,
+//	}
+//
+// Scripts set the `config` global to a dict, of the values a JSON document
+// holds: dicts with string keys, lists, tuples, strings, ints, floats, bools,
+// and None. Ints are read as float64, as JSON numbers are. Scripts are given:
+//
+//	env       a dict of the environment variables
+//	hostname  the host's name
+//	inputs    a dict of the Source's Inputs
+//
+// Scripts are sandboxed: they can't load modules, nor reach the file system
+// or network, and are stopped after MaxSteps steps. Importing the package
+// registers the `.star` format (see config.RegisterFormat), so File and Glob
+// evaluate `.star` files.
+package starlark
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+
+	"code.minty.io/config"
+	"go.starlark.net/starlark"
+)
+
+// MaxSteps is the default number of steps a script may take, see Source.
+const MaxSteps = 10000000
+
+func init() {
+	config.RegisterFormat(".star", config.DecoderFunc(func(b []byte) (map[string]interface{}, error) {
+		return (&Source{}).eval("-", b)
+	}))
+}
+
+// Source reads the configuration produced by a Starlark script.
+type Source struct {
+	Path string
+	// Inputs are given to the script as the `inputs` dict.
+	Inputs map[string]interface{}
+	// MaxSteps stops scripts taking more steps, MaxSteps when zero.
+	MaxSteps uint64
+}
+
+// Script returns a source evaluating the Starlark script at `path`.
+func Script(path string) *Source {
+	return &Source{Path: path}
+}
+
+func (s *Source) Read() (config.Config, error) {
+	b, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return *new(config.Config), err
+	}
+	m, err := s.eval(s.Path, b)
+	if err != nil {
+		return *new(config.Config), err
+	}
+	return config.FromMap(m), nil
+}
+
+func (s *Source) Name() string {
+	return "starlark " + s.Path
+}
+
+func (s *Source) eval(name string, src []byte) (map[string]interface{}, error) {
+	inputs, err := toStarlark(s.Inputs)
+	if err != nil {
+		return nil, fmt.Errorf("starlark: invalid inputs: %w", err)
+	}
+	env := starlark.NewDict(0)
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env.SetKey(starlark.String(kv[:i]), starlark.String(kv[i+1:]))
+		}
+	}
+	hostname, _ := os.Hostname()
+
+	thread := &starlark.Thread{
+		Name: name,
+		Load: func(*starlark.Thread, string) (starlark.StringDict, error) {
+			return nil, errors.New("load is not allowed")
+		},
+	}
+	steps := s.MaxSteps
+	if steps == 0 {
+		steps = MaxSteps
+	}
+	thread.SetMaxExecutionSteps(steps)
+	globals, err := starlark.ExecFile(thread, name, src, starlark.StringDict{
+		"env":      env,
+		"hostname": starlark.String(hostname),
+		"inputs":   inputs,
+	})
+	if err != nil {
+		var eval *starlark.EvalError
+		if errors.As(err, &eval) {
+			return nil, fmt.Errorf("starlark: %s", eval.Backtrace())
+		}
+		return nil, fmt.Errorf("starlark: %w", err)
+	}
+	d, ok := globals["config"].(*starlark.Dict)
+	if !ok {
+		return nil, fmt.Errorf("starlark: %s doesn't set config to a dict", name)
+	}
+	v, err := fromStarlark(d, "config", 0)
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]interface{}), nil
+}
+
+// maxDepth bounds the nesting of the config's values, so lists or dicts
+// holding themselves are rejected, as config bounds binary documents.
+const maxDepth = 1000
+
+// fromStarlark returns the Go value of `v`, at `path`, nested `depth` deep.
+func fromStarlark(v starlark.Value, path string, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("starlark: %s is nested too deeply, or holds itself", path)
+	}
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		return float64(v.Float()), nil
+	case starlark.Float:
+		return float64(v), nil
+	case *starlark.List:
+		l := make([]interface{}, v.Len())
+		for i := range l {
+			e, err := fromStarlark(v.Index(i), fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = e
+		}
+		return l, nil
+	case starlark.Tuple:
+		l := make([]interface{}, len(v))
+		for i := range l {
+			e, err := fromStarlark(v[i], fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = e
+		}
+		return l, nil
+	case *starlark.Dict:
+		m := make(map[string]interface{})
+		for _, kv := range v.Items() {
+			k, ok := kv[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("starlark: %s has a %s key, keys must be strings", path, kv[0].Type())
+			}
+			e, err := fromStarlark(kv[1], fmt.Sprintf("%s[%q]", path, string(k)), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			m[string(k)] = e
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("starlark: %s is a %s, which config can't hold", path, v.Type())
+}
+
+// toStarlark returns the Starlark value of `v`.
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case int:
+		return starlark.MakeInt64(int64(v)), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return starlark.MakeInt64(int64(v)), nil
+		}
+		return starlark.Float(v), nil
+	case []interface{}:
+		l := make([]starlark.Value, len(v))
+		for i, e := range v {
+			s, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = s
+		}
+		return starlark.NewList(l), nil
+	case map[string]interface{}:
+		d := starlark.NewDict(len(v))
+		for k, e := range v {
+			s, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			d.SetKey(starlark.String(k), s)
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("unsupported %T", v)
+}
@@ -0,0 +1,166 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrDuplicateKey is returned, as a DuplicateKeyError, when a strictly read
+// document repeats a key within a group, see WithStrict.
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// DuplicateKey is a key repeated within a group of a JSON document, by its
+// dot separated path, and its 1-based line and column (in bytes).
+type DuplicateKey struct {
+	Path         string
+	Line, Column int
+	// FirstLine and FirstColumn are of the key's first occurrence.
+	FirstLine, FirstColumn int
+}
+
+// DuplicateKeyError lists the duplicate keys of a document, in order.
+type DuplicateKeyError struct {
+	Keys []DuplicateKey
+}
+
+func (e *DuplicateKeyError) Error() string {
+	l := make([]string, len(e.Keys))
+	for i, k := range e.Keys {
+		l[i] = fmt.Sprintf("%s at %d:%d (first at %d:%d)", k.Path, k.Line, k.Column, k.FirstLine, k.FirstColumn)
+	}
+	return fmt.Sprintf("%s: %s", ErrDuplicateKey, strings.Join(l, ", "))
+}
+
+func (e *DuplicateKeyError) Unwrap() error {
+	return ErrDuplicateKey
+}
+
+// WithStrict rejects JSON documents repeating a key within a group, at any
+// level, with a DuplicateKeyError locating every duplicate. Otherwise the
+// last of the duplicates is kept, silently, as by encoding/json.
+func WithStrict() Option {
+	return func(o *options) { o.strict = true }
+}
+
+// DuplicateKeys returns the keys repeated within the groups of the JSON
+// document `b`, in order.
+func DuplicateKeys(b []byte) ([]DuplicateKey, error) {
+	keys, err := scanKeys(b)
+	if err != nil {
+		return nil, err
+	}
+	var dups []DuplicateKey
+	for _, k := range keys {
+		if k.first < 0 {
+			continue
+		}
+		d := DuplicateKey{Path: k.path}
+		d.Line, d.Column = lineColumn(b, k.offset)
+		d.FirstLine, d.FirstColumn = lineColumn(b, k.first)
+		dups = append(dups, d)
+	}
+	return dups, nil
+}
+
+// strictKeys fails when the document `b` has duplicate keys. Malformed
+// documents are left for the decoder to report.
+func strictKeys(b []byte) error {
+	dups, err := DuplicateKeys(b)
+	if err != nil || len(dups) == 0 {
+		return nil
+	}
+	return &DuplicateKeyError{dups}
+}
+
+// jsonKey is a key within a JSON document, by its dot separated path, and
+// its offset, along with that of the key's first occurrence within the group
+// when repeated, else -1.
+type jsonKey struct {
+	path   string
+	offset int
+	first  int
+}
+
+// scanKeys returns the keys of the JSON document `b`, in order.
+func scanKeys(b []byte) ([]jsonKey, error) {
+	type frame struct {
+		object bool
+		path   string
+		// keys are the offsets of the object's keys, expectKey is set when
+		// a key is next, and key is the path of the last.
+		keys      map[string]int
+		expectKey bool
+		key       string
+		// index is that of the list's next value.
+		index int
+	}
+	var (
+		stack []*frame
+		keys  []jsonKey
+	)
+	d := json.NewDecoder(bytes.NewReader(b))
+	for {
+		start := int(d.InputOffset())
+		t, err := d.Token()
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t == json.Delim('}') || t == json.Delim(']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		path := ""
+		switch {
+		case top == nil:
+		case top.object && top.expectKey:
+			// Keys start after the separators following the last token.
+			for start < len(b) && strings.IndexByte(" \t\r\n,", b[start]) >= 0 {
+				start++
+			}
+			name := t.(string)
+			k := jsonKey{path: join(top.path, name), offset: start, first: -1}
+			if first, ok := top.keys[name]; ok {
+				k.first = first
+			} else {
+				top.keys[name] = start
+			}
+			keys = append(keys, k)
+			top.expectKey, top.key = false, k.path
+			continue
+		case top.object:
+			path, top.expectKey = top.key, true
+		default:
+			path = join(top.path, strconv.Itoa(top.index))
+			top.index++
+		}
+		switch t {
+		case json.Delim('{'):
+			stack = append(stack, &frame{object: true, path: path, keys: make(map[string]int), expectKey: true})
+		case json.Delim('['):
+			stack = append(stack, &frame{path: path})
+		}
+	}
+}
+
+// lineColumn returns the 1-based line and column, in bytes, of `offset`
+// within `b`.
+func lineColumn(b []byte, offset int) (int, int) {
+	line := 1 + bytes.Count(b[:offset], []byte("\n"))
+	return line, offset - bytes.LastIndexByte(b[:offset], '\n')
+}
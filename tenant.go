@@ -0,0 +1,148 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// tenantsKey is the group of tenant-specific settings, keyed by tenant, eg.
+//
+//	"tenants": {
+//		"acme": {"limits": {"users": 500}}
+//	}
+const tenantsKey = "tenants"
+
+// DefaultTenantCacheSize is the number of tenant views kept by Tenants when
+// no Size is set.
+const DefaultTenantCacheSize = 128
+
+// Tenant returns the config as seen by the tenant `name`: its group within
+// `tenants` deep-merged over the shared settings, without the `tenants` group.
+func (c Config) Tenant(name string) Config {
+	t, _ := c.m[tenantsKey].(map[string]interface{})
+	g, _ := t[name].(map[string]interface{})
+	return c.with(merge(shared(c.m), g))
+}
+
+// shared returns `m` without the tenant-specific settings.
+func shared(m map[string]interface{}) map[string]interface{} {
+	s := make(map[string]interface{}, len(m))
+	for key, v := range m {
+		if key != tenantsKey {
+			s[key] = v
+		}
+	}
+	return s
+}
+
+// TenantLoader loads the settings of the tenant `name`, eg. from a file or a
+// remote key, returning an error matching fs.ErrNotExist when it has none.
+type TenantLoader func(name string) (Config, error)
+
+// TenantFiles returns a loader reading each tenant's settings from the file
+// named by `pattern`, with `%s` replaced by the tenant, eg.
+// `tenants/%s.json`.
+func TenantFiles(pattern string) TenantLoader {
+	return func(name string) (Config, error) {
+		if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return *new(Config), fmt.Errorf("invalid tenant %q", name)
+		}
+		return readFile(fmt.Sprintf(pattern, name), newOptions(nil))
+	}
+}
+
+// Tenants caches the views of tenants over the current config (see
+// Config.Tenant), loading their settings on first use by the Loader.
+type Tenants struct {
+	// Loader, when set, loads tenant settings that are merged over those
+	// within the `tenants` group.
+	Loader TenantLoader
+	// Size is the number of views kept, the least recently used being
+	// dropped first. DefaultTenantCacheSize when zero.
+	Size int
+
+	mu      sync.Mutex
+	gen     uint64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type tenantView struct {
+	name string
+	c    Config
+}
+
+// NewTenants returns the tenant views for `loader`, which may be nil.
+func NewTenants(size int, loader TenantLoader) *Tenants {
+	return &Tenants{Loader: loader, Size: size}
+}
+
+// Get returns the view of the tenant `name` over the current config. Views
+// are rebuilt once the current config is replaced, eg. by SetConfig, per its
+// Generation.
+func (t *Tenants) Get(name string) (Config, error) {
+	if name == "" {
+		return *new(Config), errors.New("config: empty tenant name")
+	}
+	cfg.mu.Lock()
+	c, gen := cfg.with(cfg.m), generation
+	cfg.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if gen != t.gen || t.order == nil {
+		t.gen, t.order, t.entries = gen, list.New(), make(map[string]*list.Element)
+	}
+	if e, ok := t.entries[name]; ok {
+		t.order.MoveToFront(e)
+		return e.Value.(*tenantView).c, nil
+	}
+
+	v := c.Tenant(name)
+	if t.Loader != nil {
+		l, err := t.Loader(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return *new(Config), fmt.Errorf("failed to load tenant %s: %w", name, err)
+		}
+		v = v.with(merge(v.m, l.m))
+	}
+	t.entries[name] = t.order.PushFront(&tenantView{name, v})
+	size := t.Size
+	if size <= 0 {
+		size = DefaultTenantCacheSize
+	}
+	for t.order.Len() > size {
+		e := t.order.Back()
+		t.order.Remove(e)
+		delete(t.entries, e.Value.(*tenantView).name)
+	}
+	return v, nil
+}
+
+// Forget drops the cached view of the tenant `name`, so it's reloaded on next
+// use.
+func (t *Tenants) Forget(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.entries[name]; ok {
+		t.order.Remove(e)
+		delete(t.entries, name)
+	}
+}
+
+// DefaultTenants are the tenant views used by Tenant.
+var DefaultTenants = &Tenants{}
+
+// Tenant returns the view of the tenant `name` over the current config, see
+// Tenants.Get.
+func Tenant(name string) (Config, error) {
+	return DefaultTenants.Get(name)
+}
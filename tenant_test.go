@@ -0,0 +1,31 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import "testing"
+
+func TestTenantsRebuilt(t *testing.T) {
+	cfg.mu.Lock()
+	prev := cfg.m
+	cfg.mu.Unlock()
+	defer SetConfig(prev)
+
+	m := map[string]interface{}{
+		"users":   10.0,
+		"tenants": map[string]interface{}{"acme": map[string]interface{}{"users": 500.0}},
+	}
+	SetConfig(m)
+	tenants := NewTenants(0, nil)
+	if c, _ := tenants.Get("acme"); c.m["users"] != 500.0 {
+		t.Fatalf("acme users = %v, want 500", c.m["users"])
+	}
+
+	// The same map, changed and set again, is a new generation.
+	m["tenants"] = map[string]interface{}{"acme": map[string]interface{}{"users": 50.0}}
+	SetConfig(m)
+	if c, _ := tenants.Get("acme"); c.m["users"] != 50.0 {
+		t.Errorf("acme users = %v, want 50 once the config is set again", c.m["users"])
+	}
+}
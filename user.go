@@ -0,0 +1,58 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UserConfigFile is the name of the per-user override file, looked for beside
+// the config file.
+const UserConfigFile = "config.user.json"
+
+// userFile returns the path of the first user override file found, either
+// UserConfigFile within `dir`, or `<app>/overrides.json` within the user's
+// config directory (eg. `~/.config`), `app` being the executable's name.
+func userFile(dir string) (string, bool) {
+	files := []string{filepath.Join(dir, UserConfigFile)}
+	if d, err := os.UserConfigDir(); err == nil {
+		app := strings.TrimSuffix(filepath.Base(os.Args[0]), filepath.Ext(os.Args[0]))
+		files = append(files, filepath.Join(d, app, "overrides.json"))
+	}
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// readUser deep-merges the user override file over `c`, unless
+// `APP_CONFIG_NO_USER` is set. When the config file is pinned by
+// `APP_CONFIG_SHA256` the override must be verified by its own sidecars, see
+// verify, or it's refused with ErrUnverified.
+func readUser(c Config, dir string, o *options) (Config, error) {
+	if os.Getenv("APP_CONFIG_NO_USER") != "" {
+		return c, nil
+	}
+	f, ok := userFile(dir)
+	if !ok {
+		return c, nil
+	}
+	// The digest and signature within the environment are of the config
+	// file, not the override.
+	no := *o
+	no.sidecars = true
+	no.verified = !o.sidecars && os.Getenv("APP_CONFIG_SHA256") != ""
+	u, err := readFile(f, &no)
+	if err != nil {
+		return *new(Config), err
+	}
+	m := c.with(merge(c.m, u.m))
+	m.pos = mergePositions(c.pos, u)
+	return m, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPinnedUser(t *testing.T) {
+	dir := t.TempDir()
+	main := []byte(`{"port": 9090, "host": "example.com"}`)
+	os.WriteFile(filepath.Join(dir, "config.json"), main, 0600)
+	user := []byte(`{"port": 80}`)
+	os.WriteFile(filepath.Join(dir, UserConfigFile), user, 0600)
+	read := func() (Config, error) {
+		o := newOptions(nil)
+		c, err := readFile(filepath.Join(dir, "config.json"), o)
+		if err != nil {
+			return c, err
+		}
+		return readUser(c, dir, o)
+	}
+
+	// Unpinned, the override is merged as is.
+	c, err := read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port, _ := c.Int("port"); port != 80 {
+		t.Errorf("unpinned: port = %d, want the override's 80", port)
+	}
+
+	// Pinned, the override has to be verified too.
+	sum := sha256.Sum256(main)
+	t.Setenv("APP_CONFIG_SHA256", hex.EncodeToString(sum[:]))
+	if _, err = read(); !errors.Is(err, ErrUnverified) {
+		t.Errorf("pinned without a sidecar: error = %v, want ErrUnverified", err)
+	}
+	os.WriteFile(filepath.Join(dir, UserConfigFile+".sha256"), []byte(hex.EncodeToString(sum[:])+"  "+UserConfigFile), 0600)
+	if _, err = read(); !errors.Is(err, ErrChecksum) {
+		t.Errorf("pinned with the config file's digest: error = %v, want ErrChecksum", err)
+	}
+	sum = sha256.Sum256(user)
+	os.WriteFile(filepath.Join(dir, UserConfigFile+".sha256"), []byte(hex.EncodeToString(sum[:])+"  "+UserConfigFile), 0600)
+	if c, err = read(); err != nil {
+		t.Fatal(err)
+	}
+	if port, _ := c.Int("port"); port != 80 {
+		t.Errorf("pinned and verified: port = %d, want the override's 80", port)
+	}
+
+	// The override may still be skipped.
+	os.Remove(filepath.Join(dir, UserConfigFile+".sha256"))
+	t.Setenv("APP_CONFIG_NO_USER", "1")
+	if c, err = read(); err != nil {
+		t.Fatal(err)
+	}
+	if port, _ := c.Int("port"); port != 9090 {
+		t.Errorf("skipped: port = %d, want the config file's 9090", port)
+	}
+}
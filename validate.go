@@ -0,0 +1,112 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	validatorsMu sync.Mutex
+	validators   = map[*func(Config) error]bool{}
+)
+
+// ValidationError is returned when a candidate config fails a validator, see
+// OnValidate. The current config is kept.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("candidate config failed validation: %s", e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// OnValidate calls `fn` with every candidate config before it replaces the
+// current config, eg. via SetConfig, Load, or a reload, until `remove` is
+// called, so applications can check it can actually be used, eg. that its
+// database can be connected to:
+//
+//	config.OnValidate(func(c config.Config) error {
+//		db, err := sql.Open("postgres", c.Group("db").RequiredString("dsn"))
+//		if err == nil {
+//			err = db.Ping()
+//		}
+//		return err
+//	})
+//
+// When any validator fails, the current config is kept, and the change fails
+// with a ValidationError. The current config can be read while validating,
+// but not changed.
+func OnValidate(fn func(candidate Config) error) (remove func()) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	p := &fn
+	validators[p] = true
+	return func() {
+		validatorsMu.Lock()
+		defer validatorsMu.Unlock()
+		delete(validators, p)
+	}
+}
+
+func validate(candidate Config) error {
+	validatorsMu.Lock()
+	fns := make([]func(Config) error, 0, len(validators))
+	for p := range validators {
+		fns = append(fns, *p)
+	}
+	validatorsMu.Unlock()
+	for _, fn := range fns {
+		if err := fn(candidate); err != nil {
+			return &ValidationError{err}
+		}
+	}
+	return nil
+}
+
+func hasValidators() bool {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	return len(validators) > 0
+}
+
+// snapshot is the state changed by updates, restored after a trial run.
+type snapshot struct {
+	m               map[string]interface{}
+	pos             map[string]Location
+	coerce          bool
+	lenientBools    bool
+	missingInt      int
+	missingFloat64  float64
+	owner           *admin
+	base, overrides map[string]interface{}
+	basePos         map[string]Location
+}
+
+// trial runs `fn` to get the candidate config, and restores the current
+// state. Callers must hold cfg.mu.
+func trial(a *Admin, fn func() error) (Config, error) {
+	s := snapshot{m: cfg.m, pos: cfg.pos, coerce: cfg.coerce, lenientBools: cfg.lenientBools,
+		missingInt: cfg.missingInt, missingFloat64: cfg.missingFloat64, owner: owner}
+	if a != nil {
+		s.base, s.basePos, s.overrides = a.base, a.pos, a.overrides
+	}
+	defer func() {
+		cfg.m, cfg.pos, cfg.coerce, cfg.lenientBools, owner = s.m, s.pos, s.coerce, s.lenientBools, s.owner
+		cfg.missingInt, cfg.missingFloat64 = s.missingInt, s.missingFloat64
+		if a != nil {
+			a.base, a.pos, a.overrides = s.base, s.basePos, s.overrides
+		}
+	}()
+	if err := fn(); err != nil {
+		return *new(Config), err
+	}
+	return cfg.with(cfg.m), nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrChecksum is returned when the config doesn't match its SHA-256 digest.
+	ErrChecksum = errors.New("config checksum mismatch")
+	// ErrSignature is returned when the config doesn't match its signature.
+	ErrSignature = errors.New("config signature mismatch")
+	// ErrUnverified is returned when verification is required, but there is
+	// neither a digest nor a public key to verify the config with.
+	ErrUnverified = errors.New("config could not be verified")
+)
+
+// verify checks `data`, read from the file `name` (empty when not read from a
+// file), for tampering. The expected SHA-256 digest is taken from
+// `APP_CONFIG_SHA256`, or the sidecar file `<name>.sha256` (in `sha256sum`
+// format). When `APP_CONFIG_PUBLIC_KEY` holds an ed25519 public key, the
+// detached signature within `APP_CONFIG_SIGNATURE`, or `<name>.sig`, must be
+// valid. Verification is skipped when neither is available, unless
+// `APP_CONFIG_VERIFY=required`, or `required` is set. The digest and
+// signature within the environment are of the config file itself, so unless
+// `env` is set, eg. for included files, only the sidecar files are used.
+func verify(data []byte, name string, env, required bool) error {
+	var digest string
+	if env {
+		digest = os.Getenv("APP_CONFIG_SHA256")
+	}
+	if digest == "" && name != "" {
+		b, err := ioutil.ReadFile(name + ".sha256")
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// sha256sum format is `<hex>  <file>`, only the digest matters.
+		if fields := strings.Fields(string(b)); len(fields) > 0 {
+			digest = fields[0]
+		}
+	}
+	verified := false
+	if digest != "" {
+		if err := verifyDigest(data, digest); err != nil {
+			return err
+		}
+		verified = true
+	}
+
+	if key := os.Getenv("APP_CONFIG_PUBLIC_KEY"); key != "" {
+		pub, err := decodeKey(key, ed25519.PublicKeySize)
+		if err != nil {
+			return fmt.Errorf("invalid APP_CONFIG_PUBLIC_KEY: %s", err)
+		}
+		var sig []byte
+		if env {
+			sig = []byte(os.Getenv("APP_CONFIG_SIGNATURE"))
+		}
+		if len(sig) == 0 && name != "" {
+			if sig, err = ioutil.ReadFile(name + ".sig"); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if len(sig) == 0 {
+			return fmt.Errorf("%w: missing signature", ErrSignature)
+		}
+		if err = verifySignature(data, sig, pub); err != nil {
+			return err
+		}
+		verified = true
+	}
+
+	if !verified && (required || os.Getenv("APP_CONFIG_VERIFY") == "required") {
+		return ErrUnverified
+	}
+	return nil
+}
+
+// verifyDigest checks the SHA-256 digest of `data` against the hex `digest`.
+func verifyDigest(data []byte, digest string) error {
+	want, err := hex.DecodeString(strings.TrimSpace(digest))
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("%w: invalid digest %q", ErrChecksum, digest)
+	}
+	got := sha256.Sum256(data)
+	if subtle.ConstantTimeCompare(got[:], want) != 1 {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// verifySignature checks the ed25519 signature `sig` of `data`, `sig` may be
+// raw, hex, or base64 encoded.
+func verifySignature(data, sig []byte, pub ed25519.PublicKey) error {
+	if len(sig) != ed25519.SignatureSize {
+		b, err := decodeKey(string(sig), ed25519.SignatureSize)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrSignature, err)
+		}
+		sig = b
+	}
+	if !ed25519.Verify(pub, data, sig) {
+		return ErrSignature
+	}
+	return nil
+}
+
+// decodeKey decodes a hex, or base64, encoded key of `size` bytes.
+func decodeKey(s string, size int) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if b, err := hex.DecodeString(s); err == nil && len(b) == size {
+		return b, nil
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding,
+		base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil && len(b) == size {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %d bytes, hex or base64 encoded", size)
+}
@@ -0,0 +1,99 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// Walk calls `fn` for every key, in sorted order, with its dot separated path
+// (eg. `server.port`) and value. Groups are visited before the keys within
+// them. The walk stops as soon as `fn` returns false. Computed values (see
+// Provide) aren't walked, nor are hidden sensitive groups (see
+// RequireAllowSensitive).
+func (c Config) Walk(fn func(path string, v interface{}) bool) {
+	walk("", c.visible(c.m), fn)
+}
+
+func walk(prefix string, m map[string]interface{}, fn func(string, interface{}) bool) bool {
+	keys := keys(m)
+	sort.Strings(keys)
+	for _, key := range keys {
+		path, v := prefix+key, m[key]
+		if !fn(path, v) {
+			return false
+		}
+		if g, ok := v.(map[string]interface{}); ok && !walk(path+".", g, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Flatten returns every value, other than groups, keyed by its dot separated
+// path, eg. `{"server": {"port": 9090}}` flattens to `{"server.port": 9090}`.
+func (c Config) Flatten() map[string]interface{} {
+	return flatten(c.visible(c.m))
+}
+
+// flatten flattens the values `m`, see Flatten.
+func flatten(m map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+	walk("", m, func(path string, v interface{}) bool {
+		if _, ok := v.(map[string]interface{}); !ok {
+			flat[path] = v
+		}
+		return true
+	})
+	return flat
+}
+
+// Walk walks the current config, see Config.Walk.
+func Walk(fn func(path string, v interface{}) bool) {
+	cfg.Walk(fn)
+}
+
+// Flatten flattens the current config, see Config.Flatten.
+func Flatten() map[string]interface{} {
+	return cfg.Flatten()
+}
+
+// CompleteKeys returns the sorted paths of every group and key starting with
+// `prefix`, including those of defined keys (see Define), eg. for shell
+// completion of `ser` to `server`, `server.host`, and `server.port`.
+func (c Config) CompleteKeys(prefix string) []string {
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if strings.HasPrefix(path, prefix) {
+			seen[path] = true
+		}
+	}
+	c.Walk(func(path string, v interface{}) bool {
+		add(path)
+		return true
+	})
+	for _, d := range Definitions() {
+		// Include the groups of the definition too.
+		for i, r := range d.Path {
+			if r == '.' {
+				add(d.Path[:i])
+			}
+		}
+		add(d.Path)
+	}
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// CompleteKeys completes the paths of the current config, see
+// Config.CompleteKeys.
+func CompleteKeys(prefix string) []string {
+	return cfg.CompleteKeys(prefix)
+}
@@ -0,0 +1,156 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import "sync"
+
+var (
+	watchersMu sync.Mutex
+	watchers   = map[*func(Config)]bool{}
+	// updateMu serializes changes to the current config.
+	updateMu sync.Mutex
+)
+
+// Watch calls `fn` with the current config every time it changes, eg. via
+// SetConfig, Load, or a watcher reloading it, until `stop` is called. Calls
+// are made once the change has been made, in the order of the changes, by
+// the goroutine making it, or one making an earlier change meanwhile. `fn`
+// may change the config itself; its watchers are called once it returns.
+func Watch(fn func(Config)) (stop func()) {
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+	p := &fn
+	watchers[p] = true
+	return func() {
+		watchersMu.Lock()
+		defer watchersMu.Unlock()
+		delete(watchers, p)
+	}
+}
+
+// Watch calls `fn` with the config's group within the current config, with
+// the config's settings (eg. Coerce), every time the current config changes,
+// see Watch, so a group consumed by a library (see Scoped) follows the
+// changes to it.
+func (c Config) Watch(fn func(Config)) (stop func()) {
+	return Watch(func(cur Config) {
+		g := c.with(cur.m)
+		g.path, g.pos = "", cur.pos
+		if c.path != "" {
+			g = g.Scoped(c.path)
+		}
+		fn(g)
+	})
+}
+
+// update changes the current config via `fn` (see mutable), once the
+// candidate passes the validators (see OnValidate), records it as a new
+// generation (see History), notifies the watchers, audits the change as
+// `action` (see AddAuditSink), and queues its rotations (see OnRotate).
+func update(a *Admin, action string, fn func() error) error {
+	if err := change(a, action, fn); err != nil {
+		return err
+	}
+	deliver()
+	return nil
+}
+
+// change makes the change of update, and queues its notification.
+func change(a *Admin, action string, fn func() error) error {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	if hasValidators() {
+		// Validate the candidate without holding cfg.mu, so validators can
+		// read the current config. updateMu keeps it from changing meanwhile.
+		candidate, err := func() (Config, error) {
+			cfg.mu.Lock()
+			defer cfg.mu.Unlock()
+			if err := mutable(a); err != nil {
+				return *new(Config), err
+			}
+			return trial(a, fn)
+		}()
+		if err == nil {
+			err = validate(candidate)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	if err := mutable(a); err != nil {
+		return err
+	}
+	before := cfg.with(cfg.m)
+	if err := fn(); err != nil {
+		return err
+	}
+	gen := record()
+	// Queued while holding updateMu, so notifications are in the order of
+	// the generations.
+	notificationsMu.Lock()
+	notifications = append(notifications, notification{a, action, gen, before, cfg.with(cfg.m)})
+	notificationsMu.Unlock()
+	return nil
+}
+
+// notification is a change to notify the watchers, audit sinks, and
+// rotations of.
+type notification struct {
+	a             *Admin
+	action        string
+	gen           uint64
+	before, after Config
+}
+
+var (
+	notificationsMu sync.Mutex
+	notifications   []notification
+	// delivering is set while a goroutine delivers the notifications.
+	delivering bool
+)
+
+// deliver delivers the queued notifications, in order, unless another
+// goroutine is already delivering them, without holding updateMu, so
+// watchers and audit sinks may change the config themselves; those changes
+// are notified once they return.
+func deliver() {
+	notificationsMu.Lock()
+	if delivering {
+		notificationsMu.Unlock()
+		return
+	}
+	delivering = true
+	defer func() {
+		// Let the next change deliver, should a watcher panic.
+		delivering = false
+		notificationsMu.Unlock()
+	}()
+	for len(notifications) > 0 {
+		n := notifications[0]
+		notifications = notifications[1:]
+		notificationsMu.Unlock()
+		func() {
+			defer notificationsMu.Lock()
+			n.deliver()
+		}()
+	}
+}
+
+func (n notification) deliver() {
+	watchersMu.Lock()
+	fns := make([]func(Config), 0, len(watchers))
+	for p := range watchers {
+		fns = append(fns, *p)
+	}
+	watchersMu.Unlock()
+	for _, fn := range fns {
+		fn(n.after)
+	}
+	audit(n.a, n.action, n.gen, n.before, n.after)
+	queueRotations(n.before, n.after)
+}
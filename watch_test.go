@@ -0,0 +1,65 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchersChangeConfig(t *testing.T) {
+	cfg.mu.Lock()
+	m, pos := cfg.m, cfg.pos
+	cfg.mu.Unlock()
+	defer func() {
+		cfg.mu.Lock()
+		cfg.m, cfg.pos = m, pos
+		cfg.mu.Unlock()
+	}()
+
+	var seen []int
+	stop := Watch(func(c Config) {
+		port, _ := c.Int("port")
+		seen = append(seen, port)
+		if port == 1 {
+			// Changing the config from a watcher doesn't deadlock.
+			SetConfig(map[string]interface{}{"port": 2.0})
+		}
+	})
+	defer stop()
+	done := make(chan error, 1)
+	go func() { done <- TrySetConfig(map[string]interface{}{"port": 1.0}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlocked changing the config from a watcher")
+	}
+	if len(seen) != 2 || seen[0] != 1 || seen[1] != 2 {
+		t.Errorf("watched ports %v, want [1 2], in order", seen)
+	}
+}
+
+func TestConfigWatch(t *testing.T) {
+	cfg.mu.Lock()
+	m, pos := cfg.m, cfg.pos
+	cfg.mu.Unlock()
+	defer func() {
+		cfg.mu.Lock()
+		cfg.m, cfg.pos = m, pos
+		cfg.mu.Unlock()
+	}()
+
+	var w Watcher = FromMap(nil).Coerce().Scoped("lib.cache")
+	var size int
+	stop := w.Watch(func(c Config) { size, _ = c.Int("size") })
+	defer stop()
+	SetConfig(map[string]interface{}{"lib": map[string]interface{}{"cache": map[string]interface{}{"size": "64"}}})
+	if size != 64 {
+		t.Errorf("watched size %d, want 64, within the coerced group", size)
+	}
+}
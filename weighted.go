@@ -0,0 +1,117 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+)
+
+// WeightedTarget is a target and its relative weight.
+type WeightedTarget struct {
+	Target string  `config:"target"`
+	Weight float64 `config:"weight"`
+}
+
+// WeightedList is a list of weighted targets, read from lists like:
+//
+//	"backends": [
+//		{"target": "a:8080", "weight": 3},
+//		{"target": "b:8080", "weight": 1}
+//	]
+type WeightedList []WeightedTarget
+
+// Validate returns an error when the list is empty, a weight isn't positive,
+// or a target is empty or repeated.
+func (l WeightedList) Validate() error {
+	if len(l) == 0 {
+		return fmt.Errorf("weighted list is empty")
+	}
+	seen := make(map[string]bool, len(l))
+	for _, w := range l {
+		if w.Target == "" {
+			return fmt.Errorf("weighted list has an empty target")
+		}
+		if seen[w.Target] {
+			return fmt.Errorf("weighted list target %q is repeated", w.Target)
+		}
+		seen[w.Target] = true
+		if !(w.Weight > 0) {
+			return fmt.Errorf("weighted list target %q weight %v must be greater than 0", w.Target, w.Weight)
+		}
+	}
+	return nil
+}
+
+// Total returns the sum of the weights.
+func (l WeightedList) Total() float64 {
+	var total float64
+	for _, w := range l {
+		total += w.Weight
+	}
+	return total
+}
+
+// Pick returns a target at random, in proportion to its weight, or "" when
+// the list is empty.
+func (l WeightedList) Pick() string {
+	return l.pick(rand.Float64())
+}
+
+// PickRand is Pick using the source `r`, eg. for deterministic selection.
+func (l WeightedList) PickRand(r *rand.Rand) string {
+	return l.pick(r.Float64())
+}
+
+func (l WeightedList) pick(f float64) string {
+	if len(l) == 0 {
+		return ""
+	}
+	n := f * l.Total()
+	for _, w := range l {
+		if n < w.Weight {
+			return w.Target
+		}
+		n -= w.Weight
+	}
+	// Rounding may leave a remainder, which belongs to the last target.
+	return l[len(l)-1].Target
+}
+
+// Weighted returns the validated weighted list for the `key` within the root
+// level.
+func (c Config) Weighted(key string) (WeightedList, error) {
+	var l WeightedList
+	if err := c.bindKey(key, &l); err != nil {
+		return nil, err
+	}
+	if err := l.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid '%s': %w", key, err)
+	}
+	return l, nil
+}
+
+// RequiredWeighted returns the weighted list, within the root, and exits when
+// not found or invalid.
+func (c Config) RequiredWeighted(key string) WeightedList {
+	l, err := c.Weighted(key)
+	if err != nil {
+		log.Fatalf("failed to retrieve '%s' weighted list from config: %s", key, err)
+	}
+	return l
+}
+
+// Weighted returns the validated weighted list for the `key` within the root
+// level.
+func Weighted(key string) (WeightedList, error) {
+	return cfg.Weighted(key)
+}
+
+// RequiredWeighted returns the weighted list, within the root, and exits when
+// not found or invalid.
+func RequiredWeighted(key string) WeightedList {
+	return cfg.RequiredWeighted(key)
+}
@@ -0,0 +1,157 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// whenKey marks a group as conditional, eg.
+//
+//	"paths": {"$when": {"os": "windows"}, "data": "C:\\data"}
+//
+// The group is kept, without the `$when`, only when every condition matches,
+// and is otherwise removed when loaded. Conditions are:
+//
+//	os         runtime.GOOS
+//	arch       runtime.GOARCH
+//	hostname   os.Hostname, matched as a glob pattern, eg. "web-*"
+//	env        the ENVIRONMENT variable, see ConfigFile
+//
+// and each may be a list, in which case any of them may match, eg.
+// `{"os": ["linux", "darwin"]}`.
+const whenKey = "$when"
+
+// facts are the runtime facts conditions are matched against.
+type facts struct {
+	os, arch, hostname, env string
+}
+
+func currentFacts() facts {
+	hostname, _ := os.Hostname()
+	return facts{runtime.GOOS, runtime.GOARCH, hostname, os.Getenv("ENVIRONMENT")}
+}
+
+// filter removes the groups within `v`, the value at `p`, and the items of
+// its lists, whose conditions don't match. It returns false when `v` itself
+// doesn't match.
+func (f facts) filter(p string, v interface{}) (interface{}, bool, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if cond, ok := v[whenKey]; ok {
+			match, err := f.match(cond)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid condition at '%s': %w", p, err)
+			}
+			if !match {
+				return nil, false, nil
+			}
+			delete(v, whenKey)
+		}
+		for key, val := range v {
+			val, keep, err := f.filter(join(p, key), val)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				v[key] = val
+			} else {
+				delete(v, key)
+			}
+		}
+		return v, true, nil
+	case []interface{}:
+		l := v[:0]
+		for i, item := range v {
+			item, keep, err := f.filter(join(p, strconv.Itoa(i)), item)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				l = append(l, item)
+			}
+		}
+		return l, true, nil
+	}
+	return v, true, nil
+}
+
+func (f facts) match(cond interface{}) (bool, error) {
+	m, ok := cond.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("%s must be a group of conditions", whenKey)
+	}
+	for name, want := range m {
+		var have string
+		switch name {
+		case "os":
+			have = f.os
+		case "arch":
+			have = f.arch
+		case "hostname":
+			have = f.hostname
+		case "env":
+			have = f.env
+		default:
+			return false, fmt.Errorf("unknown %s condition %q", whenKey, name)
+		}
+		match, err := matchAny(name, have, want)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchAny(name, have string, want interface{}) (bool, error) {
+	var patterns []string
+	switch w := want.(type) {
+	case string:
+		patterns = []string{w}
+	case []interface{}:
+		for _, p := range w {
+			s, ok := p.(string)
+			if !ok {
+				return false, fmt.Errorf("%s condition %q must be strings", whenKey, name)
+			}
+			patterns = append(patterns, s)
+		}
+	default:
+		return false, fmt.Errorf("%s condition %q must be a string, or list of strings", whenKey, name)
+	}
+	for _, p := range patterns {
+		if name != "hostname" {
+			if strings.EqualFold(p, have) {
+				return true, nil
+			}
+			continue
+		}
+		match, err := path.Match(strings.ToLower(p), strings.ToLower(have))
+		if err != nil {
+			return false, fmt.Errorf("%s hostname pattern %q: %s", whenKey, p, err)
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// when applies the conditions within `m`, see whenKey.
+func when(m map[string]interface{}) (map[string]interface{}, error) {
+	v, keep, err := currentFacts().filter("", m)
+	if err != nil {
+		return nil, err
+	}
+	if !keep {
+		return map[string]interface{}{}, nil
+	}
+	return v.(map[string]interface{}), nil
+}
@@ -0,0 +1,287 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package xds reads the configuration from an xDS management server, over an
+// aggregated discovery (ADS) stream, so one control plane can serve both the
+// proxies' settings and the app's, eg.
+//
+//	conn, err := grpc.NewClient("xds-server:18000", grpc.WithTransportCredentials(creds))
+//	...
+//	s := xds.New(conn, "billing-7f9c", xds.StructType)
+//	a, err := config.Open(s)
+//	...
+//	go s.Watch(ctx, a)
+//
+// Every resource of the subscribed type becomes a group of the config, named
+// by the resource: by the name of its envoy.service.discovery.v3.Resource
+// wrapper, or else its `name` field, eg. a `google.protobuf.Struct` resource
+// `{"name": "billing", "db": {"pool": 10}}` becomes the `billing` group.
+// Resources are mapped as by their canonical JSON mapping, so their types
+// must be linked into the binary, eg. by importing their generated packages.
+//
+// Experimental: only the state of the world protocol is spoken, and the API
+// may change.
+package xds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.minty.io/config"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+)
+
+// Resource type URLs.
+const (
+	StructType   = "type.googleapis.com/google.protobuf.Struct"
+	resourceType = "type.googleapis.com/envoy.service.discovery.v3.Resource"
+)
+
+// codeInvalidArgument is the gRPC status of rejected responses.
+const codeInvalidArgument = 3
+
+// Source reads the resources of a type from an xDS management server.
+type Source struct {
+	// Node identifies the client to the server.
+	Node *corev3.Node
+	// TypeURL is the type of the resources subscribed to, and Resources
+	// their names, every resource of the type when empty.
+	TypeURL   string
+	Resources []string
+	// Retry is the backoff between reconnects. Defaults to
+	// config.DefaultRetryPolicy.
+	Retry config.RetryPolicy
+
+	client discoveryv3.AggregatedDiscoveryServiceClient
+
+	mu sync.Mutex
+	// groups and version are those of the last accepted response.
+	groups  map[string]interface{}
+	version string
+}
+
+// New returns a source of the resources of `typeURL`, named `names`, or every
+// one when none are, served via `conn` to the node `nodeID`.
+func New(conn grpc.ClientConnInterface, nodeID, typeURL string, names ...string) *Source {
+	return &Source{
+		Node:      &corev3.Node{Id: nodeID},
+		TypeURL:   typeURL,
+		Resources: names,
+		Retry:     config.DefaultRetryPolicy,
+		client:    discoveryv3.NewAggregatedDiscoveryServiceClient(conn),
+	}
+}
+
+func (s *Source) Name() string {
+	return "xds " + s.TypeURL
+}
+
+// Read returns the resources last received by Watch, or else those of the
+// first response of a stream of its own.
+func (s *Source) Read() (config.Config, error) {
+	s.mu.Lock()
+	groups := s.groups
+	s.mu.Unlock()
+	if groups != nil {
+		return config.FromMap(copyGroups(groups)), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stream, err := s.client.StreamAggregatedResources(ctx)
+	if err != nil {
+		return *new(config.Config), fmt.Errorf("failed to stream %s: %w", s.Name(), err)
+	}
+	if err = stream.Send(s.request("", "", nil)); err != nil {
+		return *new(config.Config), fmt.Errorf("failed to stream %s: %w", s.Name(), err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return *new(config.Config), fmt.Errorf("failed to stream %s: %w", s.Name(), err)
+	}
+	groups, err = decode(resp)
+	if err != nil {
+		return *new(config.Config), fmt.Errorf("%s version %s: %w", s.Name(), resp.VersionInfo, err)
+	}
+	return config.FromMap(groups), nil
+}
+
+// Watch subscribes to the resources, installing every accepted response into
+// the current config, via `a` when an Admin owns it (see Admin.Reload), or via
+// config.Load otherwise, until `ctx` is done. Responses failing to decode, or
+// install, are rejected (NACKed) to the server, and keep the current config.
+// Broken streams are reconnected, backing off per Retry, resuming from the
+// last accepted version.
+func (s *Source) Watch(ctx context.Context, a *config.Admin) error {
+	for failures := 0; ; {
+		accepted, err := s.watch(ctx, a)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if accepted {
+			failures = 0
+		}
+		failures++
+		log.Printf("config: %s stream broke: %s", s.Name(), err)
+		delay := s.Retry.Delay(failures)
+		if delay <= 0 {
+			delay = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (s *Source) watch(ctx context.Context, a *config.Admin) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := s.client.StreamAggregatedResources(ctx)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	version := s.version
+	s.mu.Unlock()
+	if err = stream.Send(s.request(version, "", nil)); err != nil {
+		return false, err
+	}
+	accepted := false
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return accepted, err
+		}
+		if resp.TypeUrl != s.TypeURL {
+			continue
+		}
+		if err = s.install(resp, a); err != nil {
+			log.Printf("config: rejected %s version %s: %s", s.Name(), resp.VersionInfo, err)
+			detail := &status.Status{Code: codeInvalidArgument, Message: err.Error()}
+			if err = stream.Send(s.request(version, resp.Nonce, detail)); err != nil {
+				return accepted, err
+			}
+			continue
+		}
+		accepted, version = true, resp.VersionInfo
+		if err = stream.Send(s.request(version, resp.Nonce, nil)); err != nil {
+			return accepted, err
+		}
+	}
+}
+
+// install decodes, and installs, the resources of `resp`.
+func (s *Source) install(resp *discoveryv3.DiscoveryResponse, a *config.Admin) error {
+	groups, err := decode(resp)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	prevGroups, prevVersion := s.groups, s.version
+	s.groups, s.version = groups, resp.VersionInfo
+	s.mu.Unlock()
+	if a != nil {
+		err = a.Reload()
+	} else {
+		err = config.Load(s)
+	}
+	if err != nil {
+		s.mu.Lock()
+		s.groups, s.version = prevGroups, prevVersion
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *Source) request(version, nonce string, detail *status.Status) *discoveryv3.DiscoveryRequest {
+	return &discoveryv3.DiscoveryRequest{
+		VersionInfo:   version,
+		Node:          s.Node,
+		ResourceNames: s.Resources,
+		TypeUrl:       s.TypeURL,
+		ResponseNonce: nonce,
+		ErrorDetail:   detail,
+	}
+}
+
+// decode returns the groups of the resources of `resp`.
+func decode(resp *discoveryv3.DiscoveryResponse) (map[string]interface{}, error) {
+	groups := make(map[string]interface{}, len(resp.Resources))
+	for i, r := range resp.Resources {
+		name := ""
+		if r.TypeUrl == resourceType {
+			var wrapper discoveryv3.Resource
+			if err := anyTo(r, &wrapper); err != nil {
+				return nil, fmt.Errorf("resource %d: %w", i, err)
+			}
+			name, r = wrapper.Name, wrapper.Resource
+		}
+		m, err := toMap(r)
+		if err != nil {
+			return nil, fmt.Errorf("resource %d: %w", i, err)
+		}
+		if name == "" {
+			name, _ = m["name"].(string)
+		}
+		if name == "" {
+			return nil, errors.New("resource " + strconv.Itoa(i) + " has no name")
+		}
+		if _, ok := groups[name]; ok {
+			return nil, fmt.Errorf("duplicate resource %s", name)
+		}
+		groups[name] = m
+	}
+	return groups, nil
+}
+
+// anyTo unmarshals the message of `r` into `m`.
+func anyTo(r *anypb.Any, m proto.Message) error {
+	if r == nil {
+		return errors.New("empty resource")
+	}
+	return proto.Unmarshal(r.Value, m)
+}
+
+// toMap returns the canonical JSON mapping of the message of `r`.
+func toMap(r *anypb.Any) (map[string]interface{}, error) {
+	if r == nil {
+		return nil, errors.New("empty resource")
+	}
+	msg, err := anypb.UnmarshalNew(r, proto.UnmarshalOptions{})
+	if err != nil {
+		return nil, err
+	}
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s isn't an object: %w", r.TypeUrl, err)
+	}
+	return m, nil
+}
+
+// copyGroups copies the groups, shallowly, as a config may be changed.
+func copyGroups(groups map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(groups))
+	for k, v := range groups {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,185 @@
+// Copyright 2013 Justin Wilson. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// XMLMapping maps the elements, and attributes, of XML settings files into
+// groups and keys, see ReadXML. The root element is the config, an element
+// within it a key, a group when it has attributes, or elements, of its own:
+//
+//	<settings timeout="30">
+//		<server host="localhost"><port>9090</port></server>
+//		<peer>a</peer>
+//		<peer>b</peer>
+//	</settings>
+//
+// is read as
+//
+//	{"timeout": "30", "server": {"host": "localhost", "port": "9090"}, "peer": ["a", "b"]}
+//
+// Values are strings, which are coerced on access.
+type XMLMapping struct {
+	// AttrPrefix prefixes the keys of attributes, eg. `@`, so they can't
+	// collide with those of elements.
+	AttrPrefix string
+	// TextKey is the key of the text of elements read as groups.
+	TextKey string
+	// KeyAttr, when set, names the attribute holding the key of the elements
+	// having it, and ValueAttr the one holding their value, their text
+	// otherwise, eg. `key` and `value` for .NET's
+	// `<appSettings><add key="timeout" value="30"/></appSettings>`.
+	KeyAttr, ValueAttr string
+	// Lists are the dot separated paths of the elements read as lists, even
+	// when there's only one; repeated elements always are.
+	Lists []string
+}
+
+// DefaultXMLMapping is the mapping of `.xml` files, see RegisterFormat.
+var DefaultXMLMapping = XMLMapping{TextKey: "#text", KeyAttr: "key", ValueAttr: "value"}
+
+func init() {
+	RegisterFormat(".xml", &DefaultXMLMapping)
+}
+
+// ReadXML returns a new Config from the XML document `b`, mapped per
+// `mapping`, or DefaultXMLMapping when nil. See ReadFrom for `opts`.
+func ReadXML(b []byte, mapping *XMLMapping, opts ...Option) (Config, error) {
+	if mapping == nil {
+		mapping = &DefaultXMLMapping
+	}
+	return newOptions(opts).readFormat(mapping, b, "")
+}
+
+// Coerce reports that XML configs coerce their values, which are strings.
+func (x *XMLMapping) Coerce() bool {
+	return true
+}
+
+// Decode decodes the XML document `b`, see Decoder.
+func (x *XMLMapping) Decode(b []byte) (map[string]interface{}, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		t, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("xml: no root element")
+			}
+			return nil, err
+		}
+		if el, ok := t.(xml.StartElement); ok {
+			v, err := x.element(d, el, "", 0)
+			if err != nil {
+				return nil, err
+			}
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				// A root of only text holds no keys.
+				m = map[string]interface{}{}
+			}
+			return m, nil
+		}
+	}
+}
+
+// element reads the element `el`, at the dot separated `path`, nested
+// `depth` deep.
+func (x *XMLMapping) element(d *xml.Decoder, el xml.StartElement, path string, depth int) (interface{}, error) {
+	if depth > maxBinaryDepth {
+		return nil, errors.New("xml: nested too deeply")
+	}
+	m := make(map[string]interface{})
+	for _, a := range el.Attr {
+		if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+			continue
+		}
+		m[x.AttrPrefix+a.Name.Local] = a.Value
+	}
+	var text strings.Builder
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			key, keyed := x.key(t)
+			child := join(path, key)
+			v, err := x.element(d, t, child, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if keyed {
+				v = x.value(t, v)
+			}
+			x.add(m, key, child, v)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m[x.TextKey] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+// key returns the key of the element `el`, and whether it's named by its
+// KeyAttr.
+func (x *XMLMapping) key(el xml.StartElement) (string, bool) {
+	if x.KeyAttr != "" {
+		for _, a := range el.Attr {
+			if a.Name.Local == x.KeyAttr {
+				return a.Value, true
+			}
+		}
+	}
+	return el.Name.Local, false
+}
+
+// value returns the value of the keyed element `el`, read as `v`.
+func (x *XMLMapping) value(el xml.StartElement, v interface{}) interface{} {
+	for _, a := range el.Attr {
+		if x.ValueAttr != "" && a.Name.Local == x.ValueAttr {
+			return a.Value
+		}
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		if s, ok := m[x.TextKey]; ok {
+			return s
+		}
+		return ""
+	}
+	return v
+}
+
+// add adds the value `v` of `key`, at `path`, to `m`, making a list of
+// repeated keys.
+func (x *XMLMapping) add(m map[string]interface{}, key, path string, v interface{}) {
+	old, ok := m[key]
+	if l, isList := old.([]interface{}); ok && isList {
+		m[key] = append(l, v)
+		return
+	}
+	if ok {
+		m[key] = []interface{}{old, v}
+		return
+	}
+	if contains(x.Lists, path) {
+		m[key] = []interface{}{v}
+		return
+	}
+	m[key] = v
+}